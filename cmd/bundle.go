@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"item_compositiom_service/pkg/bundle"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var bundleParams struct {
+	Output string
+	Name   string
+}
+
+func init() {
+	flags := bundleBuildCmd.Flags()
+	flags.StringVarP(&bundleParams.Output, "output", "o", "bundle.tar.gz", "path to bundle file")
+	flags.StringVar(&bundleParams.Name, "name", "", "bundle name, e.g. a release version")
+
+	bundleCmd.AddCommand(bundleBuildCmd, bundleVerifyCmd, bundleInspectCmd)
+	rootCmd.AddCommand(bundleCmd)
+}
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Build, verify and inspect template bundles",
+}
+
+var bundleBuildCmd = &cobra.Command{
+	Use:   "build DIR",
+	Short: "Build a bundle of the templates, providers and locales directories of DIR",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var buf bytes.Buffer
+		manifest, err := bundle.Build(args[0], bundleParams.Name, &buf)
+		if err != nil {
+			return err
+		}
+
+		b, err := bundle.Read(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			return err
+		}
+		if err := verifyBundle(b); err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+
+		if err := os.WriteFile(bundleParams.Output, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("write bundle: %w", err)
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: %d files, digest %s\n", bundleParams.Output, len(manifest.Files), b.Digest)
+		return nil
+	},
+}
+
+var bundleVerifyCmd = &cobra.Command{
+	Use:   "verify FILE",
+	Short: "Check bundle checksums and validate its members",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		b, err := readBundle(args[0])
+		if err != nil {
+			return err
+		}
+
+		if err := verifyBundle(b); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: ok, digest %s\n", args[0], b.Digest)
+		return nil
+	},
+}
+
+var bundleInspectCmd = &cobra.Command{
+	Use:   "inspect FILE",
+	Short: "Print the bundle manifest",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		b, err := readBundle(args[0])
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "Name:    %s\n", b.Manifest.Name)
+		fmt.Fprintf(out, "Version: %d\n", b.Manifest.Version)
+		fmt.Fprintf(out, "Created: %s\n", b.Manifest.CreatedAt.Format(time.RFC3339))
+		fmt.Fprintf(out, "Digest:  %s\n\n", b.Digest)
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KIND\tPATH\tSIZE\tSHA256")
+		for _, f := range b.Manifest.Files {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", f.Kind, f.Path, f.Size, f.SHA256[:12])
+		}
+
+		return w.Flush()
+	},
+}
+
+func readBundle(path string) (*bundle.Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open bundle: %w", err)
+	}
+	defer f.Close()
+
+	return bundle.Read(f)
+}
+
+// verifyBundle validates the members of b and parses them the way the
+// service loads them.
+func verifyBundle(b *bundle.Bundle) error {
+	if err := bundle.Validate(b); err != nil {
+		return err
+	}
+
+	templateLib, err := newTemplateLib()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, kind := range []string{bundle.KindProvider, bundle.KindCatalog, bundle.KindTemplate} {
+		for _, f := range b.Files(kind) {
+			if _, err := templateLib.ParseTemplate(b.Content(f)); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.Path, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
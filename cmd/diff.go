@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"item_compositiom_service/pkg/schema"
+
+	"github.com/spf13/cobra"
+)
+
+var diffParams struct {
+	BreakingOnly bool
+}
+
+func init() {
+	diffCmd.Flags().BoolVar(&diffParams.BreakingOnly, "breaking-only", false, "print only breaking changes")
+
+	rootCmd.AddCommand(diffCmd)
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff OLD NEW",
+	Short: "Compare output schemas of two template versions",
+	Long:  "Compare output schemas of two template versions. Exits with an error if there are breaking changes.",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		oldInstructions, err := loadTemplate(args[0])
+		if err != nil {
+			return err
+		}
+
+		newInstructions, err := loadTemplate(args[1])
+		if err != nil {
+			return err
+		}
+
+		changes, err := schema.DiffTemplates(oldInstructions, newInstructions)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			if diffParams.BreakingOnly && !change.Breaking {
+				continue
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), change)
+		}
+
+		if breaking := schema.Breaking(changes); len(breaking) > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d breaking changes", len(breaking))
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"item_compositiom_service/internal/config"
+	mongodb "item_compositiom_service/internal/repository/mongo_db"
+	"item_compositiom_service/pkg/migrate"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var migrateParams struct {
+	To      int
+	Timeout time.Duration
+}
+
+func init() {
+	migrateCmd.PersistentFlags().DurationVar(&migrateParams.Timeout, "timeout", 10*time.Minute, "migration timeout")
+	migrateUpCmd.Flags().IntVar(&migrateParams.To, "to", 0, "target version, the latest if 0")
+	migrateDownCmd.Flags().IntVar(&migrateParams.To, "to", -1, "target version, the previous applied one if not set")
+
+	migrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd)
+	rootCmd.AddCommand(migrateCmd)
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply or revert MongoDB migrations",
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply pending migrations",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return withMigrator(cmd, func(ctx context.Context, m *migrate.Migrator) error {
+			return m.Up(ctx, migrateParams.To)
+		})
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Revert applied migrations above the target version",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return withMigrator(cmd, func(ctx context.Context, m *migrate.Migrator) error {
+			target := migrateParams.To
+			if target < 0 {
+				statuses, err := m.Status(ctx)
+				if err != nil {
+					return err
+				}
+				target = previousApplied(statuses)
+			}
+
+			return m.Down(ctx, target)
+		})
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Print applied and pending migrations",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return withMigrator(cmd, func(ctx context.Context, m *migrate.Migrator) error {
+			statuses, err := m.Status(ctx)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tAPPLIED\tDESCRIPTION")
+			for _, s := range statuses {
+				applied := "pending"
+				if s.Applied() {
+					applied = s.AppliedAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, applied, s.Description)
+			}
+
+			return w.Flush()
+		})
+	},
+}
+
+func withMigrator(cmd *cobra.Command, fn func(context.Context, *migrate.Migrator) error) error {
+	cfg, err := config.ParseConfig(rootParams.ConfigPath)
+	if err != nil {
+		return err
+	}
+	if cfg.MongoConfig == nil {
+		return fmt.Errorf("missing `mongo_storage` config")
+	}
+
+	cmd.SilenceUsage = true
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), migrateParams.Timeout)
+	defer cancel()
+
+	// Index builds may take longer than a regular operation.
+	mongoCfg := *cfg.MongoConfig
+	mongoCfg.OperationTimeout = 0
+
+	db, err := mongodb.Connect(ctx, &mongoCfg)
+	if err != nil {
+		return err
+	}
+	defer db.Client().Disconnect(context.WithoutCancel(ctx))
+
+	lgr, err := zap.NewDevelopment()
+	if err != nil {
+		return err
+	}
+
+	m, err := mongodb.NewMigrator(db, &mongoCfg, lgr)
+	if err != nil {
+		return err
+	}
+
+	return fn(ctx, m)
+}
+
+// previousApplied returns the applied version below the latest applied one.
+func previousApplied(statuses []migrate.Status) int {
+	var latest, previous int
+	for _, s := range statuses {
+		if s.Applied() {
+			latest, previous = s.Version, latest
+		}
+	}
+
+	if latest == 0 {
+		return 0
+	}
+	return previous
+}
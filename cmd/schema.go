@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"item_compositiom_service/pkg/i18n"
+	"item_compositiom_service/pkg/metrics"
+	"item_compositiom_service/pkg/output"
+	"item_compositiom_service/pkg/parser"
+	"item_compositiom_service/pkg/provider"
+	"item_compositiom_service/pkg/schema"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var schemaParams struct {
+	File   string
+	View   string
+	Format string
+	Name   string
+}
+
+func init() {
+	flags := schemaCmd.Flags()
+	flags.StringVarP(&schemaParams.File, "file", "f", "", "path to template file")
+	flags.StringVar(&schemaParams.View, "view", "", "view name, all views if empty")
+	flags.StringVar(&schemaParams.Format, "format", schema.FormatJSONSchema, "output format: json_schema, typescript or kotlin")
+	flags.StringVar(&schemaParams.Name, "name", "", "root type name for stubs, view name by default")
+	_ = schemaCmd.MarkFlagRequired("file")
+
+	rootCmd.AddCommand(schemaCmd)
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Generate output schema of template views",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		instructions, err := loadTemplate(schemaParams.File)
+		if err != nil {
+			return err
+		}
+
+		views := []string{schemaParams.View}
+		if schemaParams.View == "" {
+			views = schema.Views(instructions)
+		}
+
+		for _, view := range views {
+			viewSchema, err := schema.Generate(instructions, view)
+			if err != nil {
+				return err
+			}
+
+			name := view
+			if schemaParams.Name != "" && len(views) == 1 {
+				name = schemaParams.Name
+			}
+
+			data, err := schema.Render(viewSchema, schemaParams.Format, name)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), string(data))
+		}
+
+		return nil
+	},
+}
+
+// loadTemplate parses a template file outside of the running service.
+func loadTemplate(path string) ([]parser.Instruction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read template %s: %w", path, err)
+	}
+
+	templateLib, err := newTemplateLib()
+	if err != nil {
+		return nil, err
+	}
+
+	instructions, err := templateLib.ParseTemplate(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse template %s: %w", path, err)
+	}
+
+	return instructions, nil
+}
+
+// newTemplateLib creates a template lib with its own provider and catalog
+// storages, so that parsing does not need the service configuration.
+func newTemplateLib() (*parser.TemplateLib, error) {
+	storage, err := provider.NewProviderStorage(zap.NewNop().Sugar(), &metrics.NoopMetrics{})
+	if err != nil {
+		return nil, fmt.Errorf("create provider storage: %w", err)
+	}
+
+	templateLib, err := parser.NewTemplateLib(
+		&parser.Config{},
+		&metrics.NoopMetrics{},
+		storage,
+		i18n.NewStorage(&i18n.Config{}),
+		output.NewRegistry(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create template lib: %w", err)
+	}
+
+	return templateLib, nil
+}
@@ -1,19 +1,26 @@
 package config
 
 import (
+	"item_compositiom_service/internal/repository"
 	localdb "item_compositiom_service/internal/repository/local_db"
 	mongodb "item_compositiom_service/internal/repository/mongo_db"
 	"item_compositiom_service/internal/server"
+	"item_compositiom_service/pkg/i18n"
 	"item_compositiom_service/pkg/logger"
 	"item_compositiom_service/pkg/metrics"
+	"item_compositiom_service/pkg/parser"
 	"item_compositiom_service/pkg/tracer"
 )
 
 type Config struct {
-	GrpcConfig    *server.Config              `yaml:"grpc_server"`
-	LogConfig     *logger.Config              `yaml:"logger"`
-	TraceConfig   *tracer.Config              `yaml:"trace"`
-	MetricsConfig *metrics.Config             `yaml:"metrics"`
-	MongoConfig   *mongodb.MongoStorageConfig `yaml:"mongo_storage"`
-	LocalConfig   *localdb.LocalStorageConfig `yaml:"local_storage"`
+	GrpcConfig     *server.Config              `yaml:"grpc_server"`
+	LogConfig      *logger.Config              `yaml:"logger"`
+	TraceConfig    *tracer.Config              `yaml:"trace"`
+	MetricsConfig  *metrics.Config             `yaml:"metrics"`
+	MongoConfig    *mongodb.MongoStorageConfig `yaml:"mongo_storage"`
+	LocalConfig    *localdb.LocalStorageConfig `yaml:"local_storage"`
+	I18nConfig     *i18n.Config                `yaml:"i18n"`
+	ParserConfig   *parser.Config              `yaml:"parser"`
+	SnapshotConfig *repository.SnapshotConfig  `yaml:"template_snapshot"`
+	SourcesConfig  repository.SourcesConfig    `yaml:"template_sources"`
 }
@@ -2,11 +2,16 @@ package config
 
 import (
 	"fmt"
+	"item_compositiom_service/internal/repository"
 	localdb "item_compositiom_service/internal/repository/local_db"
 	mongodb "item_compositiom_service/internal/repository/mongo_db"
 	"item_compositiom_service/internal/server"
+	"item_compositiom_service/pkg/auth"
+	"item_compositiom_service/pkg/deadline"
+	"item_compositiom_service/pkg/i18n"
 	"item_compositiom_service/pkg/logger"
 	"item_compositiom_service/pkg/metrics"
+	"item_compositiom_service/pkg/parser"
 	"item_compositiom_service/pkg/tracer"
 	"os"
 	"time"
@@ -70,8 +75,19 @@ func getDefaultConfig() *Config {
 			Logging: &server.Logging{
 				MaxMessageSize: 1024,
 			},
-			StartDeadline: 5 * time.Second,
-			StopDeadline:  5 * time.Second,
+			StartDeadline:  5 * time.Second,
+			StopDeadline:   5 * time.Second,
+			HealthInterval: 5 * time.Second,
+			Auth: &auth.Config{
+				Enabled:       false,
+				APIKeyHeader:  "x-api-key",
+				PublicMethods: []string{"/grpc.health.v1.Health/"},
+			},
+			Deadlines: &deadline.Config{
+				Default:      time.Second,
+				Max:          10 * time.Second,
+				SafetyMargin: deadline.DefaultSafetyMargin,
+			},
 		},
 		LogConfig: &logger.Config{
 			LogLevel:   "debug",
@@ -99,6 +115,9 @@ func getDefaultConfig() *Config {
 		MetricsConfig: &metrics.Config{
 			Enable: true,
 			Port:   8080,
+			Cardinality: &metrics.CardinalityConfig{
+				MaxValues: 100,
+			},
 		},
 		MongoConfig: &mongodb.MongoStorageConfig{
 			Enabled:                 true,
@@ -107,11 +126,17 @@ func getDefaultConfig() *Config {
 			ClientConfigsCollection: "client_configs",
 			ClientSpecsCollection:   "client_specs",
 			TemplatesCollection:     "templates",
+			MigrationsCollection:    "migrations",
+			MigrateOnStart:          true,
 			OperationTimeout:        2 * time.Second,
 			ConnectionTimeout:       1 * time.Second,
 			MaxPoolSize:             1000,
 			ReadPreference:          "secondary",
-			HeartbeatFrequency:      2 * time.Second,
+			ReadPreferences: map[string]string{
+				mongodb.OpTemplateReload: "primary_preferred",
+			},
+			MaxStaleness:       90 * time.Second,
+			HeartbeatFrequency: 2 * time.Second,
 			LoggingConfig: &mongodb.LoggingConfig{
 				Enabled:            true,
 				QueryMaxBytesToLog: 512,
@@ -125,6 +150,28 @@ func getDefaultConfig() *Config {
 			ClientSpecDirPath:   "/var/data/item-composition-service/client-specs",
 			TemplateDirPath:     "/var/data/item-composition-service/templates",
 		},
+		I18nConfig: &i18n.Config{
+			DefaultLocale:   "ru",
+			FallbackLocales: []string{"en"},
+		},
+		ParserConfig: &parser.Config{
+			Limits: parser.Limits{
+				MaxOutputBytes: 1 << 20,
+				MaxDepth:       32,
+				MaxArrayLength: 1000,
+				MaxEvaluations: 10000,
+				Timeout:        200 * time.Millisecond,
+			},
+		},
+		SnapshotConfig: &repository.SnapshotConfig{
+			Enabled:  true,
+			Path:     "/var/data/item-composition-service/templates.snapshot.json",
+			Interval: 30 * time.Second,
+		},
+		SourcesConfig: repository.SourcesConfig{
+			{Type: repository.SourceMongo, Priority: 20, Policy: repository.PolicyFirstWins},
+			{Type: repository.SourceLocal, Priority: 10, Policy: repository.PolicyFirstWins},
+		},
 	}
 }
 
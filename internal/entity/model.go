@@ -1,3 +1,48 @@
 package entity
 
+import "time"
+
 type TemplateIdName string
+
+// TemplateContentRecorder is implemented by template cache setters that also
+// keep the raw content of stored templates, e.g. for on-disk snapshots.
+type TemplateContentRecorder interface {
+	RecordContent(id TemplateIdName, content []byte, updatedAt time.Time)
+}
+
+// RecordContent passes raw template content to setGetter if it is a
+// TemplateContentRecorder. Storages call it next to SetGetter.Set.
+func RecordContent(setGetter any, id TemplateIdName, content []byte, updatedAt time.Time) {
+	if r, ok := setGetter.(TemplateContentRecorder); ok {
+		r.RecordContent(id, content, updatedAt)
+	}
+}
+
+// TemplateDependencyRecorder is implemented by template cache setters that
+// keep the raw content of the documents a stored template is parsed with, e.g.
+// the catalogs and provider specs of a bundle.
+type TemplateDependencyRecorder interface {
+	RecordDependencies(id TemplateIdName, dependencies [][]byte)
+}
+
+// RecordDependencies passes the dependencies of a stored template to setGetter
+// if it is a TemplateDependencyRecorder. Storages call it after RecordContent.
+func RecordDependencies(setGetter any, id TemplateIdName, dependencies [][]byte) {
+	if r, ok := setGetter.(TemplateDependencyRecorder); ok {
+		r.RecordDependencies(id, dependencies)
+	}
+}
+
+// TemplateVersionRecorder is implemented by template cache setters that keep
+// the source version of stored templates, e.g. a commit SHA.
+type TemplateVersionRecorder interface {
+	RecordVersion(id TemplateIdName, version string)
+}
+
+// RecordVersion passes the version of a stored template to setGetter if it is
+// a TemplateVersionRecorder.
+func RecordVersion(setGetter any, id TemplateIdName, version string) {
+	if r, ok := setGetter.(TemplateVersionRecorder); ok {
+		r.RecordVersion(id, version)
+	}
+}
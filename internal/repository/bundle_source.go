@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"item_compositiom_service/internal/entity"
+	"item_compositiom_service/pkg/bundle"
+	"item_compositiom_service/pkg/cache"
+	"item_compositiom_service/pkg/parser"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// bundleFetcher opens the current bundle. tag identifies the last loaded
+// bundle; a nil reader means it has not changed. The returned tag is stored
+// only once the bundle is loaded.
+type bundleFetcher func(ctx context.Context, tag string) (io.ReadCloser, string, error)
+
+// bundleSource serves the templates of a bundle. A new bundle replaces the
+// whole set: it is loaded only if every member is valid, and its templates
+// are stored and the ones it dropped are deleted in the cache at once.
+// Otherwise the previous set is kept. The bundle digest is recorded as the
+// version of every template.
+type bundleSource struct {
+	templateLib *parser.TemplateLib
+	fetch       bundleFetcher
+
+	mu        sync.Mutex
+	tag       string
+	digest    string
+	templates map[entity.TemplateIdName][]parser.Instruction
+	contents  map[entity.TemplateIdName][]byte
+	// dependencies are the catalogs and provider specs of the bundle, which
+	// a snapshot needs to parse its templates again.
+	dependencies [][]byte
+}
+
+func newBundleSource(templateLib *parser.TemplateLib, fetch bundleFetcher) *bundleSource {
+	return &bundleSource{templateLib: templateLib, fetch: fetch}
+}
+
+func (s *bundleSource) UpdateTemplate(ctx context.Context, setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.update(ctx, setGetter)
+}
+
+func (s *bundleSource) IncrementalUpdateTemplate(ctx context.Context, setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction], id entity.TemplateIdName) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.digest == "" {
+		if err := s.update(ctx, setGetter); err != nil {
+			return err
+		}
+	}
+
+	instructions, ok := s.templates[id]
+	if !ok {
+		return fmt.Errorf("template %s not found in bundle %s", id, s.digest)
+	}
+
+	s.set(setGetter, map[entity.TemplateIdName][]parser.Instruction{id: instructions}, nil)
+	return nil
+}
+
+func (s *bundleSource) update(ctx context.Context, setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) error {
+	rc, tag, err := s.fetch(ctx, s.tag)
+	if err != nil {
+		return err
+	}
+	if rc == nil {
+		s.refresh(setGetter)
+		return nil
+	}
+	defer rc.Close()
+
+	b, err := bundle.Read(rc)
+	if err != nil {
+		return fmt.Errorf("read bundle: %w", err)
+	}
+
+	if b.Digest == s.digest {
+		s.tag = tag
+		s.refresh(setGetter)
+		return nil
+	}
+
+	templates, contents, err := s.load(b)
+	if err != nil {
+		return fmt.Errorf("reject bundle %s (%s): %w", b.Manifest.Name, b.Digest, err)
+	}
+
+	var removed []entity.TemplateIdName
+	for id := range s.templates {
+		if _, ok := templates[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+
+	s.tag, s.digest, s.templates, s.contents = tag, b.Digest, templates, contents
+	s.dependencies = dependencies(b)
+	s.set(setGetter, templates, removed)
+	return nil
+}
+
+// load validates every member of b, parses its templates and registers its
+// provider specs and protos, those of its templates included, once every
+// member is parsed. The locale catalogs of the bundle are shared by all its
+// templates; catalogs of a template itself take precedence.
+func (s *bundleSource) load(b *bundle.Bundle) (map[entity.TemplateIdName][]parser.Instruction, map[entity.TemplateIdName][]byte, error) {
+	if err := bundle.Validate(b); err != nil {
+		return nil, nil, err
+	}
+
+	stage, err := s.templateLib.Stage()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer stage.Discard()
+
+	var catalogs []parser.Instruction
+	for _, f := range b.Files(bundle.KindCatalog) {
+		instructions, err := stage.ParseTemplate(b.Content(f))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", f.Path, err)
+		}
+		catalogs = append(catalogs, instructions...)
+	}
+
+	templates := make(map[entity.TemplateIdName][]parser.Instruction)
+	contents := make(map[entity.TemplateIdName][]byte)
+
+	var errs []error
+
+	for _, f := range b.Files(bundle.KindTemplate) {
+		instructions, err := stage.ParseTemplate(b.Content(f))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Path, err))
+			continue
+		}
+
+		id := entity.TemplateIdName(f.ID())
+		templates[id] = append(slices.Clip(catalogs), instructions...)
+		contents[id] = b.Content(f)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, err
+	}
+
+	for _, f := range b.Files(bundle.KindProvider) {
+		if _, err := stage.ParseTemplate(b.Content(f)); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", f.Path, err)
+		}
+	}
+
+	stage.Commit()
+	return templates, contents, nil
+}
+
+// refresh stores the templates of the loaded bundle that left the cache.
+func (s *bundleSource) refresh(setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) {
+	missing := make(map[entity.TemplateIdName][]parser.Instruction)
+	for id, instructions := range s.templates {
+		if _, ok := setGetter.LastUpdated(id); !ok {
+			missing[id] = instructions
+		}
+		entity.RecordVersion(setGetter, id, s.digest)
+	}
+
+	if len(missing) > 0 {
+		s.set(setGetter, missing, nil)
+	}
+}
+
+// set stores templates and deletes the removed templates of the previous
+// bundle in one batch.
+func (s *bundleSource) set(setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction], templates map[entity.TemplateIdName][]parser.Instruction, removed []entity.TemplateIdName) {
+	readTime := time.Now()
+
+	cache.SetBatch(setGetter, templates, removed, readTime)
+	for id := range templates {
+		entity.RecordContent(setGetter, id, s.contents[id], readTime)
+		entity.RecordDependencies(setGetter, id, s.dependencies)
+		entity.RecordVersion(setGetter, id, s.digest)
+	}
+}
+
+// dependencies returns the catalogs of b followed by its provider specs, in
+// the order load parses them.
+func dependencies(b *bundle.Bundle) [][]byte {
+	var deps [][]byte
+	for _, kind := range []string{bundle.KindCatalog, bundle.KindProvider} {
+		for _, f := range b.Files(kind) {
+			deps = append(deps, b.Content(f))
+		}
+	}
+	return deps
+}
+
+// fileBundle opens a bundle file; its size and modification time tell
+// whether it changed.
+func fileBundle(path string) bundleFetcher {
+	return func(_ context.Context, tag string) (io.ReadCloser, string, error) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("stat bundle: %w", err)
+		}
+
+		current := fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano())
+		if current == tag {
+			return nil, tag, nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("open bundle: %w", err)
+		}
+
+		return f, current, nil
+	}
+}
+
+// httpBundle downloads a bundle; unchanged bundles are detected with ETag
+// and not downloaded again.
+func httpBundle(url string, timeout time.Duration) bundleFetcher {
+	client := &http.Client{Timeout: timeout}
+
+	return func(ctx context.Context, tag string) (io.ReadCloser, string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, "", fmt.Errorf("create bundle request: %w", err)
+		}
+		if tag != "" {
+			req.Header.Set("If-None-Match", tag)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, "", fmt.Errorf("fetch bundle %s: %w", url, err)
+		}
+
+		switch resp.StatusCode {
+		case http.StatusNotModified:
+			resp.Body.Close()
+			return nil, tag, nil
+		case http.StatusOK:
+			return resp.Body, resp.Header.Get("ETag"), nil
+		default:
+			resp.Body.Close()
+			return nil, "", fmt.Errorf("fetch bundle %s: unexpected status %s", url, resp.Status)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"item_compositiom_service/internal/entity"
+	"item_compositiom_service/pkg/bundle"
+	"item_compositiom_service/pkg/cache"
+	"item_compositiom_service/pkg/i18n"
+	"item_compositiom_service/pkg/metrics"
+	"item_compositiom_service/pkg/output"
+	"item_compositiom_service/pkg/parser"
+	"item_compositiom_service/pkg/provider"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func buildBundle(t *testing.T, name string, templates map[string]string) []byte {
+	t.Helper()
+
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "templates"), 0755))
+	for file, content := range templates {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "templates", file), []byte(content), 0644))
+	}
+
+	var buf bytes.Buffer
+	_, err := bundle.Build(dir, name, &buf)
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestBundleSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	modTime := time.Now()
+	deploy := func(data []byte) {
+		modTime = modTime.Add(time.Second)
+		require.NoError(t, os.WriteFile(path, data, 0644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	src := newBundleSource(newTestTemplateLib(t), fileBundle(path))
+	set := newTestSourceSet(&templateSource{name: "bundle", policy: PolicyFirstWins, source: src})
+	sg := mapSetGetter{}
+	ctx := context.Background()
+
+	deploy(buildBundle(t, "v1", map[string]string{"card.yaml": gitTemplate("first")}))
+	require.NoError(t, set.update(ctx, sg))
+	assert.Equal(t, "first", title(t, sg, "card"))
+
+	first, ok := set.Version("card")
+	assert.True(t, ok)
+	assert.Len(t, first, 64)
+
+	// One invalid template rejects the whole bundle.
+	deploy(buildBundle(t, "v2", map[string]string{
+		"card.yaml":  gitTemplate("second"),
+		"promo.yaml": "kind: [",
+	}))
+	err := set.update(ctx, sg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reject bundle v2")
+	assert.Contains(t, err.Error(), "templates/promo.yaml")
+	assert.Equal(t, "first", title(t, sg, "card"))
+	assert.NotContains(t, sg, entity.TemplateIdName("promo"))
+
+	version, _ := set.Version("card")
+	assert.Equal(t, first, version)
+
+	// A valid bundle replaces the set.
+	deploy(buildBundle(t, "v3", map[string]string{
+		"card.yaml":  gitTemplate("third"),
+		"promo.yaml": gitTemplate("promo"),
+	}))
+	require.NoError(t, set.update(ctx, sg))
+	assert.Equal(t, "third", title(t, sg, "card"))
+	assert.Equal(t, "promo", title(t, sg, "promo"))
+
+	third, _ := set.Version("promo")
+	assert.NotEqual(t, first, third)
+
+	// An unchanged bundle only restores evicted templates.
+	cardUpdated := sg["card"].updated
+	delete(sg, "promo")
+	require.NoError(t, set.update(ctx, sg))
+	assert.Equal(t, cardUpdated, sg["card"].updated)
+	assert.Equal(t, "promo", title(t, sg, "promo"))
+
+	delete(sg, "promo")
+	require.NoError(t, set.updateOne(ctx, sg, "promo"))
+	assert.Equal(t, "promo", title(t, sg, "promo"))
+	assert.Error(t, set.updateOne(ctx, sg, "unknown"))
+}
+
+// batchRecorder records the templates deleted by every batch.
+type batchRecorder struct {
+	mapSetGetter
+	deleted [][]entity.TemplateIdName
+}
+
+func (r *batchRecorder) SetBatch(values map[entity.TemplateIdName][]parser.Instruction, deleted []entity.TemplateIdName, updateTime time.Time) {
+	r.deleted = append(r.deleted, deleted)
+	cache.SetBatch(r.mapSetGetter, values, deleted, updateTime)
+}
+
+func TestBundleSource_Removed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	deploy := func(data []byte, modTime time.Time) {
+		require.NoError(t, os.WriteFile(path, data, 0644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	local := &fakeSource{name: "local", templates: []entity.TemplateIdName{"promo"}}
+	set := newTestSourceSet(
+		&templateSource{name: "local", policy: PolicyFirstWins, priority: 10, source: local},
+		&templateSource{name: "bundle", policy: PolicyFirstWins, priority: 20, source: newBundleSource(newTestTemplateLib(t), fileBundle(path))},
+	)
+	set.sort()
+
+	sg := &batchRecorder{mapSetGetter: mapSetGetter{}}
+	ctx := context.Background()
+
+	deploy(buildBundle(t, "v1", map[string]string{
+		"card.yaml":  gitTemplate("first"),
+		"promo.yaml": gitTemplate("promo"),
+	}), time.Now())
+	require.NoError(t, set.update(ctx, sg))
+	assert.Equal(t, "promo", title(t, sg.mapSetGetter, "promo"))
+
+	// The new bundle drops promo in the batch storing its templates, and the
+	// local source serves it from then on.
+	sg.deleted = nil
+	deploy(buildBundle(t, "v2", map[string]string{"card.yaml": gitTemplate("second")}), time.Now().Add(time.Second))
+	require.NoError(t, set.update(ctx, sg))
+
+	require.NotEmpty(t, sg.deleted)
+	assert.Equal(t, []entity.TemplateIdName{"promo"}, sg.deleted[0])
+	assert.Equal(t, "second", title(t, sg.mapSetGetter, "card"))
+	assert.Equal(t, "local", sg.mapSetGetter["promo"].value[0].Kind)
+
+	source, _ := set.Source("promo")
+	assert.Equal(t, "local", source)
+}
+
+func TestBundleSource_Catalogs(t *testing.T) {
+	dir := t.TempDir()
+	for file, content := range map[string]string{
+		"templates/card.yaml": "kind: View\nmetadata:\n  name: view\nspec:\n  template:\n    templates: [card]\n---\n" +
+			"kind: Template\nmetadata:\n  name: card\nspec:\n  title:\n    type: string\n    value: '{{t \"title\"}}'\n",
+		"locales/en.yaml": "kind: Messages\nmetadata:\n  locale: en\nspec:\n  messages:\n    title: Reactions\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte(content), 0644))
+	}
+
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	_, err = bundle.Build(dir, "v1", f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	templateLib := newTestTemplateLib(t)
+	src := newBundleSource(templateLib, fileBundle(path))
+	sg := mapSetGetter{}
+	require.NoError(t, src.UpdateTemplate(context.Background(), sg))
+
+	instructions, ok := sg.Get("card")
+	require.True(t, ok)
+
+	ctx := i18n.WithLocale(context.Background(), "en")
+	res, err := templateLib.AdjustTemplate(ctx, map[string]any{}, instructions)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"title": "Reactions"}`, string(res), "Bundle catalogs are shared by its templates")
+}
+
+func TestBundleSource_RejectedRegistersNothing(t *testing.T) {
+	providerSpec := func(name, address string) string {
+		return "version: v1\nkind: ProviderGRPC\nmetadata:\n  name: " + name + "\nspec:\n  transport:\n    address: " + address +
+			"\n    timeout: 1s\n  payload:\n    headers:\n      x-app-name: test\n  methods:\n    - package: reaction.internal\n      service: ReactionInternalService\n" +
+			"      method: GetReactionCounters\n      type: DomainBatch\n      timeout: 1s\n      request:\n        domain_ids: item.id\n      response:\n        itemId: items.domain_id\n"
+	}
+	card := "kind: Proto\nmetadata:\n  name: card.proto\nspec:\n  proto: |\n    syntax = \"proto3\";\n    package feed;\n" +
+		"    message Card {\n      string title = 1;\n    }\n---\n" + gitTemplate("first")
+
+	build := func(files map[string]string) string {
+		dir := t.TempDir()
+		for file, content := range files {
+			require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte(content), 0644))
+		}
+
+		path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+		f, err := os.Create(path)
+		require.NoError(t, err)
+		_, err = bundle.Build(dir, "v1", f)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		return path
+	}
+
+	storage, err := provider.NewProviderStorage(zap.NewNop().Sugar(), &metrics.NoopMetrics{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = storage.Close() })
+	outputs := output.NewRegistry()
+	templateLib, err := parser.NewTemplateLib(&parser.Config{}, &metrics.NoopMetrics{}, storage, i18n.NewStorage(&i18n.Config{}), outputs)
+	require.NoError(t, err)
+
+	for name, files := range map[string]map[string]string{
+		"invalid template": {
+			"templates/card.yaml":     card,
+			"templates/promo.yaml":    "kind: View\nmetadata:\n  name: promo\nspec:\n  experiment: broken\n",
+			"providers/reaction.yaml": providerSpec("reaction", "localhost:3000"),
+		},
+		"invalid provider": {
+			"templates/card.yaml":     card,
+			"providers/reaction.yaml": providerSpec("reaction", "localhost:3000"),
+			"providers/zeta.yaml":     providerSpec("zeta", "unknown-scheme://%%"),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			src := newBundleSource(templateLib, fileBundle(build(files)))
+			err := src.UpdateTemplate(context.Background(), mapSetGetter{})
+			require.ErrorContains(t, err, "reject bundle")
+
+			_, err = storage.GetProvider("reaction")
+			assert.Error(t, err, "providers of a rejected bundle are not registered")
+			_, err = outputs.FindMessage("feed.Card")
+			assert.Error(t, err, "protos of a rejected bundle are not registered")
+		})
+	}
+
+	src := newBundleSource(templateLib, fileBundle(build(map[string]string{
+		"templates/card.yaml":     card,
+		"providers/reaction.yaml": providerSpec("reaction", "localhost:3000"),
+	})))
+	require.NoError(t, src.UpdateTemplate(context.Background(), mapSetGetter{}))
+
+	_, err = storage.GetProvider("reaction")
+	assert.NoError(t, err)
+	_, err = outputs.FindMessage("feed.Card")
+	assert.NoError(t, err)
+}
+
+func TestBundleSource_HTTP(t *testing.T) {
+	data := buildBundle(t, "v1", map[string]string{"card.yaml": gitTemplate("first")})
+	downloads := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		downloads++
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	src := newBundleSource(newTestTemplateLib(t), httpBundle(srv.URL, time.Second))
+	set := newTestSourceSet(&templateSource{name: "http_bundle", policy: PolicyFirstWins, source: src})
+	sg := mapSetGetter{}
+
+	for range 3 {
+		require.NoError(t, set.update(context.Background(), sg))
+	}
+
+	assert.Equal(t, "first", title(t, sg, "card"))
+	assert.Equal(t, 1, downloads)
+}
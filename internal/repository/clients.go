@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	mongodb "item_compositiom_service/internal/repository/mongo_db"
+	"item_compositiom_service/pkg/auth"
+	"item_compositiom_service/pkg/cache"
+	"item_compositiom_service/pkg/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// clientRetryInterval is the delay between attempts to load the client
+// configs after a failed first update.
+const clientRetryInterval = 5 * time.Second
+
+var errClientsNotLoaded = errors.New("client configs are not loaded")
+
+// apiKeyEntry is an API key of the key index.
+type apiKeyEntry struct {
+	clientID  string
+	expiresAt *time.Time
+}
+
+// ClientRepository caches the client configs of Mongo and finds clients by
+// API key for auth. The key index is rebuilt by every full update, so added
+// and revoked keys take effect within the cache TTL.
+//
+// The client configs are loaded only if auth is enabled and Mongo is
+// configured. A failed first update does not stop the service: it is retried
+// in the background and reported by Check, while API keys are unavailable.
+type ClientRepository struct {
+	cache   *cache.Cache[string, any]
+	keys    atomic.Pointer[map[string]apiKeyEntry]
+	lgr     *zap.Logger
+	enabled bool
+
+	retryInterval time.Duration
+	retryCancel   context.CancelFunc
+	wg            sync.WaitGroup
+	started       atomic.Bool
+}
+
+func NewClientRepository(
+	lf fx.Lifecycle,
+	logger *zap.SugaredLogger,
+	metricsRegistry metrics.MetricsRegistry,
+	ms *mongodb.MongoStorage,
+	authConfig *auth.Config,
+) *ClientRepository {
+	r := &ClientRepository{
+		lgr:           logger.Desugar().With(zap.String("component", "client_repository")),
+		enabled:       authConfig != nil && authConfig.Enabled && ms.Enabled(),
+		retryInterval: clientRetryInterval,
+	}
+
+	r.cache = cache.New(
+		logger,
+		metricsRegistry,
+		func(ctx context.Context, sg cache.SetGetter[string, any]) error {
+			keys := make(map[string]apiKeyEntry)
+			if err := ms.UpdateClientConfig(ctx, &keyIndexSetGetter{SetGetter: sg, keys: keys}); err != nil {
+				return err
+			}
+
+			r.keys.Store(&keys)
+			return nil
+		},
+		ms.IncrementalUpdateClientConfig,
+		cache.WithName("client_configs"),
+	)
+
+	lf.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return r.Start(ctx)
+		},
+		OnStop: func(ctx context.Context) error {
+			return r.Close(ctx)
+		},
+	})
+
+	return r
+}
+
+// Start runs the first full update, and retries it in the background if it
+// fails. Without auth or Mongo there are no client configs and no API key is
+// valid.
+func (r *ClientRepository) Start(ctx context.Context) error {
+	if !r.enabled {
+		r.lgr.Info("Client configs disabled")
+		return nil
+	}
+
+	if err := r.cache.Start(ctx); err != nil {
+		r.lgr.Error("Failed to load client configs, retrying in background", zap.Error(err))
+		r.retry()
+		return nil
+	}
+
+	r.started.Store(true)
+	return nil
+}
+
+// retry repeats the first full update until it succeeds or the repository
+// is closed.
+func (r *ClientRepository) retry() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.retryCancel = cancel
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		t := time.NewTicker(r.retryInterval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+
+			startCtx, cancel := context.WithTimeout(ctx, r.retryInterval)
+			err := r.cache.Start(startCtx)
+			cancel()
+			if err == nil {
+				r.lgr.Info("Client configs loaded")
+				r.started.Store(true)
+				return
+			}
+		}
+	}()
+}
+
+func (r *ClientRepository) Close(ctx context.Context) error {
+	if r.retryCancel != nil {
+		r.retryCancel()
+	}
+	r.wg.Wait()
+
+	if !r.started.Load() {
+		return nil
+	}
+	return r.cache.Close(ctx)
+}
+
+// Enabled reports whether client configs are loaded at all.
+func (r *ClientRepository) Enabled() bool {
+	return r.enabled
+}
+
+// Check fails until the first full update succeeds.
+func (r *ClientRepository) Check(context.Context) error {
+	if r.enabled && !r.started.Load() {
+		return errClientsNotLoaded
+	}
+	return nil
+}
+
+// ClientByAPIKey implements auth.KeyStore. Until the client configs are
+// loaded it fails, so that API key calls are rejected as unavailable rather
+// than unauthenticated.
+func (r *ClientRepository) ClientByAPIKey(_ context.Context, hash string) (string, error) {
+	keys := r.keys.Load()
+	if keys == nil {
+		if r.enabled {
+			return "", errClientsNotLoaded
+		}
+		return "", auth.ErrUnknownKey
+	}
+
+	entry, ok := (*keys)[hash]
+	if !ok || entry.expiresAt != nil && !time.Now().Before(*entry.expiresAt) {
+		return "", auth.ErrUnknownKey
+	}
+
+	return entry.clientID, nil
+}
+
+// ClientConfig returns the config of the calling client, see auth.FromContext.
+func (r *ClientRepository) ClientConfig(ctx context.Context) (*mongodb.ClientConfigDocument, bool) {
+	clientID := auth.ClientIDFromContext(ctx)
+	if clientID == "" {
+		return nil, false
+	}
+
+	v, ok := r.cache.Get(clientID)
+	if !ok {
+		return nil, false
+	}
+
+	config, ok := v.(*mongodb.ClientConfigDocument)
+	return config, ok
+}
+
+// keyIndexSetGetter collects the API keys of the client configs set by a full
+// update. A key expires with its client config if it has no own expiry.
+type keyIndexSetGetter struct {
+	cache.SetGetter[string, any]
+	keys map[string]apiKeyEntry
+}
+
+func (s *keyIndexSetGetter) Set(k string, v any, updateTime time.Time) {
+	s.SetGetter.Set(k, v, updateTime)
+
+	config, ok := v.(*mongodb.ClientConfigDocument)
+	if !ok {
+		return
+	}
+
+	for _, key := range config.APIKeys {
+		expiresAt := key.ExpiresAt
+		if expiresAt == nil || config.ExpiresAt != nil && config.ExpiresAt.Before(*expiresAt) {
+			expiresAt = config.ExpiresAt
+		}
+		s.keys[key.Hash] = apiKeyEntry{clientID: config.ClientID, expiresAt: expiresAt}
+	}
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	mongodb "item_compositiom_service/internal/repository/mongo_db"
+	"item_compositiom_service/pkg/auth"
+	"item_compositiom_service/pkg/cache"
+	"item_compositiom_service/pkg/metrics"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type clientSetGetter map[string]any
+
+func (m clientSetGetter) Set(k string, v any, _ time.Time) {
+	m[k] = v
+}
+
+func (m clientSetGetter) Get(k string) (any, bool) {
+	v, ok := m[k]
+	return v, ok
+}
+
+func (m clientSetGetter) LastUpdated(string) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (m clientSetGetter) CleanUp() int { return 0 }
+
+func (m clientSetGetter) Len() int { return len(m) }
+
+func TestClientRepository_ClientByAPIKey(t *testing.T) {
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+
+	configs := []*mongodb.ClientConfigDocument{
+		{ClientID: "mobile", APIKeys: []mongodb.APIKey{
+			{Hash: auth.HashAPIKey("current"), Name: "current"},
+			{Hash: auth.HashAPIKey("rotated"), Name: "rotated", ExpiresAt: &past},
+		}},
+		{ClientID: "partner", ExpiresAt: &past, APIKeys: []mongodb.APIKey{
+			{Hash: auth.HashAPIKey("partner"), ExpiresAt: &future},
+		}},
+		{ClientID: "web"},
+	}
+
+	keys := make(map[string]apiKeyEntry)
+	sg := &keyIndexSetGetter{SetGetter: clientSetGetter{}, keys: keys}
+	for _, config := range configs {
+		sg.Set(config.ClientID, config, time.Now())
+	}
+
+	r := &ClientRepository{}
+	ctx := context.Background()
+
+	_, err := r.ClientByAPIKey(ctx, auth.HashAPIKey("current"))
+	require.ErrorIs(t, err, auth.ErrUnknownKey, "no key is valid before the first update")
+
+	r.keys.Store(&keys)
+
+	clientID, err := r.ClientByAPIKey(ctx, auth.HashAPIKey("current"))
+	require.NoError(t, err)
+	assert.Equal(t, "mobile", clientID)
+
+	_, err = r.ClientByAPIKey(ctx, auth.HashAPIKey("rotated"))
+	assert.ErrorIs(t, err, auth.ErrUnknownKey)
+
+	// Keys expire with their client config.
+	_, err = r.ClientByAPIKey(ctx, auth.HashAPIKey("partner"))
+	assert.ErrorIs(t, err, auth.ErrUnknownKey)
+
+	_, err = r.ClientByAPIKey(ctx, auth.HashAPIKey("unknown"))
+	assert.ErrorIs(t, err, auth.ErrUnknownKey)
+}
+
+func TestClientRepository_ClientConfig(t *testing.T) {
+	r := &ClientRepository{
+		cache: cache.New(zap.NewNop().Sugar(), &metrics.NoopMetrics{},
+			func(context.Context, cache.SetGetter[string, any]) error { return nil },
+			func(context.Context, cache.SetGetter[string, any], string) error { return nil },
+		),
+	}
+	r.cache.Set("mobile", &mongodb.ClientConfigDocument{ClientID: "mobile", Content: []byte("config")})
+
+	_, ok := r.ClientConfig(context.Background())
+	assert.False(t, ok, "anonymous callers have no config")
+
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{ClientID: "mobile", Method: auth.MethodAPIKey})
+	config, ok := r.ClientConfig(ctx)
+	require.True(t, ok)
+	assert.Equal(t, "config", string(config.Content))
+
+	_, ok = r.ClientConfig(auth.WithIdentity(context.Background(), &auth.Identity{ClientID: "web"}))
+	assert.False(t, ok)
+}
+
+func TestClientRepository_StartRetries(t *testing.T) {
+	var attempts atomic.Int32
+	r := &ClientRepository{
+		lgr:           zap.NewNop(),
+		enabled:       true,
+		retryInterval: 10 * time.Millisecond,
+	}
+	r.cache = cache.New(zap.NewNop().Sugar(), &metrics.NoopMetrics{},
+		func(context.Context, cache.SetGetter[string, any]) error {
+			if attempts.Add(1) < 3 {
+				return errors.New("mongo is down")
+			}
+			r.keys.Store(&map[string]apiKeyEntry{auth.HashAPIKey("current"): {clientID: "mobile"}})
+			return nil
+		},
+		func(context.Context, cache.SetGetter[string, any], string) error { return nil },
+		cache.WithTTL(time.Hour),
+	)
+
+	ctx := context.Background()
+	require.NoError(t, r.Start(ctx), "a failed first update does not fail the start")
+	assert.Error(t, r.Check(ctx))
+
+	_, err := r.ClientByAPIKey(ctx, auth.HashAPIKey("current"))
+	assert.ErrorIs(t, err, errClientsNotLoaded)
+
+	assert.Eventually(t, func() bool { return r.Check(ctx) == nil }, time.Second, 10*time.Millisecond)
+
+	clientID, err := r.ClientByAPIKey(ctx, auth.HashAPIKey("current"))
+	require.NoError(t, err)
+	assert.Equal(t, "mobile", clientID)
+	assert.NoError(t, r.Close(ctx))
+}
@@ -0,0 +1,47 @@
+package repository
+
+import "time"
+
+type SnapshotConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Path     string        `yaml:"path"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// Template source types.
+const (
+	SourceMongo      = "mongo"
+	SourceLocal      = "local"
+	SourceEmbedded   = "embedded"
+	SourceHTTPBundle = "http_bundle"
+	SourceBundle     = "bundle"
+	SourceGit        = "git"
+)
+
+// Template source policies.
+const (
+	PolicyFirstWins    = "first_wins"
+	PolicyOverride     = "override"
+	PolicyFallbackOnly = "fallback_only"
+)
+
+// SourcesConfig is the list of template sources. An empty list means Mongo,
+// then the local store.
+type SourcesConfig []SourceConfig
+
+type SourceConfig struct {
+	// Name defaults to Type; it must be unique.
+	Name     string        `yaml:"name,omitempty"`
+	Type     string        `yaml:"type"`
+	Priority int           `yaml:"priority"`
+	Policy   string        `yaml:"policy"`
+	URL      string        `yaml:"url,omitempty"`
+	Timeout  time.Duration `yaml:"timeout,omitempty"`
+	// Git source: a working copy or bare repository, the ref to read, the
+	// template directory inside the repository and whether to run git fetch
+	// before every poll. Bundle source: the bundle file.
+	Path  string `yaml:"path,omitempty"`
+	Ref   string `yaml:"ref,omitempty"`
+	Dir   string `yaml:"dir,omitempty"`
+	Fetch bool   `yaml:"fetch,omitempty"`
+}
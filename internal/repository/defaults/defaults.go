@@ -0,0 +1,8 @@
+// Package defaults embeds the templates served by the "embedded" template
+// source. Put <template_id>.yaml files into the templates directory.
+package defaults
+
+import "embed"
+
+//go:embed all:templates
+var FS embed.FS
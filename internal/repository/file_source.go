@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"item_compositiom_service/internal/entity"
+	"item_compositiom_service/pkg/cache"
+	"item_compositiom_service/pkg/parser"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+type templateFile struct {
+	content []byte
+	modTime time.Time
+}
+
+// fileSource serves templates from a set of <template_id>.yaml files returned
+// by load.
+type fileSource struct {
+	templateLib *parser.TemplateLib
+	load        func(ctx context.Context) (map[entity.TemplateIdName]templateFile, error)
+}
+
+func (s *fileSource) UpdateTemplate(ctx context.Context, setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) error {
+	files, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for id, file := range files {
+		if lastUpdated, ok := setGetter.LastUpdated(id); ok && file.modTime.Before(lastUpdated) {
+			continue
+		}
+
+		if err := s.set(setGetter, id, file); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *fileSource) IncrementalUpdateTemplate(ctx context.Context, setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction], id entity.TemplateIdName) error {
+	files, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	file, ok := files[id]
+	if !ok {
+		return fmt.Errorf("template %s not found", id)
+	}
+
+	return s.set(setGetter, id, file)
+}
+
+func (s *fileSource) set(setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction], id entity.TemplateIdName, file templateFile) error {
+	readTime := time.Now()
+
+	instructions, err := s.templateLib.ParseTemplate(file.content)
+	if err != nil {
+		return fmt.Errorf("parse template %s: %w", id, err)
+	}
+
+	setGetter.Set(id, instructions, readTime)
+	entity.RecordContent(setGetter, id, file.content, readTime)
+	return nil
+}
+
+// templateID returns the template id of a file name, false for non-template
+// files.
+func templateID(name string) (entity.TemplateIdName, bool) {
+	base := path.Base(name)
+	if path.Ext(base) != ".yaml" || strings.HasPrefix(base, ".") {
+		return "", false
+	}
+
+	return entity.TemplateIdName(strings.TrimSuffix(base, ".yaml")), true
+}
+
+// newFSSource serves the templates in the root of fsys, read once.
+func newFSSource(templateLib *parser.TemplateLib, fsys fs.FS) *fileSource {
+	var (
+		once  sync.Once
+		files map[entity.TemplateIdName]templateFile
+		err   error
+	)
+
+	return &fileSource{
+		templateLib: templateLib,
+		load: func(context.Context) (map[entity.TemplateIdName]templateFile, error) {
+			once.Do(func() {
+				files, err = readFS(fsys)
+			})
+			return files, err
+		},
+	}
+}
+
+func readFS(fsys fs.FS) (map[entity.TemplateIdName]templateFile, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read templates: %w", err)
+	}
+
+	files := make(map[entity.TemplateIdName]templateFile, len(entries))
+	for _, entry := range entries {
+		id, ok := templateID(entry.Name())
+		if entry.IsDir() || !ok {
+			continue
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read template %s: %w", entry.Name(), err)
+		}
+
+		files[id] = templateFile{content: content}
+	}
+
+	return files, nil
+}
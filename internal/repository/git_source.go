@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"item_compositiom_service/internal/entity"
+	"item_compositiom_service/pkg/cache"
+	"item_compositiom_service/pkg/parser"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gitSource reads templates from a ref of a local git repository, working
+// copy or bare. Every full update polls the ref; on a new commit only the
+// templates whose blobs changed are parsed again. The commit SHA is recorded
+// as the version of every template.
+type gitSource struct {
+	templateLib *parser.TemplateLib
+	path        string
+	ref         string
+	dir         string
+	fetch       bool
+
+	mu    sync.Mutex
+	sha   string
+	blobs map[entity.TemplateIdName]string
+}
+
+func newGitSource(templateLib *parser.TemplateLib, cfg SourceConfig) (*gitSource, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git source: %w", err)
+	}
+
+	return &gitSource{
+		templateLib: templateLib,
+		path:        cfg.Path,
+		ref:         cmp.Or(cfg.Ref, "HEAD"),
+		dir:         strings.Trim(cfg.Dir, "/"),
+		fetch:       cfg.Fetch,
+		blobs:       make(map[entity.TemplateIdName]string),
+	}, nil
+}
+
+func (s *gitSource) UpdateTemplate(ctx context.Context, setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fetch {
+		if _, err := s.git(ctx, "fetch", "--quiet"); err != nil {
+			return err
+		}
+	}
+
+	sha, err := s.resolve(ctx)
+	if err != nil {
+		return err
+	}
+
+	blobs, err := s.tree(ctx, sha)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for id, blob := range blobs {
+		if _, ok := setGetter.LastUpdated(id); ok && s.blobs[id] == blob {
+			entity.RecordVersion(setGetter, id, sha)
+			continue
+		}
+
+		if err := s.load(ctx, setGetter, id, blob, sha); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		s.blobs[id] = blob
+	}
+
+	for id := range s.blobs {
+		if _, ok := blobs[id]; !ok {
+			delete(s.blobs, id)
+		}
+	}
+
+	s.sha = sha
+	return errors.Join(errs...)
+}
+
+func (s *gitSource) IncrementalUpdateTemplate(ctx context.Context, setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction], id entity.TemplateIdName) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sha := s.sha
+	if sha == "" {
+		var err error
+		if sha, err = s.resolve(ctx); err != nil {
+			return err
+		}
+	}
+
+	blob, err := s.git(ctx, "rev-parse", "--verify", "--quiet", sha+":"+path.Join(s.dir, string(id)+".yaml"))
+	if err != nil {
+		return fmt.Errorf("template %s not found at %s", id, sha)
+	}
+
+	return s.load(ctx, setGetter, id, strings.TrimSpace(string(blob)), sha)
+}
+
+func (s *gitSource) load(
+	ctx context.Context,
+	setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction],
+	id entity.TemplateIdName,
+	blob, sha string,
+) error {
+	readTime := time.Now()
+
+	content, err := s.git(ctx, "cat-file", "blob", blob)
+	if err != nil {
+		return fmt.Errorf("read template %s: %w", id, err)
+	}
+
+	instructions, err := s.templateLib.ParseTemplate(content)
+	if err != nil {
+		return fmt.Errorf("parse template %s at %s: %w", id, sha, err)
+	}
+
+	setGetter.Set(id, instructions, readTime)
+	entity.RecordContent(setGetter, id, content, readTime)
+	entity.RecordVersion(setGetter, id, sha)
+	return nil
+}
+
+func (s *gitSource) resolve(ctx context.Context) (string, error) {
+	out, err := s.git(ctx, "rev-parse", "--verify", s.ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", s.ref, err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// tree returns the blob of every template file directly in the template
+// directory at sha.
+func (s *gitSource) tree(ctx context.Context, sha string) (map[entity.TemplateIdName]string, error) {
+	treeish := sha
+	if s.dir != "" {
+		treeish += ":" + s.dir
+	}
+
+	out, err := s.git(ctx, "ls-tree", "-z", treeish)
+	if err != nil {
+		return nil, fmt.Errorf("list templates at %s: %w", sha, err)
+	}
+
+	blobs := make(map[entity.TemplateIdName]string)
+	for _, line := range strings.Split(string(out), "\x00") {
+		// <mode> SP <type> SP <object> TAB <file>
+		meta, name, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 || fields[1] != "blob" {
+			continue
+		}
+
+		if id, ok := templateID(name); ok {
+			blobs[id] = fields[2]
+		}
+	}
+
+	return blobs, nil
+}
+
+func (s *gitSource) git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", s.path}, args...)...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
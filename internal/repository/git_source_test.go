@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"item_compositiom_service/internal/entity"
+	"item_compositiom_service/pkg/i18n"
+	"item_compositiom_service/pkg/metrics"
+	"item_compositiom_service/pkg/output"
+	"item_compositiom_service/pkg/parser"
+	"item_compositiom_service/pkg/provider"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestTemplateLib(t *testing.T) *parser.TemplateLib {
+	t.Helper()
+
+	storage, err := provider.NewProviderStorage(zap.NewNop().Sugar(), &metrics.NoopMetrics{})
+	require.NoError(t, err)
+
+	templateLib, err := parser.NewTemplateLib(
+		&parser.Config{},
+		&metrics.NoopMetrics{},
+		storage,
+		i18n.NewStorage(&i18n.Config{}),
+		output.NewRegistry(),
+	)
+	require.NoError(t, err)
+	return templateLib
+}
+
+func gitTemplate(title string) string {
+	return "kind: Template\nmetadata:\n  name: card\nspec:\n  title:\n    type: string\n    value: " + title + "\n"
+}
+
+type testRepo struct {
+	t   *testing.T
+	dir string
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	r := &testRepo{t: t, dir: t.TempDir()}
+	r.git("init", "--quiet", "--initial-branch=main")
+	return r
+}
+
+func (r *testRepo) git(args ...string) string {
+	r.t.Helper()
+
+	cmd := exec.Command("git", append([]string{
+		"-C", r.dir,
+		"-c", "user.name=test",
+		"-c", "user.email=test@example.com",
+		"-c", "commit.gpgsign=false",
+	}, args...)...)
+	out, err := cmd.CombinedOutput()
+	require.NoError(r.t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+// commit writes files (path -> content) and returns the commit SHA.
+func (r *testRepo) commit(files map[string]string) string {
+	r.t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(r.dir, name)
+		require.NoError(r.t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(r.t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	r.git("add", "-A")
+	r.git("commit", "--quiet", "-m", "update")
+	return r.git("rev-parse", "HEAD")
+}
+
+func title(t *testing.T, sg mapSetGetter, id entity.TemplateIdName) any {
+	t.Helper()
+
+	instructions, ok := sg.Get(id)
+	require.True(t, ok, id)
+	return instructions[0].Spec["title"].(map[string]any)["value"]
+}
+
+func TestGitSource(t *testing.T) {
+	repo := newTestRepo(t)
+	first := repo.commit(map[string]string{
+		"templates/card.yaml":  gitTemplate("first"),
+		"templates/promo.yaml": gitTemplate("promo"),
+		"templates/README.md":  "not a template",
+		"other/skip.yaml":      gitTemplate("skip"),
+	})
+
+	src, err := newGitSource(newTestTemplateLib(t), SourceConfig{Path: repo.dir, Ref: "main", Dir: "templates"})
+	require.NoError(t, err)
+
+	set := newTestSourceSet(&templateSource{name: "git", policy: PolicyFirstWins, source: src})
+	sg := mapSetGetter{}
+	ctx := context.Background()
+
+	require.NoError(t, set.update(ctx, sg))
+	assert.Len(t, sg, 2)
+	assert.Equal(t, "first", title(t, sg, "card"))
+
+	version, ok := set.Version("card")
+	assert.True(t, ok)
+	assert.Equal(t, first, version)
+
+	// A new commit reloads the changed template only; every template gets the
+	// new version.
+	promoUpdated := sg["promo"].updated
+	second := repo.commit(map[string]string{"templates/card.yaml": gitTemplate("second")})
+
+	require.NoError(t, set.update(ctx, sg))
+	assert.Equal(t, "second", title(t, sg, "card"))
+	assert.Equal(t, promoUpdated, sg["promo"].updated)
+
+	for _, id := range []entity.TemplateIdName{"card", "promo"} {
+		version, _ := set.Version(id)
+		assert.Equal(t, second, version, id)
+	}
+
+	// Incremental updates read the polled commit.
+	delete(sg, "promo")
+	require.NoError(t, set.updateOne(ctx, sg, "promo"))
+	assert.Equal(t, "promo", title(t, sg, "promo"))
+	assert.Error(t, set.updateOne(ctx, sg, "skip"))
+}
+
+func TestGitSource_BareRepository(t *testing.T) {
+	repo := newTestRepo(t)
+	sha := repo.commit(map[string]string{"card.yaml": gitTemplate("bare")})
+	repo.git("tag", "v1")
+
+	bare := filepath.Join(t.TempDir(), "templates.git")
+	repo.git("clone", "--quiet", "--bare", repo.dir, bare)
+
+	src, err := newGitSource(newTestTemplateLib(t), SourceConfig{Path: bare, Ref: "v1"})
+	require.NoError(t, err)
+
+	set := newTestSourceSet(&templateSource{name: "git", policy: PolicyFirstWins, source: src})
+	sg := mapSetGetter{}
+
+	require.NoError(t, set.update(context.Background(), sg))
+	assert.Equal(t, "bare", title(t, sg, "card"))
+
+	version, _ := set.Version("card")
+	assert.Equal(t, sha, version)
+}
+
+func TestGitSource_UnknownRef(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.commit(map[string]string{"card.yaml": gitTemplate("x")})
+
+	src, err := newGitSource(newTestTemplateLib(t), SourceConfig{Path: repo.dir, Ref: "missing"})
+	require.NoError(t, err)
+
+	err = src.UpdateTemplate(context.Background(), mapSetGetter{})
+	assert.ErrorContains(t, err, "resolve missing")
+}
@@ -99,7 +99,7 @@ func (s *LocalStorage) UpdateTemplate(ctx context.Context, setGetter cache.SetGe
 		}
 
 		readTime := time.Now()
-		bytes, err := os.ReadFile(file.Name())
+		bytes, err := os.ReadFile(filepath.Join(s.config.TemplateDirPath, name))
 		if err != nil {
 			s.collector.errorsCount.WithLabelValues("template", "read_file").Inc()
 			errs = append(errs, fmt.Errorf("read template file %s: %w", name, err))
@@ -116,6 +116,7 @@ func (s *LocalStorage) UpdateTemplate(ctx context.Context, setGetter cache.SetGe
 		}
 
 		setGetter.Set(idName, instructions, readTime)
+		entity.RecordContent(setGetter, idName, bytes, readTime)
 	}
 
 	return errors.Join(errs...)
@@ -153,7 +154,7 @@ func (s *LocalStorage) IncrementalUpdateTemplate(ctx context.Context, setGetter
 		s.lgr.Debug("LocalStorage template found", zap.String("template_id", string(idName)))
 
 		readTime := time.Now()
-		bytes, err := os.ReadFile(file.Name())
+		bytes, err := os.ReadFile(filepath.Join(s.config.TemplateDirPath, name))
 		if err != nil {
 			s.collector.errorsCount.WithLabelValues("template", "read_file").Inc()
 			errs = append(errs, fmt.Errorf("read template file %s: %w", name, err))
@@ -170,11 +171,21 @@ func (s *LocalStorage) IncrementalUpdateTemplate(ctx context.Context, setGetter
 		}
 
 		setGetter.Set(idName, instructions, readTime)
+		entity.RecordContent(setGetter, idName, bytes, readTime)
 	}
 
 	return errors.Join(errs...)
 }
 
+// Check reports whether the template directory is readable.
+func (s *LocalStorage) Check(_ context.Context) error {
+	if _, err := os.ReadDir(s.config.TemplateDirPath); err != nil {
+		return fmt.Errorf("read template dir %s: %w", s.config.TemplateDirPath, err)
+	}
+
+	return nil
+}
+
 func (s *LocalStorage) LogDebug(msg string, fields ...zap.Field) {
 	if s.config.LoggingConfig.Enabled {
 		s.lgr.Debug(msg, fields...)
@@ -0,0 +1,86 @@
+package localdb
+
+import (
+	"context"
+	"item_compositiom_service/internal/entity"
+	"item_compositiom_service/pkg/i18n"
+	"item_compositiom_service/pkg/metrics"
+	"item_compositiom_service/pkg/output"
+	"item_compositiom_service/pkg/parser"
+	"item_compositiom_service/pkg/provider"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type templateEntry struct {
+	instructions []parser.Instruction
+	updated      time.Time
+}
+
+type templateSetGetter map[entity.TemplateIdName]templateEntry
+
+func (m templateSetGetter) Set(k entity.TemplateIdName, v []parser.Instruction, updateTime time.Time) {
+	m[k] = templateEntry{instructions: v, updated: updateTime}
+}
+
+func (m templateSetGetter) Get(k entity.TemplateIdName) ([]parser.Instruction, bool) {
+	e, ok := m[k]
+	return e.instructions, ok
+}
+
+func (m templateSetGetter) LastUpdated(k entity.TemplateIdName) (time.Time, bool) {
+	e, ok := m[k]
+	return e.updated, ok
+}
+
+func (m templateSetGetter) CleanUp() int { return 0 }
+
+func (m templateSetGetter) Len() int { return len(m) }
+
+func newTestStorage(t *testing.T, dir string) *LocalStorage {
+	t.Helper()
+
+	storage, err := provider.NewProviderStorage(zap.NewNop().Sugar(), &metrics.NoopMetrics{})
+	require.NoError(t, err)
+
+	templateLib, err := parser.NewTemplateLib(
+		&parser.Config{},
+		&metrics.NoopMetrics{},
+		storage,
+		i18n.NewStorage(&i18n.Config{}),
+		output.NewRegistry(),
+	)
+	require.NoError(t, err)
+
+	return NewLocalStorage(
+		&LocalStorageConfig{LoggingConfig: &LoggingConfig{}, TemplateDirPath: dir},
+		zap.NewNop().Sugar(),
+		&metrics.NoopMetrics{},
+		templateLib,
+	)
+}
+
+// The template directory is usually not the working directory, so the files
+// are read by their path in it rather than by their bare name.
+func TestLocalStorage_TemplatesOutsideWorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	content := "kind: Template\nmetadata:\n  name: card\nspec:\n  title:\n    type: string\n    value: card\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "card.yaml"), []byte(content), 0644))
+
+	s := newTestStorage(t, dir)
+	ctx := context.Background()
+
+	sg := templateSetGetter{}
+	require.NoError(t, s.UpdateTemplate(ctx, sg))
+	assert.Contains(t, sg, entity.TemplateIdName("card"))
+
+	sg = templateSetGetter{}
+	require.NoError(t, s.IncrementalUpdateTemplate(ctx, sg, "card"))
+	assert.Contains(t, sg, entity.TemplateIdName("card"))
+}
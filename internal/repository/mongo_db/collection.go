@@ -0,0 +1,193 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	versionField = "version"
+	listPageSize = 500
+)
+
+var (
+	ErrNotFound = errors.New("document not found")
+	ErrConflict = errors.New("document version conflict")
+)
+
+// mongoCollection is the part of *mongo.Collection used by Collection.
+type mongoCollection interface {
+	FindOne(ctx context.Context, filter any, opts ...*options.FindOneOptions) *mongo.SingleResult
+	Find(ctx context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error)
+	InsertOne(ctx context.Context, document any, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error)
+	ReplaceOne(ctx context.Context, filter any, replacement any, opts ...*options.ReplaceOptions) (*mongo.UpdateResult, error)
+	DeleteOne(ctx context.Context, filter any, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+}
+
+type document interface {
+	key() string
+	meta() *Meta
+}
+
+// Page is a page of documents ordered by key. Next is the key to list the
+// next page after, empty on the last page.
+type Page[D any] struct {
+	Items []D
+	Next  string
+}
+
+// Collection is a typed collection of documents with a unique key field.
+type Collection[D document] struct {
+	coll     mongoCollection
+	keyField string
+	newDoc   func() D
+}
+
+func newCollection[D document](coll mongoCollection, keyField string, newDoc func() D) *Collection[D] {
+	return &Collection[D]{coll: coll, keyField: keyField, newDoc: newDoc}
+}
+
+// Get returns the document with key or ErrNotFound.
+func (c *Collection[D]) Get(ctx context.Context, key string) (D, error) {
+	d := c.newDoc()
+
+	err := c.coll.FindOne(ctx, bson.D{{Key: c.keyField, Value: key}}).Decode(d)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return d, fmt.Errorf("%w: %s %s", ErrNotFound, c.keyField, key)
+	}
+	if err != nil {
+		return d, err
+	}
+
+	d.meta().stored = true
+	return d, nil
+}
+
+// List returns up to limit documents with keys after after, ordered by key.
+func (c *Collection[D]) List(ctx context.Context, after string, limit int) (Page[D], error) {
+	filter := bson.D{}
+	if after != "" {
+		filter = bson.D{{Key: c.keyField, Value: bson.D{{Key: "$gt", Value: after}}}}
+	}
+
+	cursor, err := c.coll.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: c.keyField, Value: 1}}).
+		SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return Page[D]{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var page Page[D]
+	for cursor.Next(ctx) {
+		d := c.newDoc()
+		if err := cursor.Decode(d); err != nil {
+			return Page[D]{}, fmt.Errorf("decode %s: %w", cursor.Current.Lookup(c.keyField), err)
+		}
+
+		d.meta().stored = true
+		page.Items = append(page.Items, d)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return Page[D]{}, err
+	}
+
+	if len(page.Items) == limit && limit > 0 {
+		page.Next = page.Items[len(page.Items)-1].key()
+	}
+
+	return page, nil
+}
+
+// Save inserts a new document or replaces a stored one if its version is
+// unchanged, and increments the version. It returns ErrConflict if a new
+// document already exists or a stored one was changed or deleted.
+func (c *Collection[D]) Save(ctx context.Context, d D) error {
+	m := d.meta()
+	prev := *m
+
+	m.Version++
+	m.UpdatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	err := c.save(ctx, d, prev)
+	if err != nil {
+		*m = prev
+		return err
+	}
+
+	m.stored = true
+	return nil
+}
+
+func (c *Collection[D]) save(ctx context.Context, d D, prev Meta) error {
+	if !prev.stored {
+		_, err := c.coll.InsertOne(ctx, d)
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("%w: %s %s already exists", ErrConflict, c.keyField, d.key())
+		}
+		return err
+	}
+
+	res, err := c.coll.ReplaceOne(ctx, c.versionFilter(d.key(), prev.Version), d)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%w: %s %s is not at version %d", ErrConflict, c.keyField, d.key(), prev.Version)
+	}
+
+	return nil
+}
+
+// Delete deletes a stored document if its version is unchanged.
+func (c *Collection[D]) Delete(ctx context.Context, d D) error {
+	res, err := c.coll.DeleteOne(ctx, c.versionFilter(d.key(), d.meta().Version))
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("%w: %s %s is not at version %d", ErrConflict, c.keyField, d.key(), d.meta().Version)
+	}
+
+	return nil
+}
+
+// versionFilter matches key at version; documents written before versioning
+// have no version field and are read as version 0.
+func (c *Collection[D]) versionFilter(key string, version int64) bson.D {
+	if version == 0 {
+		return bson.D{
+			{Key: c.keyField, Value: key},
+			{Key: versionField, Value: bson.D{{Key: "$exists", Value: false}}},
+		}
+	}
+
+	return bson.D{{Key: c.keyField, Value: key}, {Key: versionField, Value: version}}
+}
+
+// Each calls fn for every document in key order, listing them page by page.
+func (c *Collection[D]) Each(ctx context.Context, fn func(D)) error {
+	for after := ""; ; {
+		page, err := c.List(ctx, after, listPageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range page.Items {
+			fn(d)
+		}
+
+		if page.Next == "" {
+			return nil
+		}
+		after = page.Next
+	}
+}
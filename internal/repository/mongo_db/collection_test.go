@@ -0,0 +1,138 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCollection_Save(t *testing.T) {
+	for name, coll := range testCollections(t, "template_view_id") {
+		t.Run(name, func(t *testing.T) {
+			templates := newTemplates(coll)
+			ctx := context.Background()
+
+			_, err := templates.Get(ctx, "card")
+			require.ErrorIs(t, err, ErrNotFound)
+
+			created := &TemplateDocument{ID: "card", Content: []byte("v1")}
+			require.NoError(t, templates.Save(ctx, created))
+			assert.Equal(t, int64(1), created.Version)
+			assert.False(t, created.UpdatedAt.IsZero())
+
+			// A new document with a stored key is a conflict.
+			err = templates.Save(ctx, &TemplateDocument{ID: "card", Content: []byte("other")})
+			require.ErrorIs(t, err, ErrConflict)
+
+			first, err := templates.Get(ctx, "card")
+			require.NoError(t, err)
+			second, err := templates.Get(ctx, "card")
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), first.Version)
+
+			first.Content = []byte("v2")
+			require.NoError(t, templates.Save(ctx, first))
+			assert.Equal(t, int64(2), first.Version)
+
+			// The second reader saves a stale version.
+			second.Content = []byte("lost")
+			require.ErrorIs(t, templates.Save(ctx, second), ErrConflict)
+			assert.Equal(t, int64(1), second.Version)
+
+			stored, err := templates.Get(ctx, "card")
+			require.NoError(t, err)
+			assert.Equal(t, "v2", string(stored.Content))
+			assert.Equal(t, int64(2), stored.Version)
+
+			// Saving again after a conflict needs a fresh read.
+			require.NoError(t, templates.Save(ctx, stored))
+			assert.Equal(t, int64(3), stored.Version)
+		})
+	}
+}
+
+func TestCollection_SaveUnversioned(t *testing.T) {
+	for name, coll := range testCollections(t, "template_view_id") {
+		t.Run(name, func(t *testing.T) {
+			templates := newTemplates(coll)
+			ctx := context.Background()
+
+			insertRaw(t, coll, bson.M{"template_view_id": "legacy", "content": []byte("v0")})
+
+			legacy, err := templates.Get(ctx, "legacy")
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), legacy.Version)
+
+			legacy.Content = []byte("v1")
+			require.NoError(t, templates.Save(ctx, legacy))
+			assert.Equal(t, int64(1), legacy.Version)
+
+			stale := &TemplateDocument{ID: "legacy", Meta: Meta{stored: true}}
+			require.ErrorIs(t, templates.Save(ctx, stale), ErrConflict)
+		})
+	}
+}
+
+func TestCollection_Delete(t *testing.T) {
+	for name, coll := range testCollections(t, "client_id") {
+		t.Run(name, func(t *testing.T) {
+			configs := newClientConfigs(coll)
+			ctx := context.Background()
+
+			config := &ClientConfigDocument{ClientID: "mobile"}
+			require.NoError(t, configs.Save(ctx, config))
+
+			stale, err := configs.Get(ctx, "mobile")
+			require.NoError(t, err)
+			require.NoError(t, configs.Save(ctx, config))
+
+			require.ErrorIs(t, configs.Delete(ctx, stale), ErrConflict)
+			require.NoError(t, configs.Delete(ctx, config))
+
+			_, err = configs.Get(ctx, "mobile")
+			require.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestCollection_List(t *testing.T) {
+	for name, coll := range testCollections(t, "name") {
+		t.Run(name, func(t *testing.T) {
+			specs := newClientSpecs(coll)
+			ctx := context.Background()
+
+			for _, name := range []string{"e", "c", "a", "d", "b"} {
+				require.NoError(t, specs.Save(ctx, &ClientSpecDocument{Name: name, Content: []byte(name)}))
+			}
+
+			var pages [][]string
+			for after := ""; ; {
+				page, err := specs.List(ctx, after, 2)
+				require.NoError(t, err)
+
+				var names []string
+				for _, spec := range page.Items {
+					names = append(names, spec.Name)
+				}
+				pages = append(pages, names)
+
+				if page.Next == "" {
+					break
+				}
+				after = page.Next
+			}
+
+			assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, pages)
+
+			var all []string
+			require.NoError(t, specs.Each(ctx, func(spec *ClientSpecDocument) {
+				all = append(all, fmt.Sprintf("%s@%d", spec.Name, spec.Version))
+			}))
+			assert.Equal(t, []string{"a@1", "b@1", "c@1", "d@1", "e@1"}, all)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package mongodb
+
+import "time"
+
+// Meta is the optimistic concurrency state of a document. Every save
+// increments Version and fails if the stored version has changed since the
+// document was read.
+type Meta struct {
+	Version   int64     `bson:"version"`
+	UpdatedAt time.Time `bson:"updated_at"`
+
+	// stored is set for documents read from the collection; others are
+	// inserted on save.
+	stored bool
+}
+
+func (m *Meta) meta() *Meta {
+	return m
+}
+
+// TemplateDocument is a document of the templates collection.
+type TemplateDocument struct {
+	ID      string `bson:"template_view_id"`
+	Content []byte `bson:"content"`
+	Meta    `bson:",inline"`
+}
+
+func (d *TemplateDocument) key() string {
+	return d.ID
+}
+
+func newTemplates(coll mongoCollection) *Collection[*TemplateDocument] {
+	return newCollection(coll, "template_view_id", func() *TemplateDocument { return &TemplateDocument{} })
+}
+
+// ClientSpecDocument is a provider spec of the client_specs collection.
+type ClientSpecDocument struct {
+	Name    string `bson:"name"`
+	Content []byte `bson:"content"`
+	Meta    `bson:",inline"`
+}
+
+func (d *ClientSpecDocument) key() string {
+	return d.Name
+}
+
+func newClientSpecs(coll mongoCollection) *Collection[*ClientSpecDocument] {
+	return newCollection(coll, "name", func() *ClientSpecDocument { return &ClientSpecDocument{} })
+}
+
+// ClientConfigDocument is a document of the client_configs collection. It is
+// removed once ExpiresAt passes, if set.
+type ClientConfigDocument struct {
+	ClientID  string     `bson:"client_id"`
+	Content   []byte     `bson:"content,omitempty"`
+	APIKeys   []APIKey   `bson:"api_keys,omitempty"`
+	ExpiresAt *time.Time `bson:"expires_at,omitempty"`
+	Meta      `bson:",inline"`
+}
+
+// APIKey is a static API key of a client. Only the SHA-256 hex hash of the key
+// is stored; see auth.HashAPIKey.
+type APIKey struct {
+	Hash      string     `bson:"hash"`
+	Name      string     `bson:"name,omitempty"`
+	ExpiresAt *time.Time `bson:"expires_at,omitempty"`
+}
+
+func (d *ClientConfigDocument) key() string {
+	return d.ClientID
+}
+
+func newClientConfigs(coll mongoCollection) *Collection[*ClientConfigDocument] {
+	return newCollection(coll, "client_id", func() *ClientConfigDocument { return &ClientConfigDocument{} })
+}
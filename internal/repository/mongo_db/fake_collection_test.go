@@ -0,0 +1,218 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fakeCollection is an in-process mongoCollection supporting the filters of
+// Collection: equality, $gt on strings and $exists, with a unique key field.
+type fakeCollection struct {
+	mu     sync.Mutex
+	unique string
+	docs   []bson.M
+}
+
+func newFakeCollection(unique string) *fakeCollection {
+	return &fakeCollection{unique: unique}
+}
+
+func toBSON[T any](v any) T {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+
+	var res T
+	if err := bson.Unmarshal(data, &res); err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (f *fakeCollection) match(doc bson.M, filter any) bool {
+	for _, e := range toBSON[bson.D](filter) {
+		value, exists := doc[e.Key]
+
+		ops, ok := e.Value.(bson.D)
+		if !ok || len(ops) == 0 || !strings.HasPrefix(ops[0].Key, "$") {
+			if !exists || !reflect.DeepEqual(value, e.Value) {
+				return false
+			}
+			continue
+		}
+
+		for _, op := range ops {
+			switch op.Key {
+			case "$exists":
+				if exists != op.Value.(bool) {
+					return false
+				}
+			case "$gt":
+				s, _ := value.(string)
+				if !exists || s <= op.Value.(string) {
+					return false
+				}
+			default:
+				panic("unsupported operator " + op.Key)
+			}
+		}
+	}
+
+	return true
+}
+
+func (f *fakeCollection) find(filter any) int {
+	for i, doc := range f.docs {
+		if f.match(doc, filter) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *fakeCollection) FindOne(_ context.Context, filter any, _ ...*options.FindOneOptions) *mongo.SingleResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.find(filter)
+	if i < 0 {
+		return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+	}
+	return mongo.NewSingleResultFromDocument(f.docs[i], nil, nil)
+}
+
+func (f *fakeCollection) Find(_ context.Context, filter any, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var docs []bson.M
+	for _, doc := range f.docs {
+		if f.match(doc, filter) {
+			docs = append(docs, doc)
+		}
+	}
+
+	for _, o := range opts {
+		if o.Sort != nil {
+			field := o.Sort.(bson.D)[0].Key
+			sort.SliceStable(docs, func(i, j int) bool {
+				return fmt.Sprint(docs[i][field]) < fmt.Sprint(docs[j][field])
+			})
+		}
+		if o.Limit != nil && *o.Limit > 0 && int(*o.Limit) < len(docs) {
+			docs = docs[:*o.Limit]
+		}
+	}
+
+	res := make([]any, len(docs))
+	for i, doc := range docs {
+		res[i] = doc
+	}
+	return mongo.NewCursorFromDocuments(res, nil, nil)
+}
+
+func (f *fakeCollection) InsertOne(_ context.Context, document any, _ ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	doc := toBSON[bson.M](document)
+	if f.find(bson.D{{Key: f.unique, Value: doc[f.unique]}}) >= 0 {
+		return nil, mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000, Message: "duplicate key"}}}
+	}
+	if _, ok := doc["_id"]; !ok {
+		doc["_id"] = primitive.NewObjectID()
+	}
+
+	f.docs = append(f.docs, doc)
+	return &mongo.InsertOneResult{InsertedID: doc["_id"]}, nil
+}
+
+func (f *fakeCollection) ReplaceOne(_ context.Context, filter any, replacement any, _ ...*options.ReplaceOptions) (*mongo.UpdateResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.find(filter)
+	if i < 0 {
+		return &mongo.UpdateResult{}, nil
+	}
+
+	doc := toBSON[bson.M](replacement)
+	doc["_id"] = f.docs[i]["_id"]
+	f.docs[i] = doc
+	return &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+}
+
+func (f *fakeCollection) DeleteOne(_ context.Context, filter any, _ ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.find(filter)
+	if i < 0 {
+		return &mongo.DeleteResult{}, nil
+	}
+
+	f.docs = append(f.docs[:i], f.docs[i+1:]...)
+	return &mongo.DeleteResult{DeletedCount: 1}, nil
+}
+
+// insertRaw stores a document as is, e.g. one written before versioning.
+func insertRaw(t *testing.T, coll mongoCollection, doc bson.M) {
+	t.Helper()
+
+	switch c := coll.(type) {
+	case *fakeCollection:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.docs = append(c.docs, toBSON[bson.M](doc))
+	case *mongo.Collection:
+		_, err := c.InsertOne(context.Background(), doc)
+		require.NoError(t, err)
+	}
+}
+
+// testCollections returns a fake collection and, if MONGO_TEST_DSN is set, a
+// collection of a temporary database of that server. Both have a unique
+// index on keyField.
+func testCollections(t *testing.T, keyField string) map[string]mongoCollection {
+	t.Helper()
+
+	res := map[string]mongoCollection{"fake": newFakeCollection(keyField)}
+
+	dsn := os.Getenv("MONGO_TEST_DSN")
+	if dsn == "" {
+		return res
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(dsn))
+	require.NoError(t, err)
+
+	db := client.Database("item_composition_service_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() {
+		_ = db.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+
+	coll := db.Collection("documents")
+	_, err = coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: keyField, Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	require.NoError(t, err)
+
+	res["mongod"] = coll
+	return res
+}
@@ -0,0 +1,25 @@
+package mongodb
+
+import (
+	"item_compositiom_service/migrations"
+	"item_compositiom_service/pkg/migrate"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// NewMigrator returns the migrator of the configured collections.
+func NewMigrator(db *mongo.Database, config *MongoStorageConfig, lgr *zap.Logger) (*migrate.Migrator, error) {
+	all := migrations.All(migrations.Collections{
+		Templates:     config.TemplatesCollection,
+		ClientSpecs:   config.ClientSpecsCollection,
+		ClientConfigs: config.ClientConfigsCollection,
+	})
+
+	opts := []migrate.Option{migrate.WithLogger(lgr)}
+	if config.MigrationsCollection != "" {
+		opts = append(opts, migrate.WithCollection(config.MigrationsCollection))
+	}
+
+	return migrate.New(db, all, opts...)
+}
@@ -9,23 +9,32 @@ import (
 	"item_compositiom_service/pkg/metrics"
 	"item_compositiom_service/pkg/parser"
 	"item_compositiom_service/pkg/tracer"
+	"strconv"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
-	"gopkg.in/mgo.v2/bson"
 )
 
 type MongoStorage struct {
 	db            *mongo.Database
-	ClientConfigs *mongo.Collection
-	ClientSpecs   *mongo.Collection
-	Templates     *mongo.Collection
-	config        *MongoStorageConfig
-	cm            *commandMonitor
+	ClientConfigs *Collection[*ClientConfigDocument]
+	ClientSpecs   *Collection[*ClientSpecDocument]
+	Templates     *Collection[*TemplateDocument]
+
+	// read only views with per-operation read preferences
+	clientConfigList *Collection[*ClientConfigDocument]
+	clientSpecList   *Collection[*ClientSpecDocument]
+	templateList     *Collection[*TemplateDocument]
+	templateReload   *Collection[*TemplateDocument]
+
+	config *MongoStorageConfig
+	cm     *commandMonitor
 
 	templateLib *parser.TemplateLib
 	lgr         *zap.Logger
@@ -69,25 +78,11 @@ func (s *MongoStorage) Start(ctx context.Context) error {
 		return nil
 	}
 
-	clientOpts := options.Client().ApplyURI(s.config.DSN).
-		SetMonitor(provideCommandMonitor(s.cm)).
-		SetTimeout(s.config.OperationTimeout).
-		SetConnectTimeout(s.config.ConnectionTimeout).
-		SetMaxPoolSize(s.config.MaxPoolSize).
-		SetHeartbeatInterval(s.config.HeartbeatFrequency)
-
-	switch s.config.ReadPreference {
-	case "secondary_preferred":
-		clientOpts.SetReadPreference(readpref.SecondaryPreferred())
-	case "secondary":
-		clientOpts.SetReadPreference(readpref.Secondary())
-	case "primary_preferred":
-		clientOpts.SetReadPreference(readpref.PrimaryPreferred())
-	case "primary":
-		clientOpts.SetReadPreference(readpref.Primary())
-	default:
-		return fmt.Errorf("unexpected read_preference value given: %s", s.config.ReadPreference)
+	clientOpts, err := clientOptions(s.config)
+	if err != nil {
+		return err
 	}
+	clientOpts.SetMonitor(provideCommandMonitor(s.cm))
 
 	client, err := mongo.Connect(ctx, clientOpts)
 	if err != nil {
@@ -99,12 +94,32 @@ func (s *MongoStorage) Start(ctx context.Context) error {
 	}
 
 	s.db = client.Database(s.config.Database)
-	s.ClientConfigs = s.db.Collection(s.config.ClientConfigsCollection)
-	s.ClientSpecs = s.db.Collection(s.config.ClientSpecsCollection)
-	s.Templates = s.db.Collection(s.config.TemplatesCollection)
+	// Causal consistency holds only for majority reads and writes; session
+	// defaults apply to transactions only, so the concerns are set on the
+	// collections the admin writes and reloads through.
+	admin := options.Collection().
+		SetReadPreference(readpref.Primary()).
+		SetReadConcern(readconcern.Majority()).
+		SetWriteConcern(writeconcern.Majority())
+	s.ClientConfigs = newClientConfigs(s.db.Collection(s.config.ClientConfigsCollection, admin))
+	s.ClientSpecs = newClientSpecs(s.db.Collection(s.config.ClientSpecsCollection, admin))
+	s.Templates = newTemplates(s.db.Collection(s.config.TemplatesCollection, admin))
+
+	s.clientConfigList = newClientConfigs(s.collection(s.config.ClientConfigsCollection, OpClientConfigList))
+	s.clientSpecList = newClientSpecs(s.collection(s.config.ClientSpecsCollection, OpClientSpecList))
+	s.templateList = newTemplates(s.collection(s.config.TemplatesCollection, OpTemplateList))
+	s.templateReload = newTemplates(s.collection(s.config.TemplatesCollection, OpTemplateReload,
+		options.Collection().SetReadConcern(readconcern.Majority())))
+
+	if s.config.MigrateOnStart {
+		migrator, err := NewMigrator(s.db, s.config, s.lgr)
+		if err != nil {
+			return err
+		}
 
-	if err := s.CreateIndexes(ctx); err != nil {
-		return fmt.Errorf("create indexes: %w", err)
+		if err := migrator.Up(ctx, 0); err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
 	}
 
 	s.lgr.Info("Component started")
@@ -112,98 +127,220 @@ func (s *MongoStorage) Start(ctx context.Context) error {
 	return nil
 }
 
+// Connect opens a database of config without the command monitor, for use
+// outside of the running service.
+func Connect(ctx context.Context, config *MongoStorageConfig) (*mongo.Database, error) {
+	clientOpts, err := clientOptions(config)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := mongo.Connect(ctx, clientOpts)
+	if err != nil {
+		return nil, fmt.Errorf("connect to mongo: %w", err)
+	}
+
+	if err := client.Ping(ctx, nil); err != nil {
+		_ = client.Disconnect(ctx)
+		return nil, fmt.Errorf("%s connection establishment: %w", component, err)
+	}
+
+	return client.Database(config.Database), nil
+}
+
+func clientOptions(config *MongoStorageConfig) (*options.ClientOptions, error) {
+	clientOpts := options.Client().ApplyURI(config.DSN).
+		SetTimeout(config.OperationTimeout).
+		SetConnectTimeout(config.ConnectionTimeout).
+		SetMaxPoolSize(config.MaxPoolSize).
+		SetHeartbeatInterval(config.HeartbeatFrequency)
+
+	if err := config.validateReadPreferences(); err != nil {
+		return nil, err
+	}
+
+	rp, err := config.readPreference("")
+	if err != nil {
+		return nil, err
+	}
+	clientOpts.SetReadPreference(rp)
+
+	return clientOpts, nil
+}
+
 func (s *MongoStorage) Disconnect(ctx context.Context) error {
+	if s.db == nil {
+		return nil
+	}
+
 	return s.db.Client().Disconnect(ctx)
 }
 
-func (s *MongoStorage) CreateIndexes(ctx context.Context) error {
-	_, err := s.Templates.Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys: bson.M{"template_view_id": 1},
-	})
-	return err
+// Enabled reports whether the storage is configured to connect.
+func (s *MongoStorage) Enabled() bool {
+	return s.config.Enabled
+}
+
+// Check pings the server.
+func (s *MongoStorage) Check(ctx context.Context) error {
+	if !s.config.Enabled {
+		return fmt.Errorf("%s is disabled", component)
+	}
+
+	if s.db == nil {
+		return fmt.Errorf("%s is not connected", component)
+	}
+
+	return s.db.Client().Ping(ctx, nil)
 }
 
 // do not forbid to propagate logger to context for command monitor
 func (s *MongoStorage) UpdateClientConfig(ctx context.Context, setGetter cache.SetGetter[string, any]) error {
-	// TODO implement
+	if s.clientConfigList == nil {
+		return fmt.Errorf("find client configs in mongo: %s is disabled", componentName)
+	}
+
+	readTime := time.Now()
+	ctx = WithCommandName(ctx, "FindClientConfigList")
+
+	err := s.clientConfigList.Each(ctx, func(config *ClientConfigDocument) {
+		setGetter.Set(config.ClientID, config, readTime)
+	})
+	if err != nil {
+		return fmt.Errorf("find client configs in mongo: %w", err)
+	}
+
 	return nil
 }
 
-func (s *MongoStorage) UpdateClientSpec(ctx context.Context, setGetter cache.SetGetter[string, any]) error {
-	// TODO implement
+func (s *MongoStorage) IncrementalUpdateClientConfig(ctx context.Context, setGetter cache.SetGetter[string, any], clientID string) error {
+	if s.clientConfigList == nil {
+		return fmt.Errorf("find client config in mongo: %s is disabled", componentName)
+	}
+
+	readTime := time.Now()
+
+	ctx = WithCommandName(ctx, "FindClientConfig")
+	config, err := s.clientConfigList.Get(ctx, clientID)
+	if err != nil {
+		return fmt.Errorf("find client config in mongo: %w", err)
+	}
+
+	setGetter.Set(config.ClientID, config, readTime)
 	return nil
 }
 
-type mongoTemplate struct {
-	ID        string    `bson:"template_view_id"`
-	Content   []byte    `bson:"content"`
-	UpdatedAt time.Time `bson:"updated_at"`
+func (s *MongoStorage) UpdateClientSpec(ctx context.Context, setGetter cache.SetGetter[string, any]) error {
+	if s.clientSpecList == nil {
+		return fmt.Errorf("find client specs in mongo: %s is disabled", componentName)
+	}
+
+	readTime := time.Now()
+	ctx = WithCommandName(ctx, "FindClientSpecList")
+
+	err := s.clientSpecList.Each(ctx, func(spec *ClientSpecDocument) {
+		setGetter.Set(spec.Name, spec, readTime)
+	})
+	if err != nil {
+		return fmt.Errorf("find client specs in mongo: %w", err)
+	}
+
+	return nil
 }
 
 func (s *MongoStorage) UpdateTemplate(ctx context.Context, setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) error {
+	if s.templateList == nil {
+		return fmt.Errorf("find templates in mongo: %s is disabled", componentName)
+	}
+
 	readTime := time.Now()
 	ctx = WithCommandName(ctx, "FindTemplateList")
-	cursor, err := s.Templates.Find(ctx, bson.M{})
-	if err != nil {
-		return fmt.Errorf("failed to find configs: %w", err)
-	}
-	defer cursor.Close(ctx)
 
 	var errs []error
-	var nullTime time.Time
-
-	for cursor.Next(ctx) {
-		var template mongoTemplate
-		if err := cursor.Decode(&template); err != nil {
-			errs = append(errs, fmt.Errorf("failed to decode config: %w", err))
-			continue
-		}
 
-		if template.UpdatedAt == nullTime {
-			template.UpdatedAt = time.Now()
-		}
+	err := s.templateList.Each(ctx, func(template *TemplateDocument) {
+		id := entity.TemplateIdName(template.ID)
 
-		if lastUpdatedTime, ok := setGetter.LastUpdated(entity.TemplateIdName(template.ID)); ok && template.UpdatedAt.Before(lastUpdatedTime) {
+		// Templates saved without updated_at are always reloaded.
+		if lastUpdatedTime, ok := setGetter.LastUpdated(id); ok && !template.UpdatedAt.IsZero() && template.UpdatedAt.Before(lastUpdatedTime) {
 			if s.config.LoggingConfig.Enabled {
 				s.lgr.Debug("MongoStorage template skipped due update time", zap.String("template_view_id", template.ID))
 			}
-			continue
+			recordVersion(setGetter, template)
+			return
 		}
 
-		instructions, err := s.templateLib.ParseTemplate(template.Content)
-		if err != nil {
-			errs = append(errs, fmt.Errorf("failed to parse template: %w", err))
-			continue
+		if err := s.setTemplate(setGetter, template, readTime); err != nil {
+			errs = append(errs, err)
 		}
-		setGetter.Set(entity.TemplateIdName(template.ID), instructions, readTime)
-	}
-
-	if err := cursor.Err(); err != nil {
-		errs = append(errs, fmt.Errorf("cursor error: %w", err))
+	})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("find templates in mongo: %w", err))
 	}
 
 	return errors.Join(errs...)
 }
 
 func (s *MongoStorage) IncrementalUpdateTemplate(ctx context.Context, setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction], id entity.TemplateIdName) error {
-	var result mongoTemplate
+	if s.templateReload == nil {
+		return fmt.Errorf("find template in mongo: %s is disabled", componentName)
+	}
+
 	readTime := time.Now()
 
 	ctx = WithCommandName(ctx, "FindTemplate")
-	err := s.Templates.FindOne(ctx, bson.M{"template_view_id": id}).Decode(&result)
-
+	template, err := s.templateReload.Get(ctx, string(id))
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return fmt.Errorf("find template in mongo: template %s not found: %w", id, err)
-		}
 		return fmt.Errorf("find template in mongo: %w", err)
 	}
 
-	instructions, err := s.templateLib.ParseTemplate(result.Content)
+	return s.setTemplate(setGetter, template, readTime)
+}
+
+func (s *MongoStorage) setTemplate(setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction], template *TemplateDocument, readTime time.Time) error {
+	instructions, err := s.templateLib.ParseTemplate(template.Content)
 	if err != nil {
-		return fmt.Errorf("parse template: %w", err)
+		return fmt.Errorf("parse template %s: %w", template.ID, err)
 	}
+
+	id := entity.TemplateIdName(template.ID)
 	setGetter.Set(id, instructions, readTime)
+	entity.RecordContent(setGetter, id, template.Content, readTime)
+	recordVersion(setGetter, template)
+	return nil
+}
+
+// recordVersion records the document version of templates saved with one.
+func recordVersion(setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction], template *TemplateDocument) {
+	if template.Version > 0 {
+		entity.RecordVersion(setGetter, entity.TemplateIdName(template.ID), strconv.FormatInt(template.Version, 10))
+	}
+}
+
+// SaveTemplate creates or replaces template content. It returns ErrConflict
+// if the template is changed concurrently or, with a non-zero
+// expectedVersion, is not at that version.
+func (s *MongoStorage) SaveTemplate(ctx context.Context, id entity.TemplateIdName, content []byte, expectedVersion int64) error {
+	if s.Templates == nil {
+		return fmt.Errorf("save template in mongo: %s is disabled", componentName)
+	}
+
+	template, err := s.Templates.Get(WithCommandName(ctx, "FindTemplate"), string(id))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("save template in mongo: %w", err)
+	}
+
+	if expectedVersion != 0 && template.Version != expectedVersion {
+		return fmt.Errorf("save template in mongo: %w: template %s is at version %d, not %d",
+			ErrConflict, id, template.Version, expectedVersion)
+	}
+
+	template.ID = string(id)
+	template.Content = content
+
+	if err := s.Templates.Save(WithCommandName(ctx, "SaveTemplate"), template); err != nil {
+		return fmt.Errorf("save template in mongo: %w", err)
+	}
 
 	return nil
 }
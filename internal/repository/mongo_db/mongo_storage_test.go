@@ -0,0 +1,173 @@
+package mongodb
+
+import (
+	"context"
+	"item_compositiom_service/internal/entity"
+	"item_compositiom_service/pkg/i18n"
+	"item_compositiom_service/pkg/metrics"
+	"item_compositiom_service/pkg/output"
+	"item_compositiom_service/pkg/parser"
+	"item_compositiom_service/pkg/provider"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type templateEntry struct {
+	instructions []parser.Instruction
+	updated      time.Time
+	version      string
+}
+
+// templateSetGetter is a template cache recording versions.
+type templateSetGetter map[entity.TemplateIdName]*templateEntry
+
+func (m templateSetGetter) Set(k entity.TemplateIdName, v []parser.Instruction, updateTime time.Time) {
+	m[k] = &templateEntry{instructions: v, updated: updateTime}
+}
+
+func (m templateSetGetter) Get(k entity.TemplateIdName) ([]parser.Instruction, bool) {
+	e, ok := m[k]
+	if !ok {
+		return nil, false
+	}
+	return e.instructions, true
+}
+
+func (m templateSetGetter) LastUpdated(k entity.TemplateIdName) (time.Time, bool) {
+	e, ok := m[k]
+	if !ok {
+		return time.Time{}, false
+	}
+	return e.updated, true
+}
+
+func (m templateSetGetter) RecordVersion(id entity.TemplateIdName, version string) {
+	m[id].version = version
+}
+
+func (m templateSetGetter) CleanUp() int { return 0 }
+
+func (m templateSetGetter) Len() int { return len(m) }
+
+func newTestStorage(t *testing.T) *MongoStorage {
+	t.Helper()
+
+	storage, err := provider.NewProviderStorage(zap.NewNop().Sugar(), &metrics.NoopMetrics{})
+	require.NoError(t, err)
+
+	templateLib, err := parser.NewTemplateLib(
+		&parser.Config{},
+		&metrics.NoopMetrics{},
+		storage,
+		i18n.NewStorage(&i18n.Config{}),
+		output.NewRegistry(),
+	)
+	require.NoError(t, err)
+
+	templates := newTemplates(newFakeCollection("template_view_id"))
+
+	return &MongoStorage{
+		config:         &MongoStorageConfig{LoggingConfig: &LoggingConfig{}},
+		templateLib:    templateLib,
+		lgr:            zap.NewNop(),
+		Templates:      templates,
+		templateList:   templates,
+		templateReload: templates,
+	}
+}
+
+func testTemplate(title string) []byte {
+	return []byte("kind: Template\nmetadata:\n  name: card\nspec:\n  title:\n    type: string\n    value: " + title + "\n")
+}
+
+func TestMongoStorage_Templates(t *testing.T) {
+	s := newTestStorage(t)
+	sg := templateSetGetter{}
+	ctx := context.Background()
+
+	require.NoError(t, s.SaveTemplate(ctx, "card", testTemplate("first"), 0))
+	require.NoError(t, s.SaveTemplate(ctx, "promo", testTemplate("promo"), 0))
+	require.NoError(t, s.UpdateTemplate(ctx, sg))
+
+	require.Len(t, sg, 2)
+	assert.Equal(t, "1", sg["card"].version)
+
+	// Saving replaces the content and bumps the version.
+	require.NoError(t, s.SaveTemplate(ctx, "card", testTemplate("second"), 1))
+	require.NoError(t, s.IncrementalUpdateTemplate(ctx, sg, "card"))
+
+	assert.Equal(t, "2", sg["card"].version)
+	assert.Equal(t, "second", sg["card"].instructions[0].Spec["title"].(map[string]any)["value"])
+
+	// A save based on an outdated version is rejected.
+	err := s.SaveTemplate(ctx, "card", testTemplate("third"), 1)
+	require.ErrorIs(t, err, ErrConflict)
+	require.NoError(t, s.IncrementalUpdateTemplate(ctx, sg, "card"))
+	assert.Equal(t, "second", sg["card"].instructions[0].Spec["title"].(map[string]any)["value"])
+
+	err = s.SaveTemplate(ctx, "new", testTemplate("new"), 1)
+	require.ErrorIs(t, err, ErrConflict, "A new template is not at the expected version")
+
+	err = s.IncrementalUpdateTemplate(ctx, sg, "unknown")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// Invalid templates are reported, the others are loaded.
+	insertRaw(t, s.Templates.coll, map[string]any{"template_view_id": "broken", "content": []byte("kind: [")})
+	delete(sg, "promo")
+
+	err = s.UpdateTemplate(ctx, sg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "parse template broken")
+	assert.Contains(t, sg, entity.TemplateIdName("promo"))
+}
+
+type clientConfigSetGetter map[string]any
+
+func (m clientConfigSetGetter) Set(k string, v any, _ time.Time) {
+	m[k] = v
+}
+
+func (m clientConfigSetGetter) Get(k string) (any, bool) {
+	v, ok := m[k]
+	return v, ok
+}
+
+func (m clientConfigSetGetter) LastUpdated(string) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (m clientConfigSetGetter) CleanUp() int { return 0 }
+
+func (m clientConfigSetGetter) Len() int { return len(m) }
+
+func TestMongoStorage_ClientConfigs(t *testing.T) {
+	configs := newClientConfigs(newFakeCollection("client_id"))
+	s := &MongoStorage{ClientConfigs: configs, clientConfigList: configs}
+	sg := clientConfigSetGetter{}
+	ctx := context.Background()
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+	require.NoError(t, configs.Save(ctx, &ClientConfigDocument{
+		ClientID: "mobile",
+		APIKeys:  []APIKey{{Hash: "abc", Name: "ci", ExpiresAt: &expiresAt}},
+	}))
+	require.NoError(t, configs.Save(ctx, &ClientConfigDocument{ClientID: "web"}))
+
+	require.NoError(t, s.UpdateClientConfig(ctx, sg))
+	require.Len(t, sg, 2)
+
+	mobile := sg["mobile"].(*ClientConfigDocument)
+	require.Len(t, mobile.APIKeys, 1)
+	assert.Equal(t, "abc", mobile.APIKeys[0].Hash)
+	assert.True(t, expiresAt.Equal(*mobile.APIKeys[0].ExpiresAt))
+
+	delete(sg, "web")
+	require.NoError(t, s.IncrementalUpdateClientConfig(ctx, sg, "web"))
+	assert.Contains(t, sg, "web")
+
+	require.ErrorIs(t, s.IncrementalUpdateClientConfig(ctx, sg, "unknown"), ErrNotFound)
+}
@@ -0,0 +1,103 @@
+package mongodb
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// Read operations with their own read preference in
+// mongo_storage.read_preferences. Others use mongo_storage.read_preference;
+// reads before a write, e.g. in SaveTemplate, always use the primary.
+const (
+	OpTemplateList     = "template_list"
+	OpTemplateReload   = "template_reload"
+	OpClientSpecList   = "client_spec_list"
+	OpClientConfigList = "client_config_list"
+)
+
+// minMaxStaleness is the smallest max staleness accepted by the server.
+const minMaxStaleness = 90 * time.Second
+
+var readOperations = map[string]struct{}{
+	OpTemplateList:     {},
+	OpTemplateReload:   {},
+	OpClientSpecList:   {},
+	OpClientConfigList: {},
+}
+
+// readPreference returns the read preference of op, the default one if op is
+// empty.
+func (c *MongoStorageConfig) readPreference(op string) (*readpref.ReadPref, error) {
+	mode := cmp.Or(c.ReadPreferences[op], c.ReadPreference)
+
+	var opts []readpref.Option
+	if c.MaxStaleness > 0 {
+		opts = append(opts, readpref.WithMaxStaleness(c.MaxStaleness))
+	}
+
+	switch mode {
+	case "secondary_preferred":
+		return readpref.SecondaryPreferred(opts...), nil
+	case "secondary":
+		return readpref.Secondary(opts...), nil
+	case "primary_preferred":
+		return readpref.PrimaryPreferred(opts...), nil
+	case "nearest":
+		return readpref.Nearest(opts...), nil
+	case "primary":
+		return readpref.Primary(), nil
+	default:
+		return nil, fmt.Errorf("unexpected read_preference value given: %s", mode)
+	}
+}
+
+func (c *MongoStorageConfig) validateReadPreferences() error {
+	if c.MaxStaleness > 0 && c.MaxStaleness < minMaxStaleness {
+		return fmt.Errorf("max_staleness must be at least %s", minMaxStaleness)
+	}
+
+	if _, err := c.readPreference(""); err != nil {
+		return err
+	}
+
+	for op := range c.ReadPreferences {
+		if _, ok := readOperations[op]; !ok {
+			return fmt.Errorf("unexpected read_preferences operation: %s", op)
+		}
+		if _, err := c.readPreference(op); err != nil {
+			return fmt.Errorf("read_preferences.%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// collection returns the collection name reading with the preference of op.
+func (s *MongoStorage) collection(name, op string, opts ...*options.CollectionOptions) *mongo.Collection {
+	// Validated by validateReadPreferences.
+	rp, _ := s.config.readPreference(op)
+	return s.db.Collection(name, append(opts, options.Collection().SetReadPreference(rp))...)
+}
+
+// CausalSession runs fn with a causally consistent session in its context:
+// reads in fn observe the writes made before them in fn, even when they are
+// served by a secondary. This holds for the admin collections and the template
+// reload, which write and read with the majority concern.
+func (s *MongoStorage) CausalSession(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.db == nil {
+		return fn(ctx)
+	}
+
+	return s.db.Client().UseSessionWithOptions(ctx,
+		options.Session().SetCausalConsistency(true),
+		func(sc mongo.SessionContext) error {
+			return fn(sc)
+		},
+	)
+}
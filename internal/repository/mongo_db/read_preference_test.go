@@ -0,0 +1,82 @@
+package mongodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestConfig_ReadPreference(t *testing.T) {
+	cfg := &MongoStorageConfig{
+		ReadPreference: "secondary",
+		ReadPreferences: map[string]string{
+			OpTemplateReload: "primary_preferred",
+			OpTemplateList:   "primary",
+		},
+		MaxStaleness: 2 * time.Minute,
+	}
+	require.NoError(t, cfg.validateReadPreferences())
+
+	tests := []struct {
+		op           string
+		mode         readpref.Mode
+		maxStaleness bool
+	}{
+		{op: "", mode: readpref.SecondaryMode, maxStaleness: true},
+		{op: OpClientConfigList, mode: readpref.SecondaryMode, maxStaleness: true},
+		{op: OpTemplateReload, mode: readpref.PrimaryPreferredMode, maxStaleness: true},
+		{op: OpTemplateList, mode: readpref.PrimaryMode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.op, func(t *testing.T) {
+			rp, err := cfg.readPreference(tt.op)
+			require.NoError(t, err)
+			assert.Equal(t, tt.mode, rp.Mode())
+
+			maxStaleness, ok := rp.MaxStaleness()
+			assert.Equal(t, tt.maxStaleness, ok)
+			if tt.maxStaleness {
+				assert.Equal(t, 2*time.Minute, maxStaleness)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateReadPreferences(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  MongoStorageConfig
+		err  string
+	}{
+		{
+			name: "unknown mode",
+			cfg:  MongoStorageConfig{ReadPreference: "any"},
+			err:  "unexpected read_preference value given: any",
+		},
+		{
+			name: "unknown operation",
+			cfg:  MongoStorageConfig{ReadPreference: "primary", ReadPreferences: map[string]string{"find": "primary"}},
+			err:  "unexpected read_preferences operation: find",
+		},
+		{
+			name: "unknown operation mode",
+			cfg:  MongoStorageConfig{ReadPreference: "primary", ReadPreferences: map[string]string{OpTemplateList: "any"}},
+			err:  "read_preferences.template_list",
+		},
+		{
+			name: "max staleness too small",
+			cfg:  MongoStorageConfig{ReadPreference: "secondary", MaxStaleness: 10 * time.Second},
+			err:  "max_staleness must be at least 1m30s",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, tt.cfg.validateReadPreferences(), tt.err)
+		})
+	}
+}
@@ -3,67 +3,209 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"item_compositiom_service/internal/entity"
 	localdb "item_compositiom_service/internal/repository/local_db"
 	mongodb "item_compositiom_service/internal/repository/mongo_db"
 	"item_compositiom_service/pkg/cache"
 	"item_compositiom_service/pkg/metrics"
 	"item_compositiom_service/pkg/parser"
+	"item_compositiom_service/pkg/tracer"
+	"strconv"
+	"sync/atomic"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/fx"
 	"go.uber.org/zap"
 )
 
 type TemplateRepository struct {
 	ms *mongodb.MongoStorage
-	ls *localdb.LocalStorage
 
-	cache *cache.Cache[entity.TemplateIdName, []parser.Instruction]
+	cache       *cache.Cache[entity.TemplateIdName, []parser.Instruction]
+	sources     *sourceSet
+	snapshot    *snapshot
+	templateLib *parser.TemplateLib
+	lgr         *zap.Logger
+
+	limiter *metrics.LabelLimiter
+	lookups *prometheus.CounterVec
+
+	ready atomic.Bool
 }
 
+// missLabel is the template label of lookups of unknown templates.
+const missLabel = "unknown"
+
 func NewTemplateRepository(
+	lf fx.Lifecycle,
 	logger *zap.SugaredLogger,
-	metrics metrics.MetricsRegistry,
+	metricsRegistry metrics.MetricsRegistry,
+	snapshotConfig *SnapshotConfig,
+	sourcesConfig SourcesConfig,
+	templateLib *parser.TemplateLib,
 	ms *mongodb.MongoStorage,
 	ls *localdb.LocalStorage,
-) *TemplateRepository {
-	cache := cache.New(
+) (*TemplateRepository, error) {
+	lgr := logger.Desugar().With(zap.String("component", "template_repository"))
+
+	r := &TemplateRepository{
+		ms:          ms,
+		snapshot:    newSnapshot(snapshotConfig, lgr),
+		templateLib: templateLib,
+		lgr:         lgr,
+		limiter:     metrics.Limiter(metricsRegistry),
+	}
+
+	sources, err := newSourceSet(sourcesConfig, lgr, templateLib, ms, ls)
+	if err != nil {
+		return nil, fmt.Errorf("create template sources: %w", err)
+	}
+	r.sources = sources
+
+	r.cache = cache.New(
 		logger,
-		metrics,
+		metricsRegistry,
 		func(ctx context.Context, sg cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) error {
-			if err := ms.UpdateTemplate(ctx, sg); err != nil {
-				if err2 := ls.UpdateTemplate(ctx, sg); err2 != nil {
-					return errors.Join(err, err2)
-				}
-
+			if err := sources.update(ctx, r.snapshot.recorder(sg)); err != nil {
 				return err
 			}
 
+			r.snapshot.markRefreshed(time.Now())
 			return nil
 		},
 		func(ctx context.Context, sg cache.SetGetter[entity.TemplateIdName, []parser.Instruction], key entity.TemplateIdName) error {
-			if err := ms.IncrementalUpdateTemplate(ctx, sg, key); err != nil {
-				if err2 := ls.IncrementalUpdateTemplate(ctx, sg, key); err2 != nil {
-					return errors.Join(err, err2)
-				}
+			return sources.updateOne(ctx, r.snapshot.recorder(sg), key)
+		},
+	)
 
-				return err
-			}
+	r.lookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "template_lookups_total",
+		Help: "Total number of template cache lookups",
+	}, []string{"template", "source", "result"})
 
-			return nil
+	if err := errors.Join(
+		metricsRegistry.GetRegistry().Register(r.lookups),
+		metricsRegistry.GetRegistry().Register(r.snapshot.stalenessGauge()),
+	); err != nil {
+		return nil, err
+	}
+
+	lf.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return r.Start(ctx)
+		},
+		OnStop: func(ctx context.Context) error {
+			return r.Close(ctx)
 		},
+	})
+
+	return r, nil
+}
+
+// Start restores the template snapshot, if any, and runs the first full
+// update. With a restored snapshot the service starts even if all template
+// storages are unavailable, and serves the last-known templates.
+func (r *TemplateRepository) Start(ctx context.Context) error {
+	restored, err := r.snapshot.restore(r.templateLib, r.cache)
+	if err != nil {
+		r.lgr.Error("Failed to restore template snapshot", zap.Error(err))
+	}
+	if restored > 0 {
+		r.lgr.Info("Template snapshot restored",
+			zap.Int("templates", restored),
+			zap.Time("refreshed_at", r.snapshot.RefreshedAt()),
+		)
+	}
+
+	if err := r.cache.Start(ctx); err != nil {
+		return err
+	}
+
+	r.snapshot.start(r.cache)
+	r.ready.Store(true)
+	return nil
+}
+
+// Check reports whether templates are loaded, from a storage or a snapshot.
+func (r *TemplateRepository) Check(_ context.Context) error {
+	if !r.ready.Load() {
+		return fmt.Errorf("templates are not loaded")
+	}
+
+	return nil
+}
+
+// Close writes the final snapshot and stops the background update.
+func (r *TemplateRepository) Close(ctx context.Context) error {
+	r.ready.Store(false)
+	return errors.Join(r.snapshot.close(r.cache), r.cache.Close(ctx))
+}
+
+func (r *TemplateRepository) GetTemplate(ctx context.Context, key entity.TemplateIdName) ([]parser.Instruction, bool) {
+	_, span := tracer.Start(ctx, "template.Lookup", tracer.TemplateID(string(key)))
+	defer span.End()
+
+	instructions, ok := r.cache.Get(key)
+	source, _ := r.sources.Source(key)
+	version, _ := r.sources.Version(key)
+	span.SetAttributes(
+		attribute.Bool("found", ok),
+		attribute.String("template.source", source),
+		attribute.String("template.version", version),
 	)
 
-	return &TemplateRepository{
-		ms:    ms,
-		ls:    ls,
-		cache: cache,
+	// Missed keys come from requests, not templates: they share one label
+	// value instead of taking the cardinality budget of the template label.
+	template, result := missLabel, "miss"
+	if ok {
+		template, result = r.limiter.Value("template", string(key)), "hit"
 	}
+	r.lookups.WithLabelValues(template, source, result).Inc()
+
+	return instructions, ok
+}
+
+// Version returns the version of key reported by its source, e.g. the commit
+// SHA for git sources.
+func (r *TemplateRepository) Version(key entity.TemplateIdName) (string, bool) {
+	return r.sources.Version(key)
 }
 
-func (r *TemplateRepository) GetTemplate(key entity.TemplateIdName) ([]parser.Instruction, bool) {
-	return r.cache.Get(key)
+// StoredVersion returns the Mongo document version of key if Mongo serves
+// it, to be passed to SaveTemplate as the expected version.
+func (r *TemplateRepository) StoredVersion(key entity.TemplateIdName) (int64, bool) {
+	src, version, ok := r.sources.lookup(key)
+	if !ok || r.ms == nil || src.source != TemplateSource(r.ms) {
+		return 0, false
+	}
+
+	v, err := strconv.ParseInt(version, 10, 64)
+	return v, err == nil
+}
+
+// Source returns the name of the template source serving key.
+func (r *TemplateRepository) Source(key entity.TemplateIdName) (string, bool) {
+	return r.sources.Source(key)
 }
 
 func (r *TemplateRepository) UpdateTemplate(ctx context.Context, key entity.TemplateIdName) {
 	r.cache.IncrementalUpdate(ctx, key)
 }
+
+// SaveTemplate stores template content in Mongo and reloads it into the cache.
+// Both run in one causally consistent session, so the reload sees the saved
+// content even if it reads from a secondary. A non-zero expectedVersion must
+// match the stored version.
+func (r *TemplateRepository) SaveTemplate(ctx context.Context, key entity.TemplateIdName, content []byte, expectedVersion int64) error {
+	return r.ms.CausalSession(ctx, func(ctx context.Context) error {
+		if err := r.ms.SaveTemplate(ctx, key, content, expectedVersion); err != nil {
+			return err
+		}
+
+		r.cache.IncrementalUpdate(ctx, key)
+		return nil
+	})
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"item_compositiom_service/internal/entity"
+	mongodb "item_compositiom_service/internal/repository/mongo_db"
+	"item_compositiom_service/pkg/cache"
+	"item_compositiom_service/pkg/metrics"
+	"item_compositiom_service/pkg/parser"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestTemplateRepository_GetTemplateLookups(t *testing.T) {
+	noop := func(context.Context, cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) error { return nil }
+
+	r := &TemplateRepository{
+		cache:   cache.New(zap.NewNop().Sugar(), &metrics.NoopMetrics{}, noop, nil),
+		sources: newTestSourceSet(),
+		limiter: metrics.Limiter(&metrics.NoopMetrics{}),
+		lookups: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "template_lookups_total"}, []string{"template", "source", "result"}),
+	}
+	r.cache.Set("feed", []parser.Instruction{{Kind: "View"}})
+
+	_, ok := r.GetTemplate(context.Background(), "feed")
+	assert.True(t, ok)
+
+	for i := range 200 {
+		_, ok := r.GetTemplate(context.Background(), entity.TemplateIdName(fmt.Sprintf("missing-%d", i)))
+		assert.False(t, ok)
+	}
+
+	assert.Equal(t, 2, testutil.CollectAndCount(r.lookups), "Misses share one series")
+	assert.Equal(t, 200.0, testutil.ToFloat64(r.lookups.WithLabelValues(missLabel, "", "miss")))
+	assert.Equal(t, "story", r.limiter.Value("template", "story"), "Misses do not use up the template label values")
+}
+
+func TestTemplateRepository_StoredVersion(t *testing.T) {
+	ms := &mongodb.MongoStorage{}
+	mongo := &templateSource{name: "mongo", policy: PolicyFirstWins, source: ms}
+	git := &templateSource{name: "git", policy: PolicyFirstWins, source: &fakeSource{}}
+
+	r := &TemplateRepository{ms: ms, sources: newTestSourceSet(mongo, git)}
+	r.sources.served["card"], r.sources.versions["card"] = mongo, "3"
+	r.sources.served["promo"], r.sources.versions["promo"] = git, "5"
+
+	version, ok := r.StoredVersion("card")
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), version)
+
+	_, ok = r.StoredVersion("promo")
+	assert.False(t, ok, "Versions of other sources are not Mongo versions")
+
+	_, ok = r.StoredVersion("missing")
+	assert.False(t, ok)
+}
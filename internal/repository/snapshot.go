@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"item_compositiom_service/internal/entity"
+	"item_compositiom_service/pkg/cache"
+	"item_compositiom_service/pkg/parser"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+type snapshotFile struct {
+	// RefreshedAt is the time of the last successful update from a live
+	// source; restored templates are as stale as it is old.
+	RefreshedAt time.Time          `json:"refreshed_at"`
+	Templates   []snapshotTemplate `json:"templates"`
+}
+
+type snapshotTemplate struct {
+	ID      entity.TemplateIdName `json:"id"`
+	Content []byte                `json:"content"`
+	// Dependencies are parsed before Content, e.g. the catalogs and provider
+	// specs of a bundle; their instructions precede the ones of the template.
+	Dependencies [][]byte  `json:"dependencies,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// snapshot keeps the raw content of cached templates, with the documents they
+// depend on, and periodically writes it to a local file. Raw content is stored
+// rather than parsed instructions, because parsing also registers the
+// providers, catalogs and protos declared in a template.
+type snapshot struct {
+	cfg *SnapshotConfig
+	lgr *zap.Logger
+
+	mu          sync.Mutex
+	templates   map[entity.TemplateIdName]snapshotTemplate
+	refreshedAt time.Time
+	dirty       bool
+
+	closed chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newSnapshot(cfg *SnapshotConfig, lgr *zap.Logger) *snapshot {
+	if cfg == nil {
+		cfg = &SnapshotConfig{}
+	}
+
+	return &snapshot{
+		cfg:       cfg,
+		lgr:       lgr,
+		templates: make(map[entity.TemplateIdName]snapshotTemplate),
+		closed:    make(chan struct{}),
+	}
+}
+
+func (s *snapshot) RecordContent(id entity.TemplateIdName, content []byte, updatedAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.templates[id] = snapshotTemplate{ID: id, Content: content, UpdatedAt: updatedAt}
+	s.dirty = true
+}
+
+// RecordDependencies records the dependencies of a template whose content was
+// recorded.
+func (s *snapshot) RecordDependencies(id entity.TemplateIdName, dependencies [][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.templates[id]
+	if !ok {
+		return
+	}
+	t.Dependencies = dependencies
+	s.templates[id] = t
+	s.dirty = true
+}
+
+func (s *snapshot) markRefreshed(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshedAt = t
+	s.dirty = true
+}
+
+// RefreshedAt returns the time of the last update from a live source, zero if
+// there was none.
+func (s *snapshot) RefreshedAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.refreshedAt
+}
+
+// stalenessGauge reports the seconds since the last update from a live
+// source, 0 before the first one.
+func (s *snapshot) stalenessGauge() prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "template_cache_staleness_seconds",
+		Help: "Seconds since templates were last updated from a live source, 0 before the first update",
+	}, func() float64 {
+		refreshedAt := s.RefreshedAt()
+		if refreshedAt.IsZero() {
+			return 0
+		}
+		return time.Since(refreshedAt).Seconds()
+	})
+}
+
+// recorder wraps sg so that storages record raw content next to parsed
+// templates.
+func (s *snapshot) recorder(sg cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) cache.SetGetter[entity.TemplateIdName, []parser.Instruction] {
+	return recordingSetGetter{SetGetter: sg, snapshot: s}
+}
+
+type recordingSetGetter struct {
+	cache.SetGetter[entity.TemplateIdName, []parser.Instruction]
+	snapshot *snapshot
+}
+
+func (r recordingSetGetter) SetBatch(values map[entity.TemplateIdName][]parser.Instruction, deleted []entity.TemplateIdName, updateTime time.Time) {
+	cache.SetBatch(r.SetGetter, values, deleted, updateTime)
+}
+
+func (r recordingSetGetter) Delete(id entity.TemplateIdName) {
+	cache.Delete(r.SetGetter, id)
+}
+
+func (r recordingSetGetter) RecordContent(id entity.TemplateIdName, content []byte, updatedAt time.Time) {
+	r.snapshot.RecordContent(id, content, updatedAt)
+}
+
+func (r recordingSetGetter) RecordDependencies(id entity.TemplateIdName, dependencies [][]byte) {
+	r.snapshot.RecordDependencies(id, dependencies)
+}
+
+// restore parses the snapshot file into c. A missing file is not an error.
+func (s *snapshot) restore(templateLib *parser.TemplateLib, c *cache.Cache[entity.TemplateIdName, []parser.Instruction]) (int, error) {
+	if !s.cfg.Enabled {
+		return 0, nil
+	}
+
+	data, err := os.ReadFile(s.cfg.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read template snapshot: %w", err)
+	}
+
+	var file snapshotFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return 0, fmt.Errorf("decode template snapshot: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	restored := 0
+	var errs []error
+
+	for _, t := range file.Templates {
+		instructions, err := restoreTemplate(templateLib, t)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("parse template %s: %w", t.ID, err))
+			continue
+		}
+
+		c.Restore(t.ID, instructions, t.UpdatedAt)
+		s.templates[t.ID] = t
+		restored++
+	}
+
+	s.refreshedAt = file.RefreshedAt
+	return restored, errors.Join(errs...)
+}
+
+// restoreTemplate parses the dependencies and the content of t. The providers
+// and protos they declare are registered only if all of them parse.
+func restoreTemplate(templateLib *parser.TemplateLib, t snapshotTemplate) ([]parser.Instruction, error) {
+	stage, err := templateLib.Stage()
+	if err != nil {
+		return nil, err
+	}
+	defer stage.Discard()
+
+	var instructions []parser.Instruction
+	for _, data := range append(slices.Clip(t.Dependencies), t.Content) {
+		parsed, err := stage.ParseTemplate(data)
+		if err != nil {
+			return nil, err
+		}
+		instructions = append(instructions, parsed...)
+	}
+
+	stage.Commit()
+	return instructions, nil
+}
+
+// write atomically replaces the snapshot file with templates still present
+// in c, if anything changed since the previous write.
+func (s *snapshot) write(c *cache.Cache[entity.TemplateIdName, []parser.Instruction]) error {
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+
+	file := snapshotFile{RefreshedAt: s.refreshedAt}
+	for id, t := range s.templates {
+		if _, ok := c.LastUpdated(id); !ok {
+			delete(s.templates, id)
+			continue
+		}
+		file.Templates = append(file.Templates, t)
+	}
+	s.dirty = false
+	s.mu.Unlock()
+
+	sort.Slice(file.Templates, func(i, j int) bool {
+		return file.Templates[i].ID < file.Templates[j].ID
+	})
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("encode template snapshot: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.cfg.Path), 0755); err != nil {
+		return fmt.Errorf("create template snapshot dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.cfg.Path), filepath.Base(s.cfg.Path)+".*")
+	if err != nil {
+		return fmt.Errorf("create template snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// CreateTemp creates the file with mode 0600 and the rename keeps it.
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write template snapshot: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write template snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write template snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.cfg.Path); err != nil {
+		return fmt.Errorf("replace template snapshot: %w", err)
+	}
+
+	return nil
+}
+
+func (s *snapshot) start(c *cache.Cache[entity.TemplateIdName, []parser.Instruction]) {
+	if !s.cfg.Enabled || s.cfg.Interval <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		t := time.NewTicker(s.cfg.Interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				if err := s.write(c); err != nil {
+					s.lgr.Error("Failed to write template snapshot", zap.Error(err))
+				}
+			case <-s.closed:
+				return
+			}
+		}
+	}()
+}
+
+// close stops the writer and writes the final snapshot.
+func (s *snapshot) close(c *cache.Cache[entity.TemplateIdName, []parser.Instruction]) error {
+	close(s.closed)
+	s.wg.Wait()
+
+	if !s.cfg.Enabled {
+		return nil
+	}
+
+	return s.write(c)
+}
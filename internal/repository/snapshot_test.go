@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"item_compositiom_service/internal/entity"
+	"item_compositiom_service/pkg/bundle"
+	"item_compositiom_service/pkg/cache"
+	"item_compositiom_service/pkg/i18n"
+	"item_compositiom_service/pkg/metrics"
+	"item_compositiom_service/pkg/output"
+	"item_compositiom_service/pkg/parser"
+	"item_compositiom_service/pkg/provider"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSnapshot_WriteFileMode(t *testing.T) {
+	noop := func(context.Context, cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) error { return nil }
+	c := cache.New(zap.NewNop().Sugar(), &metrics.NoopMetrics{}, noop, nil)
+	c.Set("feed", []parser.Instruction{{Kind: "View"}})
+
+	path := filepath.Join(t.TempDir(), "templates.json")
+	s := newSnapshot(&SnapshotConfig{Enabled: true, Path: path}, zap.NewNop())
+	s.RecordContent("feed", []byte("kind: View"), time.Now())
+
+	require.NoError(t, s.write(c))
+
+	// os.CreateTemp creates the file with mode 0600, which the rename would
+	// keep; the snapshot is readable by other users like any config file.
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for file, content := range map[string]string{
+		"templates/card.yaml": "kind: View\nmetadata:\n  name: view\nspec:\n  template:\n    templates: [card]\n---\n" +
+			"kind: Template\nmetadata:\n  name: card\nspec:\n  title:\n    type: string\n    value: '{{t \"title\"}}'\n",
+		"locales/en.yaml": "kind: Messages\nmetadata:\n  locale: en\nspec:\n  messages:\n    title: Reactions\n",
+		"providers/reaction.yaml": "version: v1\nkind: ProviderGRPC\nmetadata:\n  name: reaction\nspec:\n  transport:\n    address: localhost:3000\n" +
+			"    timeout: 1s\n  payload:\n    headers:\n      x-app-name: test\n  methods:\n    - package: reaction.internal\n" +
+			"      service: ReactionInternalService\n      method: GetReactionCounters\n      type: DomainBatch\n      timeout: 1s\n" +
+			"      request:\n        domain_ids: item.id\n      response:\n        itemId: items.domain_id\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte(content), 0644))
+	}
+
+	bundlePath := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	f, err := os.Create(bundlePath)
+	require.NoError(t, err)
+	_, err = bundle.Build(dir, "v1", f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	noop := func(context.Context, cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) error { return nil }
+	path := filepath.Join(t.TempDir(), "templates.json")
+
+	s := newSnapshot(&SnapshotConfig{Enabled: true, Path: path}, zap.NewNop())
+	src := newBundleSource(newTestTemplateLib(t), fileBundle(bundlePath))
+	c := cache.New(zap.NewNop().Sugar(), &metrics.NoopMetrics{}, func(ctx context.Context, sg cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) error {
+		return src.UpdateTemplate(ctx, s.recorder(sg))
+	}, nil)
+	require.NoError(t, c.Start(context.Background()))
+	t.Cleanup(func() { _ = c.Close(context.Background()) })
+
+	refreshedAt := time.Now().Add(-time.Hour)
+	s.markRefreshed(refreshedAt)
+	require.NoError(t, s.write(c))
+
+	storage, err := provider.NewProviderStorage(zap.NewNop().Sugar(), &metrics.NoopMetrics{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = storage.Close() })
+	templateLib, err := parser.NewTemplateLib(&parser.Config{}, &metrics.NoopMetrics{}, storage, i18n.NewStorage(&i18n.Config{}), output.NewRegistry())
+	require.NoError(t, err)
+
+	restoredCache := cache.New(zap.NewNop().Sugar(), &metrics.NoopMetrics{}, noop, nil)
+	restored := newSnapshot(&SnapshotConfig{Enabled: true, Path: path}, zap.NewNop())
+	gauge := restored.stalenessGauge()
+	assert.Zero(t, testutil.ToFloat64(gauge), "No update before the restore")
+
+	n, err := restored.restore(templateLib, restoredCache)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.True(t, refreshedAt.Equal(restored.RefreshedAt()))
+	assert.InDelta(t, time.Hour.Seconds(), testutil.ToFloat64(gauge), 60, "Restored templates are as stale as the snapshot")
+
+	_, err = storage.GetProvider("reaction")
+	assert.NoError(t, err, "Bundle providers are restored with its templates")
+
+	instructions, ok := restoredCache.Get("card")
+	require.True(t, ok)
+	ctx := i18n.WithLocale(context.Background(), "en")
+	res, err := templateLib.AdjustTemplate(ctx, map[string]any{}, instructions)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"title": "Reactions"}`, string(res), "Bundle catalogs are restored with its templates")
+}
+
+func TestSnapshot_DropsEvictedTemplates(t *testing.T) {
+	noop := func(context.Context, cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) error { return nil }
+	remove := func(_ context.Context, sg cache.SetGetter[entity.TemplateIdName, []parser.Instruction], id entity.TemplateIdName) error {
+		cache.Delete(sg, id)
+		return nil
+	}
+	c := cache.New(zap.NewNop().Sugar(), &metrics.NoopMetrics{}, noop, remove)
+
+	path := filepath.Join(t.TempDir(), "templates.json")
+	s := newSnapshot(&SnapshotConfig{Enabled: true, Path: path}, zap.NewNop())
+	for _, id := range []entity.TemplateIdName{"card", "promo"} {
+		c.Set(id, []parser.Instruction{{Kind: "Template"}})
+		s.RecordContent(id, []byte(gitTemplate(string(id))), time.Now())
+	}
+	require.NoError(t, s.write(c))
+	assert.Equal(t, 2, restoreCount(t, path))
+
+	c.IncrementalUpdate(context.Background(), "promo")
+	s.markRefreshed(time.Now())
+	require.NoError(t, s.write(c))
+	assert.Equal(t, 1, restoreCount(t, path), "Templates that left the cache are dropped")
+}
+
+// restoreCount restores the snapshot at path into an empty cache.
+func restoreCount(t *testing.T, path string) int {
+	t.Helper()
+
+	noop := func(context.Context, cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) error { return nil }
+	c := cache.New(zap.NewNop().Sugar(), &metrics.NoopMetrics{}, noop, nil)
+	n, err := newSnapshot(&SnapshotConfig{Enabled: true, Path: path}, zap.NewNop()).restore(newTestTemplateLib(t), c)
+	require.NoError(t, err)
+	return n
+}
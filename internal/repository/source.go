@@ -0,0 +1,417 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"item_compositiom_service/internal/entity"
+	"item_compositiom_service/internal/repository/defaults"
+	localdb "item_compositiom_service/internal/repository/local_db"
+	mongodb "item_compositiom_service/internal/repository/mongo_db"
+	"item_compositiom_service/pkg/cache"
+	"item_compositiom_service/pkg/parser"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultBundleTimeout = 10 * time.Second
+
+// TemplateSource loads templates into the template cache.
+type TemplateSource interface {
+	UpdateTemplate(ctx context.Context, setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) error
+	IncrementalUpdateTemplate(ctx context.Context, setGetter cache.SetGetter[entity.TemplateIdName, []parser.Instruction], id entity.TemplateIdName) error
+}
+
+var defaultSources = SourcesConfig{
+	{Type: SourceMongo, Priority: 20, Policy: PolicyFirstWins},
+	{Type: SourceLocal, Priority: 10, Policy: PolicyFirstWins},
+}
+
+type templateSource struct {
+	name     string
+	policy   string
+	priority int
+	source   TemplateSource
+}
+
+// rank orders sources: override sources win over first-wins ones, which win
+// over fallback-only ones; within a policy the higher priority wins.
+func (s *templateSource) rank() [2]int {
+	class := map[string]int{PolicyFallbackOnly: 0, PolicyFirstWins: 1, PolicyOverride: 2}[s.policy]
+	return [2]int{class, s.priority}
+}
+
+func (s *templateSource) outranks(other *templateSource) bool {
+	a, b := s.rank(), other.rank()
+	return a[0] > b[0] || a[0] == b[0] && a[1] > b[1]
+}
+
+// sourceSet resolves every template to the highest ranked source that has
+// it. Sources write to the cache through views that drop templates held by a
+// higher ranked source.
+type sourceSet struct {
+	sources []*templateSource
+	lgr     *zap.Logger
+
+	mu          sync.Mutex
+	served      map[entity.TemplateIdName]*templateSource
+	versions    map[entity.TemplateIdName]string
+	lastUpdated map[sourceKey]time.Time
+}
+
+type sourceKey struct {
+	source string
+	id     entity.TemplateIdName
+}
+
+func newSourceSet(
+	cfg SourcesConfig,
+	lgr *zap.Logger,
+	templateLib *parser.TemplateLib,
+	ms *mongodb.MongoStorage,
+	ls *localdb.LocalStorage,
+) (*sourceSet, error) {
+	if len(cfg) == 0 {
+		cfg = defaultSources
+	}
+
+	set := &sourceSet{
+		lgr:         lgr,
+		served:      make(map[entity.TemplateIdName]*templateSource),
+		versions:    make(map[entity.TemplateIdName]string),
+		lastUpdated: make(map[sourceKey]time.Time),
+	}
+
+	names := make(map[string]bool, len(cfg))
+	for i, c := range cfg {
+		src := &templateSource{
+			name:     cmp.Or(c.Name, c.Type),
+			policy:   cmp.Or(c.Policy, PolicyFirstWins),
+			priority: c.Priority,
+		}
+
+		if names[src.name] {
+			return nil, fmt.Errorf("template_sources[%d]: duplicate name %s", i, src.name)
+		}
+		names[src.name] = true
+
+		if !slices.Contains([]string{PolicyFirstWins, PolicyOverride, PolicyFallbackOnly}, src.policy) {
+			return nil, fmt.Errorf("template_sources[%d]: unknown policy %s", i, src.policy)
+		}
+
+		switch c.Type {
+		case SourceMongo:
+			src.source = ms
+		case SourceLocal:
+			src.source = ls
+		case SourceEmbedded:
+			fsys, err := fs.Sub(defaults.FS, "templates")
+			if err != nil {
+				return nil, fmt.Errorf("template_sources[%d]: %w", i, err)
+			}
+			src.source = newFSSource(templateLib, fsys)
+		case SourceHTTPBundle:
+			if c.URL == "" {
+				return nil, fmt.Errorf("template_sources[%d]: url is required", i)
+			}
+			src.source = newBundleSource(templateLib, httpBundle(c.URL, cmp.Or(c.Timeout, defaultBundleTimeout)))
+		case SourceBundle:
+			if c.Path == "" {
+				return nil, fmt.Errorf("template_sources[%d]: path is required", i)
+			}
+			src.source = newBundleSource(templateLib, fileBundle(c.Path))
+		case SourceGit:
+			gs, err := newGitSource(templateLib, c)
+			if err != nil {
+				return nil, fmt.Errorf("template_sources[%d]: %w", i, err)
+			}
+			src.source = gs
+		default:
+			return nil, fmt.Errorf("template_sources[%d]: unknown type %s", i, c.Type)
+		}
+
+		set.sources = append(set.sources, src)
+	}
+
+	set.sort()
+	return set, nil
+}
+
+func (s *sourceSet) sort() {
+	slices.SortStableFunc(s.sources, func(a, b *templateSource) int {
+		switch {
+		case a.outranks(b):
+			return -1
+		case b.outranks(a):
+			return 1
+		}
+		return 0
+	})
+}
+
+// update runs a full update of every source. Fallback-only sources are
+// updated only if all other sources failed. It fails only if no source
+// succeeded. After a successful update of a source, the templates it no
+// longer lists are released, so that the following sources can serve them.
+func (s *sourceSet) update(ctx context.Context, sg cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) error {
+	return s.each(func(src *templateSource) (bool, error) {
+		view := s.view(src, sg)
+		view.seen = make(map[entity.TemplateIdName]struct{})
+
+		if err := src.source.UpdateTemplate(ctx, view); err != nil {
+			return false, err
+		}
+
+		s.release(view)
+		return false, nil
+	})
+}
+
+// release forgets the templates the source of v did not list in the update
+// and the ones that left the cache. Templates it still served are dropped from
+// the cache.
+func (s *sourceSet) release(v *sourceView) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.lastUpdated {
+		if _, listed := v.seen[key.id]; key.source == v.src.name && !listed {
+			delete(s.lastUpdated, key)
+		}
+	}
+
+	for id, src := range s.served {
+		if src != v.src {
+			continue
+		}
+
+		_, listed := v.seen[id]
+		_, cached := v.SetGetter.LastUpdated(id)
+		if listed && cached {
+			continue
+		}
+
+		s.forget(id, src)
+		if !listed && cached {
+			cache.Delete(v.SetGetter, id)
+		}
+	}
+}
+
+// forget stops serving id from src. It is called with mu held.
+func (s *sourceSet) forget(id entity.TemplateIdName, src *templateSource) {
+	delete(s.served, id)
+	delete(s.versions, id)
+
+	s.lgr.Info("Template released by source",
+		zap.String("template_id", string(id)),
+		zap.String("source", src.name),
+	)
+}
+
+// updateOne loads id from the highest ranked source that has it.
+func (s *sourceSet) updateOne(ctx context.Context, sg cache.SetGetter[entity.TemplateIdName, []parser.Instruction], id entity.TemplateIdName) error {
+	return s.each(func(src *templateSource) (bool, error) {
+		err := src.source.IncrementalUpdateTemplate(ctx, s.view(src, sg), id)
+		return err == nil, err
+	})
+}
+
+// each calls fn for the sources in rank order until it returns stop.
+func (s *sourceSet) each(fn func(src *templateSource) (stop bool, err error)) error {
+	var errs []error
+	succeeded := false
+
+	for _, src := range s.sources {
+		if src.policy == PolicyFallbackOnly && succeeded {
+			break
+		}
+
+		stop, err := fn(src)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("template source %s: %w", src.name, err))
+			continue
+		}
+
+		succeeded = true
+		if stop {
+			break
+		}
+	}
+
+	if !succeeded {
+		return errors.Join(errs...)
+	}
+
+	if len(errs) > 0 {
+		s.lgr.Warn("Some template sources failed", zap.Error(errors.Join(errs...)))
+	}
+
+	return nil
+}
+
+// Version returns the version of id reported by its source, if any.
+func (s *sourceSet) Version(id entity.TemplateIdName) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	version, ok := s.versions[id]
+	return version, ok
+}
+
+// lookup returns the source serving id and the version it reported.
+func (s *sourceSet) lookup(id entity.TemplateIdName) (*templateSource, string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, ok := s.served[id]
+	return src, s.versions[id], ok
+}
+
+// Source returns the name of the source serving id.
+func (s *sourceSet) Source(id entity.TemplateIdName) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, ok := s.served[id]
+	if !ok {
+		return "", false
+	}
+
+	return src.name, true
+}
+
+func (s *sourceSet) view(src *templateSource, sg cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) *sourceView {
+	return &sourceView{SetGetter: sg, set: s, src: src}
+}
+
+// sourceView is the cache as seen by one source: LastUpdated reports when the
+// source itself last stored a template, and Set is dropped for templates
+// served by a higher ranked source. During a full update it records the
+// templates the source lists, i.e. checks or stores.
+type sourceView struct {
+	cache.SetGetter[entity.TemplateIdName, []parser.Instruction]
+	set  *sourceSet
+	src  *templateSource
+	seen map[entity.TemplateIdName]struct{}
+}
+
+func (v *sourceView) Set(id entity.TemplateIdName, instructions []parser.Instruction, updateTime time.Time) {
+	v.set.mu.Lock()
+	defer v.set.mu.Unlock()
+
+	if v.accept(id, updateTime) {
+		v.SetGetter.Set(id, instructions, updateTime)
+	}
+}
+
+// SetBatch stores the accepted templates and deletes the ones the source
+// served and no longer has at once.
+func (v *sourceView) SetBatch(values map[entity.TemplateIdName][]parser.Instruction, deleted []entity.TemplateIdName, updateTime time.Time) {
+	v.set.mu.Lock()
+	defer v.set.mu.Unlock()
+
+	accepted := make(map[entity.TemplateIdName][]parser.Instruction, len(values))
+	for id, instructions := range values {
+		if v.accept(id, updateTime) {
+			accepted[id] = instructions
+		}
+	}
+
+	var released []entity.TemplateIdName
+	for _, id := range deleted {
+		if _, ok := values[id]; ok {
+			continue
+		}
+
+		delete(v.set.lastUpdated, sourceKey{v.src.name, id})
+		if v.set.served[id] == v.src {
+			v.set.forget(id, v.src)
+			released = append(released, id)
+		}
+	}
+
+	cache.SetBatch(v.SetGetter, accepted, released, updateTime)
+}
+
+// accept records the update and reports whether the source serves id. It is
+// called with set.mu held.
+func (v *sourceView) accept(id entity.TemplateIdName, updateTime time.Time) bool {
+	v.see(id)
+	v.set.lastUpdated[sourceKey{v.src.name, id}] = updateTime
+
+	current, ok := v.set.served[id]
+	if ok && current != v.src && current.outranks(v.src) {
+		return false
+	}
+
+	if ok && current != v.src {
+		delete(v.set.versions, id)
+		v.set.lgr.Info("Template source changed",
+			zap.String("template_id", string(id)),
+			zap.String("from", current.name),
+			zap.String("to", v.src.name),
+		)
+	}
+	v.set.served[id] = v.src
+
+	return true
+}
+
+// LastUpdated is zero once the template left the cache, so that the source
+// loads it again.
+func (v *sourceView) LastUpdated(id entity.TemplateIdName) (time.Time, bool) {
+	_, cached := v.SetGetter.LastUpdated(id)
+
+	v.set.mu.Lock()
+	defer v.set.mu.Unlock()
+
+	v.see(id)
+	if !cached {
+		return time.Time{}, false
+	}
+
+	t, ok := v.set.lastUpdated[sourceKey{v.src.name, id}]
+	return t, ok
+}
+
+// see records that the source lists id. It is called with set.mu held.
+func (v *sourceView) see(id entity.TemplateIdName) {
+	if v.seen != nil {
+		v.seen[id] = struct{}{}
+	}
+}
+
+func (v *sourceView) RecordContent(id entity.TemplateIdName, content []byte, updatedAt time.Time) {
+	v.set.mu.Lock()
+	served := v.set.served[id] == v.src
+	v.set.mu.Unlock()
+
+	if served {
+		entity.RecordContent(v.SetGetter, id, content, updatedAt)
+	}
+}
+
+func (v *sourceView) RecordDependencies(id entity.TemplateIdName, dependencies [][]byte) {
+	v.set.mu.Lock()
+	served := v.set.served[id] == v.src
+	v.set.mu.Unlock()
+
+	if served {
+		entity.RecordDependencies(v.SetGetter, id, dependencies)
+	}
+}
+
+func (v *sourceView) RecordVersion(id entity.TemplateIdName, version string) {
+	v.set.mu.Lock()
+	defer v.set.mu.Unlock()
+
+	if v.set.served[id] == v.src {
+		v.set.versions[id] = version
+	}
+}
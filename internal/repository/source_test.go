@@ -0,0 +1,271 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"item_compositiom_service/internal/entity"
+	"item_compositiom_service/pkg/cache"
+	"item_compositiom_service/pkg/parser"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeSource struct {
+	name      string // stored as Instruction.Kind to tell sources apart
+	templates []entity.TemplateIdName
+	err       error
+	calls     int
+}
+
+func (s *fakeSource) UpdateTemplate(_ context.Context, sg cache.SetGetter[entity.TemplateIdName, []parser.Instruction]) error {
+	s.calls++
+	if s.err != nil {
+		return s.err
+	}
+
+	for _, id := range s.templates {
+		sg.Set(id, []parser.Instruction{{Kind: s.name}}, time.Now())
+	}
+	return nil
+}
+
+func (s *fakeSource) IncrementalUpdateTemplate(_ context.Context, sg cache.SetGetter[entity.TemplateIdName, []parser.Instruction], id entity.TemplateIdName) error {
+	s.calls++
+	if s.err != nil {
+		return s.err
+	}
+
+	for _, t := range s.templates {
+		if t == id {
+			sg.Set(id, []parser.Instruction{{Kind: s.name}}, time.Now())
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+func newTestSourceSet(sources ...*templateSource) *sourceSet {
+	set := &sourceSet{
+		lgr:         zap.NewNop(),
+		served:      make(map[entity.TemplateIdName]*templateSource),
+		versions:    make(map[entity.TemplateIdName]string),
+		lastUpdated: make(map[sourceKey]time.Time),
+	}
+	set.sources = append(set.sources, sources...)
+	return set
+}
+
+func TestSourceSet_Resolve(t *testing.T) {
+	errDown := errors.New("down")
+
+	tests := []struct {
+		name    string
+		sources []SourceConfig
+		fakes   map[string]*fakeSource
+		want    map[entity.TemplateIdName]string
+		wantErr bool
+	}{
+		{
+			name: "first wins by priority, lower source fills gaps",
+			sources: []SourceConfig{
+				{Name: "local", Priority: 10},
+				{Name: "mongo", Priority: 20},
+			},
+			fakes: map[string]*fakeSource{
+				"mongo": {templates: []entity.TemplateIdName{"a"}},
+				"local": {templates: []entity.TemplateIdName{"a", "b"}},
+			},
+			want: map[entity.TemplateIdName]string{"a": "mongo", "b": "local"},
+		},
+		{
+			name: "override beats higher priority",
+			sources: []SourceConfig{
+				{Name: "mongo", Priority: 20},
+				{Name: "local", Priority: 10, Policy: PolicyOverride},
+			},
+			fakes: map[string]*fakeSource{
+				"mongo": {templates: []entity.TemplateIdName{"a", "b"}},
+				"local": {templates: []entity.TemplateIdName{"a"}},
+			},
+			want: map[entity.TemplateIdName]string{"a": "local", "b": "mongo"},
+		},
+		{
+			name: "fallback is skipped while another source works",
+			sources: []SourceConfig{
+				{Name: "mongo", Priority: 20},
+				{Name: "embedded", Priority: 100, Policy: PolicyFallbackOnly},
+			},
+			fakes: map[string]*fakeSource{
+				"mongo":    {templates: []entity.TemplateIdName{"a"}},
+				"embedded": {templates: []entity.TemplateIdName{"a", "b"}},
+			},
+			want: map[entity.TemplateIdName]string{"a": "mongo"},
+		},
+		{
+			name: "fallback serves when all other sources fail",
+			sources: []SourceConfig{
+				{Name: "mongo", Priority: 20},
+				{Name: "embedded", Policy: PolicyFallbackOnly},
+			},
+			fakes: map[string]*fakeSource{
+				"mongo":    {err: errDown},
+				"embedded": {templates: []entity.TemplateIdName{"a"}},
+			},
+			want: map[entity.TemplateIdName]string{"a": "embedded"},
+		},
+		{
+			name: "all sources fail",
+			sources: []SourceConfig{
+				{Name: "mongo", Priority: 20},
+				{Name: "local", Priority: 10},
+			},
+			fakes: map[string]*fakeSource{
+				"mongo": {err: errDown},
+				"local": {err: errDown},
+			},
+			want:    map[entity.TemplateIdName]string{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sources []*templateSource
+			for _, c := range tt.sources {
+				tt.fakes[c.Name].name = c.Name
+				sources = append(sources, &templateSource{
+					name:     c.Name,
+					policy:   cmp.Or(c.Policy, PolicyFirstWins),
+					priority: c.Priority,
+					source:   tt.fakes[c.Name],
+				})
+			}
+			set := newTestSourceSet(sources...)
+			set.sort()
+
+			sg := mapSetGetter{}
+
+			err := set.update(context.Background(), sg)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			for id, source := range tt.want {
+				got, ok := set.Source(id)
+				assert.True(t, ok, id)
+				assert.Equal(t, source, got, id)
+
+				instructions, ok := sg.Get(id)
+				require.True(t, ok, id)
+				assert.Equal(t, source, instructions[0].Kind, id)
+			}
+			assert.Len(t, sg, len(tt.want))
+		})
+	}
+}
+
+func TestSourceSet_UpdateOne(t *testing.T) {
+	mongo := &fakeSource{name: "mongo", templates: []entity.TemplateIdName{"a"}}
+	local := &fakeSource{name: "local", templates: []entity.TemplateIdName{"a", "b"}}
+	embedded := &fakeSource{name: "embedded", templates: []entity.TemplateIdName{"c"}}
+
+	set := newTestSourceSet(
+		&templateSource{name: "embedded", policy: PolicyFallbackOnly, source: embedded},
+		&templateSource{name: "local", policy: PolicyFirstWins, priority: 10, source: local},
+		&templateSource{name: "mongo", policy: PolicyFirstWins, priority: 20, source: mongo},
+	)
+	set.sort()
+
+	sg := mapSetGetter{}
+	ctx := context.Background()
+
+	// The first source having the template serves it; later ones are not asked.
+	require.NoError(t, set.updateOne(ctx, sg, "a"))
+	assert.Equal(t, "mongo", sg["a"].value[0].Kind)
+	assert.Equal(t, 0, local.calls)
+
+	// Mongo does not have b, local does.
+	require.NoError(t, set.updateOne(ctx, sg, "b"))
+	assert.Equal(t, "local", sg["b"].value[0].Kind)
+
+	// Mongo and local fail for c, so the fallback is consulted.
+	require.NoError(t, set.updateOne(ctx, sg, "c"))
+	assert.Equal(t, "embedded", sg["c"].value[0].Kind)
+
+	assert.Error(t, set.updateOne(ctx, sg, "d"))
+}
+
+func TestSourceSet_Removed(t *testing.T) {
+	mongo := &fakeSource{name: "mongo", templates: []entity.TemplateIdName{"a", "b"}}
+	local := &fakeSource{name: "local", templates: []entity.TemplateIdName{"a"}}
+
+	mongoSrc := &templateSource{name: "mongo", policy: PolicyFirstWins, priority: 20, source: mongo}
+	set := newTestSourceSet(
+		&templateSource{name: "local", policy: PolicyFirstWins, priority: 10, source: local},
+		mongoSrc,
+	)
+	set.sort()
+
+	sg := mapSetGetter{}
+	ctx := context.Background()
+
+	require.NoError(t, set.update(ctx, sg))
+	set.view(mongoSrc, sg).RecordVersion("a", "7")
+	assert.Equal(t, "mongo", sg["a"].value[0].Kind)
+
+	// Mongo stops listing its templates: a falls back to local, b is gone.
+	mongo.templates = nil
+	require.NoError(t, set.update(ctx, sg))
+
+	assert.Equal(t, "local", sg["a"].value[0].Kind)
+	source, _ := set.Source("a")
+	assert.Equal(t, "local", source)
+	_, ok := set.Version("a")
+	assert.False(t, ok, "The version of the previous source is dropped")
+
+	assert.NotContains(t, sg, entity.TemplateIdName("b"))
+	_, ok = set.Source("b")
+	assert.False(t, ok)
+
+	// A template that left the cache is forgotten on the next update.
+	delete(sg, "a")
+	local.templates = nil
+	require.NoError(t, set.update(ctx, sg))
+	_, ok = set.Source("a")
+	assert.False(t, ok)
+	assert.Empty(t, set.lastUpdated)
+}
+
+type mapEntry struct {
+	value   []parser.Instruction
+	updated time.Time
+}
+
+type mapSetGetter map[entity.TemplateIdName]mapEntry
+
+func (m mapSetGetter) Set(k entity.TemplateIdName, v []parser.Instruction, updateTime time.Time) {
+	m[k] = mapEntry{value: v, updated: updateTime}
+}
+
+func (m mapSetGetter) Get(k entity.TemplateIdName) ([]parser.Instruction, bool) {
+	e, ok := m[k]
+	return e.value, ok
+}
+
+func (m mapSetGetter) LastUpdated(k entity.TemplateIdName) (time.Time, bool) {
+	e, ok := m[k]
+	return e.updated, ok
+}
+
+func (m mapSetGetter) Delete(k entity.TemplateIdName) { delete(m, k) }
+
+func (m mapSetGetter) CleanUp() int { return 0 }
+
+func (m mapSetGetter) Len() int { return len(m) }
@@ -2,15 +2,20 @@ package server
 
 import (
 	"fmt"
+	"item_compositiom_service/pkg/auth"
+	"item_compositiom_service/pkg/deadline"
 	"time"
 )
 
 type Config struct {
-	ListenAddress  string        `yaml:"listen_address"`
-	Logging        *Logging      `yaml:"logging"`
-	UnixSocketUser string        `yaml:"unix_socket_user"`
-	StartDeadline  time.Duration `yaml:"start_deadline"`
-	StopDeadline   time.Duration `yaml:"stop_deadline"`
+	ListenAddress  string           `yaml:"listen_address"`
+	Logging        *Logging         `yaml:"logging"`
+	UnixSocketUser string           `yaml:"unix_socket_user"`
+	StartDeadline  time.Duration    `yaml:"start_deadline"`
+	StopDeadline   time.Duration    `yaml:"stop_deadline"`
+	HealthInterval time.Duration    `yaml:"health_interval"`
+	Deadlines      *deadline.Config `yaml:"deadlines"`
+	Auth           *auth.Config     `yaml:"auth"`
 }
 
 type Logging struct {
@@ -23,11 +28,14 @@ type Logging struct {
 
 func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	tmp := struct {
-		ListenAddress  *string        `yaml:"listen_address"`
-		Logging        *Logging       `yaml:"logging"`
-		UnixSocketUser string         `yaml:"unix_socket_user"`
-		StartDeadline  *time.Duration `yaml:"start_deadline"`
-		StopDeadline   *time.Duration `yaml:"stop_deadline"`
+		ListenAddress  *string          `yaml:"listen_address"`
+		Logging        *Logging         `yaml:"logging"`
+		UnixSocketUser string           `yaml:"unix_socket_user"`
+		StartDeadline  *time.Duration   `yaml:"start_deadline"`
+		StopDeadline   *time.Duration   `yaml:"stop_deadline"`
+		HealthInterval time.Duration    `yaml:"health_interval"`
+		Deadlines      *deadline.Config `yaml:"deadlines"`
+		Auth           *auth.Config     `yaml:"auth"`
 	}{}
 
 	if err := unmarshal(&tmp); err != nil {
@@ -51,6 +59,9 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	c.Logging = tmp.Logging
 	c.StartDeadline = *tmp.StartDeadline
 	c.StopDeadline = *tmp.StopDeadline
+	c.HealthInterval = tmp.HealthInterval
+	c.Deadlines = tmp.Deadlines
+	c.Auth = tmp.Auth
 
 	return nil
 }
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const defaultHealthInterval = 5 * time.Second
+
+// Health service names of dependencies; providers are reported as
+// "provider.<name>".
+const (
+	healthMongo    = "mongo"
+	healthLocal    = "local_storage"
+	healthClients  = "client_configs"
+	healthProvider = "provider."
+)
+
+type healthCheck func(context.Context) error
+
+// healthChecker periodically updates the health server: the overall status
+// ("") follows ready, each dependency gets its own service status. A
+// dependency that is gone, e.g. a provider removed by a template reload, is
+// reported as SERVICE_UNKNOWN, as the health server reports services it never
+// knew.
+type healthChecker struct {
+	server   *health.Server
+	interval time.Duration
+	ready    healthCheck
+	checks   func() map[string]healthCheck
+	reported map[string]struct{}
+}
+
+func (h *healthChecker) run(ctx context.Context) {
+	t := time.NewTicker(h.interval)
+	defer t.Stop()
+
+	for {
+		h.update(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (h *healthChecker) update(ctx context.Context) {
+	checks := h.checks()
+	for name, check := range checks {
+		h.server.SetServingStatus(name, h.status(ctx, check))
+	}
+
+	for name := range h.reported {
+		if _, ok := checks[name]; !ok {
+			h.server.SetServingStatus(name, healthgrpc.HealthCheckResponse_SERVICE_UNKNOWN)
+		}
+	}
+
+	h.reported = make(map[string]struct{}, len(checks))
+	for name := range checks {
+		h.reported[name] = struct{}{}
+	}
+
+	h.server.SetServingStatus("", h.status(ctx, h.ready))
+}
+
+func (h *healthChecker) status(ctx context.Context, check healthCheck) healthgrpc.HealthCheckResponse_ServingStatus {
+	ctx, cancel := context.WithTimeout(ctx, h.interval)
+	defer cancel()
+
+	if err := check(ctx); err != nil {
+		return healthgrpc.HealthCheckResponse_NOT_SERVING
+	}
+
+	return healthgrpc.HealthCheckResponse_SERVING
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/health"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealthChecker_Update(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	failing := func(context.Context) error { return errors.New("unavailable") }
+
+	checks := map[string]healthCheck{
+		healthProvider + "users": ok,
+		healthProvider + "likes": failing,
+	}
+	h := &healthChecker{
+		server:   health.NewServer(),
+		interval: time.Second,
+		ready:    ok,
+		checks:   func() map[string]healthCheck { return checks },
+	}
+
+	status := func(service string) healthgrpc.HealthCheckResponse_ServingStatus {
+		res, err := h.server.Check(context.Background(), &healthgrpc.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		return res.Status
+	}
+
+	h.update(context.Background())
+	assert.Equal(t, healthgrpc.HealthCheckResponse_SERVING, status(""))
+	assert.Equal(t, healthgrpc.HealthCheckResponse_SERVING, status(healthProvider+"users"))
+	assert.Equal(t, healthgrpc.HealthCheckResponse_NOT_SERVING, status(healthProvider+"likes"))
+
+	// The likes provider is removed by a template reload.
+	delete(checks, healthProvider+"likes")
+	h.update(context.Background())
+	assert.Equal(t, healthgrpc.HealthCheckResponse_SERVING, status(healthProvider+"users"))
+	assert.Equal(t, healthgrpc.HealthCheckResponse_SERVICE_UNKNOWN, status(healthProvider+"likes"))
+}
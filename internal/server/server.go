@@ -9,9 +9,13 @@ import (
 	"google.golang.org/grpc/health"
 	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
+	"item_compositiom_service/pkg/auth"
+	"item_compositiom_service/pkg/deadline"
+	"item_compositiom_service/pkg/i18n"
 	"item_compositiom_service/pkg/logger"
 	"item_compositiom_service/pkg/metrics"
 	"item_compositiom_service/pkg/recovery"
+	"item_compositiom_service/pkg/requestctx"
 	"item_compositiom_service/pkg/tracer"
 	"net"
 	"os"
@@ -21,7 +25,11 @@ import (
 	"sync"
 
 	servicepb "item_compositiom_service/internal/generated/service"
+	"item_compositiom_service/internal/repository"
+	localdb "item_compositiom_service/internal/repository/local_db"
+	mongodb "item_compositiom_service/internal/repository/mongo_db"
 	"item_compositiom_service/internal/services"
+	"item_compositiom_service/pkg/provider"
 )
 
 type Server struct {
@@ -32,6 +40,7 @@ type Server struct {
 	runCtx       context.Context
 	runCancelFn  context.CancelFunc
 	healthServer *health.Server
+	health       *healthChecker
 }
 
 func NewServer(
@@ -39,10 +48,21 @@ func NewServer(
 	config *Config,
 
 	implItemCompositionService *services.Service,
+	implAdminService *services.AdminService,
 
 	lgrInterceptor *logger.Interceptor,
 	traceInterceptor *tracer.Interceptor,
 	metricsInterceptor *metrics.Interceptor,
+	i18nInterceptor *i18n.Interceptor,
+	requestCtxInterceptor *requestctx.Interceptor,
+	deadlineInterceptor *deadline.Interceptor,
+	authInterceptor *auth.Interceptor,
+
+	templates *repository.TemplateRepository,
+	ms *mongodb.MongoStorage,
+	ls *localdb.LocalStorage,
+	providers *provider.ProviderStorage,
+	clients *repository.ClientRepository,
 ) (*Server, error) {
 	if config == nil {
 		return nil, fmt.Errorf("gRPC server config is nil")
@@ -73,11 +93,16 @@ func NewServer(
 			lgrInterceptor.GetServerInterceptor(logOpts...),
 			metricsInterceptor.GetServerInterceptor(),
 			recovery.RecoverInterceptor,
-			// TODO set deadline, health interceptors
+			authInterceptor.GetServerInterceptor(),
+			i18nInterceptor.GetServerInterceptor(),
+			// ctx.deadline of the request environment is the server deadline.
+			deadlineInterceptor.GetServerInterceptor(),
+			requestCtxInterceptor.GetServerInterceptor(),
 		),
 	)
 
 	servicepb.RegisterItemCompositionServiceServer(server, implItemCompositionService)
+	servicepb.RegisterItemCompositionAdminServiceServer(server, implAdminService)
 
 	reflection.Register(server)
 
@@ -92,6 +117,26 @@ func NewServer(
 		runCtx:       ctx,
 		runCancelFn:  cancel,
 		healthServer: healthServer,
+		health: &healthChecker{
+			server:   healthServer,
+			interval: cmp.Or(config.HealthInterval, defaultHealthInterval),
+			ready:    templates.Check,
+			checks: func() map[string]healthCheck {
+				checks := map[string]healthCheck{
+					healthLocal: ls.Check,
+				}
+				if ms.Enabled() {
+					checks[healthMongo] = ms.Check
+				}
+				if clients.Enabled() {
+					checks[healthClients] = clients.Check
+				}
+				for name, check := range providers.HealthChecks() {
+					checks[healthProvider+name] = check
+				}
+				return checks
+			},
+		},
 	}
 
 	res.healthServer.SetServingStatus("", healthgrpc.HealthCheckResponse_NOT_SERVING)
@@ -116,11 +161,15 @@ func (s *Server) Start(_ context.Context) error {
 		return fmt.Errorf("gRPC server listen: %w", err)
 	}
 
-	s.wg.Add(1)
+	s.wg.Add(2)
 	go func() {
 		defer s.wg.Done()
 		s.resErr = s.server.Serve(lis)
 	}()
+	go func() {
+		defer s.wg.Done()
+		s.health.run(s.runCtx)
+	}()
 
 	return nil
 }
@@ -130,6 +179,7 @@ func (s *Server) Stop(ctx context.Context) error {
 	go func() {
 		defer close(done)
 		s.runCancelFn()
+		s.healthServer.Shutdown()
 		s.server.GracefulStop()
 		s.wg.Wait()
 	}()
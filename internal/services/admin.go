@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"item_compositiom_service/internal/entity"
+	"item_compositiom_service/internal/repository"
+	mongodb "item_compositiom_service/internal/repository/mongo_db"
+	"item_compositiom_service/pkg/parser"
+	"item_compositiom_service/pkg/schema"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	servicepb "item_compositiom_service/internal/generated/service"
+)
+
+var schemaFormats = map[servicepb.SchemaFormat]string{
+	servicepb.SchemaFormat_SCHEMA_FORMAT_JSON_SCHEMA: schema.FormatJSONSchema,
+	servicepb.SchemaFormat_SCHEMA_FORMAT_TYPESCRIPT:  schema.FormatTypeScript,
+	servicepb.SchemaFormat_SCHEMA_FORMAT_KOTLIN:      schema.FormatKotlin,
+}
+
+type AdminService struct {
+	*servicepb.UnimplementedItemCompositionAdminServiceServer
+
+	templates   *repository.TemplateRepository
+	templateLib *parser.TemplateLib
+}
+
+func NewAdminService(templates *repository.TemplateRepository, templateLib *parser.TemplateLib) *AdminService {
+	return &AdminService{
+		UnimplementedItemCompositionAdminServiceServer: &servicepb.UnimplementedItemCompositionAdminServiceServer{},
+		templates:   templates,
+		templateLib: templateLib,
+	}
+}
+
+func (s *AdminService) GetViewSchema(ctx context.Context, req *servicepb.GetViewSchemaRequest) (*servicepb.GetViewSchemaResponse, error) {
+	if req.GetTemplateId() == "" || req.GetView() == "" {
+		return nil, status.Error(codes.InvalidArgument, "template_id and view are required")
+	}
+
+	format, ok := schemaFormats[req.GetFormat()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported format %s", req.GetFormat())
+	}
+
+	instructions, ok := s.templates.GetTemplate(ctx, entity.TemplateIdName(req.GetTemplateId()))
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "template %s not found", req.GetTemplateId())
+	}
+
+	viewSchema, err := schema.Generate(instructions, req.GetView())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	data, err := schema.Render(viewSchema, format, req.GetView())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &servicepb.GetViewSchemaResponse{Schema: string(data)}, nil
+}
+
+// SaveTemplate validates a new template version and compares its output
+// schemas with the loaded version. Breaking changes are rejected unless forced.
+// The save is aborted if the stored template is no longer the compared one or
+// the expected version of the request.
+// Providers and protos of the new version are registered only once it is
+// saved and loaded by the repository.
+func (s *AdminService) SaveTemplate(ctx context.Context, req *servicepb.SaveTemplateRequest) (*servicepb.SaveTemplateResponse, error) {
+	if req.GetTemplateId() == "" || len(req.GetContent()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "template_id and content are required")
+	}
+
+	id := entity.TemplateIdName(req.GetTemplateId())
+
+	newInstructions, err := s.templateLib.ValidateTemplate(req.GetContent())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid template: %s", err)
+	}
+
+	// The version is read before the template, so that a reload in between
+	// fails the save rather than saving over an unchecked version.
+	expectedVersion := req.GetExpectedVersion()
+	if expectedVersion == 0 {
+		expectedVersion, _ = s.templates.StoredVersion(id)
+	}
+
+	var changes []schema.Change
+	if oldInstructions, ok := s.templates.GetTemplate(ctx, id); ok {
+		changes, err = schema.DiffTemplates(oldInstructions, newInstructions)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "compare schemas: %s", err)
+		}
+	}
+
+	if breaking := schema.Breaking(changes); len(breaking) > 0 && !req.GetForce() {
+		messages := make([]string, len(breaking))
+		for i, change := range breaking {
+			messages[i] = change.String()
+		}
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"template %s has breaking changes, use force to save: %s", id, strings.Join(messages, "; "))
+	}
+
+	if err := s.templates.SaveTemplate(ctx, id, req.GetContent(), expectedVersion); err != nil {
+		if errors.Is(err, mongodb.ErrConflict) {
+			return nil, status.Error(codes.Aborted, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	res := &servicepb.SaveTemplateResponse{
+		Changes: make([]*servicepb.SchemaChange, len(changes)),
+	}
+	for i, change := range changes {
+		res.Changes[i] = &servicepb.SchemaChange{
+			Path:     change.Path,
+			Kind:     string(change.Kind),
+			Breaking: change.Breaking,
+			Message:  change.Message,
+		}
+	}
+
+	return res, nil
+}
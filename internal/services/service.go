@@ -2,18 +2,147 @@ package services
 
 import (
 	"context"
+	"errors"
+	"item_compositiom_service/internal/entity"
+	"item_compositiom_service/internal/repository"
+	"item_compositiom_service/pkg/logger"
+	"item_compositiom_service/pkg/output"
+	"item_compositiom_service/pkg/parser"
+	"item_compositiom_service/pkg/tracer"
+	"sync"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	servicepb "item_compositiom_service/internal/generated/service"
 )
 
+var encodings = map[servicepb.Encoding]output.Encoding{
+	servicepb.Encoding_ENCODING_UNSPECIFIED:  output.EncodingJSON,
+	servicepb.Encoding_ENCODING_JSON:         output.EncodingJSON,
+	servicepb.Encoding_ENCODING_COMPACT_JSON: output.EncodingCompactJSON,
+	servicepb.Encoding_ENCODING_PROTOBUF:     output.EncodingProtobuf,
+}
+
+var itemEncodings = map[output.Encoding]servicepb.Encoding{
+	output.EncodingJSON:        servicepb.Encoding_ENCODING_JSON,
+	output.EncodingCompactJSON: servicepb.Encoding_ENCODING_COMPACT_JSON,
+	output.EncodingProtobuf:    servicepb.Encoding_ENCODING_PROTOBUF,
+}
+
+// templateGetter finds the parsed template of an item type.
+type templateGetter interface {
+	GetTemplate(ctx context.Context, key entity.TemplateIdName) ([]parser.Instruction, bool)
+}
+
 type Service struct {
 	*servicepb.UnimplementedItemCompositionServiceServer
+
+	templates   templateGetter
+	templateLib *parser.TemplateLib
 }
 
-func NewService() *Service {
-	return &Service{&servicepb.UnimplementedItemCompositionServiceServer{}}
+func NewService(templates *repository.TemplateRepository, templateLib *parser.TemplateLib) *Service {
+	return &Service{
+		UnimplementedItemCompositionServiceServer: &servicepb.UnimplementedItemCompositionServiceServer{},
+		templates:   templates,
+		templateLib: templateLib,
+	}
 }
 
-func (service *Service) GetItems(context.Context, *servicepb.GetItemsRequest) (*servicepb.GetItemsResponse, error) {
-	return nil, nil
+// GetItems renders every item with the template named by its key type, in
+// the request order. An item whose template is unknown or whose render fails
+// is returned with its key and an error instead of data.
+func (service *Service) GetItems(ctx context.Context, req *servicepb.GetItemsRequest) (*servicepb.GetItemsResponse, error) {
+	encoding, ok := encodings[req.GetEncoding()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported encoding %s", req.GetEncoding())
+	}
+
+	rendered := make([]*servicepb.Item, len(req.GetItems()))
+
+	var wg sync.WaitGroup
+	for i, meta := range req.GetItems() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rendered[i] = service.render(ctx, meta, encoding)
+		}()
+	}
+	wg.Wait()
+
+	return &servicepb.GetItemsResponse{Items: rendered}, nil
+}
+
+// render composes one item. The item fields are the item metadata with the id
+// and type of its key.
+func (service *Service) render(ctx context.Context, meta *servicepb.ItemMeta, encoding output.Encoding) *servicepb.Item {
+	lgr := logger.FromContext(ctx).Desugar().With(
+		zap.String("item_id", meta.GetKey().GetId()),
+		zap.String("item_type", meta.GetKey().GetType()),
+	)
+
+	ctx = parser.WithTemplateID(ctx, meta.GetKey().GetType())
+	ctx = tracer.WithAttributes(ctx, tracer.ItemKey(meta.GetKey().GetType()+":"+meta.GetKey().GetId()))
+
+	instructions, ok := service.templates.GetTemplate(ctx, entity.TemplateIdName(meta.GetKey().GetType()))
+	if !ok {
+		lgr.Warn("Item dropped: template not found")
+		return &servicepb.Item{
+			Key: meta.GetKey(),
+			Error: &servicepb.ItemError{
+				Code:    servicepb.ItemErrorCode_ITEM_ERROR_CODE_TEMPLATE_NOT_FOUND,
+				Message: "template not found",
+			},
+		}
+	}
+
+	item := meta.GetMetadata().AsMap()
+	item["id"] = meta.GetKey().GetId()
+	item["type"] = meta.GetKey().GetType()
+
+	res, err := service.templateLib.Render(ctx, item, instructions, encoding)
+	if err != nil {
+		lgr.Warn("Item dropped", zap.Error(err))
+		return &servicepb.Item{Key: meta.GetKey(), Error: itemError(err)}
+	}
+
+	experiments := make([]*servicepb.ExperimentAssignment, len(res.Experiments))
+	for i, assignment := range res.Experiments {
+		experiments[i] = &servicepb.ExperimentAssignment{
+			Experiment: assignment.Experiment,
+			Variant:    assignment.Variant,
+		}
+	}
+
+	return &servicepb.Item{
+		Key:         meta.GetKey(),
+		Data:        res.Data,
+		Encoding:    itemEncodings[res.Encoding],
+		MessageType: res.MessageType,
+		Experiments: experiments,
+	}
+}
+
+// itemError is the per-item error of a failed render; a missing required
+// field is reported with the name of the (first) field.
+func itemError(err error) *servicepb.ItemError {
+	res := &servicepb.ItemError{
+		Code:    servicepb.ItemErrorCode_ITEM_ERROR_CODE_RENDER_FAILED,
+		Message: err.Error(),
+	}
+
+	var fieldErr *parser.FieldError
+	switch {
+	case errors.Is(err, parser.ErrLimitExceeded):
+		res.Code = servicepb.ItemErrorCode_ITEM_ERROR_CODE_LIMIT_EXCEEDED
+	case errors.Is(err, parser.ErrRequiredField):
+		res.Code = servicepb.ItemErrorCode_ITEM_ERROR_CODE_REQUIRED_FIELD_MISSING
+		if errors.As(err, &fieldErr) {
+			res.Field = fieldErr.Field
+		}
+	}
+
+	return res
 }
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"item_compositiom_service/internal/entity"
+	"item_compositiom_service/pkg/i18n"
+	"item_compositiom_service/pkg/metrics"
+	"item_compositiom_service/pkg/output"
+	"item_compositiom_service/pkg/parser"
+	"item_compositiom_service/pkg/provider"
+	"item_compositiom_service/pkg/tracer"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	servicepb "item_compositiom_service/internal/generated/service"
+)
+
+const testTemplate = `
+kind: Proto
+metadata:
+  name: card.proto
+spec:
+  proto: |
+    syntax = "proto3";
+    package feed;
+    message Card {
+      string id = 1;
+      string title = 2;
+    }
+---
+kind: View
+metadata:
+  name: card
+spec:
+  if: item.kind == "card"
+  output:
+    message: feed.Card
+  template:
+    templates: ["card"]
+---
+kind: View
+metadata:
+  name: plain
+spec:
+  if: item.kind != "card"
+  template:
+    templates: ["card"]
+  experiment:
+    name: plain_layout
+    key: item.id
+    variants:
+      - name: control
+        weight: 100
+---
+kind: Template
+metadata:
+  name: card
+spec:
+  id:
+    type: "string"
+    path: "item.id"
+  title:
+    type: "string"
+    path: "item.title"
+    required: true
+`
+
+const limitedTemplate = `
+kind: View
+metadata:
+  name: list
+spec:
+  template:
+    templates: ["list"]
+  limits:
+    max_array_length: 2
+---
+kind: Template
+metadata:
+  name: list
+spec:
+  list:
+    type: "number"
+    path: "item.list"
+`
+
+type testTemplates map[entity.TemplateIdName][]parser.Instruction
+
+func (t testTemplates) GetTemplate(_ context.Context, key entity.TemplateIdName) ([]parser.Instruction, bool) {
+	instructions, ok := t[key]
+	return instructions, ok
+}
+
+func newTestService(t *testing.T) (*Service, *output.Registry) {
+	t.Helper()
+
+	providers, err := provider.NewProviderStorage(zap.NewNop().Sugar(), &metrics.NoopMetrics{})
+	require.NoError(t, err)
+
+	outputs := output.NewRegistry()
+	templateLib, err := parser.NewTemplateLib(&parser.Config{}, &metrics.NoopMetrics{}, providers, i18n.NewStorage(nil), outputs)
+	require.NoError(t, err)
+
+	instructions, err := templateLib.ParseTemplate([]byte(testTemplate))
+	require.NoError(t, err)
+
+	limited, err := templateLib.ParseTemplate([]byte(limitedTemplate))
+	require.NoError(t, err)
+
+	return &Service{
+		templates:   testTemplates{"post": instructions, "limited": limited},
+		templateLib: templateLib,
+	}, outputs
+}
+
+func itemMeta(t *testing.T, id, typ string, metadata map[string]any) *servicepb.ItemMeta {
+	t.Helper()
+
+	md, err := structpb.NewStruct(metadata)
+	require.NoError(t, err)
+	return &servicepb.ItemMeta{Key: &servicepb.Key{Id: id, Type: typ}, Metadata: md}
+}
+
+func TestService_GetItems(t *testing.T) {
+	service, outputs := newTestService(t)
+	items := []*servicepb.ItemMeta{
+		itemMeta(t, "1", "post", map[string]any{"kind": "card", "title": "First"}),
+		itemMeta(t, "2", "unknown", map[string]any{"title": "No template"}),
+		itemMeta(t, "3", "post", map[string]any{"kind": "card"}),
+		itemMeta(t, "4", "post", map[string]any{"kind": "text", "title": "Fourth"}),
+	}
+
+	tests := []struct {
+		name     string
+		encoding servicepb.Encoding
+		card     servicepb.Encoding
+		plain    servicepb.Encoding
+	}{
+		{"unspecified", servicepb.Encoding_ENCODING_UNSPECIFIED, servicepb.Encoding_ENCODING_JSON, servicepb.Encoding_ENCODING_JSON},
+		{"json", servicepb.Encoding_ENCODING_JSON, servicepb.Encoding_ENCODING_JSON, servicepb.Encoding_ENCODING_JSON},
+		{"compact json", servicepb.Encoding_ENCODING_COMPACT_JSON, servicepb.Encoding_ENCODING_COMPACT_JSON, servicepb.Encoding_ENCODING_COMPACT_JSON},
+		{"protobuf", servicepb.Encoding_ENCODING_PROTOBUF, servicepb.Encoding_ENCODING_PROTOBUF, servicepb.Encoding_ENCODING_COMPACT_JSON},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := service.GetItems(context.Background(), &servicepb.GetItemsRequest{Items: items, Encoding: tc.encoding})
+			require.NoError(t, err)
+			require.Len(t, res.GetItems(), 4)
+
+			card, plain := res.GetItems()[0], res.GetItems()[3]
+			assert.Equal(t, "1", card.GetKey().GetId())
+			assert.Equal(t, tc.card, card.GetEncoding())
+			assert.Equal(t, "feed.Card", card.GetMessageType())
+
+			assert.Equal(t, "4", plain.GetKey().GetId())
+			assert.Equal(t, tc.plain, plain.GetEncoding())
+			assert.Empty(t, plain.GetMessageType())
+
+			if tc.plain != servicepb.Encoding_ENCODING_PROTOBUF {
+				assert.JSONEq(t, `{"id": "4", "title": "Fourth"}`, string(plain.GetData()))
+			}
+			if tc.card == servicepb.Encoding_ENCODING_JSON {
+				assert.Contains(t, string(card.GetData()), "\n", "JSON is indented")
+			}
+			if tc.card == servicepb.Encoding_ENCODING_COMPACT_JSON {
+				assert.Equal(t, `{"id":"1","title":"First"}`, string(card.GetData()))
+			}
+		})
+	}
+
+	t.Run("protobuf data", func(t *testing.T) {
+		res, err := service.GetItems(context.Background(), &servicepb.GetItemsRequest{
+			Items:    items[:1],
+			Encoding: servicepb.Encoding_ENCODING_PROTOBUF,
+		})
+		require.NoError(t, err)
+		require.Len(t, res.GetItems(), 1)
+
+		md, err := outputs.FindMessage(res.GetItems()[0].GetMessageType())
+		require.NoError(t, err)
+
+		msg := dynamicpb.NewMessage(md)
+		require.NoError(t, proto.Unmarshal(res.GetItems()[0].GetData(), msg))
+		assert.Equal(t, "1", msg.Get(md.Fields().ByName("id")).String())
+		assert.Equal(t, "First", msg.Get(md.Fields().ByName("title")).String())
+	})
+
+	t.Run("experiments", func(t *testing.T) {
+		res, err := service.GetItems(context.Background(), &servicepb.GetItemsRequest{Items: items})
+		require.NoError(t, err)
+		require.Len(t, res.GetItems(), 4)
+
+		assert.Empty(t, res.GetItems()[0].GetExperiments())
+
+		experiments := res.GetItems()[3].GetExperiments()
+		require.Len(t, experiments, 1)
+		assert.Equal(t, "plain_layout", experiments[0].GetExperiment())
+		assert.Equal(t, "control", experiments[0].GetVariant())
+	})
+
+	t.Run("item errors", func(t *testing.T) {
+		res, err := service.GetItems(context.Background(), &servicepb.GetItemsRequest{Items: items})
+		require.NoError(t, err)
+		require.Len(t, res.GetItems(), 4)
+
+		assert.Nil(t, res.GetItems()[0].GetError())
+
+		noTemplate := res.GetItems()[1]
+		assert.Equal(t, "2", noTemplate.GetKey().GetId())
+		assert.Empty(t, noTemplate.GetData())
+		assert.Equal(t, servicepb.ItemErrorCode_ITEM_ERROR_CODE_TEMPLATE_NOT_FOUND, noTemplate.GetError().GetCode())
+
+		noTitle := res.GetItems()[2]
+		assert.Equal(t, "3", noTitle.GetKey().GetId())
+		assert.Empty(t, noTitle.GetData())
+		assert.Equal(t, servicepb.ItemErrorCode_ITEM_ERROR_CODE_REQUIRED_FIELD_MISSING, noTitle.GetError().GetCode())
+		assert.Equal(t, "title", noTitle.GetError().GetField())
+		assert.Contains(t, noTitle.GetError().GetMessage(), parser.ErrRequiredField.Error())
+
+		res, err = service.GetItems(context.Background(), &servicepb.GetItemsRequest{Items: []*servicepb.ItemMeta{
+			itemMeta(t, "5", "limited", map[string]any{"list": []any{1, 2}}),
+			itemMeta(t, "6", "limited", map[string]any{"list": []any{1, 2, 3}}),
+		}})
+		require.NoError(t, err)
+		require.Len(t, res.GetItems(), 2)
+
+		assert.Nil(t, res.GetItems()[0].GetError())
+		assert.Equal(t, servicepb.ItemErrorCode_ITEM_ERROR_CODE_LIMIT_EXCEEDED, res.GetItems()[1].GetError().GetCode())
+		assert.Contains(t, res.GetItems()[1].GetError().GetMessage(), parser.LimitArrayLength)
+	})
+
+	t.Run("unknown encoding", func(t *testing.T) {
+		_, err := service.GetItems(context.Background(), &servicepb.GetItemsRequest{Items: items, Encoding: 42})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("no items", func(t *testing.T) {
+		res, err := service.GetItems(context.Background(), &servicepb.GetItemsRequest{})
+		require.NoError(t, err)
+		assert.Empty(t, res.GetItems())
+	})
+}
+
+func TestService_GetItemsSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	service, _ := newTestService(t)
+	_, err := service.GetItems(context.Background(), &servicepb.GetItemsRequest{
+		Items: []*servicepb.ItemMeta{itemMeta(t, "1", "post", map[string]any{"kind": "card", "title": "First"})},
+	})
+	require.NoError(t, err)
+
+	require.NotEmpty(t, recorder.Ended())
+	for _, span := range recorder.Ended() {
+		assert.Contains(t, span.Attributes(), tracer.TemplateID("post"), span.Name())
+		assert.Contains(t, span.Attributes(), tracer.ItemKey("post:1"), span.Name())
+	}
+}
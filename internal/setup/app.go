@@ -7,8 +7,15 @@ import (
 	mongodb "item_compositiom_service/internal/repository/mongo_db"
 	"item_compositiom_service/internal/server"
 	"item_compositiom_service/internal/services"
+	"item_compositiom_service/pkg/auth"
+	"item_compositiom_service/pkg/deadline"
+	"item_compositiom_service/pkg/i18n"
 	"item_compositiom_service/pkg/logger"
 	"item_compositiom_service/pkg/metrics"
+	"item_compositiom_service/pkg/output"
+	"item_compositiom_service/pkg/parser"
+	"item_compositiom_service/pkg/provider"
+	"item_compositiom_service/pkg/requestctx"
 	"item_compositiom_service/pkg/tracer"
 
 	"go.uber.org/fx"
@@ -26,6 +33,7 @@ func Setup(configPath string) (*fx.App, error) {
 		fx.StopTimeout(cfg.GrpcConfig.StopDeadline),
 		fx.Provide(
 			services.NewService,
+			services.NewAdminService,
 			server.NewServer,
 			logger.NewLogger,
 			logger.NewInterceptor,
@@ -36,12 +44,30 @@ func Setup(configPath string) (*fx.App, error) {
 			mongodb.NewMongoStorage,
 			localdb.NewLocalStorage,
 			repository.NewTemplateRepository,
+			parser.NewTemplateLib,
+			provider.NewProviderStorage,
+			i18n.NewStorage,
+			i18n.NewInterceptor,
+			output.NewRegistry,
+			requestctx.NewInterceptor,
+			deadline.NewInterceptor,
+			auth.NewInterceptor,
+			repository.NewClientRepository,
 			func() string {
 				return configPath
 			},
 			func() *server.Config {
 				return cfg.GrpcConfig
 			},
+			func() *auth.Config {
+				return cfg.GrpcConfig.Auth
+			},
+			func(r *repository.ClientRepository) auth.KeyStore {
+				return r
+			},
+			func() *deadline.Config {
+				return cfg.GrpcConfig.Deadlines
+			},
 			func() *logger.Config {
 				return cfg.LogConfig
 			},
@@ -57,6 +83,18 @@ func Setup(configPath string) (*fx.App, error) {
 			func() *localdb.LocalStorageConfig {
 				return cfg.LocalConfig
 			},
+			func() *i18n.Config {
+				return cfg.I18nConfig
+			},
+			func() *parser.Config {
+				return cfg.ParserConfig
+			},
+			func() *repository.SnapshotConfig {
+				return cfg.SnapshotConfig
+			},
+			func() repository.SourcesConfig {
+				return cfg.SourcesConfig
+			},
 		),
 		fx.Invoke(func(*server.Server) {}),
 		fx.Invoke(func(l *zap.SugaredLogger) {
@@ -65,5 +103,6 @@ func Setup(configPath string) (*fx.App, error) {
 		fx.Invoke(func(*tracer.Tracer) {}),
 		fx.Invoke(func(metrics.MetricsRegistry) {}),
 		fx.Invoke(func(*mongodb.MongoStorage) {}),
+		fx.Invoke(func(*repository.TemplateRepository) {}),
 	), nil
 }
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"context"
+	"item_compositiom_service/pkg/migrate"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// templatesUniqueID replaces the template_view_id index that the service used
+// to create at startup with a unique one.
+func templatesUniqueID(c Collections) migrate.Migration {
+	keys := bson.D{{Key: "template_view_id", Value: 1}}
+	const name = "template_view_id_1"
+
+	return migrate.Migration{
+		Version:     1,
+		Description: "templates: unique template_view_id",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			coll := db.Collection(c.Templates)
+			if err := dropIndex(ctx, coll, name); err != nil {
+				return err
+			}
+			return createIndex(ctx, coll, keys, options.Index().SetName(name).SetUnique(true))
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			coll := db.Collection(c.Templates)
+			if err := dropIndex(ctx, coll, name); err != nil {
+				return err
+			}
+			return createIndex(ctx, coll, keys, options.Index().SetName(name))
+		},
+	}
+}
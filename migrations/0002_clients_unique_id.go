@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"context"
+	"item_compositiom_service/pkg/migrate"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// clientsUniqueID makes provider specs unique by name and client configs by
+// client_id.
+func clientsUniqueID(c Collections) migrate.Migration {
+	return migrate.Migration{
+		Version:     2,
+		Description: "client_specs, client_configs: unique name and client_id",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			err := createIndex(ctx, db.Collection(c.ClientSpecs),
+				bson.D{{Key: "name", Value: 1}},
+				options.Index().SetName("name_1").SetUnique(true),
+			)
+			if err != nil {
+				return err
+			}
+
+			return createIndex(ctx, db.Collection(c.ClientConfigs),
+				bson.D{{Key: "client_id", Value: 1}},
+				options.Index().SetName("client_id_1").SetUnique(true),
+			)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if err := dropIndex(ctx, db.Collection(c.ClientSpecs), "name_1"); err != nil {
+				return err
+			}
+			return dropIndex(ctx, db.Collection(c.ClientConfigs), "client_id_1")
+		},
+	}
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+	"item_compositiom_service/pkg/migrate"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// clientConfigsTTL removes client configs once their expires_at passes, e.g.
+// temporary clients. Configs without expires_at never expire.
+func clientConfigsTTL(c Collections) migrate.Migration {
+	const name = "expires_at_ttl"
+
+	return migrate.Migration{
+		Version:     3,
+		Description: "client_configs: expire by expires_at",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db.Collection(c.ClientConfigs),
+				bson.D{{Key: "expires_at", Value: 1}},
+				options.Index().SetName(name).SetExpireAfterSeconds(0),
+			)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndex(ctx, db.Collection(c.ClientConfigs), name)
+		},
+	}
+}
@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"item_compositiom_service/pkg/migrate"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// content is stored as binary by the service; strings are accepted for
+// documents written by hand.
+var contentSchema = bson.M{"bsonType": bson.A{"binData", "string"}}
+
+// schemaValidators rejects writes of documents without their key or content.
+// With the moderate level, existing invalid documents can still be updated.
+func schemaValidators(c Collections) migrate.Migration {
+	schemas := map[string]bson.M{
+		c.Templates: {
+			"bsonType": "object",
+			"required": bson.A{"template_view_id", "content"},
+			"properties": bson.M{
+				"template_view_id": bson.M{"bsonType": "string", "minLength": 1},
+				"content":          contentSchema,
+				"updated_at":       bson.M{"bsonType": "date"},
+			},
+		},
+		c.ClientSpecs: {
+			"bsonType": "object",
+			"required": bson.A{"name", "content"},
+			"properties": bson.M{
+				"name":       bson.M{"bsonType": "string", "minLength": 1},
+				"content":    contentSchema,
+				"updated_at": bson.M{"bsonType": "date"},
+			},
+		},
+		c.ClientConfigs: {
+			"bsonType": "object",
+			"required": bson.A{"client_id"},
+			"properties": bson.M{
+				"client_id":  bson.M{"bsonType": "string", "minLength": 1},
+				"content":    contentSchema,
+				"updated_at": bson.M{"bsonType": "date"},
+				"expires_at": bson.M{"bsonType": "date"},
+			},
+		},
+	}
+
+	return migrate.Migration{
+		Version:     4,
+		Description: "templates, client_specs, client_configs: schema validators",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			for coll, schema := range schemas {
+				if err := setValidator(ctx, db, coll, bson.M{"$jsonSchema": schema}, "moderate"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			for coll := range schemas {
+				if err := setValidator(ctx, db, coll, bson.M{}, "off"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func setValidator(ctx context.Context, db *mongo.Database, coll string, validator bson.M, level string) error {
+	err := db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: coll},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: level},
+		{Key: "validationAction", Value: "error"},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("set validator of %s: %w", coll, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+	"item_compositiom_service/pkg/migrate"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// clientAPIKeys makes an API key hash belong to one client at most. Configs
+// without keys are not indexed.
+func clientAPIKeys(c Collections) migrate.Migration {
+	const name = "api_keys_hash_1"
+
+	return migrate.Migration{
+		Version:     5,
+		Description: "client_configs: unique api key hashes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db.Collection(c.ClientConfigs),
+				bson.D{{Key: "api_keys.hash", Value: 1}},
+				options.Index().SetName(name).SetUnique(true).SetSparse(true),
+			)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndex(ctx, db.Collection(c.ClientConfigs), name)
+		},
+	}
+}
@@ -0,0 +1,57 @@
+// Package migrations holds the MongoDB migrations of the service
+// collections. New migrations get the next version and are appended to All;
+// applied migrations are never edited.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"item_compositiom_service/pkg/migrate"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// indexNotFound is the server error code of dropping a missing index.
+const indexNotFound = 27
+
+// Collections are the configured collection names.
+type Collections struct {
+	Templates     string
+	ClientSpecs   string
+	ClientConfigs string
+}
+
+func All(c Collections) []migrate.Migration {
+	return []migrate.Migration{
+		templatesUniqueID(c),
+		clientsUniqueID(c),
+		clientConfigsTTL(c),
+		schemaValidators(c),
+		clientAPIKeys(c),
+	}
+}
+
+func createIndex(ctx context.Context, coll *mongo.Collection, keys bson.D, opts *options.IndexOptions) error {
+	if _, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys, Options: opts}); err != nil {
+		return fmt.Errorf("create index on %s: %w", coll.Name(), err)
+	}
+
+	return nil
+}
+
+func dropIndex(ctx context.Context, coll *mongo.Collection, name string) error {
+	_, err := coll.Indexes().DropOne(ctx, name)
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(indexNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("drop index %s on %s: %w", name, coll.Name(), err)
+	}
+
+	return nil
+}
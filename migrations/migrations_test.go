@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAll(t *testing.T) {
+	all := All(Collections{Templates: "templates", ClientSpecs: "client_specs", ClientConfigs: "client_configs"})
+
+	for i, m := range all {
+		assert.Equal(t, i+1, m.Version, "versions are consecutive")
+		assert.NotEmpty(t, m.Description)
+		assert.NotNil(t, m.Up)
+		assert.NotNil(t, m.Down)
+	}
+}
@@ -0,0 +1,396 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"item_compositiom_service/pkg/metrics"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type testKeys struct {
+	rsa     *rsa.PrivateKey
+	ec      *ecdsa.PrivateKey
+	ed      ed25519.PrivateKey
+	jwksDir string
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// newTestKeys writes a JWKS file with an RSA, a P-256 and an Ed25519 key.
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	jwks := map[string]any{"keys": []map[string]any{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "alg": "ES256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(edKey.Public().(ed25519.PublicKey))},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+	}}
+
+	data, err := json.Marshal(jwks)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "jwks.json"), data, 0o600))
+
+	return &testKeys{rsa: rsaKey, ec: ecKey, ed: edKey, jwksDir: dir}
+}
+
+func (k *testKeys) path() string {
+	return filepath.Join(k.jwksDir, "jwks.json")
+}
+
+func (k *testKeys) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]any{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+	case "PS256":
+		sig, err = rsa.SignPSS(rand.Reader, k.rsa, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case "EdDSA":
+		sig = ed25519.Sign(k.ed, []byte(signed))
+	default:
+		sig = []byte("unsigned")
+	}
+	require.NoError(t, err)
+
+	return signed + "." + b64(sig)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":       "https://auth.example.com",
+		"aud":       []string{"other", "item-composition-service"},
+		"sub":       "user-1",
+		"client_id": "mobile",
+		"exp":       time.Now().Add(time.Hour).Unix(),
+		"nbf":       time.Now().Add(-time.Minute).Unix(),
+	}
+}
+
+func with(claims map[string]any, key string, value any) map[string]any {
+	res := make(map[string]any, len(claims))
+	for k, v := range claims {
+		res[k] = v
+	}
+	if value == nil {
+		delete(res, key)
+	} else {
+		res[key] = value
+	}
+	return res
+}
+
+func TestJWTVerifier(t *testing.T) {
+	keys := newTestKeys(t)
+
+	verifier, err := newJWTVerifier(&JWTConfig{
+		JWKSPath: keys.path(),
+		Issuer:   "https://auth.example.com",
+		Audience: "item-composition-service",
+		Leeway:   time.Second,
+	})
+	require.NoError(t, err)
+
+	for _, tt := range []struct{ alg, kid string }{
+		{"RS256", "rsa"},
+		{"PS256", "rsa"},
+		{"ES256", "ec"},
+		{"EdDSA", "ed"},
+	} {
+		t.Run(tt.alg, func(t *testing.T) {
+			id, err := verifier.verify(keys.sign(t, tt.alg, tt.kid, validClaims()))
+			require.NoError(t, err)
+			assert.Equal(t, "mobile", id.ClientID)
+			assert.Equal(t, "user-1", id.Subject)
+			assert.Equal(t, MethodJWT, id.Method)
+		})
+	}
+
+	invalid := map[string]string{
+		"expired":         keys.sign(t, "RS256", "rsa", with(validClaims(), "exp", time.Now().Add(-time.Minute).Unix())),
+		"no exp":          keys.sign(t, "RS256", "rsa", with(validClaims(), "exp", nil)),
+		"not yet valid":   keys.sign(t, "RS256", "rsa", with(validClaims(), "nbf", time.Now().Add(time.Minute).Unix())),
+		"other issuer":    keys.sign(t, "RS256", "rsa", with(validClaims(), "iss", "https://evil.example.com")),
+		"other audience":  keys.sign(t, "RS256", "rsa", with(validClaims(), "aud", "other")),
+		"no client":       keys.sign(t, "RS256", "rsa", with(validClaims(), "client_id", nil)),
+		"unknown key":     keys.sign(t, "RS256", "missing", validClaims()),
+		"encryption key":  keys.sign(t, "RS256", "enc", validClaims()),
+		"key algorithm":   keys.sign(t, "RS256", "ec", validClaims()),
+		"alg none":        keys.sign(t, "none", "rsa", validClaims()),
+		"symmetric alg":   keys.sign(t, "HS256", "rsa", validClaims()),
+		"wrong key type":  keys.sign(t, "EdDSA", "rsa", validClaims()),
+		"malformed token": "not-a-token",
+	}
+
+	for name, token := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := verifier.verify(token)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+
+	t.Run("tampered claims", func(t *testing.T) {
+		token := keys.sign(t, "RS256", "rsa", validClaims())
+		other := keys.sign(t, "RS256", "rsa", with(validClaims(), "client_id", "admin"))
+
+		parts, otherParts := strings.Split(token, "."), strings.Split(other, ".")
+		parts[1] = otherParts[1]
+
+		_, err := verifier.verify(strings.Join(parts, "."))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
+
+func TestJWTVerifier_ClaimsMapping(t *testing.T) {
+	keys := newTestKeys(t)
+
+	verifier, err := newJWTVerifier(&JWTConfig{
+		JWKSPath: keys.path(),
+		Claims:   ClaimsConfig{ClientID: "azp", Subject: "email"},
+	})
+	require.NoError(t, err)
+
+	id, err := verifier.verify(keys.sign(t, "ES256", "ec", with(with(validClaims(), "azp", "web"), "email", "a@example.com")))
+	require.NoError(t, err)
+	assert.Equal(t, "web", id.ClientID)
+	assert.Equal(t, "a@example.com", id.Subject)
+	assert.Equal(t, "mobile", id.Claims["client_id"])
+}
+
+func TestLoadJWKS_Invalid(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, content := range map[string]string{
+		"not json":      "{",
+		"no keys":       `{"keys": []}`,
+		"unknown kty":   `{"keys": [{"kty": "oct", "kid": "a", "k": "c2VjcmV0"}]}`,
+		"bad curve":     `{"keys": [{"kty": "EC", "kid": "a", "crv": "P-192", "x": "AQ", "y": "AQ"}]}`,
+		"off curve":     `{"keys": [{"kty": "EC", "kid": "a", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`,
+		"duplicate kid": `{"keys": [{"kty": "OKP", "kid": "a", "crv": "Ed25519", "x": "` + b64(make([]byte, 32)) + `"}, {"kty": "OKP", "kid": "a", "crv": "Ed25519", "x": "` + b64(make([]byte, 32)) + `"}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, "jwks.json")
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+			_, err := loadJWKS(path)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := loadJWKS(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+type testKeyStore map[string]string
+
+func (s testKeyStore) ClientByAPIKey(_ context.Context, hash string) (string, error) {
+	if hash == HashAPIKey("broken") {
+		return "", errors.New("storage is down")
+	}
+	if clientID, ok := s[hash]; ok {
+		return clientID, nil
+	}
+	return "", ErrUnknownKey
+}
+
+func TestInterceptor(t *testing.T) {
+	keys := newTestKeys(t)
+
+	interceptor, err := NewInterceptor(&Config{
+		Enabled:       true,
+		PublicMethods: []string{"/grpc.health.v1.Health/", "/svc/Public"},
+		JWT:           &JWTConfig{JWKSPath: keys.path()},
+	}, testKeyStore{HashAPIKey("secret"): "partner"}, &metrics.NoopMetrics{}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		method   string
+		md       metadata.MD
+		code     codes.Code
+		clientID string
+	}{
+		{"api key", "/svc/GetItems", metadata.Pairs("x-api-key", "secret"), codes.OK, "partner"},
+		{"unknown api key", "/svc/GetItems", metadata.Pairs("x-api-key", "other"), codes.Unauthenticated, ""},
+		{"key store error", "/svc/GetItems", metadata.Pairs("x-api-key", "broken"), codes.Unavailable, ""},
+		{"bearer token", "/svc/GetItems", metadata.Pairs("authorization", "Bearer "+keys.sign(t, "RS256", "rsa", validClaims())), codes.OK, "mobile"},
+		{"invalid bearer token", "/svc/GetItems", metadata.Pairs("authorization", "Bearer "+keys.sign(t, "none", "rsa", validClaims())), codes.Unauthenticated, ""},
+		{"basic auth", "/svc/GetItems", metadata.Pairs("authorization", "Basic dXNlcjpwYXNz"), codes.Unauthenticated, ""},
+		{"no credentials", "/svc/GetItems", nil, codes.Unauthenticated, ""},
+		{"public service", "/grpc.health.v1.Health/Check", nil, codes.OK, ""},
+		{"public method", "/svc/Public", nil, codes.OK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+
+			var id *Identity
+			_, err := interceptor.GetServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method},
+				func(ctx context.Context, _ interface{}) (interface{}, error) {
+					id = FromContext(ctx)
+					return nil, nil
+				})
+
+			assert.Equal(t, tt.code, status.Code(err))
+			assert.Equal(t, tt.clientID, ClientIDFromContext(WithIdentity(context.Background(), id)))
+		})
+	}
+}
+
+func TestInterceptor_Disabled(t *testing.T) {
+	interceptor, err := NewInterceptor(nil, nil, &metrics.NoopMetrics{}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	called := false
+	_, err = interceptor.GetServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/svc/GetItems"},
+		func(ctx context.Context, _ interface{}) (interface{}, error) {
+			called = true
+			return nil, nil
+		})
+	assert.NoError(t, err)
+	assert.True(t, called)
+}
+
+// testRegistry exposes a real registry to read the counters of interceptors.
+type testRegistry struct {
+	*prometheus.Registry
+}
+
+func (r testRegistry) GetRegistry() prometheus.Registerer {
+	return r.Registry
+}
+
+func TestInterceptor_RequestMetricsClient(t *testing.T) {
+	registry := testRegistry{prometheus.NewRegistry()}
+
+	requests, err := metrics.NewInterceptor(registry)
+	require.NoError(t, err)
+	interceptor, err := NewInterceptor(&Config{Enabled: true}, testKeyStore{HashAPIKey("secret"): "partner"}, registry, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	// The metrics interceptor runs before auth, as in the server chain.
+	call := func(md metadata.MD) {
+		info := &grpc.UnaryServerInfo{FullMethod: "/svc/GetItems"}
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+		_, _ = requests.GetServerInterceptor()(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return interceptor.GetServerInterceptor()(ctx, req, info, func(context.Context, interface{}) (interface{}, error) {
+				return nil, nil
+			})
+		})
+	}
+
+	call(metadata.Pairs("x-api-key", "secret"))
+	call(metadata.Pairs("x-api-key", "unknown"))
+
+	expected := `
+# HELP grpc_server_requests_total The total number of grpc requests
+# TYPE grpc_server_requests_total counter
+grpc_server_requests_total{client="",code="Unauthenticated",method="/svc/GetItems"} 1
+grpc_server_requests_total{client="partner",code="OK",method="/svc/GetItems"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "grpc_server_requests_total"),
+		"Requests are counted by the client authenticated in an inner interceptor")
+}
+
+func TestInterceptor_Admin(t *testing.T) {
+	keys := newTestKeys(t)
+	admin := "/item_composition.ItemCompositionAdminService/SaveTemplate"
+
+	interceptor, err := NewInterceptor(&Config{
+		Enabled: true,
+		JWT:     &JWTConfig{JWKSPath: keys.path()},
+		Admin:   &AdminConfig{ClientIDs: []string{"ops"}, Scope: "item-composition:admin"},
+	}, testKeyStore{HashAPIKey("secret"): "partner", HashAPIKey("ops"): "ops"}, &metrics.NoopMetrics{}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	noAdmin, err := NewInterceptor(&Config{Enabled: true}, testKeyStore{HashAPIKey("ops"): "ops"}, &metrics.NoopMetrics{}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	bearer := func(claims map[string]any) metadata.MD {
+		return metadata.Pairs("authorization", "Bearer "+keys.sign(t, "RS256", "rsa", claims))
+	}
+
+	tests := []struct {
+		name        string
+		interceptor *Interceptor
+		method      string
+		md          metadata.MD
+		code        codes.Code
+	}{
+		{"listed client", interceptor, admin, metadata.Pairs("x-api-key", "ops"), codes.OK},
+		{"other client", interceptor, admin, metadata.Pairs("x-api-key", "secret"), codes.PermissionDenied},
+		{"other client non-admin method", interceptor, "/svc/GetItems", metadata.Pairs("x-api-key", "secret"), codes.OK},
+		{"scope claim", interceptor, admin, bearer(with(validClaims(), "scope", "items:read item-composition:admin")), codes.OK},
+		{"scp claim", interceptor, admin, bearer(with(validClaims(), "scp", []any{"item-composition:admin"})), codes.OK},
+		{"missing scope", interceptor, admin, bearer(with(validClaims(), "scope", "items:read")), codes.PermissionDenied},
+		{"no credentials", interceptor, admin, nil, codes.Unauthenticated},
+		{"no admin config", noAdmin, admin, metadata.Pairs("x-api-key", "ops"), codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+
+			_, err := tt.interceptor.GetServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method},
+				func(ctx context.Context, _ interface{}) (interface{}, error) {
+					return nil, nil
+				})
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
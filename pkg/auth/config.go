@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAPIKeyHeader  = "x-api-key"
+	defaultClientIDClaim = "client_id"
+	defaultSubjectClaim  = "sub"
+	defaultAdminService  = "/item_composition.ItemCompositionAdminService/"
+)
+
+// Config enables authentication of gRPC calls. PublicMethods are full method
+// names served without it; a name ending with `/` matches a whole service.
+//
+//	auth:
+//	  enabled: true
+//	  api_key_header: x-api-key
+//	  public_methods:
+//	    - /grpc.health.v1.Health/
+//	  jwt:
+//	    jwks_path: /etc/item-composition-service/jwks.json
+//	    issuer: https://auth.example.com
+//	    audience: item-composition-service
+//	    leeway: 30s
+//	    claims:
+//	      client_id: azp
+//	  admin:
+//	    client_ids: [ops-console]
+//	    scope: item-composition:admin
+type Config struct {
+	Enabled       bool         `yaml:"enabled"`
+	APIKeyHeader  string       `yaml:"api_key_header"`
+	PublicMethods []string     `yaml:"public_methods"`
+	JWT           *JWTConfig   `yaml:"jwt,omitempty"`
+	Admin         *AdminConfig `yaml:"admin,omitempty"`
+}
+
+// AdminConfig allows the admin methods, the admin service by default, to the
+// listed clients and to bearer tokens granted Scope. Without it admin methods
+// are denied to every caller.
+type AdminConfig struct {
+	Methods   []string `yaml:"methods"`
+	ClientIDs []string `yaml:"client_ids"`
+	Scope     string   `yaml:"scope"`
+}
+
+// JWTConfig validates bearer tokens against the keys of a local JWKS file.
+// The issuer and audience are checked if set.
+type JWTConfig struct {
+	JWKSPath string        `yaml:"jwks_path"`
+	Issuer   string        `yaml:"issuer"`
+	Audience string        `yaml:"audience"`
+	Leeway   time.Duration `yaml:"leeway"`
+	Claims   ClaimsConfig  `yaml:"claims"`
+}
+
+// ClaimsConfig names the claims mapped into the Identity, `client_id` and
+// `sub` by default.
+type ClaimsConfig struct {
+	ClientID string `yaml:"client_id"`
+	Subject  string `yaml:"subject"`
+}
+
+func (c *Config) apiKeyHeader() string {
+	if c.APIKeyHeader == "" {
+		return defaultAPIKeyHeader
+	}
+	return strings.ToLower(c.APIKeyHeader)
+}
+
+func (c *Config) public(fullMethod string) bool {
+	return matchMethod(c.PublicMethods, fullMethod)
+}
+
+func (c *Config) admin() *AdminConfig {
+	if c.Admin == nil {
+		return &AdminConfig{}
+	}
+	return c.Admin
+}
+
+func (c *AdminConfig) restricts(fullMethod string) bool {
+	if len(c.Methods) == 0 {
+		return matchMethod([]string{defaultAdminService}, fullMethod)
+	}
+	return matchMethod(c.Methods, fullMethod)
+}
+
+// allows reports whether id is a listed client or carries the admin scope in
+// the `scope` (space separated) or `scp` claim of its token.
+func (c *AdminConfig) allows(id *Identity) bool {
+	if slices.Contains(c.ClientIDs, id.ClientID) {
+		return true
+	}
+	if c.Scope == "" {
+		return false
+	}
+
+	if scope, ok := id.Claims["scope"].(string); ok && slices.Contains(strings.Fields(scope), c.Scope) {
+		return true
+	}
+	switch scp := id.Claims["scp"].(type) {
+	case string:
+		return slices.Contains(strings.Fields(scp), c.Scope)
+	case []any:
+		return slices.Contains(scp, any(c.Scope))
+	}
+	return false
+}
+
+// matchMethod reports whether fullMethod is one of methods; a name ending
+// with `/` matches a whole service.
+func matchMethod(methods []string, fullMethod string) bool {
+	for _, m := range methods {
+		if m == fullMethod || strings.HasSuffix(m, "/") && strings.HasPrefix(fullMethod, m) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *JWTConfig) validate() error {
+	if c.JWKSPath == "" {
+		return fmt.Errorf("missing required `auth.jwt.jwks_path`")
+	}
+	if c.Leeway < 0 {
+		return fmt.Errorf("`auth.jwt.leeway` must not be negative")
+	}
+	return nil
+}
+
+func (c *ClaimsConfig) clientID() string {
+	if c.ClientID == "" {
+		return defaultClientIDClaim
+	}
+	return c.ClientID
+}
+
+func (c *ClaimsConfig) subject() string {
+	if c.Subject == "" {
+		return defaultSubjectClaim
+	}
+	return c.Subject
+}
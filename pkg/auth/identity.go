@@ -0,0 +1,59 @@
+// Package auth authenticates gRPC callers by static API keys or JWT bearer
+// tokens and stores the caller identity in the request context.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// ErrUnknownKey is returned by a KeyStore for keys of no client.
+var ErrUnknownKey = errors.New("unknown api key")
+
+// KeyStore finds the client owning an API key by the key hash.
+type KeyStore interface {
+	ClientByAPIKey(ctx context.Context, hash string) (clientID string, err error)
+}
+
+// HashAPIKey is the hash API keys are stored and looked up by, so the keys
+// themselves are never stored.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Identity is an authenticated caller. Claims are the claims of the JWT, nil
+// for API keys.
+type Identity struct {
+	ClientID string
+	Subject  string
+	Method   string
+	Claims   map[string]any
+}
+
+type contextKey struct{}
+
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the caller identity, nil for unauthenticated requests.
+func FromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(contextKey{}).(*Identity)
+	return id
+}
+
+// ClientIDFromContext returns the client id of the caller, empty if unknown.
+func ClientIDFromContext(ctx context.Context) string {
+	if id := FromContext(ctx); id != nil {
+		return id.ClientID
+	}
+	return ""
+}
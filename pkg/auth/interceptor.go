@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"item_compositiom_service/pkg/logger"
+	"item_compositiom_service/pkg/metrics"
+	"item_compositiom_service/pkg/tracer"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	headerAuthorization = "authorization"
+	bearerPrefix        = "bearer "
+	methodNone          = "none"
+
+	resultOK              = "ok"
+	resultUnauthenticated = "unauthenticated"
+	resultForbidden       = "forbidden"
+	resultError           = "error"
+)
+
+type Interceptor struct {
+	config  *Config
+	keys    KeyStore
+	jwt     *jwtVerifier
+	lgr     *zap.Logger
+	limiter *metrics.LabelLimiter
+	total   *prometheus.CounterVec
+}
+
+func NewInterceptor(config *Config, keys KeyStore, registry metrics.MetricsRegistry, lgr *zap.SugaredLogger) (*Interceptor, error) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	i := &Interceptor{
+		config:  config,
+		keys:    keys,
+		lgr:     lgr.Desugar().With(zap.String("component", "auth")),
+		limiter: metrics.Limiter(registry),
+		total: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "grpc_server",
+			Name:      "auth_total",
+			Help:      "The total number of authenticated and rejected grpc requests",
+		}, []string{"auth_method", "client", "result"}),
+	}
+
+	if config.Enabled && config.JWT != nil {
+		verifier, err := newJWTVerifier(config.JWT)
+		if err != nil {
+			return nil, fmt.Errorf("create jwt verifier: %w", err)
+		}
+		i.jwt = verifier
+	}
+
+	if err := registry.GetRegistry().Register(i.total); err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+// GetServerInterceptor rejects calls of non-public methods without a valid
+// API key or bearer token with Unauthenticated, and calls of admin methods by
+// callers the admin config does not allow with PermissionDenied. The caller
+// identity is stored in the context and added to the context logger and spans,
+// and its client is passed to the metrics interceptor. It must run after the
+// logger and metrics interceptors so that rejected calls are logged and
+// counted.
+func (i *Interceptor) GetServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		if !i.config.Enabled || info != nil && i.config.public(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		id, method, err := i.authenticate(ctx)
+		if err != nil {
+			result := resultUnauthenticated
+			if status.Code(err) != codes.Unauthenticated {
+				result = resultError
+			}
+			i.total.WithLabelValues(method, "", result).Inc()
+			logger.FromContext(ctx).Desugar().Info("Request rejected",
+				zap.String("component", "auth"),
+				zap.String("auth_method", method),
+				zap.Error(err),
+			)
+			return nil, err
+		}
+
+		client := i.limiter.Value("client", id.ClientID)
+		metrics.SetClient(ctx, id.ClientID)
+
+		if admin := i.config.admin(); info != nil && admin.restricts(info.FullMethod) && !admin.allows(id) {
+			i.total.WithLabelValues(method, client, resultForbidden).Inc()
+			logger.FromContext(ctx).Desugar().Info("Request rejected",
+				zap.String("component", "auth"),
+				zap.String("auth_method", method),
+				zap.String("client_id", id.ClientID),
+				zap.String("reason", "not an admin"),
+			)
+			return nil, status.Error(codes.PermissionDenied, "admin access required")
+		}
+
+		i.total.WithLabelValues(method, client, resultOK).Inc()
+
+		trace.SpanFromContext(ctx).SetAttributes(tracer.ClientID(id.ClientID))
+		ctx = tracer.WithAttributes(ctx, tracer.ClientID(id.ClientID))
+		ctx = logger.ContextWithKV(ctx, "client_id", id.ClientID, "auth_method", id.Method)
+
+		return handler(WithIdentity(ctx, id), req)
+	}
+}
+
+// authenticate checks the API key header, then the bearer token. It returns
+// the method tried, none if the call has no credentials.
+func (i *Interceptor) authenticate(ctx context.Context) (*Identity, string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if key := first(md.Get(i.config.apiKeyHeader())); key != "" {
+		id, err := i.apiKey(ctx, key)
+		return id, MethodAPIKey, err
+	}
+
+	if auth := first(md.Get(headerAuthorization)); len(auth) > len(bearerPrefix) && strings.EqualFold(auth[:len(bearerPrefix)], bearerPrefix) {
+		id, err := i.bearer(auth[len(bearerPrefix):])
+		return id, MethodJWT, err
+	}
+
+	return nil, methodNone, status.Error(codes.Unauthenticated, "missing credentials")
+}
+
+func (i *Interceptor) apiKey(ctx context.Context, key string) (*Identity, error) {
+	if i.keys == nil {
+		return nil, status.Error(codes.Unauthenticated, "api keys are not accepted")
+	}
+
+	clientID, err := i.keys.ClientByAPIKey(ctx, HashAPIKey(key))
+	if errors.Is(err, ErrUnknownKey) {
+		return nil, status.Error(codes.Unauthenticated, "invalid api key")
+	}
+	if err != nil {
+		i.lgr.Error("Failed to look up api key", zap.Error(err))
+		return nil, status.Error(codes.Unavailable, "api key lookup failed")
+	}
+
+	return &Identity{ClientID: clientID, Subject: clientID, Method: MethodAPIKey}, nil
+}
+
+func (i *Interceptor) bearer(token string) (*Identity, error) {
+	if i.jwt == nil {
+		return nil, status.Error(codes.Unauthenticated, "bearer tokens are not accepted")
+	}
+
+	id, err := i.jwt.verify(strings.TrimSpace(token))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return id, nil
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+// jwksReloadInterval limits how often an unknown key id re-reads the JWKS
+// file, so rotated keys are picked up without a restart.
+const jwksReloadInterval = 10 * time.Second
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type publicKey struct {
+	key crypto.PublicKey
+	// alg restricts the key to one algorithm, any matching one if empty.
+	alg string
+}
+
+// jwks are the signing keys of a JWKS file by key id.
+type jwks struct {
+	path string
+
+	mu       sync.Mutex
+	keys     map[string]publicKey
+	loadedAt time.Time
+}
+
+func loadJWKS(path string) (*jwks, error) {
+	s := &jwks{path: path}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *jwks) load() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("read jwks: %w", err)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("parse jwks %s: %w", s.path, err)
+	}
+
+	s.keys, s.loadedAt = keys, time.Now()
+	return nil
+}
+
+// key returns the key with kid. An unknown kid reloads the file, at most once
+// per jwksReloadInterval; a failed reload keeps the loaded keys.
+func (s *jwks) key(kid string) (publicKey, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.keys[kid]; ok {
+		return key, true
+	}
+
+	if time.Since(s.loadedAt) < jwksReloadInterval {
+		return publicKey{}, false
+	}
+
+	keys := s.keys
+	if err := s.load(); err != nil {
+		s.keys, s.loadedAt = keys, time.Now()
+		return publicKey{}, false
+	}
+
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+func parseJWKS(data []byte) (map[string]publicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]publicKey, len(set.Keys))
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %d %q: %w", i, k.Kid, err)
+		}
+		if _, ok := keys[k.Kid]; ok {
+			return nil, fmt.Errorf("key %d: duplicate kid %q", i, k.Kid)
+		}
+		keys[k.Kid] = publicKey{key: key, alg: k.Alg}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signing keys")
+	}
+
+	return keys, nil
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("e: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		curve, err := ecCurve(k.Crv)
+		if err != nil {
+			return nil, err
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid x")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func ecCurve(name string) (elliptic.Curve, error) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", name)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
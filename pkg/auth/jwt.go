@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// algorithm is a JWS signature algorithm; symmetric and `none` algorithms are
+// not supported, so a token is always signed by a key of the JWKS.
+type algorithm struct {
+	hash   crypto.Hash
+	verify func(key crypto.PublicKey, hash crypto.Hash, digest, signed, sig []byte) bool
+}
+
+var algorithms = map[string]algorithm{
+	"RS256": {crypto.SHA256, verifyPKCS1},
+	"RS384": {crypto.SHA384, verifyPKCS1},
+	"RS512": {crypto.SHA512, verifyPKCS1},
+	"PS256": {crypto.SHA256, verifyPSS},
+	"PS384": {crypto.SHA384, verifyPSS},
+	"PS512": {crypto.SHA512, verifyPSS},
+	"ES256": {crypto.SHA256, verifyECDSA},
+	"ES384": {crypto.SHA384, verifyECDSA},
+	"ES512": {crypto.SHA512, verifyECDSA},
+	"EdDSA": {0, verifyEd25519},
+}
+
+// jwtVerifier validates signed JWTs and maps their claims into an Identity.
+type jwtVerifier struct {
+	config *JWTConfig
+	keys   *jwks
+	now    func() time.Time
+}
+
+func newJWTVerifier(config *JWTConfig) (*jwtVerifier, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	keys, err := loadJWKS(config.JWKSPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jwtVerifier{config: config, keys: keys, now: time.Now}, nil
+}
+
+func (v *jwtVerifier) verify(token string) (*Identity, error) {
+	claims, err := v.parse(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	if err := v.validateClaims(claims); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	clientID, _ := claims[v.config.Claims.clientID()].(string)
+	if clientID == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrInvalidToken, v.config.Claims.clientID())
+	}
+	subject, _ := claims[v.config.Claims.subject()].(string)
+
+	return &Identity{
+		ClientID: clientID,
+		Subject:  subject,
+		Method:   MethodJWT,
+		Claims:   claims,
+	}, nil
+}
+
+// parse checks the signature of a compact JWS and returns its claims.
+func (v *jwtVerifier) parse(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+
+	alg, ok := algorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+
+	key, ok := v.keys.key(header.Kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", header.Kid)
+	}
+	if key.alg != "" && key.alg != header.Alg {
+		return nil, fmt.Errorf("key %q does not allow %s", header.Kid, header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	var digest []byte
+	if alg.hash != 0 {
+		h := alg.hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+	if !alg.verify(key.key, alg.hash, digest, signed, sig) {
+		return nil, fmt.Errorf("signature mismatch")
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("claims: %w", err)
+	}
+
+	return claims, nil
+}
+
+// validateClaims checks exp, which is required, nbf, iss and aud.
+func (v *jwtVerifier) validateClaims(claims map[string]any) error {
+	now := v.now()
+	leeway := v.config.Leeway
+
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return fmt.Errorf("missing exp claim")
+	}
+	if !now.Before(exp.Add(leeway)) {
+		return fmt.Errorf("token expired")
+	}
+
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(leeway).Before(nbf) {
+		return fmt.Errorf("token not valid yet")
+	}
+
+	if v.config.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.config.Issuer {
+			return fmt.Errorf("unexpected issuer %q", iss)
+		}
+	}
+
+	if v.config.Audience != "" && !hasAudience(claims["aud"], v.config.Audience) {
+		return fmt.Errorf("audience %q not allowed", v.config.Audience)
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func numericDate(v any) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*1e9)), true
+}
+
+func hasAudience(aud any, want string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == want
+	case []any:
+		for _, a := range aud {
+			if a == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func verifyPKCS1(key crypto.PublicKey, hash crypto.Hash, digest, _, sig []byte) bool {
+	pub, ok := key.(*rsa.PublicKey)
+	return ok && rsa.VerifyPKCS1v15(pub, hash, digest, sig) == nil
+}
+
+func verifyPSS(key crypto.PublicKey, hash crypto.Hash, digest, _, sig []byte) bool {
+	pub, ok := key.(*rsa.PublicKey)
+	return ok && rsa.VerifyPSS(pub, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+}
+
+// ecdsaCurveBits are the curves of the ES algorithms by their hash.
+var ecdsaCurveBits = map[crypto.Hash]int{
+	crypto.SHA256: 256,
+	crypto.SHA384: 384,
+	crypto.SHA512: 521,
+}
+
+// verifyECDSA checks a signature of the fixed size r || s form of JWS made
+// with the curve of the algorithm.
+func verifyECDSA(key crypto.PublicKey, hash crypto.Hash, digest, _, sig []byte) bool {
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok || pub.Curve.Params().BitSize != ecdsaCurveBits[hash] {
+		return false
+	}
+
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	if len(sig) != 2*size {
+		return false
+	}
+
+	r := new(big.Int).SetBytes(sig[:size])
+	s := new(big.Int).SetBytes(sig[size:])
+	return ecdsa.Verify(pub, digest, r, s)
+}
+
+func verifyEd25519(key crypto.PublicKey, _ crypto.Hash, _, signed, sig []byte) bool {
+	pub, ok := key.(ed25519.PublicKey)
+	return ok && ed25519.Verify(pub, signed, sig)
+}
@@ -0,0 +1,305 @@
+// Package bundle implements the template bundle format: a tar.gz archive with
+// a manifest, templates, provider specs and locale catalogs. The manifest is
+// the first member and lists every other member with its SHA-256, so a bundle
+// is deployed and loaded as a whole.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ManifestPath    = "manifest.yaml"
+	FormatVersion   = 1
+	maxMemberSize   = 16 << 20
+	memberExtension = ".yaml"
+)
+
+// Member kinds and the bundle directories they are stored in.
+const (
+	KindTemplate = "template"
+	KindProvider = "provider"
+	KindCatalog  = "catalog"
+)
+
+var kindDirs = map[string]string{
+	"templates": KindTemplate,
+	"providers": KindProvider,
+	"locales":   KindCatalog,
+}
+
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+type Manifest struct {
+	Version   int       `yaml:"version"`
+	Name      string    `yaml:"name"`
+	CreatedAt time.Time `yaml:"created_at"`
+	Files     []File    `yaml:"files"`
+}
+
+type File struct {
+	Path   string `yaml:"path"`
+	Kind   string `yaml:"kind"`
+	SHA256 string `yaml:"sha256"`
+	Size   int64  `yaml:"size"`
+}
+
+// ID is the file name without the extension: the template id, provider or
+// locale name.
+func (f File) ID() string {
+	return strings.TrimSuffix(path.Base(f.Path), memberExtension)
+}
+
+type Bundle struct {
+	Manifest Manifest
+	// Digest is the SHA-256 of the manifest; it identifies the bundle.
+	Digest string
+	files  map[string][]byte
+}
+
+// Files returns the manifest entries of kind in manifest order.
+func (b *Bundle) Files(kind string) []File {
+	var res []File
+	for _, f := range b.Manifest.Files {
+		if f.Kind == kind {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+func (b *Bundle) Content(f File) []byte {
+	return b.files[f.Path]
+}
+
+// Build writes a bundle of the yaml files in the templates, providers and
+// locales subdirectories of dir.
+func Build(dir, name string, w io.Writer) (*Manifest, error) {
+	manifest := Manifest{
+		Version:   FormatVersion,
+		Name:      name,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	contents := make(map[string][]byte)
+
+	subdirs := make([]string, 0, len(kindDirs))
+	for subdir := range kindDirs {
+		subdirs = append(subdirs, subdir)
+	}
+	sort.Strings(subdirs)
+
+	for _, subdir := range subdirs {
+		entries, err := os.ReadDir(filepath.Join(dir, subdir))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", subdir, err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != memberExtension {
+				continue
+			}
+
+			content, err := os.ReadFile(filepath.Join(dir, subdir, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", entry.Name(), err)
+			}
+
+			p := path.Join(subdir, entry.Name())
+			contents[p] = content
+			manifest.Files = append(manifest.Files, File{
+				Path:   p,
+				Kind:   kindDirs[subdir],
+				SHA256: checksum(content),
+				Size:   int64(len(content)),
+			})
+		}
+	}
+
+	if len(manifest.Files) == 0 {
+		return nil, fmt.Errorf("no templates, providers or locales in %s", dir)
+	}
+
+	manifestData, err := yaml.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("encode manifest: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if err := writeMember(tw, ManifestPath, manifestData, manifest.CreatedAt); err != nil {
+		return nil, err
+	}
+	for _, f := range manifest.Files {
+		if err := writeMember(tw, f.Path, contents[f.Path], manifest.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("write bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("write bundle: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+func writeMember(tw *tar.Writer, name string, content []byte, modTime time.Time) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(content)),
+		ModTime: modTime,
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// Read reads a bundle and checks it against its manifest: every member must
+// be listed with a matching size and checksum, and every listed file must be
+// present.
+func Read(r io.Reader) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	b := &Bundle{files: make(map[string][]byte)}
+
+	name, manifestData, err := readMember(tr)
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %s is missing", ErrInvalidBundle, ManifestPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if name != ManifestPath {
+		return nil, fmt.Errorf("%w: first member is %s, expected %s", ErrInvalidBundle, name, ManifestPath)
+	}
+
+	if err := yaml.Unmarshal(manifestData, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("%w: decode manifest: %w", ErrInvalidBundle, err)
+	}
+	if b.Manifest.Version != FormatVersion {
+		return nil, fmt.Errorf("%w: unsupported format version %d", ErrInvalidBundle, b.Manifest.Version)
+	}
+	b.Digest = checksum(manifestData)
+
+	listed := make(map[string]File, len(b.Manifest.Files))
+	for _, f := range b.Manifest.Files {
+		if err := checkFile(f); err != nil {
+			return nil, err
+		}
+		if _, ok := listed[f.Path]; ok {
+			return nil, fmt.Errorf("%w: %s is listed twice", ErrInvalidBundle, f.Path)
+		}
+		listed[f.Path] = f
+	}
+
+	for {
+		name, content, err := readMember(tr)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		f, ok := listed[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not in the manifest", ErrInvalidBundle, name)
+		}
+		if _, ok := b.files[name]; ok {
+			return nil, fmt.Errorf("%w: %s is stored twice", ErrInvalidBundle, name)
+		}
+		if int64(len(content)) != f.Size || checksum(content) != f.SHA256 {
+			return nil, fmt.Errorf("%w: %s checksum mismatch", ErrInvalidBundle, name)
+		}
+
+		b.files[name] = content
+	}
+
+	for _, f := range b.Manifest.Files {
+		if _, ok := b.files[f.Path]; !ok {
+			return nil, fmt.Errorf("%w: %s is missing", ErrInvalidBundle, f.Path)
+		}
+	}
+
+	return b, nil
+}
+
+// readMember reads the next regular member.
+func readMember(tr *tar.Reader) (string, []byte, error) {
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return "", nil, io.EOF
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+		}
+
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return "", nil, fmt.Errorf("%w: %s is not a regular file", ErrInvalidBundle, hdr.Name)
+		}
+		if hdr.Size > maxMemberSize {
+			return "", nil, fmt.Errorf("%w: %s is larger than %d bytes", ErrInvalidBundle, hdr.Name, maxMemberSize)
+		}
+
+		content, err := io.ReadAll(io.LimitReader(tr, maxMemberSize))
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: read %s: %w", ErrInvalidBundle, hdr.Name, err)
+		}
+
+		return hdr.Name, content, nil
+	}
+}
+
+func checkFile(f File) error {
+	dir, name := path.Split(f.Path)
+	kind, ok := kindDirs[strings.TrimSuffix(dir, "/")]
+	if !ok || path.Clean(f.Path) != f.Path || path.Ext(name) != memberExtension {
+		return fmt.Errorf("%w: unexpected path %s", ErrInvalidBundle, f.Path)
+	}
+	if f.Kind != kind {
+		return fmt.Errorf("%w: %s has kind %s, expected %s", ErrInvalidBundle, f.Path, f.Kind, kind)
+	}
+
+	return nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
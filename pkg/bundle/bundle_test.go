@@ -0,0 +1,236 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	testTemplate = `
+kind: Template
+metadata:
+  name: card
+spec:
+  title:
+    type: "string"
+    value: "{{item.title}}"
+`
+	testProvider = `
+version: v1
+kind: ProviderGRPC
+metadata:
+  name: reaction
+spec:
+  transport:
+    address: localhost:3000
+    timeout: 1s
+  payload:
+    headers:
+      x-app-name: test
+  methods:
+    - package: reaction.internal
+      service: ReactionInternalService
+      method: GetReactionCounters
+      type: DomainBatch
+      timeout: 1s
+      request:
+        domain_ids: item.id
+      response:
+        itemId: items.domain_id
+`
+	testCatalog = `
+kind: Messages
+metadata:
+  locale: en
+spec:
+  messages:
+    reactions.title: "Reactions"
+`
+)
+
+func writeTestDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	}
+
+	return dir
+}
+
+func buildTestBundle(t *testing.T) []byte {
+	t.Helper()
+
+	dir := writeTestDir(t, map[string]string{
+		"templates/card.yaml":     testTemplate,
+		"templates/README.md":     "not a member",
+		"providers/reaction.yaml": testProvider,
+		"locales/en.yaml":         testCatalog,
+	})
+
+	var buf bytes.Buffer
+	_, err := Build(dir, "v1.2.3", &buf)
+	require.NoError(t, err)
+
+	return buf.Bytes()
+}
+
+type member struct {
+	name    string
+	content []byte
+}
+
+func writeTarGz(t *testing.T, members []member) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for _, m := range members {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: m.name, Mode: 0644, Size: int64(len(m.content))}))
+		_, err := tw.Write(m.content)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func manifestOf(t *testing.T, files ...member) []byte {
+	t.Helper()
+
+	manifest := Manifest{Version: FormatVersion, Name: "test"}
+	for _, f := range files {
+		manifest.Files = append(manifest.Files, File{
+			Path:   f.name,
+			Kind:   KindTemplate,
+			SHA256: checksum(f.content),
+			Size:   int64(len(f.content)),
+		})
+	}
+
+	data, err := yaml.Marshal(manifest)
+	require.NoError(t, err)
+	return data
+}
+
+func TestBuildRead(t *testing.T) {
+	b, err := Read(bytes.NewReader(buildTestBundle(t)))
+	require.NoError(t, err)
+
+	assert.Equal(t, "v1.2.3", b.Manifest.Name)
+	assert.Len(t, b.Digest, 64)
+	require.Len(t, b.Manifest.Files, 3)
+
+	templates := b.Files(KindTemplate)
+	require.Len(t, templates, 1)
+	assert.Equal(t, "templates/card.yaml", templates[0].Path)
+	assert.Equal(t, "card", templates[0].ID())
+	assert.Equal(t, testTemplate, string(b.Content(templates[0])))
+
+	assert.Len(t, b.Files(KindProvider), 1)
+	assert.Len(t, b.Files(KindCatalog), 1)
+	assert.NoError(t, Validate(b))
+}
+
+func TestRead_Invalid(t *testing.T) {
+	card := member{"templates/card.yaml", []byte(testTemplate)}
+	other := member{"templates/other.yaml", []byte(testTemplate)}
+
+	tests := []struct {
+		name    string
+		members []member
+		err     string
+	}{
+		{
+			name:    "manifest is not first",
+			members: []member{card, {ManifestPath, manifestOf(t, card)}},
+			err:     "first member is templates/card.yaml",
+		},
+		{
+			name:    "checksum mismatch",
+			members: []member{{ManifestPath, manifestOf(t, card)}, {card.name, []byte("kind: View")}},
+			err:     "checksum mismatch",
+		},
+		{
+			name:    "unlisted member",
+			members: []member{{ManifestPath, manifestOf(t, card)}, card, other},
+			err:     "templates/other.yaml is not in the manifest",
+		},
+		{
+			name:    "missing member",
+			members: []member{{ManifestPath, manifestOf(t, card, other)}, card},
+			err:     "templates/other.yaml is missing",
+		},
+		{
+			name:    "stored twice",
+			members: []member{{ManifestPath, manifestOf(t, card)}, card, card},
+			err:     "stored twice",
+		},
+		{
+			name:    "unexpected path",
+			members: []member{{ManifestPath, manifestOf(t, member{"../card.yaml", card.content})}},
+			err:     "unexpected path ../card.yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(bytes.NewReader(writeTarGz(t, tt.members)))
+			require.ErrorIs(t, err, ErrInvalidBundle)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{
+			name:  "invalid catalog",
+			files: map[string]string{"locales/en.yaml": "kind: Messages\nmetadata:\n  locale: en\nspec:\n  messages:\n    title: 1\n"},
+			err:   "locales/en.yaml",
+		},
+		{
+			name:  "provider in template",
+			files: map[string]string{"templates/card.yaml": testProvider},
+			err:   "provider specs belong to providers/",
+		},
+		{
+			name:  "invalid provider",
+			files: map[string]string{"providers/reaction.yaml": "kind: ProviderGRPC\nspec: [\n"},
+			err:   "providers/reaction.yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			_, err := Build(writeTestDir(t, tt.files), "test", &buf)
+			require.NoError(t, err)
+
+			b, err := Read(&buf)
+			require.NoError(t, err)
+
+			err = Validate(b)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
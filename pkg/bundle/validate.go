@@ -0,0 +1,84 @@
+package bundle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"item_compositiom_service/pkg/i18n"
+	"item_compositiom_service/pkg/provider"
+
+	"gopkg.in/yaml.v3"
+)
+
+type catalogDocument struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Locale string `yaml:"locale"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Messages map[string]any `yaml:"messages"`
+	} `yaml:"spec"`
+}
+
+// Validate parses the provider specs and locale catalogs of b without
+// registering them. Templates are validated by the loader, which parses them
+// anyway; here they are only checked not to embed provider specs or catalogs,
+// which belong to their own directories.
+func Validate(b *Bundle) error {
+	var errs []error
+
+	for _, f := range b.Files(KindTemplate) {
+		if err := checkTemplate(b.Content(f)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Path, err))
+		}
+	}
+
+	for _, f := range b.Files(KindProvider) {
+		if _, err := provider.NewGRPCProviderParser().Parse(b.Content(f)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Path, err))
+		}
+	}
+
+	for _, f := range b.Files(KindCatalog) {
+		var doc catalogDocument
+		if err := yaml.Unmarshal(b.Content(f), &doc); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Path, err))
+			continue
+		}
+		if doc.Kind != "Messages" {
+			errs = append(errs, fmt.Errorf("%s: kind %s, expected Messages", f.Path, doc.Kind))
+			continue
+		}
+		if _, err := i18n.ParseCatalog(doc.Metadata.Locale, doc.Spec.Messages); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func checkTemplate(content []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+
+	for {
+		var doc struct {
+			Kind string `yaml:"kind"`
+		}
+
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch doc.Kind {
+		case "ProviderGRPC":
+			return fmt.Errorf("provider specs belong to providers/")
+		case "Messages":
+			return fmt.Errorf("catalogs belong to locales/")
+		}
+	}
+}
@@ -33,6 +33,22 @@ func (s *backgroundSetGetter[K, V]) Set(k K, v V, updateTime time.Time) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.set(k, v, updateTime)
+}
+
+func (s *backgroundSetGetter[K, V]) SetBatch(values map[K]V, deleted []K, updateTime time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range deleted {
+		s.delete(k)
+	}
+	for k, v := range values {
+		s.set(k, v, updateTime)
+	}
+}
+
+func (s *backgroundSetGetter[K, V]) set(k K, v V, updateTime time.Time) {
 	if prev, ok := s.data[k]; ok {
 		prev.lastUpdated = updateTime
 		prev.value = v
@@ -44,12 +60,32 @@ func (s *backgroundSetGetter[K, V]) Set(k K, v V, updateTime time.Time) {
 	s.data[k].value = v
 }
 
+func (s *backgroundSetGetter[K, V]) Delete(k K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delete(k)
+}
+
+func (s *backgroundSetGetter[K, V]) delete(k K) {
+	if entry, ok := s.data[k]; ok {
+		delete(s.data, k)
+		s.entryPool.Put(entry)
+	}
+}
+
 func (s *backgroundSetGetter[K, V]) Get(k K) (V, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	var res V
+
 	v, ok := s.data[k]
-	return v.value, ok
+	if !ok {
+		return res, false
+	}
+
+	return v.value, true
 }
 
 func (s *backgroundSetGetter[K, V]) LastUpdated(key K) (time.Time, bool) {
@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackgroundSetGetter(t *testing.T) {
+	s := newBackgroundSetGetter[string, string](time.Minute)
+
+	// Get dereferenced the entry of a missing key and panicked.
+	assert.NotPanics(t, func() {
+		v, ok := s.Get("a")
+		assert.False(t, ok)
+		assert.Empty(t, v)
+	})
+
+	updated := time.Now()
+	s.Set("a", "1", updated)
+
+	v, ok := s.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", v)
+
+	lastUpdated, ok := s.LastUpdated("a")
+	assert.True(t, ok)
+	assert.True(t, updated.Equal(lastUpdated))
+	assert.Equal(t, 1, s.Len())
+}
@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type clockEntry[K comparable, V any] struct {
+	key         K
+	value       V
+	lastUpdated time.Time
+	referenced  atomic.Bool
+}
+
+// clockSetGetter approximates LRU with the CLOCK algorithm: Get only sets a
+// reference bit under the read lock, and on overflow the hand sweeps the slots,
+// clearing reference bits until it finds an entry that was not read since the
+// previous sweep.
+type clockSetGetter[K comparable, V any] struct {
+	data     map[K]int
+	slots    []*clockEntry[K, V]
+	free     []int
+	hand     int
+	mu       sync.RWMutex
+	ttl      time.Duration
+	capacity int
+	now      func() time.Time
+	onEvict  func(reason string)
+}
+
+func newClockSetGetter[K comparable, V any](capacity int, ttl time.Duration) *clockSetGetter[K, V] {
+	free := make([]int, capacity)
+	for i := range free {
+		free[i] = capacity - 1 - i
+	}
+
+	return &clockSetGetter[K, V]{
+		data:     make(map[K]int, capacity),
+		slots:    make([]*clockEntry[K, V], capacity),
+		free:     free,
+		ttl:      ttl,
+		capacity: capacity,
+		now:      time.Now,
+		onEvict:  func(string) {},
+	}
+}
+
+func (c *clockSetGetter[K, V]) Set(k K, v V, updateTime time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(k, v, updateTime)
+}
+
+func (c *clockSetGetter[K, V]) SetBatch(values map[K]V, deleted []K, updateTime time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, k := range deleted {
+		c.delete(k)
+	}
+	for k, v := range values {
+		c.set(k, v, updateTime)
+	}
+}
+
+func (c *clockSetGetter[K, V]) set(k K, v V, updateTime time.Time) {
+	if c.capacity == 0 {
+		return
+	}
+
+	if i, ok := c.data[k]; ok {
+		entry := c.slots[i]
+		entry.value = v
+		entry.lastUpdated = updateTime
+		entry.referenced.Store(true)
+		return
+	}
+
+	if len(c.free) == 0 {
+		c.evict()
+	}
+
+	i := c.free[len(c.free)-1]
+	c.free = c.free[:len(c.free)-1]
+
+	entry := &clockEntry[K, V]{key: k, value: v, lastUpdated: updateTime}
+	c.slots[i] = entry
+	c.data[k] = i
+}
+
+func (c *clockSetGetter[K, V]) evict() {
+	for {
+		entry := c.slots[c.hand]
+		if entry.referenced.Swap(false) {
+			c.hand = (c.hand + 1) % c.capacity
+			continue
+		}
+
+		c.remove(c.hand)
+		c.hand = (c.hand + 1) % c.capacity
+		c.onEvict(evictionCapacity)
+		return
+	}
+}
+
+func (c *clockSetGetter[K, V]) remove(i int) {
+	delete(c.data, c.slots[i].key)
+	c.slots[i] = nil
+	c.free = append(c.free, i)
+}
+
+func (c *clockSetGetter[K, V]) Delete(k K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.delete(k)
+}
+
+func (c *clockSetGetter[K, V]) delete(k K) {
+	if i, ok := c.data[k]; ok {
+		c.remove(i)
+	}
+}
+
+func (c *clockSetGetter[K, V]) lock() {
+	c.mu.Lock()
+}
+
+func (c *clockSetGetter[K, V]) unlock() {
+	c.mu.Unlock()
+}
+
+// Get treats expired entries as missing; they are removed by CleanUp or
+// overwritten by the next Set.
+func (c *clockSetGetter[K, V]) Get(k K) (V, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var res V
+
+	i, ok := c.data[k]
+	if !ok {
+		return res, false
+	}
+
+	entry := c.slots[i]
+	if c.expired(entry) {
+		return res, false
+	}
+
+	entry.referenced.Store(true)
+	return entry.value, true
+}
+
+func (c *clockSetGetter[K, V]) LastUpdated(key K) (time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	i, ok := c.data[key]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return c.slots[i].lastUpdated, true
+}
+
+func (c *clockSetGetter[K, V]) CleanUp() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cleaned := 0
+
+	for _, i := range c.data {
+		if c.expired(c.slots[i]) {
+			cleaned++
+			c.remove(i)
+		}
+	}
+
+	return cleaned
+}
+
+func (c *clockSetGetter[K, V]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.data)
+}
+
+func (c *clockSetGetter[K, V]) expired(entry *clockEntry[K, V]) bool {
+	return c.now().Sub(entry.lastUpdated) >= c.ttl
+}
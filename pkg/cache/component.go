@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"item_compositiom_service/pkg/metrics"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
 type SetGetter[K comparable, V any] interface {
@@ -18,6 +20,43 @@ type SetGetter[K comparable, V any] interface {
 	Len() int
 }
 
+// BatchSetter is implemented by set getters that delete and store several
+// values under one lock, so that readers see either all of the changes or none.
+type BatchSetter[K comparable, V any] interface {
+	SetBatch(values map[K]V, deleted []K, updateTime time.Time)
+}
+
+// Deleter is implemented by set getters that can drop an entry, e.g. one its
+// source no longer has.
+type Deleter[K comparable] interface {
+	Delete(k K)
+}
+
+// Delete removes k from sg and reports whether sg supports deletion.
+func Delete[K comparable, V any](sg SetGetter[K, V], k K) bool {
+	d, ok := sg.(Deleter[K])
+	if ok {
+		d.Delete(k)
+	}
+	return ok
+}
+
+// SetBatch deletes keys and stores values in sg at once if it is a
+// BatchSetter, one by one otherwise. A key both deleted and stored is stored.
+func SetBatch[K comparable, V any](sg SetGetter[K, V], values map[K]V, deleted []K, updateTime time.Time) {
+	if bs, ok := sg.(BatchSetter[K, V]); ok {
+		bs.SetBatch(values, deleted, updateTime)
+		return
+	}
+
+	for _, k := range deleted {
+		Delete(sg, k)
+	}
+	for k, v := range values {
+		sg.Set(k, v, updateTime)
+	}
+}
+
 type Cache[K comparable, V any] struct {
 	wg     sync.WaitGroup
 	mu     sync.Mutex
@@ -27,7 +66,11 @@ type Cache[K comparable, V any] struct {
 	incrementalUpdateFunc func(context.Context, SetGetter[K, V], K) error
 	cfg                   config
 	setGetter             SetGetter[K, V]
+	loads                 singleflight.Group
+	refreshing            sync.Map
+	restored              atomic.Bool
 	lgr                   *zap.Logger
+	now                   func() time.Time
 }
 
 func New[K comparable, V any](
@@ -61,6 +104,7 @@ func New[K comparable, V any](
 	})
 
 	cache := Cache[K, V]{
+		closed:                make(chan struct{}),
 		cfg:                   cfg,
 		fullUpdateFunc:        fullUpdateFunc,
 		incrementalUpdateFunc: incrementalUpdateFunc,
@@ -68,13 +112,23 @@ func New[K comparable, V any](
 			zap.String("component", label),
 			zap.String("cache_name", cfg.Name),
 		),
+		now: time.Now,
 	}
 
 	if cfg.Type == Background {
-		cache.closed = make(chan struct{})
 		cache.setGetter = newBackgroundSetGetter[K, V](cfg.TTL)
 	} else {
-		cache.setGetter = newLruSetGetter[K, V](cfg.Capacity, cfg.TTL)
+		onEvict := func(reason string) {
+			collector.cacheEvictions.WithLabelValues(cfg.Name, reason).Inc()
+		}
+
+		if cfg.Shards > 1 || cfg.Eviction == EvictionClock {
+			cache.setGetter = newShardedSetGetter[K, V](cfg.Shards, cfg.Capacity, cfg.TTL, cfg.Eviction, onEvict)
+		} else {
+			sg := newLruSetGetter[K, V](cfg.Capacity, cfg.TTL)
+			sg.onEvict = onEvict
+			cache.setGetter = sg
+		}
 	}
 
 	return &cache
@@ -108,6 +162,12 @@ func (c *Cache[K, V]) Start(ctx context.Context) (err error) {
 	case <-ctx.Done():
 		return ctx.Err()
 	case <-done:
+		// A cache warmed up with Restore keeps serving its entries and
+		// retries the full update in the background.
+		if err != nil && c.restored.Load() && c.setGetter.Len() > 0 {
+			c.lgr.Warn("Serving restored entries after failed first update", zap.Error(err))
+			err = nil
+		}
 		if err == nil {
 			c.backgroundUpdate()
 		}
@@ -128,6 +188,7 @@ func (c *Cache[K, V]) backgroundUpdate() {
 			select {
 			case <-t.C:
 				c.mu.Lock()
+				failed := false
 				if c.cfg.Type == Background {
 					ctx, cancel := context.WithTimeout(context.Background(), c.cfg.TTL)
 					defer cancel()
@@ -145,12 +206,16 @@ func (c *Cache[K, V]) backgroundUpdate() {
 						collector.cacheFullUpdates.WithLabelValues(c.cfg.Name).Inc()
 						c.lgr.Info("Cache updated successfully")
 					}
+					failed = err != nil
 				}
 
-				cleaned := c.setGetter.CleanUp()
-				if cleaned > 0 {
-					collector.cacheEvictions.WithLabelValues(c.cfg.Name).Add(float64(cleaned))
-					c.lgr.Info(fmt.Sprintf("Cleaned %d objects due ttl", cleaned))
+				// Entries are not aged out while the source is unavailable.
+				if !failed {
+					cleaned := c.setGetter.CleanUp()
+					if cleaned > 0 {
+						collector.cacheEvictions.WithLabelValues(c.cfg.Name, evictionTTL).Add(float64(cleaned))
+						c.lgr.Info(fmt.Sprintf("Cleaned %d objects due ttl", cleaned))
+					}
 				}
 
 				collector.cacheSize.WithLabelValues(c.cfg.Name).Set(float64(c.setGetter.Len()))
@@ -196,6 +261,26 @@ func (c *Cache[K, V]) Get(k K) (V, bool) {
 	return v, ok
 }
 
+// Set stores a value loaded outside of the update functions.
+func (c *Cache[K, V]) Set(k K, v V) {
+	c.setGetter.Set(k, v, time.Now())
+	collector.cacheSize.WithLabelValues(c.cfg.Name).Set(float64(c.setGetter.Len()))
+}
+
+// Restore stores a value with its original update time, e.g. one read from a
+// snapshot before Start, so that update functions comparing LastUpdated with
+// the source still reload changed entries.
+func (c *Cache[K, V]) Restore(k K, v V, updateTime time.Time) {
+	c.setGetter.Set(k, v, updateTime)
+	c.restored.Store(true)
+	collector.cacheSize.WithLabelValues(c.cfg.Name).Set(float64(c.setGetter.Len()))
+}
+
+// LastUpdated returns the update time of k without counting a hit or a miss.
+func (c *Cache[K, V]) LastUpdated(k K) (time.Time, bool) {
+	return c.setGetter.LastUpdated(k)
+}
+
 func (c *Cache[K, V]) Close(ctx context.Context) error {
 	close(c.closed)
 
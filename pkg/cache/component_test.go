@@ -0,0 +1,352 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"item_compositiom_service/pkg/metrics"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestLRU(
+	t *testing.T,
+	load func(context.Context, SetGetter[string, string], string) error,
+	opts ...Option,
+) *Cache[string, string] {
+	t.Helper()
+
+	noFullUpdate := func(context.Context, SetGetter[string, string]) error { return nil }
+
+	opts = append([]Option{WithName(t.Name()), WithLRU(), WithCapacity(10), WithTTL(time.Minute)}, opts...)
+	c := New[string, string](zap.NewNop().Sugar(), &metrics.NoopMetrics{}, noFullUpdate, load, opts...)
+	t.Cleanup(func() {
+		require.NoError(t, c.Close(context.Background()))
+	})
+
+	return c
+}
+
+func TestCache_Load(t *testing.T) {
+	errBackend := errors.New("backend")
+
+	tests := []struct {
+		name      string
+		preset    map[string]string
+		key       string
+		loadErr   error
+		skipStore bool
+		want      string
+		wantErr   error
+		wantLoads int
+	}{
+		{
+			name:      "hit does not call loader",
+			preset:    map[string]string{"a": "cached"},
+			key:       "a",
+			want:      "cached",
+			wantLoads: 0,
+		},
+		{
+			name:      "miss loads value",
+			key:       "a",
+			want:      "loaded:a",
+			wantLoads: 1,
+		},
+		{
+			name:      "loader error is returned",
+			key:       "a",
+			loadErr:   errBackend,
+			wantErr:   errBackend,
+			wantLoads: 1,
+		},
+		{
+			name:      "key not stored by loader",
+			key:       "a",
+			skipStore: true,
+			wantErr:   ErrNotFound,
+			wantLoads: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var loads atomic.Int32
+			c := newTestLRU(t, func(_ context.Context, sg SetGetter[string, string], k string) error {
+				loads.Add(1)
+				if tt.loadErr != nil {
+					return tt.loadErr
+				}
+				if !tt.skipStore {
+					sg.Set(k, "loaded:"+k, time.Now())
+				}
+				return nil
+			})
+
+			for k, v := range tt.preset {
+				c.Set(k, v)
+			}
+
+			got, err := c.Load(context.Background(), tt.key)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+			assert.EqualValues(t, tt.wantLoads, loads.Load())
+		})
+	}
+}
+
+func TestCache_LoadDeduplicates(t *testing.T) {
+	var loads atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	c := newTestLRU(t, func(_ context.Context, sg SetGetter[string, string], k string) error {
+		if loads.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		sg.Set(k, "v", time.Now())
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.Load(context.Background(), "a")
+			assert.NoError(t, err)
+			assert.Equal(t, "v", v)
+		}()
+	}
+
+	<-started
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, loads.Load())
+}
+
+func TestCache_LoadOutlivesCaller(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	c := newTestLRU(t, func(ctx context.Context, sg SetGetter[string, string], k string) error {
+		close(started)
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		sg.Set(k, "v", time.Now())
+		return nil
+	}, WithLoadTimeout(time.Second))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.Load(ctx, "a")
+		first <- err
+	}()
+	<-started
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := c.Load(context.Background(), "a")
+		second <- err
+	}()
+
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled, "The canceled caller stops waiting")
+
+	close(release)
+	assert.NoError(t, <-second, "The shared load is not canceled with its first caller")
+
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "v", v)
+}
+
+func TestCache_LoadTimeout(t *testing.T) {
+	c := newTestLRU(t, func(ctx context.Context, _ SetGetter[string, string], _ string) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithLoadTimeout(10*time.Millisecond))
+
+	_, err := c.Load(context.Background(), "a")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestCache_RefreshAhead(t *testing.T) {
+	var loads atomic.Int32
+
+	c := newTestLRU(t, func(_ context.Context, sg SetGetter[string, string], k string) error {
+		n := loads.Add(1)
+		sg.Set(k, k+string(rune('0'+n)), time.Now())
+		return nil
+	}, WithTTL(time.Minute), WithRefreshAhead(0.5))
+	refreshes := testutil.ToFloat64(collector.cacheRefreshes.WithLabelValues(t.Name()))
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	v, err := c.Load(context.Background(), "a")
+	require.NoError(t, err)
+	assert.Equal(t, "a1", v)
+
+	// Before the refresh threshold the cached value is served as is.
+	now = now.Add(20 * time.Second)
+	v, err = c.Load(context.Background(), "a")
+	require.NoError(t, err)
+	assert.Equal(t, "a1", v)
+	assert.EqualValues(t, 1, loads.Load())
+
+	// Past the threshold the old value is still served while it reloads.
+	now = now.Add(20 * time.Second)
+	v, err = c.Load(context.Background(), "a")
+	require.NoError(t, err)
+	assert.Equal(t, "a1", v)
+
+	assert.Eventually(t, func() bool {
+		v, ok := c.Get("a")
+		return ok && v == "a2"
+	}, time.Second, 5*time.Millisecond)
+	assert.EqualValues(t, refreshes+1, testutil.ToFloat64(collector.cacheRefreshes.WithLabelValues(t.Name())))
+}
+
+func TestCache_EvictionMetrics(t *testing.T) {
+	c := newTestLRU(t, nil, WithCapacity(1))
+	evictions := func(reason string) float64 {
+		return testutil.ToFloat64(collector.cacheEvictions.WithLabelValues(t.Name(), reason))
+	}
+	capacity, ttl := evictions(evictionCapacity), evictions(evictionTTL)
+
+	c.Set("a", "1")
+	c.Set("b", "2")
+
+	assert.Equal(t, capacity+1, evictions(evictionCapacity))
+	assert.Equal(t, ttl, evictions(evictionTTL))
+}
+
+func TestCache_StartRestored(t *testing.T) {
+	errSource := errors.New("source unavailable")
+
+	tests := []struct {
+		name     string
+		restored bool
+		partial  bool
+		wantErr  error
+	}{
+		{name: "empty cache fails", wantErr: errSource},
+		{name: "partially updated cache fails", partial: true, wantErr: errSource},
+		{name: "restored cache serves stale entries", restored: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New[string, string](
+				zap.NewNop().Sugar(),
+				&metrics.NoopMetrics{},
+				func(_ context.Context, sg SetGetter[string, string]) error {
+					if tt.partial {
+						sg.Set("b", "partial", time.Now())
+					}
+					return errSource
+				},
+				nil,
+				WithName(t.Name()),
+				WithTTL(time.Hour),
+			)
+			t.Cleanup(func() {
+				require.NoError(t, c.Close(context.Background()))
+			})
+
+			updated := time.Now().Add(-time.Minute)
+			if tt.restored {
+				c.Restore("a", "stale", updated)
+			}
+
+			err := c.Start(context.Background())
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			v, ok := c.Get("a")
+			assert.True(t, ok)
+			assert.Equal(t, "stale", v)
+
+			lastUpdated, ok := c.LastUpdated("a")
+			assert.True(t, ok)
+			assert.True(t, updated.Equal(lastUpdated))
+		})
+	}
+}
+
+func TestSetBatch(t *testing.T) {
+	values := map[string]string{"a": "1", "b": "2", "c": "3"}
+	updated := time.Now()
+
+	for name, sg := range map[string]SetGetter[string, string]{
+		"background": newBackgroundSetGetter[string, string](time.Minute),
+		"lru":        newLruSetGetter[string, string](10, time.Minute),
+		"clock":      newClockSetGetter[string, string](10, time.Minute),
+		"sharded":    newShardedSetGetter[string, string](4, 16, time.Minute, EvictionLRU, func(string) {}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, ok := sg.(BatchSetter[string, string])
+			assert.True(t, ok)
+
+			sg.Set("old", "0", updated)
+			sg.Set("a", "0", updated)
+			SetBatch(sg, values, []string{"old", "a"}, updated)
+
+			_, ok = sg.Get("old")
+			assert.False(t, ok, "Deleted keys are removed")
+			assert.Equal(t, len(values), sg.Len(), "Keys both deleted and set are kept")
+			for k, v := range values {
+				got, ok := sg.Get(k)
+				assert.True(t, ok, k)
+				assert.Equal(t, v, got)
+
+				lastUpdated, _ := sg.LastUpdated(k)
+				assert.Equal(t, updated, lastUpdated)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	for name, sg := range map[string]SetGetter[string, string]{
+		"background": newBackgroundSetGetter[string, string](time.Minute),
+		"lru":        newLruSetGetter[string, string](10, time.Minute),
+		"clock":      newClockSetGetter[string, string](10, time.Minute),
+		"sharded":    newShardedSetGetter[string, string](4, 10, time.Minute, EvictionLRU, func(string) {}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			sg.Set("a", "1", time.Now())
+			sg.Set("b", "2", time.Now())
+
+			assert.True(t, Delete(sg, "a"))
+			assert.True(t, Delete(sg, "missing"))
+
+			_, ok := sg.Get("a")
+			assert.False(t, ok)
+			_, ok = sg.LastUpdated("a")
+			assert.False(t, ok)
+			assert.Equal(t, 1, sg.Len())
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrNotFound is returned by Load when the update function did not store the
+// requested key.
+var ErrNotFound = errors.New("not found")
+
+// Load returns the value of k. On a miss it calls the incremental update
+// function to load the key (read-through). With WithRefreshAhead an entry
+// close to expiry is returned and reloaded in the background.
+func (c *Cache[K, V]) Load(ctx context.Context, k K) (V, error) {
+	if v, ok := c.Get(k); ok {
+		c.refreshAhead(ctx, k)
+		return v, nil
+	}
+
+	return c.Reload(ctx, k)
+}
+
+// Reload loads k with the incremental update function even if it is cached.
+// Concurrent loads of one key share a call, which is not canceled with the
+// request that started it and is bounded by the load timeout; every caller
+// waits for it only until its own ctx is done.
+func (c *Cache[K, V]) Reload(ctx context.Context, k K) (V, error) {
+	loaded := c.loads.DoChan(loadKey(k), func() (any, error) {
+		ctx, cancel := c.detach(ctx)
+		defer cancel()
+
+		return c.load(ctx, k)
+	})
+
+	select {
+	case res := <-loaded:
+		v, _ := res.Val.(V)
+		return v, res.Err
+	case <-ctx.Done():
+		var v V
+		return v, ctx.Err()
+	}
+}
+
+func (c *Cache[K, V]) load(ctx context.Context, k K) (V, error) {
+	start := time.Now()
+	err := c.incrementalUpdateFunc(ctx, c.setGetter, k)
+	collector.loadDurationHistogram.WithLabelValues(c.cfg.Name).Observe(time.Since(start).Seconds())
+
+	var v V
+	if err != nil {
+		collector.cacheLoads.WithLabelValues(c.cfg.Name, "error").Inc()
+		return v, err
+	}
+
+	v, ok := c.setGetter.Get(k)
+	if !ok {
+		collector.cacheLoads.WithLabelValues(c.cfg.Name, "not_found").Inc()
+		return v, ErrNotFound
+	}
+
+	collector.cacheLoads.WithLabelValues(c.cfg.Name, "ok").Inc()
+	collector.cacheSize.WithLabelValues(c.cfg.Name).Set(float64(c.setGetter.Len()))
+	return v, nil
+}
+
+// detach returns the context of a shared load: it keeps the values of ctx but
+// not its cancellation and deadline, and expires after the load timeout.
+func (c *Cache[K, V]) detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := c.cfg.LoadTimeout
+	if timeout <= 0 {
+		timeout = c.cfg.TTL
+	}
+
+	return context.WithTimeout(context.WithoutCancel(ctx), timeout)
+}
+
+func (c *Cache[K, V]) refreshAhead(ctx context.Context, k K) {
+	if c.cfg.RefreshAhead <= 0 {
+		return
+	}
+
+	updated, ok := c.setGetter.LastUpdated(k)
+	if !ok || c.now().Sub(updated) < time.Duration(c.cfg.RefreshAhead*float64(c.cfg.TTL)) {
+		return
+	}
+
+	if _, refreshing := c.refreshing.LoadOrStore(k, struct{}{}); refreshing {
+		return
+	}
+
+	collector.cacheRefreshes.WithLabelValues(c.cfg.Name).Inc()
+
+	ctx = context.WithoutCancel(ctx)
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer c.refreshing.Delete(k)
+
+		if _, err := c.Reload(ctx, k); err != nil {
+			c.lgr.Warn("Failed to refresh cache entry", zap.Error(err))
+		}
+	}()
+}
+
+// loadKey is the singleflight key of k.
+func loadKey[K comparable](k K) string {
+	return fmt.Sprintf("%#v", k)
+}
@@ -6,12 +6,21 @@ import (
 	"time"
 )
 
+// Eviction reasons reported by the LRU set getter.
+const (
+	evictionTTL      = "ttl"
+	evictionCapacity = "capacity"
+)
+
 type lruEntry[K comparable, V any] struct {
 	key         K
 	value       V
 	lastUpdated time.Time
 }
 
+// lruSetGetter keeps at most capacity entries, evicting the least recently
+// used one on overflow. Entries older than ttl are expired: Get treats them as
+// missing and CleanUp removes them.
 type lruSetGetter[K comparable, V any] struct {
 	data      map[K]*list.Element
 	list      *list.List
@@ -19,6 +28,8 @@ type lruSetGetter[K comparable, V any] struct {
 	entryPool sync.Pool
 	ttl       time.Duration
 	capacity  int
+	now       func() time.Time
+	onEvict   func(reason string)
 }
 
 func newLruSetGetter[K comparable, V any](capacity int, ttl time.Duration) *lruSetGetter[K, V] {
@@ -32,6 +43,8 @@ func newLruSetGetter[K comparable, V any](capacity int, ttl time.Duration) *lruS
 		},
 		ttl:      ttl,
 		capacity: capacity,
+		now:      time.Now,
+		onEvict:  func(string) {},
 	}
 }
 
@@ -39,6 +52,22 @@ func (l *lruSetGetter[K, V]) Set(k K, v V, updateTime time.Time) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	l.set(k, v, updateTime)
+}
+
+func (l *lruSetGetter[K, V]) SetBatch(values map[K]V, deleted []K, updateTime time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, k := range deleted {
+		l.delete(k)
+	}
+	for k, v := range values {
+		l.set(k, v, updateTime)
+	}
+}
+
+func (l *lruSetGetter[K, V]) set(k K, v V, updateTime time.Time) {
 	if l.capacity == 0 {
 		return
 	}
@@ -55,6 +84,7 @@ func (l *lruSetGetter[K, V]) Set(k K, v V, updateTime time.Time) {
 		oldest := l.list.Front()
 		if oldest != nil {
 			l.removeElement(oldest)
+			l.onEvict(evictionCapacity)
 		}
 	}
 
@@ -67,6 +97,27 @@ func (l *lruSetGetter[K, V]) Set(k K, v V, updateTime time.Time) {
 	l.data[k] = elem
 }
 
+func (l *lruSetGetter[K, V]) Delete(k K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.delete(k)
+}
+
+func (l *lruSetGetter[K, V]) delete(k K) {
+	if elem, ok := l.data[k]; ok {
+		l.removeElement(elem)
+	}
+}
+
+func (l *lruSetGetter[K, V]) lock() {
+	l.mu.Lock()
+}
+
+func (l *lruSetGetter[K, V]) unlock() {
+	l.mu.Unlock()
+}
+
 func (l *lruSetGetter[K, V]) Get(k K) (V, bool) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -74,17 +125,18 @@ func (l *lruSetGetter[K, V]) Get(k K) (V, bool) {
 	var res V
 
 	elem, ok := l.data[k]
-	if ok && time.Since(elem.Value.(*lruEntry[K, V]).lastUpdated) < l.ttl {
-		l.removeElement(elem)
+	if !ok {
 		return res, false
 	}
 
-	if ok {
-		l.list.MoveToBack(elem)
-		return elem.Value.(*lruEntry[K, V]).value, true
+	if l.expired(elem.Value.(*lruEntry[K, V])) {
+		l.removeElement(elem)
+		l.onEvict(evictionTTL)
+		return res, false
 	}
 
-	return res, false
+	l.list.MoveToBack(elem)
+	return elem.Value.(*lruEntry[K, V]).value, true
 }
 
 func (l *lruSetGetter[K, V]) LastUpdated(key K) (time.Time, bool) {
@@ -106,8 +158,7 @@ func (l *lruSetGetter[K, V]) CleanUp() int {
 	cleaned := 0
 
 	for _, elem := range l.data {
-		entry := elem.Value.(*lruEntry[K, V])
-		if time.Since(entry.lastUpdated) > l.ttl {
+		if l.expired(elem.Value.(*lruEntry[K, V])) {
 			cleaned++
 			l.removeElement(elem)
 		}
@@ -123,9 +174,16 @@ func (l *lruSetGetter[K, V]) Len() int {
 	return len(l.data)
 }
 
+func (l *lruSetGetter[K, V]) expired(entry *lruEntry[K, V]) bool {
+	return l.now().Sub(entry.lastUpdated) >= l.ttl
+}
+
 func (l *lruSetGetter[K, V]) removeElement(elem *list.Element) {
 	entry := elem.Value.(*lruEntry[K, V])
 	delete(l.data, entry.key)
-	l.entryPool.Put(entry)
 	l.list.Remove(elem)
+
+	var zero lruEntry[K, V]
+	*entry = zero
+	l.entryPool.Put(entry)
 }
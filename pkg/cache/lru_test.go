@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func TestLruSetGetter(t *testing.T) {
+	type op struct {
+		set     string
+		get     string
+		advance time.Duration
+	}
+
+	tests := []struct {
+		name      string
+		capacity  int
+		ttl       time.Duration
+		ops       []op
+		present   []string
+		missing   []string
+		evictions map[string]int
+	}{
+		{
+			// Get used to drop entries younger than ttl, so every fresh
+			// entry was a miss and only expired ones were served.
+			name:     "entry is returned right after set",
+			capacity: 2,
+			ttl:      time.Minute,
+			ops:      []op{{set: "a"}, {get: "a"}, {get: "a"}},
+			present:  []string{"a"},
+		},
+		{
+			name:     "fresh entry is returned",
+			capacity: 2,
+			ttl:      time.Minute,
+			ops:      []op{{set: "a"}, {advance: 59 * time.Second}},
+			present:  []string{"a"},
+		},
+		{
+			name:      "entry expires at ttl",
+			capacity:  2,
+			ttl:       time.Minute,
+			ops:       []op{{set: "a"}, {advance: time.Minute}},
+			missing:   []string{"a"},
+			evictions: map[string]int{evictionTTL: 1},
+		},
+		{
+			name:      "least recently set entry is evicted",
+			capacity:  2,
+			ttl:       time.Minute,
+			ops:       []op{{set: "a"}, {set: "b"}, {set: "c"}},
+			present:   []string{"b", "c"},
+			missing:   []string{"a"},
+			evictions: map[string]int{evictionCapacity: 1},
+		},
+		{
+			name:      "get marks entry as recently used",
+			capacity:  2,
+			ttl:       time.Minute,
+			ops:       []op{{set: "a"}, {set: "b"}, {get: "a"}, {set: "c"}},
+			present:   []string{"a", "c"},
+			missing:   []string{"b"},
+			evictions: map[string]int{evictionCapacity: 1},
+		},
+		{
+			name:     "update refreshes existing entry",
+			capacity: 2,
+			ttl:      time.Minute,
+			ops: []op{
+				{set: "a"}, {set: "b"}, {advance: 30 * time.Second},
+				{set: "a"}, {advance: 40 * time.Second},
+			},
+			present:   []string{"a"},
+			missing:   []string{"b"},
+			evictions: map[string]int{evictionTTL: 1},
+		},
+		{
+			name:     "zero capacity stores nothing",
+			capacity: 0,
+			ttl:      time.Minute,
+			ops:      []op{{set: "a"}},
+			missing:  []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Unix(0, 0)}
+			evictions := map[string]int{}
+
+			l := newLruSetGetter[string, string](tt.capacity, tt.ttl)
+			l.now = clock.Now
+			l.onEvict = func(reason string) { evictions[reason]++ }
+
+			for _, o := range tt.ops {
+				switch {
+				case o.set != "":
+					l.Set(o.set, o.set, clock.now)
+				case o.get != "":
+					l.Get(o.get)
+				default:
+					clock.now = clock.now.Add(o.advance)
+				}
+			}
+
+			for _, k := range tt.present {
+				v, ok := l.Get(k)
+				assert.True(t, ok, k)
+				assert.Equal(t, k, v)
+			}
+
+			for _, k := range tt.missing {
+				_, ok := l.Get(k)
+				assert.False(t, ok, k)
+			}
+
+			if tt.evictions == nil {
+				tt.evictions = map[string]int{}
+			}
+			assert.Equal(t, tt.evictions, evictions)
+		})
+	}
+}
+
+func TestLruSetGetter_CleanUp(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+
+	l := newLruSetGetter[string, int](10, time.Minute)
+	l.now = clock.Now
+
+	l.Set("old", 1, clock.now)
+	clock.now = clock.now.Add(45 * time.Second)
+	l.Set("new", 2, clock.now)
+	clock.now = clock.now.Add(30 * time.Second)
+
+	assert.Equal(t, 1, l.CleanUp())
+	assert.Equal(t, 1, l.Len())
+
+	_, ok := l.LastUpdated("old")
+	assert.False(t, ok)
+
+	// The freed slot is reused without evicting the live entry.
+	l.Set("another", 3, clock.now)
+	v, ok := l.Get("new")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+}
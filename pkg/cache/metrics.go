@@ -18,6 +18,9 @@ type metricsCollector struct {
 	cacheHits                          prometheus.CounterVec
 	cacheMisses                        prometheus.CounterVec
 	cacheEvictions                     prometheus.CounterVec
+	cacheLoads                         prometheus.CounterVec
+	cacheRefreshes                     prometheus.CounterVec
+	loadDurationHistogram              prometheus.HistogramVec
 	cacheErrors                        prometheus.CounterVec
 	cacheFullUpdates                   prometheus.CounterVec
 	cacheIncrementalUpdates            prometheus.CounterVec
@@ -46,6 +49,21 @@ func newMetricsCollector(registry metrics.MetricsRegistry) (*metricsCollector, e
 	metrics.cacheEvictions = *prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "cache_evictions",
 		Help: "The number of cache evictions",
+	}, []string{"cache_name", "reason"})
+
+	metrics.cacheLoads = *prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_loads",
+		Help: "The number of read-through loads",
+	}, []string{"cache_name", "result"})
+
+	metrics.cacheRefreshes = *prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_refresh_ahead",
+		Help: "The number of refresh-ahead loads",
+	}, []string{"cache_name"})
+
+	metrics.loadDurationHistogram = *prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "cache_load_duration_seconds",
+		Help: "The duration of read-through loads",
 	}, []string{"cache_name"})
 
 	metrics.cacheErrors = *prometheus.NewCounterVec(prometheus.CounterOpts{
@@ -79,6 +97,9 @@ func newMetricsCollector(registry metrics.MetricsRegistry) (*metricsCollector, e
 		r.Register(metrics.cacheHits),
 		r.Register(metrics.cacheMisses),
 		r.Register(metrics.cacheEvictions),
+		r.Register(metrics.cacheLoads),
+		r.Register(metrics.cacheRefreshes),
+		r.Register(metrics.loadDurationHistogram),
 		r.Register(metrics.cacheErrors),
 		r.Register(metrics.cacheFullUpdates),
 		r.Register(metrics.cacheIncrementalUpdates),
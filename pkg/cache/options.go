@@ -11,11 +11,23 @@ const (
 	LRU
 )
 
+// EvictionPolicy selects how a full LRU cache shard picks the entry to evict.
+type EvictionPolicy int
+
+const (
+	EvictionLRU EvictionPolicy = iota
+	EvictionClock
+)
+
 type config struct {
-	Name     string
-	Type     CacheType
-	TTL      time.Duration
-	Capacity int
+	Name         string
+	Type         CacheType
+	TTL          time.Duration
+	Capacity     int
+	RefreshAhead float64
+	LoadTimeout  time.Duration
+	Shards       int
+	Eviction     EvictionPolicy
 }
 
 type Option func(*config)
@@ -43,3 +55,36 @@ func WithTTL(ttl time.Duration) Option {
 		c.TTL = ttl
 	}
 }
+
+// WithRefreshAhead makes Load reload an LRU entry in the background once it is
+// older than factor * TTL, so hot keys do not expire. factor is in (0, 1).
+func WithRefreshAhead(factor float64) Option {
+	return func(c *config) {
+		c.RefreshAhead = factor
+	}
+}
+
+// WithLoadTimeout bounds the calls of the incremental update function made by
+// Load and Reload, TTL by default.
+func WithLoadTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.LoadTimeout = timeout
+	}
+}
+
+// WithShards splits an LRU cache into n hash-partitioned shards, rounded up to
+// a power of two, each holding an equal part of the capacity rounded up. The
+// shard count is lowered to fit a smaller capacity, one entry per shard.
+func WithShards(n int) Option {
+	return func(c *config) {
+		c.Shards = n
+	}
+}
+
+// WithClock makes a sharded LRU cache evict with the CLOCK algorithm, whose
+// Get does not take a write lock.
+func WithClock() Option {
+	return func(c *config) {
+		c.Eviction = EvictionClock
+	}
+}
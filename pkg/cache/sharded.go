@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"fmt"
+	"hash/maphash"
+	"math/bits"
+	"time"
+)
+
+// shardedSetGetter hash-partitions keys over independent LRU or CLOCK shards,
+// so concurrent readers of different keys do not contend on one lock.
+type shardedSetGetter[K comparable, V any] struct {
+	shards []shard[K, V]
+	mask   uint64
+	hash   func(K) uint64
+}
+
+// shard is an LRU or CLOCK set getter whose lock a batch can hold while
+// changing it.
+type shard[K comparable, V any] interface {
+	SetGetter[K, V]
+	Deleter[K]
+	lock()
+	unlock()
+	set(k K, v V, updateTime time.Time)
+	delete(k K)
+}
+
+// newShardedSetGetter rounds shards up to a power of two and splits capacity
+// evenly between them. A capacity below the shard count lowers the shard
+// count, so that every shard holds at least one entry.
+func newShardedSetGetter[K comparable, V any](
+	shards, capacity int,
+	ttl time.Duration,
+	eviction EvictionPolicy,
+	onEvict func(reason string),
+) *shardedSetGetter[K, V] {
+	if shards < 1 {
+		shards = 1
+	}
+	n := 1 << bits.Len(uint(shards-1))
+	for n > 1 && n > capacity {
+		n >>= 1
+	}
+	shardCapacity := (capacity + n - 1) / n
+
+	s := &shardedSetGetter[K, V]{
+		shards: make([]shard[K, V], n),
+		mask:   uint64(n - 1),
+		hash:   keyHasher[K](maphash.MakeSeed()),
+	}
+
+	for i := range s.shards {
+		if eviction == EvictionClock {
+			sg := newClockSetGetter[K, V](shardCapacity, ttl)
+			sg.onEvict = onEvict
+			s.shards[i] = sg
+		} else {
+			sg := newLruSetGetter[K, V](shardCapacity, ttl)
+			sg.onEvict = onEvict
+			s.shards[i] = sg
+		}
+	}
+
+	return s
+}
+
+func (s *shardedSetGetter[K, V]) shard(k K) shard[K, V] {
+	return s.shards[s.hash(k)&s.mask]
+}
+
+// keyHasher picks the hash function once per key type; converting the key to
+// an interface that does not escape keeps Get free of allocations.
+func keyHasher[K comparable](seed maphash.Seed) func(K) uint64 {
+	var zero K
+	switch any(zero).(type) {
+	case string:
+		return func(k K) uint64 { return maphash.String(seed, any(k).(string)) }
+	case int:
+		return func(k K) uint64 { return mix64(uint64(any(k).(int))) }
+	case int64:
+		return func(k K) uint64 { return mix64(uint64(any(k).(int64))) }
+	case uint64:
+		return func(k K) uint64 { return mix64(any(k).(uint64)) }
+	default:
+		return func(k K) uint64 { return maphash.String(seed, fmt.Sprint(k)) }
+	}
+}
+
+// mix64 is the splitmix64 finalizer; it spreads sequential integer keys over
+// the low bits used to pick a shard.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func (s *shardedSetGetter[K, V]) Set(k K, v V, updateTime time.Time) {
+	s.shard(k).Set(k, v, updateTime)
+}
+
+func (s *shardedSetGetter[K, V]) Get(k K) (V, bool) {
+	return s.shard(k).Get(k)
+}
+
+func (s *shardedSetGetter[K, V]) Delete(k K) {
+	s.shard(k).Delete(k)
+}
+
+// SetBatch locks the shards of all keys in index order, so that readers see
+// the whole batch or none of it.
+func (s *shardedSetGetter[K, V]) SetBatch(values map[K]V, deleted []K, updateTime time.Time) {
+	locked := make([]bool, len(s.shards))
+	for _, k := range deleted {
+		locked[s.hash(k)&s.mask] = true
+	}
+	for k := range values {
+		locked[s.hash(k)&s.mask] = true
+	}
+
+	for i, shard := range s.shards {
+		if locked[i] {
+			shard.lock()
+			defer shard.unlock()
+		}
+	}
+
+	for _, k := range deleted {
+		s.shard(k).delete(k)
+	}
+	for k, v := range values {
+		s.shard(k).set(k, v, updateTime)
+	}
+}
+
+func (s *shardedSetGetter[K, V]) LastUpdated(key K) (time.Time, bool) {
+	return s.shard(key).LastUpdated(key)
+}
+
+func (s *shardedSetGetter[K, V]) CleanUp() int {
+	cleaned := 0
+	for _, shard := range s.shards {
+		cleaned += shard.CleanUp()
+	}
+
+	return cleaned
+}
+
+func (s *shardedSetGetter[K, V]) Len() int {
+	n := 0
+	for _, shard := range s.shards {
+		n += shard.Len()
+	}
+
+	return n
+}
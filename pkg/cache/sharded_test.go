@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockSetGetter(t *testing.T) {
+	tests := []struct {
+		name    string
+		reads   []string
+		present []string
+		missing []string
+	}{
+		{
+			name:    "unreferenced entry is evicted first",
+			reads:   []string{"a"},
+			present: []string{"a", "c", "d"},
+			missing: []string{"b"},
+		},
+		{
+			name:    "all referenced evicts in clock order",
+			reads:   []string{"a", "b", "c"},
+			present: []string{"b", "c", "d"},
+			missing: []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evictions := 0
+			c := newClockSetGetter[string, string](3, time.Minute)
+			c.onEvict = func(string) { evictions++ }
+
+			now := time.Now()
+			for _, k := range []string{"a", "b", "c"} {
+				c.Set(k, k, now)
+			}
+			for _, k := range tt.reads {
+				c.Get(k)
+			}
+			c.Set("d", "d", now)
+
+			for _, k := range tt.present {
+				_, ok := c.LastUpdated(k)
+				assert.True(t, ok, k)
+			}
+			for _, k := range tt.missing {
+				_, ok := c.LastUpdated(k)
+				assert.False(t, ok, k)
+			}
+			assert.Equal(t, 1, evictions)
+			assert.Equal(t, 3, c.Len())
+		})
+	}
+}
+
+func TestClockSetGetter_Expiry(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+
+	c := newClockSetGetter[string, int](2, time.Minute)
+	c.now = clock.Now
+
+	c.Set("a", 1, clock.now)
+	clock.now = clock.now.Add(time.Minute)
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.CleanUp())
+	assert.Equal(t, 0, c.Len())
+
+	c.Set("b", 2, clock.now)
+	v, ok := c.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+}
+
+func TestShardedSetGetter(t *testing.T) {
+	for _, eviction := range []EvictionPolicy{EvictionLRU, EvictionClock} {
+		var evictions atomic.Int32
+		s := newShardedSetGetter[int, int](3, 64, time.Minute, eviction, func(string) { evictions.Add(1) })
+
+		assert.Len(t, s.shards, 4)
+
+		now := time.Now()
+		for i := range 32 {
+			s.Set(i, i*i, now)
+		}
+		for i := range 32 {
+			v, ok := s.Get(i)
+			if ok {
+				assert.Equal(t, i*i, v)
+			}
+		}
+		assert.Equal(t, 32-int(evictions.Load()), s.Len())
+
+		for i := range 1000 {
+			s.Set(i, i, now)
+		}
+		assert.LessOrEqual(t, s.Len(), 64)
+		assert.Equal(t, 1000-s.Len(), int(evictions.Load()))
+	}
+}
+
+func TestShardedSetGetter_SmallCapacity(t *testing.T) {
+	tests := []struct {
+		shards, capacity int
+		wantShards       int
+	}{
+		{shards: 16, capacity: 3, wantShards: 2},
+		{shards: 16, capacity: 1, wantShards: 1},
+		{shards: 16, capacity: 0, wantShards: 1},
+		{shards: 4, capacity: 4, wantShards: 4},
+	}
+
+	for _, tt := range tests {
+		s := newShardedSetGetter[int, int](tt.shards, tt.capacity, time.Minute, EvictionLRU, func(string) {})
+		assert.Len(t, s.shards, tt.wantShards)
+
+		now := time.Now()
+		for i := range 100 {
+			s.Set(i, i, now)
+		}
+		assert.LessOrEqual(t, s.Len(), tt.capacity+tt.wantShards-1,
+			"capacity %d is exceeded by at most the rounding of %d shards", tt.capacity, tt.wantShards)
+	}
+}
+
+const benchKeys = 10000
+
+func benchmarkSetGetter(b *testing.B, sg SetGetter[string, int]) {
+	keys := make([]string, benchKeys)
+	now := time.Now()
+	for i := range keys {
+		keys[i] = "template:" + strconv.Itoa(i)
+		sg.Set(keys[i], i, now)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			// One write per 16 reads, like template reloads under read traffic.
+			k := keys[i%benchKeys]
+			if i%16 == 0 {
+				sg.Set(k, i, now)
+			} else {
+				sg.Get(k)
+			}
+			i += 7
+		}
+	})
+}
+
+func BenchmarkSetGetter(b *testing.B) {
+	b.Run("background", func(b *testing.B) {
+		benchmarkSetGetter(b, newBackgroundSetGetter[string, int](time.Hour))
+	})
+	b.Run("lru", func(b *testing.B) {
+		benchmarkSetGetter(b, newLruSetGetter[string, int](benchKeys, time.Hour))
+	})
+	b.Run("sharded_lru", func(b *testing.B) {
+		benchmarkSetGetter(b, newShardedSetGetter[string, int](32, benchKeys, time.Hour, EvictionLRU, func(string) {}))
+	})
+	b.Run("sharded_clock", func(b *testing.B) {
+		benchmarkSetGetter(b, newShardedSetGetter[string, int](32, benchKeys, time.Hour, EvictionClock, func(string) {}))
+	})
+}
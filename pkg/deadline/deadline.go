@@ -0,0 +1,108 @@
+// Package deadline bounds the time the server spends on a request and splits
+// what is left of it between provider calls.
+package deadline
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultSafetyMargin is kept from the request deadline for composing and
+// encoding the response when no margin is configured.
+const DefaultSafetyMargin = 20 * time.Millisecond
+
+// ErrBudgetExhausted is returned for optional calls skipped because the
+// request has no time left for them.
+var ErrBudgetExhausted = errors.New("request deadline budget exhausted")
+
+// Config sets the server-side deadlines of requests. Methods are keyed by the
+// full gRPC method name, e.g. `/item_composition.ItemCompositionService/GetItems`,
+// and override the defaults field by field.
+//
+//	deadlines:
+//	  default: 1s
+//	  max: 5s
+//	  safety_margin: 20ms
+//	  methods:
+//	    /item_composition.ItemCompositionService/GetItems:
+//	      default: 300ms
+type Config struct {
+	Default      time.Duration           `yaml:"default"`
+	Max          time.Duration           `yaml:"max"`
+	SafetyMargin time.Duration           `yaml:"safety_margin"`
+	Methods      map[string]MethodConfig `yaml:"methods,omitempty"`
+}
+
+type MethodConfig struct {
+	Default time.Duration `yaml:"default"`
+	Max     time.Duration `yaml:"max"`
+}
+
+// method returns the default and max deadlines of a method, zero if unset.
+func (c *Config) method(fullMethod string) (def, max time.Duration) {
+	def, max = c.Default, c.Max
+	if m, ok := c.Methods[fullMethod]; ok {
+		if m.Default > 0 {
+			def = m.Default
+		}
+		if m.Max > 0 {
+			max = m.Max
+		}
+	}
+
+	if max > 0 && (def <= 0 || def > max) {
+		def = max
+	}
+	return def, max
+}
+
+// bound applies the default deadline to a context without one and caps a
+// later deadline at max.
+func bound(ctx context.Context, def, max time.Duration) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if max > 0 && time.Until(deadline) > max {
+			return context.WithTimeout(ctx, max)
+		}
+		return ctx, func() {}
+	}
+
+	if def > 0 {
+		return context.WithTimeout(ctx, def)
+	}
+	return ctx, func() {}
+}
+
+type marginKey struct{}
+
+func withSafetyMargin(ctx context.Context, margin time.Duration) context.Context {
+	return context.WithValue(ctx, marginKey{}, margin)
+}
+
+func safetyMargin(ctx context.Context) time.Duration {
+	if margin, ok := ctx.Value(marginKey{}).(time.Duration); ok {
+		return margin
+	}
+	return DefaultSafetyMargin
+}
+
+// Timeout returns the timeout of a call made within the request budget: the
+// smaller of timeout and the time left before the deadline of ctx minus the
+// safety margin. A zero timeout means the call has no own limit. ok is false
+// once the budget is exhausted.
+func Timeout(ctx context.Context, timeout time.Duration) (res time.Duration, ok bool) {
+	deadline, hasDeadline := ctx.Deadline()
+	if !hasDeadline {
+		return timeout, true
+	}
+
+	budget := time.Until(deadline) - safetyMargin(ctx)
+	if budget <= 0 {
+		return 0, false
+	}
+
+	if timeout > 0 && timeout < budget {
+		return timeout, true
+	}
+	return budget, true
+}
@@ -0,0 +1,125 @@
+package deadline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+const getItems = "/item_composition.ItemCompositionService/GetItems"
+
+func TestConfig_Method(t *testing.T) {
+	config := &Config{
+		Default: time.Second,
+		Max:     5 * time.Second,
+		Methods: map[string]MethodConfig{
+			getItems:   {Default: 300 * time.Millisecond},
+			"/svc/Big": {Default: 10 * time.Second},
+			"/svc/Own": {Max: 500 * time.Millisecond},
+		},
+	}
+
+	tests := []struct {
+		method   string
+		def, max time.Duration
+	}{
+		{"/svc/Other", time.Second, 5 * time.Second},
+		{getItems, 300 * time.Millisecond, 5 * time.Second},
+		// The default never exceeds the max.
+		{"/svc/Big", 5 * time.Second, 5 * time.Second},
+		{"/svc/Own", 500 * time.Millisecond, 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			def, max := config.method(tt.method)
+			assert.Equal(t, tt.def, def)
+			assert.Equal(t, tt.max, max)
+		})
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	interceptor := NewInterceptor(&Config{
+		Default:      time.Second,
+		Max:          2 * time.Second,
+		SafetyMargin: 50 * time.Millisecond,
+	}).GetServerInterceptor()
+
+	tests := []struct {
+		name   string
+		client time.Duration
+		want   time.Duration
+	}{
+		{"no client deadline", 0, time.Second},
+		{"shorter client deadline", 500 * time.Millisecond, 500 * time.Millisecond},
+		{"capped client deadline", time.Minute, 2 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.client > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.client)
+				defer cancel()
+			}
+
+			var (
+				left   time.Duration
+				margin time.Duration
+			)
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: getItems},
+				func(ctx context.Context, _ interface{}) (interface{}, error) {
+					deadline, ok := ctx.Deadline()
+					assert.True(t, ok)
+					left, margin = time.Until(deadline), safetyMargin(ctx)
+					return nil, nil
+				})
+			assert.NoError(t, err)
+
+			assert.InDelta(t, tt.want, left, float64(100*time.Millisecond))
+			assert.Equal(t, 50*time.Millisecond, margin)
+		})
+	}
+}
+
+func TestInterceptor_NoConfig(t *testing.T) {
+	interceptor := NewInterceptor(nil).GetServerInterceptor()
+
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: getItems},
+		func(ctx context.Context, _ interface{}) (interface{}, error) {
+			_, ok := ctx.Deadline()
+			assert.False(t, ok)
+			return nil, nil
+		})
+	assert.NoError(t, err)
+}
+
+func TestTimeout(t *testing.T) {
+	timeout, ok := Timeout(context.Background(), time.Second)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, timeout)
+
+	ctx, cancel := context.WithTimeout(withSafetyMargin(context.Background(), 100*time.Millisecond), 600*time.Millisecond)
+	defer cancel()
+
+	// The method timeout fits the budget.
+	timeout, ok = Timeout(ctx, 200*time.Millisecond)
+	assert.True(t, ok)
+	assert.Equal(t, 200*time.Millisecond, timeout)
+
+	// The budget is what is left minus the margin.
+	timeout, ok = Timeout(ctx, time.Second)
+	assert.True(t, ok)
+	assert.InDelta(t, 500*time.Millisecond, timeout, float64(50*time.Millisecond))
+
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	_, ok = Timeout(short, time.Second)
+	assert.False(t, ok)
+}
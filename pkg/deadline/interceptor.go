@@ -0,0 +1,44 @@
+package deadline
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+type Interceptor struct {
+	config *Config
+}
+
+func NewInterceptor(config *Config) *Interceptor {
+	if config == nil {
+		config = &Config{}
+	}
+	return &Interceptor{config: config}
+}
+
+// GetServerInterceptor sets the default deadline of the method on requests
+// sent without one and caps longer client deadlines at the method max. The
+// safety margin is stored for Timeout.
+func (i *Interceptor) GetServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		var fullMethod string
+		if info != nil {
+			fullMethod = info.FullMethod
+		}
+
+		def, max := i.config.method(fullMethod)
+		ctx, cancel := bound(ctx, def, max)
+		defer cancel()
+
+		if i.config.SafetyMargin > 0 {
+			ctx = withSafetyMargin(ctx, i.config.SafetyMargin)
+		}
+
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,256 @@
+package expression
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	register("default", fnDefault)
+	register("coalesce", fnCoalesce)
+	register("len", fnLen)
+	register("contains", fnContains)
+	register("get", fnGet)
+}
+
+// default(value, fallback) returns fallback when value is nil or empty.
+func fnDefault(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("default", args, 2, 2); err != nil {
+		return nil, err
+	}
+
+	if isEmpty(args[0]) {
+		return args[1], nil
+	}
+	return args[0], nil
+}
+
+// coalesce(values...) returns the first value that is not nil or empty.
+func fnCoalesce(_ context.Context, args ...any) (any, error) {
+	for _, arg := range args {
+		if !isEmpty(arg) {
+			return arg, nil
+		}
+	}
+	return nil, nil
+}
+
+// len(value) returns the length of a string, array or object; 0 for nil.
+func fnLen(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("len", args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	if args[0] == nil {
+		return 0.0, nil
+	}
+
+	if s, ok := args[0].(string); ok {
+		return float64(len([]rune(s))), nil
+	}
+
+	v := reflect.ValueOf(args[0])
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), nil
+	default:
+		return nil, fmt.Errorf("len() unsupported type %T", args[0])
+	}
+}
+
+// contains(container, value) checks a substring, an array element or an object key.
+func fnContains(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("contains", args, 2, 2); err != nil {
+		return nil, err
+	}
+
+	switch container := args[0].(type) {
+	case nil:
+		return false, nil
+	case string:
+		return strings.Contains(container, ToString(args[1])), nil
+	case map[string]any:
+		_, ok := container[ToString(args[1])]
+		return ok, nil
+	}
+
+	v := reflect.ValueOf(args[0])
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if equal(v.Index(i).Interface(), args[1]) {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return nil, fmt.Errorf("contains() unsupported type %T", args[0])
+	}
+}
+
+// get(value, "a.b[0].c", [fallback]) walks nested objects and arrays and
+// returns fallback (or nil) instead of failing on a missing step.
+func fnGet(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("get", args, 2, 3); err != nil {
+		return nil, err
+	}
+
+	path, ok := args[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("get() path must be a string, got %T", args[1])
+	}
+
+	var fallback any
+	if len(args) == 3 {
+		fallback = args[2]
+	}
+
+	value, found := Lookup(args[0], path)
+	if !found || value == nil {
+		return fallback, nil
+	}
+	return value, nil
+}
+
+// Lookup resolves a dotted path such as `a.b[0].c` or `a.b.0.c` in value.
+func Lookup(value any, path string) (any, bool) {
+	current := value
+	for _, step := range splitPath(path) {
+		switch node := current.(type) {
+		case map[string]any:
+			next, ok := node[step]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []any:
+			idx, err := strconv.Atoi(step)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			current = node[idx]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func splitPath(path string) []string {
+	path = strings.NewReplacer("[", ".", "]", "", "\"", "", "'", "").Replace(path)
+
+	var steps []string
+	for _, step := range strings.Split(path, ".") {
+		if step != "" {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+func expectArgs(name string, args []any, minArgs, maxArgs int) error {
+	if len(args) < minArgs || (maxArgs >= 0 && len(args) > maxArgs) {
+		if minArgs == maxArgs {
+			return fmt.Errorf("%s() expects %d arguments, got %d", name, minArgs, len(args))
+		}
+		return fmt.Errorf("%s() expects %d to %d arguments, got %d", name, minArgs, maxArgs, len(args))
+	}
+	return nil
+}
+
+func isEmpty(v any) bool {
+	if v == nil {
+		return true
+	}
+
+	switch val := v.(type) {
+	case string:
+		return val == ""
+	case []any:
+		return len(val) == 0
+	case map[string]any:
+		return len(val) == 0
+	}
+	return false
+}
+
+func equal(a, b any) bool {
+	af, aok := ToFloat(a)
+	bf, bok := ToFloat(b)
+	if aok && bok {
+		return af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// ToFloat converts numbers and numeric strings to float64.
+func ToFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toNumber(name string, v any) (float64, error) {
+	f, ok := ToFloat(v)
+	if !ok {
+		return 0, fmt.Errorf("%s() expects a number, got %T", name, v)
+	}
+	return f, nil
+}
+
+func toInt(name string, v any) (int, error) {
+	f, err := toNumber(name, v)
+	if err != nil {
+		return 0, err
+	}
+	return int(f), nil
+}
+
+// ToString converts a value to its string form; nil becomes an empty string.
+func ToString(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case fmt.Stringer:
+		return val.String()
+	}
+	return fmt.Sprint(v)
+}
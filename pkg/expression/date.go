@@ -0,0 +1,264 @@
+package expression
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Dates are represented as Unix seconds (float64) inside expressions, so they
+// compare and add like any other number.
+
+var dateLayouts = map[string]string{
+	"RFC3339":  time.RFC3339,
+	"date":     "2006-01-02",
+	"datetime": "2006-01-02 15:04:05",
+	"time":     "15:04",
+	"unix":     "",
+}
+
+var parseLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func init() {
+	register("now", fnNow)
+	register("parseDate", fnParseDate)
+	register("formatDate", fnFormatDate)
+	register("duration", fnDuration)
+	register("addDuration", fnAddDuration)
+	register("dateDiff", fnDateDiff)
+	register("startOfDay", fnStartOfDay)
+}
+
+// now() returns the current time.
+func fnNow(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("now", args, 0, 0); err != nil {
+		return nil, err
+	}
+	return unixSeconds(time.Now()), nil
+}
+
+// parseDate(value, [layout]) parses a string (RFC3339 and common layouts by
+// default) or passes a number through.
+func fnParseDate(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("parseDate", args, 1, 2); err != nil {
+		return nil, err
+	}
+
+	layout := ""
+	if len(args) == 2 {
+		layout = ToString(args[1])
+	}
+
+	t, err := ToTime(args[0], layout)
+	if err != nil {
+		return nil, fmt.Errorf("parseDate(): %w", err)
+	}
+	return unixSeconds(t), nil
+}
+
+// formatDate(value, [layout], [timezone]) formats a date; layout is a Go layout
+// or one of RFC3339, date, datetime, time, unix.
+func fnFormatDate(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("formatDate", args, 1, 3); err != nil {
+		return nil, err
+	}
+
+	t, err := ToTime(args[0], "")
+	if err != nil {
+		return nil, fmt.Errorf("formatDate(): %w", err)
+	}
+
+	layout := time.RFC3339
+	if len(args) >= 2 {
+		layout = ToString(args[1])
+	}
+
+	if len(args) == 3 {
+		loc, err := time.LoadLocation(ToString(args[2]))
+		if err != nil {
+			return nil, fmt.Errorf("formatDate(): %w", err)
+		}
+		t = t.In(loc)
+	}
+
+	return FormatTime(t, layout), nil
+}
+
+// duration("1h30m") returns the duration in seconds; a `d` suffix means days.
+func fnDuration(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("duration", args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	d, err := parseDuration(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("duration(): %w", err)
+	}
+	return d.Seconds(), nil
+}
+
+// addDuration(date, duration) shifts a date by a duration string or seconds.
+func fnAddDuration(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("addDuration", args, 2, 2); err != nil {
+		return nil, err
+	}
+
+	t, err := ToTime(args[0], "")
+	if err != nil {
+		return nil, fmt.Errorf("addDuration(): %w", err)
+	}
+
+	d, err := parseDuration(args[1])
+	if err != nil {
+		return nil, fmt.Errorf("addDuration(): %w", err)
+	}
+	return unixSeconds(t.Add(d)), nil
+}
+
+// dateDiff(from, to, [unit]) returns to - from in seconds, minutes, hours or days.
+func fnDateDiff(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("dateDiff", args, 2, 3); err != nil {
+		return nil, err
+	}
+
+	from, err := ToTime(args[0], "")
+	if err != nil {
+		return nil, fmt.Errorf("dateDiff(): %w", err)
+	}
+
+	to, err := ToTime(args[1], "")
+	if err != nil {
+		return nil, fmt.Errorf("dateDiff(): %w", err)
+	}
+
+	unit := "seconds"
+	if len(args) == 3 {
+		unit = ToString(args[2])
+	}
+
+	diff := to.Sub(from)
+	switch unit {
+	case "seconds":
+		return diff.Seconds(), nil
+	case "minutes":
+		return math.Trunc(diff.Minutes()), nil
+	case "hours":
+		return math.Trunc(diff.Hours()), nil
+	case "days":
+		return math.Trunc(diff.Hours() / 24), nil
+	default:
+		return nil, fmt.Errorf("dateDiff(): unknown unit %s", unit)
+	}
+}
+
+// startOfDay(date, [timezone]) truncates a date to midnight.
+func fnStartOfDay(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("startOfDay", args, 1, 2); err != nil {
+		return nil, err
+	}
+
+	t, err := ToTime(args[0], "")
+	if err != nil {
+		return nil, fmt.Errorf("startOfDay(): %w", err)
+	}
+
+	loc := time.UTC
+	if len(args) == 2 {
+		loc, err = time.LoadLocation(ToString(args[1]))
+		if err != nil {
+			return nil, fmt.Errorf("startOfDay(): %w", err)
+		}
+	}
+
+	t = t.In(loc)
+	return unixSeconds(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)), nil
+}
+
+// FormatTime formats t with a Go layout or a named layout.
+func FormatTime(t time.Time, layout string) string {
+	if named, ok := dateLayouts[layout]; ok {
+		if layout == "unix" {
+			return fmt.Sprint(t.Unix())
+		}
+		layout = named
+	}
+	return t.Format(layout)
+}
+
+// ParseTime parses a date string with layout, or with the common layouts when
+// layout is empty.
+func ParseTime(value, layout string) (time.Time, error) {
+	if layout == "unix" {
+		f, ok := ToFloat(value)
+		if !ok {
+			return time.Time{}, fmt.Errorf("cannot parse unix time %q", value)
+		}
+		return fromUnixSeconds(f), nil
+	}
+
+	if layout != "" {
+		if named, ok := dateLayouts[layout]; ok {
+			layout = named
+		}
+		return time.Parse(layout, value)
+	}
+
+	for _, l := range parseLayouts {
+		if t, err := time.Parse(l, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse date %q", value)
+}
+
+// ToTime converts a date string (parsed with layout) or Unix seconds to time.Time.
+func ToTime(v any, layout string) (time.Time, error) {
+	switch val := v.(type) {
+	case time.Time:
+		return val, nil
+	case string:
+		if f, ok := ToFloat(val); ok && layout == "" {
+			return fromUnixSeconds(f), nil
+		}
+		return ParseTime(val, layout)
+	}
+
+	if f, ok := ToFloat(v); ok {
+		return fromUnixSeconds(f), nil
+	}
+	return time.Time{}, fmt.Errorf("cannot convert %T to date", v)
+}
+
+func parseDuration(v any) (time.Duration, error) {
+	if f, ok := ToFloat(v); ok {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+
+	s := ToString(v)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		f, ok := ToFloat(days)
+		if !ok {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(f * 24 * float64(time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+func fromUnixSeconds(f float64) time.Time {
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second))).UTC()
+}
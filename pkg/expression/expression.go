@@ -0,0 +1,257 @@
+package expression
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/PaesslerAG/gval"
+)
+
+// MaxRange bounds `range` over an integer in interpolation.
+const MaxRange = 10000
+
+// ErrRangeTooLarge is returned by interpolation ranging over an integer above
+// MaxRange.
+var ErrRangeTooLarge = errors.New("range too large")
+
+// Names of the functions injected into interpolation templates by meter.
+const (
+	stepFunc  = "__step"
+	rangeFunc = "__range"
+)
+
+// Func is a library function. The same implementation is exposed to gval
+// expressions (conditions, paths, filters) and to string interpolation.
+type Func func(ctx context.Context, args ...any) (any, error)
+
+var (
+	functions = map[string]Func{}
+
+	langOnce sync.Once
+	lang     gval.Language
+)
+
+func register(name string, fn Func) {
+	if _, exists := functions[name]; exists {
+		panic("expression: function registered twice: " + name)
+	}
+	functions[name] = fn
+}
+
+// Functions returns the sorted names of all library functions.
+func Functions() []string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Language returns gval.Full extended with the library functions.
+func Language() gval.Language {
+	langOnce.Do(func() {
+		extensions := make([]gval.Language, 0, len(functions))
+		for name, fn := range functions {
+			extensions = append(extensions, gval.Function(name, func(ctx context.Context, args ...any) (any, error) {
+				return fn(ctx, args...)
+			}))
+		}
+		lang = gval.Full(extensions...)
+	})
+	return lang
+}
+
+// Evaluate evaluates expression against params. Global namespaces such as
+// `time` are added to params unless params already define them.
+func Evaluate(ctx context.Context, expression string, params map[string]any) (any, error) {
+	return Language().EvaluateWithContext(ctx, expression, withGlobals(params))
+}
+
+// EvaluateBool evaluates expression and requires a boolean result.
+func EvaluateBool(ctx context.Context, expression string, params map[string]any) (bool, error) {
+	value, err := Evaluate(ctx, expression, params)
+	if err != nil {
+		return false, err
+	}
+
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression did not evaluate to a boolean: %v", value)
+	}
+	return result, nil
+}
+
+// Validate parses expression without evaluating it.
+func Validate(expression string) error {
+	_, err := Language().NewEvaluable(expression)
+	return err
+}
+
+// Interpolate renders text as a text/template. Every param is exposed as a
+// zero-argument function, so `{{item.name}}` reads params["item"]["name"].
+func Interpolate(ctx context.Context, text string, params map[string]any) (string, error) {
+	var buf bytes.Buffer
+	if err := InterpolateTo(ctx, &buf, text, params, nil); err != nil {
+		return text, err
+	}
+	return buf.String(), nil
+}
+
+// InterpolateTo is Interpolate writing to w; a write error aborts execution.
+// step, if set, is called before every function call, template invocation and
+// range iteration, and its error aborts execution as well. Execution also
+// stops once ctx is done.
+func InterpolateTo(ctx context.Context, w io.Writer, text string, params map[string]any, step func() error) error {
+	tick := func() error {
+		if step != nil {
+			if err := step(); err != nil {
+				return err
+			}
+		}
+		return ctx.Err()
+	}
+
+	funcs := make(template.FuncMap, len(functions)+len(params)+3)
+	for name, fn := range functions {
+		funcs[name] = func(args ...any) (any, error) {
+			if err := tick(); err != nil {
+				return nil, err
+			}
+			return fn(ctx, args...)
+		}
+	}
+	for name, value := range withGlobals(params) {
+		funcs[name] = func() (any, error) { return value, tick() }
+	}
+	funcs[stepFunc] = func() (string, error) { return "", tick() }
+	funcs[rangeFunc] = checkRange
+
+	tmpl, err := template.New("interpolation").Funcs(funcs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("error parsing template: %w", err)
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			meter(t.Tree.Root)
+		}
+	}
+
+	if err := tmpl.Execute(w, nil); err != nil {
+		return fmt.Errorf("error executing template: %w", err)
+	}
+	return nil
+}
+
+// meter instruments a parsed template: a step is called on entering it and on
+// every range iteration, and the value of every range is checked by
+// checkRange. text/template does not observe a context by itself, so a range
+// with an empty body would otherwise run to the end.
+func meter(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+
+	list.Nodes = append([]parse.Node{stepNode(list.Position())}, list.Nodes...)
+	for _, node := range list.Nodes {
+		meterNode(node)
+	}
+}
+
+func meterNode(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.RangeNode:
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pipe.Position(),
+			Args:     []parse.Node{parse.NewIdentifier(rangeFunc).SetPos(n.Pipe.Position())},
+		})
+		meter(n.List)
+		walkList(n.ElseList)
+	case *parse.IfNode:
+		walkList(n.List)
+		walkList(n.ElseList)
+	case *parse.WithNode:
+		walkList(n.List)
+		walkList(n.ElseList)
+	case *parse.ListNode:
+		walkList(n)
+	}
+}
+
+func walkList(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		meterNode(node)
+	}
+}
+
+func stepNode(pos parse.Pos) *parse.ActionNode {
+	return &parse.ActionNode{
+		NodeType: parse.NodeAction,
+		Pos:      pos,
+		Pipe: &parse.PipeNode{
+			NodeType: parse.NodePipe,
+			Pos:      pos,
+			Cmds: []*parse.CommandNode{{
+				NodeType: parse.NodeCommand,
+				Pos:      pos,
+				Args:     []parse.Node{parse.NewIdentifier(stepFunc).SetPos(pos)},
+			}},
+		},
+	}
+}
+
+// checkRange passes the value of a range through, failing an integer above
+// MaxRange.
+func checkRange(value any) (any, error) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() > MaxRange {
+			return nil, fmt.Errorf("%w: %d > %d", ErrRangeTooLarge, v.Int(), MaxRange)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > MaxRange {
+			return nil, fmt.Errorf("%w: %d > %d", ErrRangeTooLarge, v.Uint(), MaxRange)
+		}
+	}
+	return value, nil
+}
+
+func withGlobals(params map[string]any) map[string]any {
+	res := make(map[string]any, len(params)+1)
+	for k, v := range params {
+		res[k] = v
+	}
+
+	if _, ok := res["time"]; !ok {
+		res["time"] = timeNamespace(time.Now())
+	}
+	return res
+}
+
+// timeNamespace exposes the current time and duration units, all in seconds,
+// so `time.Now - 7 * time.Day` is plain arithmetic.
+func timeNamespace(now time.Time) map[string]any {
+	return map[string]any{
+		"Now":    unixSeconds(now),
+		"Second": 1.0,
+		"Minute": 60.0,
+		"Hour":   3600.0,
+		"Day":    86400.0,
+		"Week":   604800.0,
+	}
+}
@@ -0,0 +1,251 @@
+package expression
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"item_compositiom_service/pkg/i18n"
+)
+
+var testItem = map[string]any{
+	"name":      "  Alice  ",
+	"title":     "hello world",
+	"count":     3,
+	"price":     1234567.891,
+	"createdAt": "2024-03-15T10:30:00Z",
+	"tags":      []any{"news", "sport"},
+	"empty":     "",
+	"author": map[string]any{
+		"name":    "Bob",
+		"friends": []any{map[string]any{"name": "Eve"}},
+	},
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []struct {
+		fn       string
+		expr     string
+		expected any
+	}{
+		{"default", `default(item.missing, "none")`, "none"},
+		{"default", `default(item.empty, "none")`, "none"},
+		{"default", `default(item.author.name, "none")`, "Bob"},
+		{"coalesce", `coalesce(item.missing, item.empty, item.author.name)`, "Bob"},
+		{"coalesce", `coalesce(item.missing, item.empty)`, nil},
+		{"len", `len(item.tags)`, 2.0},
+		{"len", `len("реакция")`, 7.0},
+		{"len", `len(item.missing)`, 0.0},
+		{"contains", `contains(item.tags, "sport")`, true},
+		{"contains", `contains(item.title, "world")`, true},
+		{"contains", `contains(item.author, "name")`, true},
+		{"contains", `contains(item.tags, "music")`, false},
+		{"get", `get(item, "author.friends[0].name")`, "Eve"},
+		{"get", `get(item, "author.friends.0.name")`, "Eve"},
+		{"get", `get(item, "author.enemies[0].name", "nobody")`, "nobody"},
+		{"get", `get(item.missing, "a.b")`, nil},
+
+		{"parseDate", `parseDate(item.createdAt)`, 1710498600.0},
+		{"parseDate", `parseDate("15.03.2024", "02.01.2006")`, 1710460800.0},
+		{"parseDate", `parseDate(1710498600)`, 1710498600.0},
+		{"formatDate", `formatDate(item.createdAt, "date")`, "2024-03-15"},
+		{"formatDate", `formatDate(item.createdAt, "02.01.2006 15:04", "Europe/Moscow")`, "15.03.2024 13:30"},
+		{"formatDate", `formatDate(1710498600, "unix")`, "1710498600"},
+		{"duration", `duration("1h30m")`, 5400.0},
+		{"duration", `duration("7d")`, 604800.0},
+		{"addDuration", `formatDate(addDuration(item.createdAt, "24h"), "date")`, "2024-03-16"},
+		{"addDuration", `formatDate(addDuration(item.createdAt, -86400), "date")`, "2024-03-14"},
+		{"dateDiff", `dateDiff(item.createdAt, "2024-03-18T10:30:00Z", "days")`, 3.0},
+		{"dateDiff", `dateDiff(item.createdAt, "2024-03-15T11:00:00Z")`, 1800.0},
+		{"startOfDay", `formatDate(startOfDay(item.createdAt), "datetime")`, "2024-03-15 00:00:00"},
+		{"now", `now() > parseDate(item.createdAt)`, true},
+
+		{"formatNumber", `formatNumber(item.price, 2)`, "1 234 567.89"},
+		{"formatNumber", `formatNumber(item.price, 1, ",", ".")`, "1,234,567.9"},
+		{"formatNumber", `formatNumber(-1500)`, "-1 500"},
+		{"compactNumber", `compactNumber(1530)`, "1.5K"},
+		{"compactNumber", `compactNumber(2000000)`, "2M"},
+		{"compactNumber", `compactNumber(999)`, "999"},
+		{"round", `round(2.345, 2)`, 2.35},
+		{"round", `round(2.5)`, 3.0},
+		{"floor", `floor(2.7)`, 2.0},
+		{"ceil", `ceil(2.1)`, 3.0},
+		{"abs", `abs(-4)`, 4.0},
+		{"plural", `plural(1, "reaction", "reactions")`, "reaction"},
+		{"plural", `plural(item.count, "reaction", "reactions")`, "reactions"},
+		{"plural", `plural(1, "реакция", "реакции", "реакций")`, "реакция"},
+		{"plural", `plural(3, "реакция", "реакции", "реакций")`, "реакции"},
+		{"plural", `plural(5, "реакция", "реакции", "реакций")`, "реакций"},
+		{"plural", `plural(11, "реакция", "реакции", "реакций")`, "реакций"},
+		{"plural", `plural(21, "реакция", "реакции", "реакций")`, "реакция"},
+
+		{"lower", `lower("ABC")`, "abc"},
+		{"upper", `upper(item.author.name)`, "BOB"},
+		{"trim", `trim(item.name)`, "Alice"},
+		{"capitalize", `capitalize(item.title)`, "Hello world"},
+		{"truncate", `truncate(item.title, 5)`, "hello…"},
+		{"truncate", `truncate(item.title, 5, "...")`, "hello..."},
+		{"truncate", `truncate(item.title, 50)`, "hello world"},
+		{"replace", `replace(item.title, "world", "there")`, "hello there"},
+		{"split", `split("a,b", ",")`, []any{"a", "b"}},
+		{"join", `join(item.tags, ", ")`, "news, sport"},
+		{"versionCompare", `versionCompare("5.10.0", "5.9.3")`, 1.0},
+		{"versionCompare", `versionCompare("v5.2", "5.2.0")`, 0.0},
+		{"versionCompare", `versionCompare("5.2.0-beta", "5.2.0")`, -1.0},
+		{"versionCompare", `versionCompare("4.9", "5")`, -1.0},
+		{"hasPrefix", `hasPrefix(item.title, "hello")`, true},
+		{"hasSuffix", `hasSuffix(item.title, "hello")`, false},
+		{"substr", `substr(item.title, 6)`, "world"},
+		{"substr", `substr(item.title, 0, 100)`, "hello world"},
+		{"concat", `concat(item.author.name, "-", 42)`, "Bob-42"},
+		{"format", `format("%s has %v tags", item.author.name, len(item.tags))`, "Bob has 2 tags"},
+
+		{"t", `t("no.translator")`, "no.translator"},
+	}
+
+	covered := map[string]bool{}
+	ctx := context.Background()
+
+	for _, tc := range tests {
+		covered[tc.fn] = true
+		t.Run(tc.expr, func(t *testing.T) {
+			res, err := Evaluate(ctx, tc.expr, map[string]any{"item": testItem})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+
+	for _, name := range Functions() {
+		assert.True(t, covered[name], "function %s has no test case", name)
+	}
+}
+
+func TestFunctions_Errors(t *testing.T) {
+	ctx := context.Background()
+	for _, expr := range []string{
+		`default(1)`,
+		`len(42)`,
+		`parseDate("not a date")`,
+		`formatDate(item.createdAt, "date", "Mars/Olympus")`,
+		`duration("soon")`,
+		`dateDiff(0, 1, "years")`,
+		`round("abc")`,
+		`plural(1, "one")`,
+		`join("abc", ",")`,
+		`get(item, 1)`,
+	} {
+		_, err := Evaluate(ctx, expr, map[string]any{"item": testItem})
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestTimeNamespace(t *testing.T) {
+	ctx := context.Background()
+	recent := float64(time.Now().Add(-time.Hour).Unix())
+	old := float64(time.Now().Add(-30 * 24 * time.Hour).Unix())
+
+	match, err := EvaluateBool(ctx, "item.createdAt > time.Now - 7 * time.Day", map[string]any{
+		"item": map[string]any{"createdAt": recent},
+	})
+	assert.NoError(t, err)
+	assert.True(t, match)
+
+	match, err = EvaluateBool(ctx, "item.createdAt > time.Now - 7 * time.Day", map[string]any{
+		"item": map[string]any{"createdAt": old},
+	})
+	assert.NoError(t, err)
+	assert.False(t, match)
+}
+
+func TestEvaluateBool_NotBoolean(t *testing.T) {
+	_, err := EvaluateBool(context.Background(), "1 + 1", nil)
+	assert.Error(t, err)
+}
+
+func TestInterpolate(t *testing.T) {
+	tests := []struct {
+		tmpl     string
+		expected string
+	}{
+		{"Hello, {{item.author.name}}!", "Hello, Bob!"},
+		{"{{item.count}} {{plural item.count \"реакция\" \"реакции\" \"реакций\"}}", "3 реакции"},
+		{"{{default item.missing \"n/a\"}}", "n/a"},
+		{"{{formatDate item.createdAt \"date\"}}", "2024-03-15"},
+		{"{{upper (get item \"author.friends.0.name\")}}", "EVE"},
+		{"{{len item.tags}} tags", "2 tags"},
+	}
+
+	for _, tc := range tests {
+		res, err := Interpolate(context.Background(), tc.tmpl, map[string]any{"item": testItem})
+		assert.NoError(t, err, tc.tmpl)
+		assert.Equal(t, tc.expected, res, tc.tmpl)
+	}
+
+	_, err := Interpolate(context.Background(), "{{broken", nil)
+	assert.Error(t, err)
+}
+
+func TestInterpolateTo_Steps(t *testing.T) {
+	var buf strings.Builder
+	steps := 0
+	err := InterpolateTo(context.Background(), &buf, `{{define "x"}}{{.}}{{end}}{{range 3}}{{template "x" .}}{{end}} {{item.count}}`,
+		map[string]any{"item": testItem}, func() error {
+			steps++
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, "012 3", buf.String())
+	assert.Equal(t, 1+3+3+1, steps, "template, range iterations, invocations and calls")
+
+	stop := errors.New("stop")
+	err = InterpolateTo(context.Background(), &buf, "{{range 10}}{{end}}", nil, func() error { return stop })
+	assert.ErrorIs(t, err, stop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = InterpolateTo(ctx, &buf, "{{range 10000}}{{end}}", nil, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = Interpolate(context.Background(), "{{range 3000000000}}{{end}}", nil)
+	assert.ErrorIs(t, err, ErrRangeTooLarge)
+
+	res, err := Interpolate(context.Background(), "{{range $i, $tag := item.tags}}{{$i}}{{$tag}}{{end}}", map[string]any{"item": testItem})
+	assert.NoError(t, err)
+	assert.Equal(t, "0news1sport", res, "ranges over data are kept")
+}
+
+func TestTranslate(t *testing.T) {
+	storage := i18n.NewStorage(&i18n.Config{DefaultLocale: "en"})
+	catalog := &i18n.Catalog{
+		Locale: "ru",
+		Messages: map[string]i18n.Message{
+			"greeting": {Text: "Привет, {name}!"},
+			"reactions": {Plural: map[string]string{
+				"one":   "{count} реакция",
+				"few":   "{count} реакции",
+				"many":  "{count} реакций",
+				"other": "{count} реакции",
+			}},
+		},
+	}
+	ctx := i18n.WithTranslator(context.Background(), storage.Translator("ru", catalog))
+	params := map[string]any{"item": testItem}
+
+	res, err := Evaluate(ctx, `t("greeting", {"name": item.author.name})`, params)
+	assert.NoError(t, err)
+	assert.Equal(t, "Привет, Bob!", res)
+
+	res, err = Evaluate(ctx, `t("reactions", "count", item.count)`, params)
+	assert.NoError(t, err)
+	assert.Equal(t, "3 реакции", res)
+
+	text, err := Interpolate(ctx, `{{t "reactions" "count" 25}}`, params)
+	assert.NoError(t, err)
+	assert.Equal(t, "25 реакций", text)
+
+	_, err = Evaluate(ctx, `t("reactions", "count")`, params)
+	assert.Error(t, err)
+}
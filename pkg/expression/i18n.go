@@ -0,0 +1,43 @@
+package expression
+
+import (
+	"context"
+	"fmt"
+
+	"item_compositiom_service/pkg/i18n"
+)
+
+func init() {
+	register("t", fnTranslate)
+}
+
+// t(key, [args]) translates key with the translator of the current render.
+// Arguments are an object (`t("key", {"count": 3})`) or name/value pairs
+// (`{{t "key" "count" 3}}`).
+func fnTranslate(ctx context.Context, args ...any) (any, error) {
+	if err := expectArgs("t", args, 1, -1); err != nil {
+		return nil, err
+	}
+
+	key := ToString(args[0])
+
+	var params map[string]any
+	switch rest := args[1:]; {
+	case len(rest) == 1:
+		m, ok := rest[0].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("t() expects an object or name/value pairs as arguments, got %T", rest[0])
+		}
+		params = m
+	case len(rest)%2 != 0:
+		return nil, fmt.Errorf("t() expects name/value pairs as arguments")
+	case len(rest) > 0:
+		params = make(map[string]any, len(rest)/2)
+		for i := 0; i < len(rest); i += 2 {
+			params[ToString(rest[i])] = rest[i+1]
+		}
+	}
+
+	text, _ := i18n.TranslatorFromContext(ctx).Translate(key, params)
+	return text, nil
+}
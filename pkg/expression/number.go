@@ -0,0 +1,170 @@
+package expression
+
+import (
+	"context"
+	"item_compositiom_service/pkg/i18n"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	register("formatNumber", fnFormatNumber)
+	register("compactNumber", fnCompactNumber)
+	register("round", fnRound)
+	register("floor", fnFloor)
+	register("ceil", fnCeil)
+	register("abs", fnAbs)
+	register("plural", fnPlural)
+}
+
+// formatNumber(n, [decimals], [thousandsSep], [decimalSep]) formats a number
+// with a fixed number of decimals and grouped thousands.
+func fnFormatNumber(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("formatNumber", args, 1, 4); err != nil {
+		return nil, err
+	}
+
+	n, err := toNumber("formatNumber", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	decimals := 0
+	if len(args) >= 2 {
+		if decimals, err = toInt("formatNumber", args[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	thousandsSep, decimalSep := " ", "."
+	if len(args) >= 3 {
+		thousandsSep = ToString(args[2])
+	}
+	if len(args) == 4 {
+		decimalSep = ToString(args[3])
+	}
+
+	return FormatNumber(n, decimals, thousandsSep, decimalSep), nil
+}
+
+// compactNumber(n) shortens large numbers: 1530 -> 1.5K, 2000000 -> 2M.
+func fnCompactNumber(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("compactNumber", args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	n, err := toNumber("compactNumber", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, unit := range []struct {
+		size   float64
+		suffix string
+	}{{1e9, "B"}, {1e6, "M"}, {1e3, "K"}} {
+		if math.Abs(n) >= unit.size {
+			short := math.Trunc(n/unit.size*10) / 10
+			return strconv.FormatFloat(short, 'f', -1, 64) + unit.suffix, nil
+		}
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64), nil
+}
+
+// round(n, [digits]) rounds half away from zero.
+func fnRound(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("round", args, 1, 2); err != nil {
+		return nil, err
+	}
+
+	n, err := toNumber("round", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	digits := 0
+	if len(args) == 2 {
+		if digits, err = toInt("round", args[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	pow := math.Pow(10, float64(digits))
+	return math.Round(n*pow) / pow, nil
+}
+
+func fnFloor(_ context.Context, args ...any) (any, error) {
+	return unaryMath("floor", math.Floor, args)
+}
+
+func fnCeil(_ context.Context, args ...any) (any, error) {
+	return unaryMath("ceil", math.Ceil, args)
+}
+
+func fnAbs(_ context.Context, args ...any) (any, error) {
+	return unaryMath("abs", math.Abs, args)
+}
+
+// plural(n, one, many) uses English rules; plural(n, one, few, many) uses
+// Russian rules (1 реакция, 3 реакции, 5 реакций).
+func fnPlural(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("plural", args, 3, 4); err != nil {
+		return nil, err
+	}
+
+	n, err := toNumber("plural", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 3 {
+		if n == 1 {
+			return ToString(args[1]), nil
+		}
+		return ToString(args[2]), nil
+	}
+
+	switch i18n.PluralCategory("ru", n) {
+	case i18n.PluralOne:
+		return ToString(args[1]), nil
+	case i18n.PluralMany:
+		return ToString(args[3]), nil
+	default:
+		return ToString(args[2]), nil
+	}
+}
+
+// FormatNumber formats n with decimals digits after decimalSep and groups the
+// integer part with thousandsSep.
+func FormatNumber(n float64, decimals int, thousandsSep, decimalSep string) string {
+	s := strconv.FormatFloat(math.Abs(n), 'f', decimals, 64)
+	intPart, fracPart, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	if n < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(thousandsSep)
+		}
+		b.WriteRune(r)
+	}
+	if fracPart != "" {
+		b.WriteString(decimalSep)
+		b.WriteString(fracPart)
+	}
+	return b.String()
+}
+
+func unaryMath(name string, fn func(float64) float64, args []any) (any, error) {
+	if err := expectArgs(name, args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	n, err := toNumber(name, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return fn(n), nil
+}
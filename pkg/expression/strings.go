@@ -0,0 +1,161 @@
+package expression
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+func init() {
+	register("lower", stringFunc("lower", strings.ToLower))
+	register("upper", stringFunc("upper", strings.ToUpper))
+	register("trim", stringFunc("trim", strings.TrimSpace))
+	register("capitalize", stringFunc("capitalize", capitalize))
+	register("truncate", fnTruncate)
+	register("replace", fnReplace)
+	register("split", fnSplit)
+	register("join", fnJoin)
+	register("hasPrefix", fnHasPrefix)
+	register("hasSuffix", fnHasSuffix)
+	register("substr", fnSubstr)
+	register("concat", fnConcat)
+	register("format", fnFormat)
+}
+
+// truncate(s, n, [suffix]) cuts s to n runes and appends suffix ("…" by default).
+func fnTruncate(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("truncate", args, 2, 3); err != nil {
+		return nil, err
+	}
+
+	n, err := toInt("truncate", args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := "…"
+	if len(args) == 3 {
+		suffix = ToString(args[2])
+	}
+
+	runes := []rune(ToString(args[0]))
+	if len(runes) <= n {
+		return string(runes), nil
+	}
+	return string(runes[:max(n, 0)]) + suffix, nil
+}
+
+// replace(s, old, new) replaces all occurrences of old.
+func fnReplace(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("replace", args, 3, 3); err != nil {
+		return nil, err
+	}
+	return strings.ReplaceAll(ToString(args[0]), ToString(args[1]), ToString(args[2])), nil
+}
+
+// split(s, sep) returns an array of strings.
+func fnSplit(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("split", args, 2, 2); err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(ToString(args[0]), ToString(args[1]))
+	res := make([]any, len(parts))
+	for i, p := range parts {
+		res[i] = p
+	}
+	return res, nil
+}
+
+// join(array, sep) joins array elements into a string.
+func fnJoin(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("join", args, 2, 2); err != nil {
+		return nil, err
+	}
+
+	items, ok := args[0].([]any)
+	if !ok && args[0] != nil {
+		return nil, fmt.Errorf("join() expects an array, got %T", args[0])
+	}
+
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = ToString(item)
+	}
+	return strings.Join(parts, ToString(args[1])), nil
+}
+
+func fnHasPrefix(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("hasPrefix", args, 2, 2); err != nil {
+		return nil, err
+	}
+	return strings.HasPrefix(ToString(args[0]), ToString(args[1])), nil
+}
+
+func fnHasSuffix(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("hasSuffix", args, 2, 2); err != nil {
+		return nil, err
+	}
+	return strings.HasSuffix(ToString(args[0]), ToString(args[1])), nil
+}
+
+// substr(s, start, [end]) slices s by rune positions, clamped to its length.
+func fnSubstr(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("substr", args, 2, 3); err != nil {
+		return nil, err
+	}
+
+	runes := []rune(ToString(args[0]))
+
+	start, err := toInt("substr", args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	end := len(runes)
+	if len(args) == 3 {
+		if end, err = toInt("substr", args[2]); err != nil {
+			return nil, err
+		}
+	}
+
+	start = min(max(start, 0), len(runes))
+	end = min(max(end, start), len(runes))
+	return string(runes[start:end]), nil
+}
+
+// concat(values...) joins the string forms of all arguments.
+func fnConcat(_ context.Context, args ...any) (any, error) {
+	var b strings.Builder
+	for _, arg := range args {
+		b.WriteString(ToString(arg))
+	}
+	return b.String(), nil
+}
+
+// format(pattern, args...) is fmt.Sprintf.
+func fnFormat(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("format", args, 1, -1); err != nil {
+		return nil, err
+	}
+	return fmt.Sprintf(ToString(args[0]), args[1:]...), nil
+}
+
+func stringFunc(name string, fn func(string) string) Func {
+	return func(_ context.Context, args ...any) (any, error) {
+		if err := expectArgs(name, args, 1, 1); err != nil {
+			return nil, err
+		}
+		return fn(ToString(args[0])), nil
+	}
+}
+
+func capitalize(s string) string {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return s
+	}
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,68 @@
+package expression
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	register("versionCompare", fnVersionCompare)
+}
+
+// versionCompare(a, b) compares dotted versions such as app versions and
+// returns -1, 0 or 1. Missing parts are zeros; a pre-release (`5.2.0-beta`)
+// is lower than its release.
+func fnVersionCompare(_ context.Context, args ...any) (any, error) {
+	if err := expectArgs("versionCompare", args, 2, 2); err != nil {
+		return nil, err
+	}
+	return float64(compareVersions(ToString(args[0]), ToString(args[1]))), nil
+}
+
+func compareVersions(a, b string) int {
+	aCore, aPre, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(a), "v"), "-")
+	bCore, bPre, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(b), "v"), "-")
+
+	aParts, bParts := strings.Split(aCore, "."), strings.Split(bCore, ".")
+	for i := 0; i < max(len(aParts), len(bParts)); i++ {
+		if c := compareVersionPart(versionPart(aParts, i), versionPart(bParts, i)); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	case aPre < bPre:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func versionPart(parts []string, i int) string {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return "0"
+}
+
+func compareVersionPart(a, b string) int {
+	an, aErr := strconv.Atoi(a)
+	bn, bErr := strconv.Atoi(b)
+	if aErr == nil && bErr == nil {
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,108 @@
+package i18n
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Message is a translated string or a set of plural forms keyed by CLDR
+// category. The plural form is chosen by the `count` argument.
+type Message struct {
+	Text   string
+	Plural map[string]string
+}
+
+type Catalog struct {
+	Locale   string
+	Messages map[string]Message
+}
+
+var placeholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// ParseCatalog converts the `spec.messages` section of a Messages document.
+func ParseCatalog(locale string, messages map[string]any) (*Catalog, error) {
+	if locale == "" {
+		return nil, fmt.Errorf("metadata.locale is required")
+	}
+
+	catalog := &Catalog{
+		Locale:   normalize(locale),
+		Messages: make(map[string]Message, len(messages)),
+	}
+
+	for key, raw := range messages {
+		switch val := raw.(type) {
+		case string:
+			catalog.Messages[key] = Message{Text: val}
+		case map[string]any:
+			forms := make(map[string]string, len(val))
+			for category, form := range val {
+				text, ok := form.(string)
+				if !ok {
+					return nil, fmt.Errorf("message %s: plural form %s is not a string", key, category)
+				}
+				forms[category] = text
+			}
+			if _, ok := forms[PluralOther]; !ok {
+				return nil, fmt.Errorf("message %s: plural form %s is required", key, PluralOther)
+			}
+			catalog.Messages[key] = Message{Plural: forms}
+		default:
+			return nil, fmt.Errorf("message %s: unsupported value type %T", key, raw)
+		}
+	}
+
+	return catalog, nil
+}
+
+func (m Message) render(locale string, args map[string]any) string {
+	text := m.Text
+	if m.Plural != nil {
+		text = m.Plural[PluralOther]
+		if n, ok := toFloat(args["count"]); ok {
+			if form, ok := m.Plural[PluralCategory(locale, n)]; ok {
+				text = form
+			}
+		}
+	}
+
+	return Format(text, args)
+}
+
+// Format substitutes `{name}` placeholders with args; unknown placeholders are kept.
+func Format(text string, args map[string]any) string {
+	if len(args) == 0 {
+		return text
+	}
+
+	return placeholder.ReplaceAllStringFunc(text, func(m string) string {
+		val, ok := args[m[1:len(m)-1]]
+		if !ok {
+			return m
+		}
+		if f, ok := val.(float64); ok {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		return fmt.Sprint(val)
+	})
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
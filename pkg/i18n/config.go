@@ -0,0 +1,6 @@
+package i18n
+
+type Config struct {
+	DefaultLocale   string   `yaml:"default_locale"`
+	FallbackLocales []string `yaml:"fallback_locales"`
+}
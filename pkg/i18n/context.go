@@ -0,0 +1,30 @@
+package i18n
+
+import "context"
+
+type contextKey int
+
+const (
+	localeKey contextKey = iota
+	translatorKey
+)
+
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey, locale)
+}
+
+// LocaleFromContext returns the locale requested by the caller, if any.
+func LocaleFromContext(ctx context.Context) string {
+	locale, _ := ctx.Value(localeKey).(string)
+	return locale
+}
+
+func WithTranslator(ctx context.Context, t *Translator) context.Context {
+	return context.WithValue(ctx, translatorKey, t)
+}
+
+// TranslatorFromContext returns the translator of the current render or nil.
+func TranslatorFromContext(ctx context.Context) *Translator {
+	t, _ := ctx.Value(translatorKey).(*Translator)
+	return t
+}
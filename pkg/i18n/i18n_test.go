@@ -0,0 +1,185 @@
+package i18n
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestPluralCategory(t *testing.T) {
+	tests := []struct {
+		locale   string
+		n        float64
+		expected string
+	}{
+		{"ru", 1, PluralOne},
+		{"ru", 21, PluralOne},
+		{"ru", 101, PluralOne},
+		{"ru", 2, PluralFew},
+		{"ru", 3, PluralFew},
+		{"ru", 24, PluralFew},
+		{"ru", 0, PluralMany},
+		{"ru", 5, PluralMany},
+		{"ru", 11, PluralMany},
+		{"ru", 12, PluralMany},
+		{"ru", 14, PluralMany},
+		{"ru", 111, PluralMany},
+		{"ru", 1.5, PluralOther},
+		{"ru-RU", 3, PluralFew},
+		{"uk", 4, PluralFew},
+		{"en", 1, PluralOne},
+		{"en", 0, PluralOther},
+		{"en", 3, PluralOther},
+		{"", 1, PluralOne},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, PluralCategory(tc.locale, tc.n), "%s %v", tc.locale, tc.n)
+	}
+}
+
+func TestParseCatalog(t *testing.T) {
+	catalog, err := ParseCatalog("ru_RU", map[string]any{
+		"title": "Реакции",
+		"count": map[string]any{"one": "{count} реакция", "other": "{count} реакции"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "ru-ru", catalog.Locale)
+	assert.Equal(t, "Реакции", catalog.Messages["title"].Text)
+	assert.Equal(t, "{count} реакция", catalog.Messages["count"].Plural[PluralOne])
+
+	_, err = ParseCatalog("", map[string]any{"title": "x"})
+	assert.Error(t, err, "Locale is required")
+
+	_, err = ParseCatalog("ru", map[string]any{"count": map[string]any{"one": "x"}})
+	assert.Error(t, err, "Plural form other is required")
+
+	_, err = ParseCatalog("ru", map[string]any{"count": 1})
+	assert.Error(t, err, "Unsupported message type")
+}
+
+func TestTranslator_FallbackChain(t *testing.T) {
+	storage := NewStorage(&Config{DefaultLocale: "en", FallbackLocales: []string{"ru"}})
+	catalogs := []*Catalog{
+		{Locale: "en", Messages: map[string]Message{
+			"title":  {Text: "Reactions"},
+			"shared": {Text: "en"},
+		}},
+		{Locale: "ru", Messages: map[string]Message{
+			"shared": {Text: "ru"},
+			"only":   {Text: "только ru"},
+		}},
+		{Locale: "ru-ua", Messages: map[string]Message{
+			"regional": {Text: "регион"},
+		}},
+	}
+
+	assert.Equal(t, []string{"kk-kz", "kk", "ru", "en"}, storage.Chain("kk_KZ"))
+	assert.Equal(t, []string{"ru", "en"}, storage.Chain(""))
+
+	tr := storage.Translator("ru-UA", catalogs...)
+	for key, expected := range map[string]string{
+		"regional": "регион",
+		"shared":   "ru",
+		"only":     "только ru",
+		"title":    "Reactions",
+	} {
+		text, ok := tr.Translate(key, nil)
+		assert.True(t, ok, key)
+		assert.Equal(t, expected, text, key)
+	}
+
+	text, ok := tr.Translate("missing", nil)
+	assert.False(t, ok)
+	assert.Equal(t, "missing", text)
+
+	text, ok = storage.Translator("en", catalogs...).Translate("shared", nil)
+	assert.True(t, ok)
+	assert.Equal(t, "en", text)
+
+	text, ok = storage.Translator("en").Translate("title", nil)
+	assert.False(t, ok, "Catalogs are only those of the translator")
+	assert.Equal(t, "title", text)
+
+	override := &Catalog{Locale: "en", Messages: map[string]Message{"title": {Text: "Reactions!"}}}
+	text, _ = storage.Translator("en", append(catalogs, override)...).Translate("title", nil)
+	assert.Equal(t, "Reactions!", text, "Later catalogs replace keys")
+
+	var nilTranslator *Translator
+	text, ok = nilTranslator.Translate("title", nil)
+	assert.False(t, ok)
+	assert.Equal(t, "title", text)
+}
+
+func TestTranslator_Plural(t *testing.T) {
+	storage := NewStorage(nil)
+	catalog := &Catalog{Locale: "ru", Messages: map[string]Message{
+		"reactions": {Plural: map[string]string{
+			PluralOne:   "{count} реакция",
+			PluralFew:   "{count} реакции",
+			PluralMany:  "{count} реакций",
+			PluralOther: "{count} реакции",
+		}},
+	}}
+
+	tr := storage.Translator("ru", catalog)
+	for count, expected := range map[float64]string{
+		1:   "1 реакция",
+		3:   "3 реакции",
+		5:   "5 реакций",
+		1.5: "1.5 реакции",
+	} {
+		text, _ := tr.Translate("reactions", map[string]any{"count": count})
+		assert.Equal(t, expected, text)
+	}
+
+	text, _ := tr.Translate("reactions", nil)
+	assert.Equal(t, "{count} реакции", text, "Without count the other form is used")
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "Hi, Bob! You have 2 {unknown}", Format("Hi, {name}! You have {n} {unknown}", map[string]any{"name": "Bob", "n": 2.0}))
+	assert.Equal(t, "{name}", Format("{name}", nil))
+}
+
+type testRequest struct {
+	metadata *structpb.Struct
+}
+
+func (r *testRequest) GetMetadata() *structpb.Struct {
+	return r.metadata
+}
+
+func TestInterceptor(t *testing.T) {
+	interceptor := NewInterceptor().GetServerInterceptor()
+
+	locale := func(ctx context.Context, req interface{}) string {
+		var res string
+		_, err := interceptor(ctx, req, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			res = LocaleFromContext(ctx)
+			return nil, nil
+		})
+		assert.NoError(t, err)
+		return res
+	}
+
+	md, err := structpb.NewStruct(map[string]any{"locale": "ru-RU"})
+	assert.NoError(t, err)
+
+	headers := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"x-locale", "en",
+		"accept-language", "kk-KZ,ru;q=0.8",
+	))
+	acceptLanguage := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"accept-language", "kk-KZ,ru;q=0.8",
+	))
+
+	assert.Equal(t, "ru-RU", locale(headers, &testRequest{metadata: md}), "Request metadata wins")
+	assert.Equal(t, "en", locale(headers, &testRequest{}), "x-locale header")
+	assert.Equal(t, "kk-KZ", locale(acceptLanguage, "request"), "First accept-language tag")
+	assert.Equal(t, "", locale(context.Background(), "request"))
+}
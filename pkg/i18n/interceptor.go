@@ -0,0 +1,80 @@
+package i18n
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	metadataLocaleField  = "locale"
+	headerLocale         = "x-locale"
+	headerAcceptLanguage = "accept-language"
+)
+
+type requestWithMetadata interface {
+	GetMetadata() *structpb.Struct
+}
+
+type Interceptor struct{}
+
+func NewInterceptor() *Interceptor {
+	return &Interceptor{}
+}
+
+// GetServerInterceptor stores the requested locale in the context. The
+// `locale` field of the request metadata wins over the x-locale and
+// accept-language headers.
+func (i *Interceptor) GetServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context,
+		req interface{},
+		_ *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		if locale := requestLocale(ctx, req); locale != "" {
+			ctx = WithLocale(ctx, locale)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func requestLocale(ctx context.Context, req interface{}) string {
+	if r, ok := req.(requestWithMetadata); ok {
+		if field, ok := r.GetMetadata().GetFields()[metadataLocaleField]; ok {
+			if locale := field.GetStringValue(); locale != "" {
+				return locale
+			}
+		}
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get(headerLocale); len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+
+	if values := md.Get(headerAcceptLanguage); len(values) > 0 {
+		return parseAcceptLanguage(values[0])
+	}
+
+	return ""
+}
+
+// parseAcceptLanguage returns the first tag of an Accept-Language header.
+// Clients send tags in preference order, so q-values are not compared.
+func parseAcceptLanguage(header string) string {
+	first, _, _ := strings.Cut(header, ",")
+	tag, _, _ := strings.Cut(first, ";")
+	tag = strings.TrimSpace(tag)
+	if tag == "*" {
+		return ""
+	}
+	return tag
+}
@@ -0,0 +1,48 @@
+package i18n
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	PluralOne   = "one"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+// PluralCategory returns the CLDR plural category of n for locale.
+// East Slavic languages use one/few/many/other, everything else one/other.
+func PluralCategory(locale string, n float64) string {
+	switch language(locale) {
+	case "ru", "uk", "be":
+		if n != math.Trunc(n) {
+			return PluralOther
+		}
+
+		abs := int64(math.Abs(n))
+		switch {
+		case abs%10 == 1 && abs%100 != 11:
+			return PluralOne
+		case abs%10 >= 2 && abs%10 <= 4 && (abs%100 < 12 || abs%100 > 14):
+			return PluralFew
+		default:
+			return PluralMany
+		}
+	default:
+		if n == 1 {
+			return PluralOne
+		}
+		return PluralOther
+	}
+}
+
+func language(locale string) string {
+	lang, _, _ := strings.Cut(normalize(locale), "-")
+	return lang
+}
+
+func normalize(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
@@ -0,0 +1,99 @@
+package i18n
+
+import "strings"
+
+// Storage holds the locale fallback config. Catalogs belong to templates and
+// are passed to every translator, so messages of one template are never
+// visible to another and a reloaded template drops removed messages.
+type Storage struct {
+	cfg *Config
+}
+
+func NewStorage(cfg *Config) *Storage {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	return &Storage{
+		cfg: cfg,
+	}
+}
+
+// Translator returns a translator over catalogs for the fallback chain of
+// locale: the locale itself, its language, configured fallbacks and the
+// default locale. Keys of a later catalog replace earlier ones.
+func (s *Storage) Translator(locale string, catalogs ...*Catalog) *Translator {
+	return &Translator{
+		catalogs: catalogs,
+		locales:  s.Chain(locale),
+	}
+}
+
+func (s *Storage) Chain(locale string) []string {
+	var chain []string
+	seen := map[string]struct{}{}
+
+	add := func(l string) {
+		l = normalize(l)
+		if l == "" {
+			return
+		}
+		if _, ok := seen[l]; ok {
+			return
+		}
+		seen[l] = struct{}{}
+		chain = append(chain, l)
+	}
+
+	add(locale)
+	if strings.Contains(normalize(locale), "-") {
+		add(language(locale))
+	}
+	for _, l := range s.cfg.FallbackLocales {
+		add(l)
+	}
+	add(s.cfg.DefaultLocale)
+
+	return chain
+}
+
+type Translator struct {
+	catalogs []*Catalog
+	locales  []string
+}
+
+// Locales returns the fallback chain, most preferred first.
+func (t *Translator) Locales() []string {
+	if t == nil {
+		return nil
+	}
+	return t.locales
+}
+
+// Translate renders key in the first locale of the chain that defines it.
+// The key itself is returned when no catalog has it.
+func (t *Translator) Translate(key string, args map[string]any) (string, bool) {
+	if t == nil {
+		return key, false
+	}
+
+	for _, locale := range t.locales {
+		if msg, ok := t.lookup(locale, key); ok {
+			return msg.render(locale, args), true
+		}
+	}
+
+	return key, false
+}
+
+func (t *Translator) lookup(locale, key string) (Message, bool) {
+	for i := len(t.catalogs) - 1; i >= 0; i-- {
+		if c := t.catalogs[i]; c.Locale == locale {
+			if msg, ok := c.Messages[key]; ok {
+				return msg, true
+			}
+		}
+	}
+
+	return Message{}, false
+}
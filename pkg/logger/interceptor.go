@@ -17,6 +17,11 @@ import (
 	"sync"
 )
 
+const redacted = "[REDACTED]"
+
+// credentialHeaders are logged and traced without their values.
+var credentialHeaders = []string{"authorization", "cookie", "api-key", "token", "secret", "password"}
+
 type options struct {
 	disable             bool
 	disableEnrichTraces bool
@@ -193,6 +198,12 @@ func (i *Interceptor) metadataLogField(ctx context.Context) (zap.Field, string)
 	fields := make([]zap.Field, 0, md.Len())
 	builder := mdPool.Get().(*strings.Builder)
 	for k, v := range md {
+		if isCredential(k) {
+			fields = append(fields, zap.String(k, redacted))
+			builder.WriteString(fmt.Sprintf("%s: '%s'\n", k, redacted))
+			continue
+		}
+
 		if len(v) == 0 {
 			fields = append(fields, zap.String(k, ""))
 			builder.WriteString(fmt.Sprintf("%s: ''\n", k))
@@ -211,3 +222,12 @@ func (i *Interceptor) metadataLogField(ctx context.Context) (zap.Field, string)
 
 	return zap.Dict("metadata", fields...), builder.String()
 }
+
+func isCredential(key string) bool {
+	for _, h := range credentialHeaders {
+		if strings.Contains(key, h) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// OverflowValue replaces label values that are not allowlisted or exceed the
+// cardinality limit of their label.
+const OverflowValue = "__overflow__"
+
+const defaultMaxLabelValues = 100
+
+type CardinalityConfig struct {
+	// MaxValues is the number of distinct values kept per label; later values
+	// are reported as OverflowValue.
+	MaxValues int `yaml:"max_values"`
+	// Allowlist restricts labels to the listed values.
+	Allowlist map[string][]string `yaml:"allowlist"`
+}
+
+// LabelLimiter caps the number of distinct values of high-cardinality labels
+// such as template id or provider method.
+type LabelLimiter struct {
+	mu        sync.RWMutex
+	maxValues int
+	allowlist map[string][]string
+	seen      map[string]map[string]struct{}
+	overflows *prometheus.CounterVec
+}
+
+func NewLabelLimiter(cfg *CardinalityConfig) *LabelLimiter {
+	if cfg == nil {
+		cfg = &CardinalityConfig{}
+	}
+
+	maxValues := cfg.MaxValues
+	if maxValues <= 0 {
+		maxValues = defaultMaxLabelValues
+	}
+
+	return &LabelLimiter{
+		maxValues: maxValues,
+		allowlist: cfg.Allowlist,
+		seen:      make(map[string]map[string]struct{}),
+		overflows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "metrics_label_overflow_total",
+			Help: "Total number of label values replaced with the overflow value",
+		}, []string{"label"}),
+	}
+}
+
+// Value returns value if the label may take it, OverflowValue otherwise.
+func (l *LabelLimiter) Value(label, value string) string {
+	if allowed, ok := l.allowlist[label]; ok {
+		if slices.Contains(allowed, value) {
+			return value
+		}
+		l.overflows.WithLabelValues(label).Inc()
+		return OverflowValue
+	}
+
+	l.mu.RLock()
+	_, known := l.seen[label][value]
+	l.mu.RUnlock()
+	if known {
+		return value
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	values, ok := l.seen[label]
+	if !ok {
+		values = make(map[string]struct{})
+		l.seen[label] = values
+	}
+
+	if _, ok := values[value]; !ok && len(values) >= l.maxValues {
+		l.overflows.WithLabelValues(label).Inc()
+		return OverflowValue
+	}
+
+	values[value] = struct{}{}
+	return value
+}
+
+// Limiter returns the label limiter of the registry, or a limiter with default
+// settings if the registry has none.
+func Limiter(registry MetricsRegistry) *LabelLimiter {
+	if r, ok := registry.(interface{ LabelLimiter() *LabelLimiter }); ok {
+		return r.LabelLimiter()
+	}
+	return NewLabelLimiter(nil)
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelLimiter(t *testing.T) {
+	limiter := NewLabelLimiter(&CardinalityConfig{
+		MaxValues: 2,
+		Allowlist: map[string][]string{"provider": {"reaction"}},
+	})
+
+	assert.Equal(t, "feed", limiter.Value("template", "feed"))
+	assert.Equal(t, "profile", limiter.Value("template", "profile"))
+	assert.Equal(t, OverflowValue, limiter.Value("template", "search"))
+	assert.Equal(t, "feed", limiter.Value("template", "feed"), "known values stay after overflow")
+	assert.Equal(t, "card", limiter.Value("view", "card"), "limits are per label")
+
+	assert.Equal(t, "reaction", limiter.Value("provider", "reaction"))
+	assert.Equal(t, OverflowValue, limiter.Value("provider", "comments"))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(limiter.overflows.WithLabelValues("template")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(limiter.overflows.WithLabelValues("provider")))
+}
+
+func TestLimiter_Default(t *testing.T) {
+	limiter := Limiter(&NoopMetrics{})
+	for i := 0; i < defaultMaxLabelValues; i++ {
+		limiter.Value("template", string(rune('a'+i%26))+string(rune('a'+i/26)))
+	}
+	assert.Equal(t, OverflowValue, limiter.Value("template", "one-more"))
+}
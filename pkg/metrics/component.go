@@ -21,6 +21,7 @@ type Metrics struct {
 	wg         sync.WaitGroup
 	metricsLgr *metricsErrorLogger
 	server     *http.Server
+	limiter    *LabelLimiter
 
 	r *prometheus.Registry
 }
@@ -31,12 +32,17 @@ func NewMetrics(lc fx.Lifecycle, c *Config, lgr *zap.SugaredLogger) (MetricsRegi
 	}
 
 	metrics := &Metrics{
-		r: prometheus.NewRegistry(),
+		r:       prometheus.NewRegistry(),
+		limiter: NewLabelLimiter(c.Cardinality),
 		metricsLgr: &metricsErrorLogger{
 			lgr.With("component", "metrics"),
 		},
 	}
 
+	if err := metrics.r.Register(metrics.limiter.overflows); err != nil {
+		return nil, err
+	}
+
 	http.Handle("/metrics", promhttp.HandlerFor(metrics.r, promhttp.HandlerOpts{
 		ErrorLog: metrics.metricsLgr,
 		Registry: metrics.r,
@@ -96,6 +102,10 @@ func (m *Metrics) GetRegistry() prometheus.Registerer {
 	return m.r
 }
 
+func (m *Metrics) LabelLimiter() *LabelLimiter {
+	return m.limiter
+}
+
 type metricsErrorLogger struct {
 	*zap.SugaredLogger
 }
@@ -3,4 +3,6 @@ package metrics
 type Config struct {
 	Enable bool `yaml:"enable"`
 	Port   int  `yaml:"port"`
+
+	Cardinality *CardinalityConfig `yaml:"cardinality"`
 }
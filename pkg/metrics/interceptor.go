@@ -11,6 +11,7 @@ import (
 )
 
 type Interceptor struct {
+	limiter         *LabelLimiter
 	totalRequests   *prometheus.CounterVec
 	requestDuration *prometheus.HistogramVec
 	errorsTotal     *prometheus.CounterVec
@@ -20,13 +21,14 @@ func NewInterceptor(m MetricsRegistry) (*Interceptor, error) {
 	namespace := "grpc_server"
 
 	i := &Interceptor{
+		limiter: Limiter(m),
 		totalRequests: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: namespace,
 				Name:      "requests_total",
 				Help:      "The total number of grpc requests",
 			},
-			[]string{"method", "code"},
+			[]string{"method", "code", "client"},
 		),
 		requestDuration: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
@@ -60,6 +62,24 @@ func NewInterceptor(m MetricsRegistry) (*Interceptor, error) {
 	return i, nil
 }
 
+type clientKey struct{}
+
+// caller holds the client of a request once an inner interceptor has
+// authenticated it.
+type caller struct {
+	client string
+}
+
+// SetClient records the authenticated client of the request ctx belongs to,
+// so that the metrics interceptor wrapping it counts the request by client.
+func SetClient(ctx context.Context, client string) {
+	if c, ok := ctx.Value(clientKey{}).(*caller); ok {
+		c.client = client
+	}
+}
+
+// GetServerInterceptor counts requests by method, code and the client set
+// with SetClient during the call, empty for anonymous calls.
 func (i *Interceptor) GetServerInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context,
 		req interface{},
@@ -69,13 +89,19 @@ func (i *Interceptor) GetServerInterceptor() grpc.UnaryServerInterceptor {
 		startTime := time.Now()
 		method := info.FullMethod
 
-		resp, err := handler(ctx, req)
+		c := &caller{}
+		resp, err := handler(context.WithValue(ctx, clientKey{}, c), req)
 
 		dur := time.Since(startTime)
 		statusCode := status.Code(err)
 
+		client := c.client
+		if client != "" {
+			client = i.limiter.Value("client", client)
+		}
+
 		i.requestDuration.WithLabelValues(method).Observe(dur.Seconds())
-		i.totalRequests.WithLabelValues(method, statusCode.String()).Inc()
+		i.totalRequests.WithLabelValues(method, statusCode.String(), client).Inc()
 
 		if statusCode != codes.OK {
 			i.errorsTotal.WithLabelValues(method, statusCode.String()).Inc()
@@ -0,0 +1,399 @@
+// Package migrate runs versioned MongoDB migrations. Applied versions are
+// recorded in a migrations collection, which also holds the lock that keeps
+// concurrent runners, e.g. several replicas starting at once, from applying
+// the same migration twice.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	lockID             = "lock"
+	defaultCollection  = "migrations"
+	defaultLockTTL     = time.Minute
+	defaultLockRetry   = time.Second
+	lockExpiresAtField = "expires_at"
+)
+
+var ErrLocked = errors.New("migrations are locked by another runner")
+
+// ErrLockLost aborts a run whose lock could not be renewed, e.g. because it
+// expired and another runner took it over.
+var ErrLockLost = errors.New("migration lock lost")
+
+// Migration is a versioned change of the database; Down reverts Up.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+	Down        func(ctx context.Context, db *mongo.Database) error
+}
+
+// Status is the state of a migration; AppliedAt is zero for pending ones.
+type Status struct {
+	Version     int
+	Description string
+	AppliedAt   time.Time
+}
+
+func (s Status) Applied() bool {
+	return !s.AppliedAt.IsZero()
+}
+
+type record struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+type Migrator struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+	migrations []Migration
+	owner      string
+	lockTTL    time.Duration
+	lockRetry  time.Duration
+	lgr        *zap.Logger
+}
+
+type Option func(*Migrator)
+
+// WithCollection sets the migrations collection, "migrations" by default.
+func WithCollection(name string) Option {
+	return func(m *Migrator) {
+		m.collection = m.db.Collection(name)
+	}
+}
+
+// WithLockTTL sets how long the lock is held without a renewal before another
+// runner may take it over. A running migration renews it every third of ttl.
+func WithLockTTL(ttl time.Duration) Option {
+	return func(m *Migrator) {
+		m.lockTTL = ttl
+	}
+}
+
+func WithLogger(lgr *zap.Logger) Option {
+	return func(m *Migrator) {
+		m.lgr = lgr
+	}
+}
+
+func New(db *mongo.Database, migrations []Migration, opts ...Option) (*Migrator, error) {
+	if err := validate(migrations); err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+
+	m := &Migrator{
+		db:         db,
+		collection: db.Collection(defaultCollection),
+		migrations: slices.SortedFunc(slices.Values(migrations), byVersion),
+		owner:      hostname + "/" + strconv.Itoa(os.Getpid()) + "/" + primitive.NewObjectID().Hex(),
+		lockTTL:    defaultLockTTL,
+		lockRetry:  defaultLockRetry,
+		lgr:        zap.NewNop(),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m, nil
+}
+
+// Up applies the pending migrations up to target, all of them if target is 0.
+func (m *Migrator) Up(ctx context.Context, target int) error {
+	return m.locked(ctx, func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range pending(m.migrations, applied, target) {
+			if err := m.lock(ctx); err != nil {
+				return err
+			}
+
+			m.lgr.Info("Applying migration", zap.Int("version", migration.Version), zap.String("description", migration.Description))
+			if err := migration.Up(ctx, m.db); err != nil {
+				return fmt.Errorf("migration %d up: %w", migration.Version, err)
+			}
+
+			_, err := m.collection.InsertOne(ctx, record{
+				Version:     migration.Version,
+				Description: migration.Description,
+				AppliedAt:   time.Now().UTC(),
+			})
+			if err != nil {
+				return fmt.Errorf("record migration %d: %w", migration.Version, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Down reverts the applied migrations above target, newest first.
+func (m *Migrator) Down(ctx context.Context, target int) error {
+	return m.locked(ctx, func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		migrations, err := reverting(m.migrations, applied, target)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			if err := m.lock(ctx); err != nil {
+				return err
+			}
+
+			m.lgr.Info("Reverting migration", zap.Int("version", migration.Version), zap.String("description", migration.Description))
+			if err := migration.Down(ctx, m.db); err != nil {
+				return fmt.Errorf("migration %d down: %w", migration.Version, err)
+			}
+
+			if _, err := m.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: migration.Version}}); err != nil {
+				return fmt.Errorf("unrecord migration %d: %w", migration.Version, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Status returns the known migrations and the applied ones this binary does
+// not know, by version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		res = append(res, Status{
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   applied[migration.Version].AppliedAt,
+		})
+		delete(applied, migration.Version)
+	}
+
+	for _, r := range applied {
+		res = append(res, Status{Version: r.Version, Description: r.Description, AppliedAt: r.AppliedAt})
+	}
+
+	slices.SortFunc(res, func(a, b Status) int { return a.Version - b.Version })
+	return res, nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]record, error) {
+	cursor, err := m.collection.Find(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$type", Value: "number"}}}})
+	if err != nil {
+		return nil, fmt.Errorf("find applied migrations: %w", err)
+	}
+
+	var records []record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("decode applied migrations: %w", err)
+	}
+
+	applied := make(map[int]record, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+
+	return applied, nil
+}
+
+// locked runs fn holding the lock, waiting for it until ctx is done. The lock
+// is renewed while fn runs; if a renewal fails, the ctx of fn is canceled and
+// ErrLockLost is returned.
+func (m *Migrator) locked(ctx context.Context, fn func(context.Context) error) error {
+	// An expired lock of a crashed runner is also removed by the TTL index.
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: lockExpiresAtField, Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("create lock index: %w", err)
+	}
+
+	for {
+		err := m.lock(ctx)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrLocked) {
+			return err
+		}
+
+		m.lgr.Info("Waiting for migration lock")
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrLocked, ctx.Err())
+		case <-time.After(m.lockRetry):
+		}
+	}
+
+	defer func() {
+		_, err := m.collection.DeleteOne(context.WithoutCancel(ctx), bson.D{
+			{Key: "_id", Value: lockID},
+			{Key: "owner", Value: m.owner},
+		})
+		if err != nil {
+			m.lgr.Warn("Failed to release migration lock", zap.Error(err))
+		}
+	}()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		heartbeat(ctx, m.lockTTL/3, m.lock, cancel)
+	}()
+
+	err = fn(ctx)
+	if cause := context.Cause(ctx); errors.Is(cause, ErrLockLost) {
+		err = cause
+	}
+
+	cancel(nil)
+	<-renewed
+
+	return err
+}
+
+// heartbeat calls renew every interval until ctx is done. A failed renewal
+// cancels ctx with ErrLockLost.
+func heartbeat(ctx context.Context, interval time.Duration, renew func(context.Context) error, cancel context.CancelCauseFunc) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		if err := renew(ctx); err != nil && ctx.Err() == nil {
+			cancel(fmt.Errorf("%w: %w", ErrLockLost, err))
+			return
+		}
+	}
+}
+
+// lock takes or extends the lock. A lock held by another runner is taken
+// over only once it expires.
+func (m *Migrator) lock(ctx context.Context) error {
+	now := time.Now()
+
+	_, err := m.collection.UpdateOne(ctx,
+		bson.D{
+			{Key: "_id", Value: lockID},
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: "owner", Value: m.owner}},
+				bson.D{{Key: lockExpiresAtField, Value: bson.D{{Key: "$lt", Value: now}}}},
+			}},
+		},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "owner", Value: m.owner},
+			{Key: lockExpiresAtField, Value: now.Add(m.lockTTL)},
+		}}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrLocked
+	}
+	if err != nil {
+		return fmt.Errorf("lock migrations: %w", err)
+	}
+
+	return nil
+}
+
+func validate(migrations []Migration) error {
+	versions := make(map[int]struct{}, len(migrations))
+
+	for _, migration := range migrations {
+		if migration.Version <= 0 {
+			return fmt.Errorf("migration %d: version must be positive", migration.Version)
+		}
+		if _, ok := versions[migration.Version]; ok {
+			return fmt.Errorf("migration %d: duplicate version", migration.Version)
+		}
+		if migration.Up == nil || migration.Down == nil {
+			return fmt.Errorf("migration %d: up and down are required", migration.Version)
+		}
+		versions[migration.Version] = struct{}{}
+	}
+
+	return nil
+}
+
+// pending returns the migrations to apply to reach target, 0 meaning the
+// latest version, in version order.
+func pending(migrations []Migration, applied map[int]record, target int) []Migration {
+	var res []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if target > 0 && migration.Version > target {
+			break
+		}
+		res = append(res, migration)
+	}
+
+	return res
+}
+
+// reverting returns the applied migrations above target, newest first.
+func reverting(migrations []Migration, applied map[int]record, target int) ([]Migration, error) {
+	known := make(map[int]Migration, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = migration
+	}
+
+	var res []Migration
+	for version := range applied {
+		if version <= target {
+			continue
+		}
+
+		migration, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("migration %d is applied but unknown", version)
+		}
+		res = append(res, migration)
+	}
+
+	slices.SortFunc(res, func(a, b Migration) int { return b.Version - a.Version })
+	return res, nil
+}
+
+func byVersion(a, b Migration) int {
+	return a.Version - b.Version
+}
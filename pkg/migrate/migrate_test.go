@@ -0,0 +1,133 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func testMigrations(versions ...int) []Migration {
+	noop := func(context.Context, *mongo.Database) error { return nil }
+
+	res := make([]Migration, 0, len(versions))
+	for _, v := range versions {
+		res = append(res, Migration{Version: v, Up: noop, Down: noop})
+	}
+	return res
+}
+
+func appliedVersions(versions ...int) map[int]record {
+	res := make(map[int]record, len(versions))
+	for _, v := range versions {
+		res[v] = record{Version: v}
+	}
+	return res
+}
+
+func versions(migrations []Migration) []int {
+	res := make([]int, 0, len(migrations))
+	for _, m := range migrations {
+		res = append(res, m.Version)
+	}
+	return res
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, validate(testMigrations(1, 2, 3)))
+	assert.ErrorContains(t, validate(testMigrations(1, 2, 2)), "duplicate version")
+	assert.ErrorContains(t, validate(testMigrations(0)), "version must be positive")
+
+	missingDown := testMigrations(1)
+	missingDown[0].Down = nil
+	assert.ErrorContains(t, validate(missingDown), "up and down are required")
+}
+
+func TestPending(t *testing.T) {
+	migrations := testMigrations(1, 2, 3, 4)
+
+	tests := []struct {
+		name    string
+		applied map[int]record
+		target  int
+		want    []int
+	}{
+		{name: "fresh database", applied: appliedVersions(), want: []int{1, 2, 3, 4}},
+		{name: "up to date", applied: appliedVersions(1, 2, 3, 4), want: []int{}},
+		{name: "partially applied", applied: appliedVersions(1, 2), want: []int{3, 4}},
+		{name: "gap", applied: appliedVersions(1, 3), want: []int{2, 4}},
+		{name: "target", applied: appliedVersions(1), target: 3, want: []int{2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, versions(pending(migrations, tt.applied, tt.target)))
+		})
+	}
+}
+
+func TestReverting(t *testing.T) {
+	migrations := testMigrations(1, 2, 3, 4)
+
+	tests := []struct {
+		name    string
+		applied map[int]record
+		target  int
+		want    []int
+		err     string
+	}{
+		{name: "all", applied: appliedVersions(1, 2, 3), target: 0, want: []int{3, 2, 1}},
+		{name: "target", applied: appliedVersions(1, 2, 3, 4), target: 2, want: []int{4, 3}},
+		{name: "nothing above target", applied: appliedVersions(1, 2), target: 2, want: []int{}},
+		{name: "unknown applied", applied: appliedVersions(1, 5), target: 0, err: "migration 5 is applied but unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := reverting(migrations, tt.applied, tt.target)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, versions(res))
+		})
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	errTaken := errors.New("taken over")
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	renewals := 0
+	renew := func(context.Context) error {
+		renewals++
+		if renewals == 3 {
+			return errTaken
+		}
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		heartbeat(ctx, time.Millisecond, renew, cancel)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("heartbeat did not stop after a failed renewal")
+	}
+
+	assert.Equal(t, 3, renewals)
+	assert.ErrorIs(t, context.Cause(ctx), ErrLockLost)
+	assert.ErrorIs(t, context.Cause(ctx), errTaken)
+}
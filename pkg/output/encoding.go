@@ -0,0 +1,59 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+type Encoding string
+
+const (
+	EncodingJSON        Encoding = "json"
+	EncodingCompactJSON Encoding = "compact_json"
+	EncodingProtobuf    Encoding = "protobuf"
+)
+
+// Encode serializes a composed item. Protobuf needs the View's output message
+// descriptor; result fields unknown to the message are dropped.
+func Encode(value map[string]any, encoding Encoding, md protoreflect.MessageDescriptor) ([]byte, error) {
+	switch encoding {
+	case EncodingJSON:
+		return json.MarshalIndent(value, "", "  ")
+	case EncodingCompactJSON:
+		return json.Marshal(value)
+	case EncodingProtobuf:
+		if md == nil {
+			return nil, fmt.Errorf("protobuf encoding requires an output message")
+		}
+
+		msg, err := ToMessage(value, md)
+		if err != nil {
+			return nil, err
+		}
+
+		return proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+}
+
+// ToMessage converts a composed item into a dynamic message of type md using
+// the protobuf JSON mapping.
+func ToMessage(value map[string]any, md protoreflect.MessageDescriptor) (*dynamicpb.Message, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	msg := dynamicpb.NewMessage(md)
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("failed to convert value to %s: %w", md.FullName(), err)
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,93 @@
+package output
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const cardProto = `
+syntax = "proto3";
+
+package feed;
+
+message Card {
+  string title = 1;
+  int32 reactions = 2;
+  repeated Tag tags = 3;
+
+  message Tag {
+    string name = 1;
+  }
+}
+`
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.RegisterProto("card.proto", []byte(cardProto)))
+
+	md, err := r.FindMessage("feed.Card")
+	require.NoError(t, err)
+	assert.Equal(t, "title", string(md.Fields().ByNumber(1).Name()))
+
+	_, err = r.FindMessage("feed.Card.Tag")
+	assert.NoError(t, err)
+
+	_, err = r.FindMessage("feed.Unknown")
+	assert.Error(t, err)
+
+	err = r.RegisterProto("broken.proto", []byte(`syntax = "proto3"; message {`))
+	assert.Error(t, err)
+
+	err = r.RegisterProto("list.proto", []byte(`
+syntax = "proto3";
+package feed;
+import "card.proto";
+message CardList { repeated Card cards = 1; }
+`))
+	assert.NoError(t, err, "registered files can be imported")
+}
+
+func TestEncode(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.RegisterProto("card.proto", []byte(cardProto)))
+	md, err := r.FindMessage("feed.Card")
+	require.NoError(t, err)
+
+	value := map[string]any{
+		"title":     "Hello",
+		"reactions": 5,
+		"tags":      []any{map[string]any{"name": "news"}},
+		"unknown":   "dropped",
+	}
+
+	data, err := Encode(value, EncodingJSON, nil)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "\n  ")
+
+	data, err = Encode(value, EncodingCompactJSON, nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"title":"Hello","reactions":5,"tags":[{"name":"news"}],"unknown":"dropped"}`, string(data))
+	assert.NotContains(t, string(data), "\n")
+
+	data, err = Encode(value, EncodingProtobuf, md)
+	require.NoError(t, err)
+
+	msg := dynamicpb.NewMessage(md)
+	require.NoError(t, proto.Unmarshal(data, msg))
+	assert.Equal(t, "Hello", msg.Get(md.Fields().ByName("title")).String())
+	assert.Equal(t, int64(5), msg.Get(md.Fields().ByName("reactions")).Int())
+	assert.Equal(t, 1, msg.Get(md.Fields().ByName("tags")).List().Len())
+
+	_, err = Encode(value, EncodingProtobuf, nil)
+	assert.Error(t, err, "protobuf requires a message")
+
+	_, err = Encode(map[string]any{"reactions": "many"}, EncodingProtobuf, md)
+	assert.Error(t, err, "type mismatch")
+
+	_, err = Encode(value, "xml", nil)
+	assert.Error(t, err)
+}
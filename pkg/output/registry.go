@@ -0,0 +1,104 @@
+package output
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Registry holds message descriptors of `Proto` documents that Views use as
+// output types.
+type Registry struct {
+	mu       sync.RWMutex
+	sources  map[string]string
+	messages map[protoreflect.FullName]protoreflect.MessageDescriptor
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		sources:  make(map[string]string),
+		messages: make(map[protoreflect.FullName]protoreflect.MessageDescriptor),
+	}
+}
+
+// RegisterProto parses a proto file and registers all its messages, including
+// nested ones. The file may import files registered earlier and well-known types.
+func (r *Registry) RegisterProto(filename string, source []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sources := make(map[string]string, len(r.sources)+1)
+	for name, src := range r.sources {
+		sources[name] = src
+	}
+	sources[filename] = string(source)
+
+	parser := protoparse.Parser{
+		Accessor: protoparse.FileContentsFromMap(sources),
+	}
+
+	fds, err := parser.ParseFiles(filename)
+	if err != nil {
+		return fmt.Errorf("failed to parse proto %s: %w", filename, err)
+	}
+
+	r.sources[filename] = string(source)
+	registerMessages(r.messages, fds[0].UnwrapFile().Messages())
+	return nil
+}
+
+// Clone returns a registry with the protos registered so far. Protos
+// registered to the clone are not visible in r.
+func (r *Registry) Clone() *Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	clone := NewRegistry()
+	for name, src := range r.sources {
+		clone.sources[name] = src
+	}
+	for name, md := range r.messages {
+		clone.messages[name] = md
+	}
+
+	return clone
+}
+
+// Merge registers the protos of o, usually a clone of r with more protos, in
+// r at once.
+func (r *Registry) Merge(o *Registry) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, src := range o.sources {
+		r.sources[name] = src
+	}
+	for name, md := range o.messages {
+		r.messages[name] = md
+	}
+}
+
+// FindMessage returns the descriptor of a registered message by its full name.
+func (r *Registry) FindMessage(fullName string) (protoreflect.MessageDescriptor, error) {
+	r.mu.RLock()
+	md, ok := r.messages[protoreflect.FullName(fullName)]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("message %s not registered", fullName)
+	}
+
+	return md, nil
+}
+
+func registerMessages(dst map[protoreflect.FullName]protoreflect.MessageDescriptor, messages protoreflect.MessageDescriptors) {
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		dst[md.FullName()] = md
+		registerMessages(dst, md.Messages())
+	}
+}
@@ -0,0 +1,5 @@
+package parser
+
+type Config struct {
+	Limits Limits `yaml:"limits"`
+}
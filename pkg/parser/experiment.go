@@ -0,0 +1,144 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"item_compositiom_service/pkg/expression"
+)
+
+// bucketCount is the resolution of variant weights: 0.01%.
+const bucketCount = 10000
+
+// Assignment is the experiment variant an item was rendered with.
+type Assignment struct {
+	Experiment string
+	Variant    string
+}
+
+// experiment is the `spec.experiment` block of a View:
+//
+//	experiment:
+//	  name: card_layout
+//	  key: request.principal_id
+//	  variants:
+//	    - name: control
+//	      weight: 90
+//	    - name: big_image
+//	      weight: 10
+//	      templates: ["card_big_image"]
+//
+// A variant without templates keeps the View's templates.
+type experiment struct {
+	name     string
+	key      string
+	variants []variant
+	total    float64
+}
+
+type variant struct {
+	name      string
+	weight    float64
+	templates []string
+}
+
+func parseExperiment(raw any) (*experiment, error) {
+	spec, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("experiment must be an object")
+	}
+
+	exp := &experiment{}
+	exp.name, _ = spec["name"].(string)
+	exp.key, _ = spec["key"].(string)
+	if exp.name == "" || exp.key == "" {
+		return nil, fmt.Errorf("experiment requires name and key")
+	}
+
+	if err := expression.Validate(exp.key); err != nil {
+		return nil, fmt.Errorf("experiment %s key: %w", exp.name, err)
+	}
+
+	variants, _ := spec["variants"].([]any)
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("experiment %s has no variants", exp.name)
+	}
+
+	for _, rawVariant := range variants {
+		v, ok := rawVariant.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("experiment %s variant must be an object", exp.name)
+		}
+
+		name, _ := v["name"].(string)
+		weight, ok := expression.ToFloat(v["weight"])
+		if name == "" || !ok || weight < 0 {
+			return nil, fmt.Errorf("experiment %s variant requires name and non-negative weight", exp.name)
+		}
+
+		var templates []string
+		if list, ok := v["templates"].([]any); ok {
+			for _, tmpl := range list {
+				if tmplName, ok := tmpl.(string); ok {
+					templates = append(templates, tmplName)
+				}
+			}
+		}
+
+		exp.variants = append(exp.variants, variant{name: name, weight: weight, templates: templates})
+		exp.total += weight
+	}
+
+	if exp.total <= 0 {
+		return nil, fmt.Errorf("experiment %s has zero total weight", exp.name)
+	}
+
+	return exp, nil
+}
+
+// assign picks a variant by hashing the experiment name with the bucketing key,
+// so a key always gets the same variant while weights stay the same.
+func (e *experiment) assign(key string) *variant {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(e.name))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+
+	bucket := float64(h.Sum64()%bucketCount) / bucketCount * e.total
+
+	var upper float64
+	for i := range e.variants {
+		upper += e.variants[i].weight
+		if bucket < upper {
+			return &e.variants[i]
+		}
+	}
+	return &e.variants[len(e.variants)-1]
+}
+
+// applyExperiment returns the templates of the assigned variant. The View's
+// templates are kept if the View has no experiment or the key is empty.
+func (t *TemplateLib) applyExperiment(ctx context.Context, instr Instruction, templates []string, item map[string]any) ([]string, *Assignment) {
+	exp := instr.experiment
+	if exp == nil {
+		return templates, nil
+	}
+
+	if err := budgetFromContext(ctx).evaluate(ctx); err != nil {
+		return templates, nil
+	}
+
+	key, err := expression.Evaluate(ctx, exp.key, t.params(ctx, item))
+	if err != nil || expression.ToString(key) == "" {
+		t.metrics.errorsCount.WithLabelValues("experiment_error", "empty_bucketing_key").Inc()
+		return templates, nil
+	}
+
+	v := exp.assign(expression.ToString(key))
+	t.metrics.observeAssignment(exp.name, v.name)
+
+	if len(v.templates) > 0 {
+		templates = v.templates
+	}
+	return templates, &Assignment{Experiment: exp.name, Variant: v.name}
+}
@@ -0,0 +1,216 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+var ErrLimitExceeded = errors.New("render limit exceeded")
+
+var errRenderTimeout = errors.New("render timeout")
+
+const (
+	LimitOutputBytes = "max_output_bytes"
+	LimitDepth       = "max_depth"
+	LimitArrayLength = "max_array_length"
+	LimitEvaluations = "max_evaluations"
+	LimitTimeout     = "timeout"
+)
+
+// Limits bound the work spent on rendering one item. Zero means unlimited.
+type Limits struct {
+	MaxOutputBytes int           `yaml:"max_output_bytes"`
+	MaxDepth       int           `yaml:"max_depth"`
+	MaxArrayLength int           `yaml:"max_array_length"`
+	MaxEvaluations int           `yaml:"max_evaluations"`
+	Timeout        time.Duration `yaml:"timeout"`
+}
+
+// LimitError is returned when rendering exceeds one of the limits.
+type LimitError struct {
+	Limit string
+	Max   any
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s %v", ErrLimitExceeded, e.Limit, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// parseLimits parses the `spec.limits` block of a View; `timeout` is a
+// duration string such as "50ms".
+func parseLimits(raw any) (Limits, error) {
+	var res Limits
+
+	spec, ok := raw.(map[string]any)
+	if !ok {
+		return res, fmt.Errorf("limits must be an object")
+	}
+
+	for key, value := range spec {
+		if key == LimitTimeout {
+			str, _ := value.(string)
+			d, err := time.ParseDuration(str)
+			if err != nil || d < 0 {
+				return res, fmt.Errorf("invalid %s %v", key, value)
+			}
+			res.Timeout = d
+			continue
+		}
+
+		n, ok := value.(int)
+		if !ok || n < 0 {
+			return res, fmt.Errorf("invalid %s %v", key, value)
+		}
+
+		switch key {
+		case LimitOutputBytes:
+			res.MaxOutputBytes = n
+		case LimitDepth:
+			res.MaxDepth = n
+		case LimitArrayLength:
+			res.MaxArrayLength = n
+		case LimitEvaluations:
+			res.MaxEvaluations = n
+		default:
+			return res, fmt.Errorf("unknown limit %s", key)
+		}
+	}
+
+	return res, nil
+}
+
+// stricter combines limits, keeping the lower of two set values. It joins the
+// limits of several Views and applies them to the global ones, which a View
+// can tighten but not raise.
+func (l Limits) stricter(o Limits) Limits {
+	minPositive := func(a, b int) int {
+		if a == 0 || (b > 0 && b < a) {
+			return b
+		}
+		return a
+	}
+
+	l.MaxOutputBytes = minPositive(l.MaxOutputBytes, o.MaxOutputBytes)
+	l.MaxDepth = minPositive(l.MaxDepth, o.MaxDepth)
+	l.MaxArrayLength = minPositive(l.MaxArrayLength, o.MaxArrayLength)
+	l.MaxEvaluations = minPositive(l.MaxEvaluations, o.MaxEvaluations)
+	l.Timeout = time.Duration(minPositive(int(l.Timeout), int(o.Timeout)))
+	return l
+}
+
+type budgetKey struct{}
+
+// budget tracks the limits of one render. The first exceeded limit is kept and
+// fails every following evaluation, so a runaway template stops early.
+type budget struct {
+	limits      Limits
+	evaluations atomic.Int64
+	err         atomic.Pointer[LimitError]
+}
+
+func withBudget(ctx context.Context, b *budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+func budgetFromContext(ctx context.Context) *budget {
+	b, _ := ctx.Value(budgetKey{}).(*budget)
+	return b
+}
+
+func (b *budget) exceed(limit string, max any) error {
+	err := &LimitError{Limit: limit, Max: max}
+	b.err.CompareAndSwap(nil, err)
+	return b.err.Load()
+}
+
+// evaluate accounts for one expression evaluation.
+func (b *budget) evaluate(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	if err := b.check(ctx); err != nil {
+		return err
+	}
+
+	if n := b.evaluations.Add(1); b.limits.MaxEvaluations > 0 && n > int64(b.limits.MaxEvaluations) {
+		return b.exceed(LimitEvaluations, b.limits.MaxEvaluations)
+	}
+	return nil
+}
+
+// check returns the exceeded limit, if any, including an expired render
+// timeout. The end of the request itself is returned as ctx.Err().
+func (b *budget) check(ctx context.Context) error {
+	if err := b.err.Load(); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		if errors.Is(context.Cause(ctx), errRenderTimeout) {
+			return b.exceed(LimitTimeout, b.limits.Timeout)
+		}
+		return ctx.Err()
+	}
+	return nil
+}
+
+// checkShape walks a composed value and checks nesting depth and array
+// lengths; the item itself is at depth 1.
+func (b *budget) checkShape(value any, depth int) error {
+	switch value.(type) {
+	case map[string]any, []any:
+		if b.limits.MaxDepth > 0 && depth > b.limits.MaxDepth {
+			return b.exceed(LimitDepth, b.limits.MaxDepth)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, elem := range v {
+			if err := b.checkShape(elem, depth+1); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if b.limits.MaxArrayLength > 0 && len(v) > b.limits.MaxArrayLength {
+			return b.exceed(LimitArrayLength, b.limits.MaxArrayLength)
+		}
+		for _, elem := range v {
+			if err := b.checkShape(elem, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// limitedWriter fails interpolation output that outgrows the output limit or
+// the deadline, which stops a runaway `range`.
+type limitedWriter struct {
+	ctx     context.Context
+	budget  *budget
+	written int
+	buf     []byte
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.budget != nil {
+		if err := w.budget.check(w.ctx); err != nil {
+			return 0, err
+		}
+
+		w.written += len(p)
+		if max := w.budget.limits.MaxOutputBytes; max > 0 && w.written > max {
+			return 0, w.budget.exceed(LimitOutputBytes, max)
+		}
+	}
+
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
@@ -5,18 +5,28 @@ import (
 
 	"errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"time"
 )
 
+// Render metrics are labelled by template id, matched view and status; template
+// and view values, like experiment and variant names, are capped by the
+// registry label limiter.
 type metricsCollector struct {
+	limiter *metrics.LabelLimiter
+
 	parseTime          prometheus.HistogramVec
 	adjustTime         prometheus.HistogramVec
 	errorsCount        prometheus.CounterVec
 	parseRequestCount  prometheus.CounterVec
 	adjustRequestCount prometheus.CounterVec
+
+	experimentAssignments prometheus.CounterVec
 }
 
 func newMetricsCollector(registry metrics.MetricsRegistry) (*metricsCollector, error) {
-	metrics := &metricsCollector{}
+	metrics := &metricsCollector{
+		limiter: metrics.Limiter(registry),
+	}
 
 	metrics.parseTime = *prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "parser_parse_time_seconds",
@@ -26,7 +36,7 @@ func newMetricsCollector(registry metrics.MetricsRegistry) (*metricsCollector, e
 	metrics.adjustTime = *prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "parser_adjust_time_seconds",
 		Help: "Time taken to adjust templates",
-	}, []string{})
+	}, []string{"template", "view", "status"})
 
 	metrics.errorsCount = *prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "parser_errors_total",
@@ -41,7 +51,12 @@ func newMetricsCollector(registry metrics.MetricsRegistry) (*metricsCollector, e
 	metrics.adjustRequestCount = *prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "parser_adjust_requests_total",
 		Help: "Total number of adjust requests",
-	}, []string{})
+	}, []string{"template", "view", "status"})
+
+	metrics.experimentAssignments = *prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "parser_experiment_assignments_total",
+		Help: "Total number of items rendered with an experiment variant",
+	}, []string{"experiment", "variant"})
 
 	r := registry.GetRegistry()
 
@@ -51,6 +66,7 @@ func newMetricsCollector(registry metrics.MetricsRegistry) (*metricsCollector, e
 		r.Register(metrics.errorsCount),
 		r.Register(metrics.parseRequestCount),
 		r.Register(metrics.adjustRequestCount),
+		r.Register(metrics.experimentAssignments),
 	)
 	if err != nil {
 		return nil, err
@@ -58,3 +74,18 @@ func newMetricsCollector(registry metrics.MetricsRegistry) (*metricsCollector, e
 
 	return metrics, nil
 }
+
+func (m *metricsCollector) observeRender(templateID, view, status string, dur time.Duration) {
+	templateID = m.limiter.Value("template", templateID)
+	view = m.limiter.Value("view", view)
+
+	m.adjustRequestCount.WithLabelValues(templateID, view, status).Inc()
+	m.adjustTime.WithLabelValues(templateID, view, status).Observe(dur.Seconds())
+}
+
+func (m *metricsCollector) observeAssignment(experiment, variant string) {
+	m.experimentAssignments.WithLabelValues(
+		m.limiter.Value("experiment", experiment),
+		m.limiter.Value("variant", variant),
+	).Inc()
+}
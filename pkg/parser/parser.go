@@ -3,43 +3,78 @@ package parser
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/PaesslerAG/gval"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	"gopkg.in/yaml.v3"
 	"io"
+	"item_compositiom_service/pkg/expression"
+	"item_compositiom_service/pkg/i18n"
 	"item_compositiom_service/pkg/logger"
 	"item_compositiom_service/pkg/metrics"
+	"item_compositiom_service/pkg/output"
 	"item_compositiom_service/pkg/provider"
+	"item_compositiom_service/pkg/requestctx"
+	"item_compositiom_service/pkg/tracer"
 	"strings"
 	"sync"
-	"text/template"
 	"time"
 )
 
 type contextKey string
 
 const (
-	DataKey contextKey = "data"
+	DataKey       contextKey = "data"
+	TemplateIDKey contextKey = "template_id"
 )
 
+// WithTemplateID sets the id of the rendered template, used as a metric label
+// and a span attribute.
+func WithTemplateID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, TemplateIDKey, id)
+	return tracer.WithAttributes(ctx, tracer.TemplateID(id))
+}
+
+func templateIDFromContext(ctx context.Context) string {
+	if id, _ := ctx.Value(TemplateIDKey).(string); id != "" {
+		return id
+	}
+	return "unknown"
+}
+
 type TemplateLib struct {
-	mu      sync.RWMutex
-	metrics *metricsCollector
-	storage *provider.ProviderStorage
+	mu       sync.RWMutex
+	limits   Limits
+	metrics  *metricsCollector
+	storage  *provider.ProviderStorage
+	messages *i18n.Storage
+	outputs  *output.Registry
 }
 
-func NewTemplateLib(metricsRegistry metrics.MetricsRegistry, storage *provider.ProviderStorage) (*TemplateLib, error) {
+func NewTemplateLib(
+	cfg *Config,
+	metricsRegistry metrics.MetricsRegistry,
+	storage *provider.ProviderStorage,
+	messages *i18n.Storage,
+	outputs *output.Registry,
+) (*TemplateLib, error) {
 	collector, err := newMetricsCollector(metricsRegistry)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create collector collector: %w", err)
 	}
 
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
 	return &TemplateLib{
-		metrics: collector,
-		storage: storage,
+		limits:   cfg.Limits,
+		metrics:  collector,
+		storage:  storage,
+		messages: messages,
+		outputs:  outputs,
 	}, nil
 }
 
@@ -49,6 +84,78 @@ type Instruction struct {
 	Metadata map[string]any `yaml:"metadata"`
 	Spec     map[string]any `yaml:"spec"`
 	If       string         `yaml:"if,omitempty"`
+
+	// catalog is the parsed catalog of a Messages instruction.
+	catalog *i18n.Catalog
+	// experiment and limits are the parsed blocks of a View, nil if not set.
+	experiment *experiment
+	limits     *Limits
+}
+
+// Result is an encoded composed item.
+type Result struct {
+	Data []byte
+	// Encoding is the encoding actually used: protobuf falls back to compact
+	// JSON when the matched View declares no output message.
+	Encoding output.Encoding
+	// MessageType is the full name of the View's output message, if any.
+	MessageType string
+	// Experiments are the variants assigned by matching Views.
+	Experiments []Assignment
+}
+
+// ValidateTemplate parses templateData like ParseTemplate without side
+// effects: its providers and protos are registered to throwaway stores, so a
+// template that is not saved does not change what is served.
+func (t *TemplateLib) ValidateTemplate(templateData []byte) ([]Instruction, error) {
+	stage, err := t.Stage()
+	if err != nil {
+		return nil, err
+	}
+	defer stage.Discard()
+
+	return stage.ParseTemplate(templateData)
+}
+
+// Stage parses templates like its TemplateLib, but registers their providers
+// and protos to its own stores until Commit. A set of templates parsed with
+// one Stage changes what is served only once all of them are parsed.
+type Stage struct {
+	lib    *TemplateLib
+	target *TemplateLib
+}
+
+func (t *TemplateLib) Stage() (*Stage, error) {
+	storage, err := provider.NewProviderStorage(zap.NewNop().Sugar(), &metrics.NoopMetrics{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stage{
+		lib: &TemplateLib{
+			limits:   t.limits,
+			metrics:  t.metrics,
+			storage:  storage,
+			messages: t.messages,
+			outputs:  t.outputs.Clone(),
+		},
+		target: t,
+	}, nil
+}
+
+func (s *Stage) ParseTemplate(templateData []byte) ([]Instruction, error) {
+	return s.lib.ParseTemplate(templateData)
+}
+
+// Commit registers the staged providers and protos to the TemplateLib.
+func (s *Stage) Commit() {
+	s.lib.storage.MoveTo(s.target.storage)
+	s.target.outputs.Merge(s.lib.outputs)
+}
+
+// Discard closes the staged providers that were not committed.
+func (s *Stage) Discard() error {
+	return s.lib.storage.Close()
 }
 
 func (t *TemplateLib) ParseTemplate(templateData []byte) ([]Instruction, error) {
@@ -87,6 +194,56 @@ func (t *TemplateLib) ParseTemplate(templateData []byte) ([]Instruction, error)
 			continue
 		}
 
+		if instr.Kind == "Messages" {
+			locale, _ := instr.Metadata["locale"].(string)
+			messages, _ := instr.Spec["messages"].(map[string]any)
+
+			catalog, err := i18n.ParseCatalog(locale, messages)
+			if err != nil {
+				t.metrics.errorsCount.WithLabelValues("messages_parse_error", "catalog_error").Inc()
+				return nil, fmt.Errorf("error parsing messages: %w", err)
+			}
+
+			instr.catalog = catalog
+			tmpInstructions = append(tmpInstructions, instr)
+			continue
+		}
+
+		if instr.Kind == "Proto" {
+			name, _ := instr.Metadata["name"].(string)
+			source, _ := instr.Spec["proto"].(string)
+			if name == "" || source == "" {
+				t.metrics.errorsCount.WithLabelValues("proto_parse_error", "proto_spec_error").Inc()
+				return nil, fmt.Errorf("proto document requires metadata.name and spec.proto")
+			}
+
+			if err := t.outputs.RegisterProto(name, []byte(source)); err != nil {
+				t.metrics.errorsCount.WithLabelValues("proto_parse_error", "proto_descriptor_error").Inc()
+				return nil, fmt.Errorf("error parsing proto: %w", err)
+			}
+			continue
+		}
+
+		if instr.Kind == "View" {
+			if raw, ok := instr.Spec["experiment"]; ok {
+				exp, err := parseExperiment(raw)
+				if err != nil {
+					t.metrics.errorsCount.WithLabelValues("parse_error", "invalid_experiment").Inc()
+					return nil, fmt.Errorf("error parsing view experiment: %w", err)
+				}
+				instr.experiment = exp
+			}
+
+			if raw, ok := instr.Spec["limits"]; ok {
+				limits, err := parseLimits(raw)
+				if err != nil {
+					t.metrics.errorsCount.WithLabelValues("parse_error", "invalid_limits").Inc()
+					return nil, fmt.Errorf("error parsing view limits: %w", err)
+				}
+				instr.limits = &limits
+			}
+		}
+
 		if ifValue, exists := instr.Spec["if"]; exists {
 			if ifCondition, ok := ifValue.(string); ok {
 				instr.If = ifCondition
@@ -100,30 +257,137 @@ func (t *TemplateLib) ParseTemplate(templateData []byte) ([]Instruction, error)
 	return tmpInstructions, nil
 }
 
+// AdjustTemplate renders an item as indented JSON.
 func (t *TemplateLib) AdjustTemplate(ctx context.Context, item map[string]any, instructions []Instruction) ([]byte, error) {
+	res, err := t.Render(ctx, item, instructions, output.EncodingJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data, nil
+}
+
+// Render composes an item and encodes it with the requested encoding.
+func (t *TemplateLib) Render(ctx context.Context, item map[string]any, instructions []Instruction, encoding output.Encoding) (res *Result, err error) {
 	startTime := time.Now()
-	t.metrics.adjustRequestCount.WithLabelValues().Inc()
+	view := "none"
+	defer func() {
+		t.metrics.observeRender(templateIDFromContext(ctx), view, renderStatus(err), time.Since(startTime))
+	}()
 
-	templateSet := t.findApplicableTemplate(ctx, instructions, item)
+	ctx, span := tracer.Start(ctx, "template.Render")
+	defer func() { tracer.End(span, err) }()
 
-	combinedResult := t.combineTemplates(ctx, instructions, templateSet, item)
+	ctx = i18n.WithTranslator(ctx, t.messages.Translator(i18n.LocaleFromContext(ctx), catalogs(instructions)...))
+
+	report := reportFromContext(ctx)
+	if report == nil {
+		report = NewReport()
+		ctx = WithReport(ctx, report)
+	}
+	report.reset()
+
+	b := &budget{limits: t.limits}
+	renderCtx, cancel := withTimeout(withBudget(ctx, b), startTime, b.limits.Timeout)
+	defer cancel()
+
+	matchCtx, matchSpan := tracer.Start(renderCtx, "template.MatchViews")
+	match := t.findApplicableTemplate(matchCtx, instructions, item)
+	matchSpan.SetAttributes(tracer.ViewKey.StringSlice(match.views))
+	matchSpan.End()
+	if len(match.views) > 0 {
+		view = match.views[0]
+	}
+
+	// Limits of matched Views tighten the global ones for the rest of the render.
+	if match.limits != nil {
+		b.limits = t.limits.stricter(*match.limits)
+		cancel()
+		renderCtx, cancel = withTimeout(withBudget(ctx, b), startTime, b.limits.Timeout)
+		defer cancel()
+	}
+
+	combinedResult := t.combineTemplates(renderCtx, instructions, match.templates, item)
+
+	err = b.check(renderCtx)
+	if err == nil {
+		err = b.checkShape(combinedResult, 1)
+	}
+	if err != nil {
+		return nil, t.limitExceeded(err)
+	}
+
+	if err := report.missingRequired(); err != nil {
+		t.metrics.errorsCount.WithLabelValues("adjust_error", "required_field_missing").Inc()
+		return nil, fmt.Errorf("item dropped: %w", err)
+	}
 
 	if len(combinedResult) == 0 {
 		logger.FromContext(ctx).With("component", "template_lib").Warn("No combined result")
 	}
 
-	finalJSON, err := json.MarshalIndent(combinedResult, "", "  ")
+	res = &Result{
+		Encoding:    encoding,
+		MessageType: match.messageType,
+		Experiments: match.experiments,
+	}
+
+	var md protoreflect.MessageDescriptor
+	if match.messageType != "" {
+		if md, err = t.outputs.FindMessage(match.messageType); err != nil {
+			t.metrics.errorsCount.WithLabelValues("adjust_error", "output_message_not_found").Inc()
+			return nil, fmt.Errorf("error resolving output message: %w", err)
+		}
+	} else if encoding == output.EncodingProtobuf {
+		res.Encoding = output.EncodingCompactJSON
+	}
+
+	_, encodeSpan := tracer.Start(ctx, "template.Encode", attribute.String("encoding", string(res.Encoding)))
+	data, err := output.Encode(combinedResult, res.Encoding, md)
+	encodeSpan.SetAttributes(attribute.Int("size", len(data)))
+	tracer.End(encodeSpan, err)
 	if err != nil {
-		t.metrics.errorsCount.WithLabelValues("adjust_error", "json_marshal_error").Inc()
-		return nil, fmt.Errorf("error marshaling final result: %w", err)
+		t.metrics.errorsCount.WithLabelValues("adjust_error", "encode_error").Inc()
+		return nil, fmt.Errorf("error encoding final result: %w", err)
+	}
+	res.Data = data
+
+	if max := b.limits.MaxOutputBytes; max > 0 && len(data) > max {
+		return nil, t.limitExceeded(b.exceed(LimitOutputBytes, max))
 	}
 
-	t.metrics.adjustTime.WithLabelValues().Observe(time.Since(startTime).Seconds())
-	return finalJSON, nil
+	return res, nil
 }
 
-func (t *TemplateLib) findApplicableTemplate(ctx context.Context, instructions []Instruction, item map[string]any) map[string]struct{} {
-	templateSet := make(map[string]struct{})
+// catalogs returns the message catalogs of a template, used only by its own
+// renders.
+func catalogs(instructions []Instruction) []*i18n.Catalog {
+	var res []*i18n.Catalog
+	for _, instr := range instructions {
+		if instr.catalog != nil {
+			res = append(res, instr.catalog)
+		}
+	}
+	return res
+}
+
+// viewMatch is the result of matching Views against an item.
+type viewMatch struct {
+	templates map[string]struct{}
+	// messageType is the output message of the first matching View that declares one.
+	messageType string
+	views       []string
+	experiments []Assignment
+	// limits are the combined limits of matching Views, nil if none set any.
+	limits *Limits
+}
+
+// findApplicableTemplate collects the templates of all matching Views; a View
+// with an experiment contributes the templates of the assigned variant.
+func (t *TemplateLib) findApplicableTemplate(ctx context.Context, instructions []Instruction, item map[string]any) *viewMatch {
+	match := &viewMatch{
+		templates: make(map[string]struct{}),
+	}
 
 	for _, instr := range instructions {
 		if strings.TrimSpace(instr.Kind) != "View" {
@@ -131,31 +395,56 @@ func (t *TemplateLib) findApplicableTemplate(ctx context.Context, instructions [
 		}
 
 		if instr.If != "" {
-			match, err := t.evaluateCondition(ctx, instr.If, item)
+			ok, err := t.evaluateCondition(ctx, instr.If, item)
 			if err != nil {
 				logger.FromContext(ctx).With("component", "template_lib").Warn("Failed to evaluate condition", zap.Error(err))
 				continue
 			}
-			if !match {
+			if !ok {
 				continue
 			}
 		}
 
-		if templateValue, exists := instr.Spec["template"]; exists {
-			if specTemplates, ok := templateValue.(map[string]any); ok {
-				if templatesValue, exists := specTemplates["templates"]; exists {
-					if templates, ok := templatesValue.([]any); ok {
-						for _, tmpl := range templates {
-							if tmplName, ok := tmpl.(string); ok {
-								templateSet[tmplName] = struct{}{}
-							}
-						}
-					}
+		if name, ok := instr.Metadata["name"].(string); ok {
+			match.views = append(match.views, name)
+		}
+
+		if outputValue, ok := instr.Spec["output"].(map[string]any); ok && match.messageType == "" {
+			match.messageType, _ = outputValue["message"].(string)
+		}
+
+		if instr.limits != nil {
+			limits := *instr.limits
+			if match.limits != nil {
+				limits = match.limits.stricter(limits)
+			}
+			match.limits = &limits
+		}
+
+		templates, assignment := t.applyExperiment(ctx, instr, viewTemplateNames(instr), item)
+		if assignment != nil {
+			match.experiments = append(match.experiments, *assignment)
+		}
+
+		for _, tmplName := range templates {
+			match.templates[tmplName] = struct{}{}
+		}
+	}
+	return match
+}
+
+func viewTemplateNames(instr Instruction) []string {
+	var res []string
+	if specTemplates, ok := instr.Spec["template"].(map[string]any); ok {
+		if templates, ok := specTemplates["templates"].([]any); ok {
+			for _, tmpl := range templates {
+				if tmplName, ok := tmpl.(string); ok {
+					res = append(res, tmplName)
 				}
 			}
 		}
 	}
-	return templateSet
+	return res
 }
 
 func (t *TemplateLib) combineTemplates(ctx context.Context, instructions []Instruction, templateSet map[string]struct{}, item map[string]any) map[string]any {
@@ -171,6 +460,17 @@ func (t *TemplateLib) combineTemplates(ctx context.Context, instructions []Instr
 			continue
 		}
 
+		if instr.If != "" {
+			match, err := t.evaluateCondition(ctx, instr.If, item)
+			if err != nil {
+				logger.FromContext(ctx).With("component", "template_lib").Warn("Failed to evaluate template condition", zap.Error(err))
+				continue
+			}
+			if !match {
+				continue
+			}
+		}
+
 		t.applyTemplateSpec(ctx, instr.Spec, combined, item)
 	}
 
@@ -209,12 +509,25 @@ func (t *TemplateLib) applyMapValue(ctx context.Context, key string, val map[str
 		t.processNumberValue(ctx, key, val, combined, item)
 	case "array":
 		t.processArrayValue(ctx, key, val, combined, item)
+		t.requireValue(ctx, key, val, combined, nil)
 	case "bool":
 		if boolVal, ok := val["value"].(bool); ok {
 			combined[key] = boolVal
 		}
+		t.requireValue(ctx, key, val, combined, nil)
 	case "object":
 		t.applyNestedObject(ctx, key, val, combined, item)
+		t.requireValue(ctx, key, val, combined, nil)
+	case "date":
+		t.processTypedValue(ctx, key, typeStr, val, combined, item, convertDate)
+	case "enum":
+		t.processTypedValue(ctx, key, typeStr, val, combined, item, convertEnum)
+	case "money":
+		t.processTypedValue(ctx, key, typeStr, val, combined, item, t.convertMoney)
+	case "url":
+		t.processTypedValue(ctx, key, typeStr, val, combined, item, t.convertURL)
+	case "image":
+		t.processTypedValue(ctx, key, typeStr, val, combined, item, t.convertImage)
 	default:
 		combined[key] = val
 	}
@@ -249,50 +562,34 @@ func (t *TemplateLib) applyNestedObject(ctx context.Context, key string, val map
 
 func (t *TemplateLib) processStringValue(ctx context.Context, key string, val map[string]any, result map[string]any, item map[string]any) error {
 	var errList []error
-	if pathValue, exists := val["path"]; exists {
-		pathStr, ok := pathValue.(string)
-		if !ok {
-			errList = append(errList, fmt.Errorf("path value is not a string for key: %s", key))
-		} else {
-			var p provider.Provider
-			if !strings.HasPrefix(pathStr, "item") {
-				pathes := strings.Split(pathStr, ".")
-				var err error
-
-				p, err = t.storage.GetProvider(pathes[0])
-				if err != nil {
-					return fmt.Errorf("Failed to get provider for path: %s", pathStr)
-				}
-
-				res, err := p.ExecuteMethod(ctx, pathStr, item)
-				if err != nil {
-					return fmt.Errorf("Failed to execute method for path: %s", pathStr)
-				}
-				item = res.(map[string]any)
-			}
-
-			resolvedValue, err := gval.Evaluate(pathStr, map[string]interface{}{
-				"item": item,
-			})
-			if err != nil {
-				errList = append(errList, fmt.Errorf("error resolving path for key %s: %w", key, err))
-				result[key] = nil
-			} else {
-				result[key] = resolvedValue
-			}
+	if _, exists := val["path"]; exists {
+		resolvedValue, err := t.resolveField(ctx, val, item)
+		if err != nil {
+			errList = append(errList, fmt.Errorf("error resolving path for key %s: %w", key, err))
 		}
+		t.setField(ctx, key, val, result, resolvedValue, err)
 	} else if valValue, exists := val["value"]; exists {
+		var cause error
 		if tmpl, ok := valValue.(string); ok {
 			interpolated, err := t.interpolateString(ctx, tmpl, item)
 			if err != nil {
-				errList = append(errList, fmt.Errorf("error interpolating string for key %s: %w", key, err))
-				result[key] = tmpl
+				cause = fmt.Errorf("error interpolating string for key %s: %w", key, err)
+				errList = append(errList, cause)
+				if defaultValue, ok := val["default"]; ok {
+					result[key] = defaultValue
+				} else if !isRequired(val) {
+					result[key] = tmpl
+				}
 			} else {
 				result[key] = interpolated
 			}
 		} else {
-			errList = append(errList, fmt.Errorf("value is not a string for key: %s", key))
+			cause = fmt.Errorf("value is not a string for key: %s", key)
+			errList = append(errList, cause)
 		}
+		t.requireValue(ctx, key, val, result, cause)
+	} else {
+		t.requireValue(ctx, key, val, result, nil)
 	}
 
 	if len(errList) > 0 {
@@ -302,41 +599,100 @@ func (t *TemplateLib) processStringValue(ctx context.Context, key string, val ma
 }
 
 func (t *TemplateLib) processNumberValue(ctx context.Context, key string, val map[string]any, result map[string]any, item map[string]any) {
-	pathValue, exists := val["path"]
-	if !exists {
+	if _, exists := val["path"]; !exists {
+		t.requireValue(ctx, key, val, result, nil)
 		return
 	}
-	pathStr, ok := pathValue.(string)
+
+	resolvedValue, err := t.resolveField(ctx, val, item)
+	if err != nil {
+		logger.FromContext(ctx).With("component", "template_lib").Warn("Error resolving path for key %s: %v", key, err)
+	}
+	t.setField(ctx, key, val, result, resolvedValue, err)
+}
+
+// resolveField resolves `path`; when it fails or gives null, it tries the
+// `fallback` path and then the `default` value. The returned error describes
+// the failed paths and is nil if nothing failed or a default was used.
+func (t *TemplateLib) resolveField(ctx context.Context, val map[string]any, item map[string]any) (any, error) {
+	pathStr, ok := val["path"].(string)
 	if !ok {
-		logger.FromContext(ctx).With("component", "template_lib").Warn("Path value is not a string for key: %s", key)
-		return
+		return nil, fmt.Errorf("path value is not a string")
 	}
-	if !strings.HasPrefix(pathStr, "item") {
-		pathes := strings.Split(pathStr, ".")
 
-		p, err := t.storage.GetProvider(pathes[0])
-		if err != nil {
-			logger.FromContext(ctx).With("component", "template_lib").Error("No such provider: %s", err)
-			return
-		}
+	value, err := t.resolvePath(ctx, pathStr, item)
+	if err == nil && value != nil {
+		return value, nil
+	}
 
-		res, err := p.ExecuteMethod(ctx, pathStr, item)
-		if err != nil {
-			logger.FromContext(ctx).With("component", "template_lib").Error("No such method: %s", err)
-			return
+	if fallback, ok := val["fallback"].(string); ok && fallback != "" {
+		fallbackValue, fallbackErr := t.resolvePath(ctx, fallback, item)
+		if fallbackErr == nil && fallbackValue != nil {
+			return fallbackValue, nil
+		}
+		if fallbackErr != nil {
+			err = errors.Join(err, fmt.Errorf("fallback: %w", fallbackErr))
 		}
-		item = res.(map[string]any)
 	}
 
-	resolvedValue, err := gval.Evaluate(pathStr, map[string]interface{}{
-		"item": item,
-	})
-	if err != nil {
-		logger.FromContext(ctx).With("component", "template_lib").Warn("Error resolving path for key %s: %v", key, err)
-		result[key] = nil
-	} else {
-		result[key] = resolvedValue
+	if defaultValue, ok := val["default"]; ok {
+		return defaultValue, nil
+	}
+
+	return nil, err
+}
+
+// setField stores a resolved value, see requireValue.
+func (t *TemplateLib) setField(ctx context.Context, key string, val map[string]any, result map[string]any, value any, err error) {
+	result[key] = value
+	t.requireValue(ctx, key, val, result, err)
+}
+
+// requireValue reports a `required` field of any type that is null or not set,
+// so that Render drops the whole item. cause is why it could not be rendered.
+func (t *TemplateLib) requireValue(ctx context.Context, key string, val map[string]any, result map[string]any, cause error) {
+	if !isRequired(val) || result[key] != nil {
+		return
+	}
+
+	err := ErrRequiredField
+	if cause != nil {
+		err = fmt.Errorf("%w: %w", ErrRequiredField, cause)
+	}
+	reportFromContext(ctx).add(&FieldError{Field: key, Err: err})
+}
+
+func isRequired(val map[string]any) bool {
+	required, _ := val["required"].(bool)
+	return required
+}
+
+// resolvePath evaluates a path expression. A path whose first segment names a
+// registered provider (`reaction.GetReactionCounters.items[0].total_count`)
+// calls that provider method and exposes its response under the same name.
+func (t *TemplateLib) resolvePath(ctx context.Context, path string, item map[string]any) (any, error) {
+	if err := budgetFromContext(ctx).evaluate(ctx); err != nil {
+		return nil, err
+	}
+
+	params := t.params(ctx, item)
+
+	if providerName, rest, ok := strings.Cut(path, "."); ok {
+		if p, err := t.storage.GetProvider(providerName); err == nil {
+			methodName, _, _ := strings.Cut(rest, ".")
+
+			res, err := p.ExecuteMethod(ctx, methodName, item)
+			if err != nil {
+				return nil, fmt.Errorf("execute method %s of provider %s: %w", methodName, providerName, err)
+			}
+
+			params[providerName] = map[string]any{
+				methodName: res,
+			}
+		}
 	}
+
+	return expression.Evaluate(ctx, path, params)
 }
 
 func (t *TemplateLib) processArrayValue(ctx context.Context, key string, val map[string]any, result map[string]any, item map[string]any) {
@@ -380,48 +736,89 @@ func (t *TemplateLib) processArrayValue(ctx context.Context, key string, val map
 	result[key] = processed
 }
 
-func (t *TemplateLib) interpolateString(_ context.Context, templateStr string, item map[string]any) (string, error) {
-	tmpl, err := template.New("interpolation").Funcs(template.FuncMap{
-		"item": func() map[string]any { return item },
-	}).Parse(templateStr)
-	if err != nil {
-		return templateStr, fmt.Errorf("error parsing template: %w", err)
+// interpolateString renders a string template; the output counts against the
+// output size limit, every function call and range iteration is an evaluation,
+// and it is aborted once any limit is exceeded.
+func (t *TemplateLib) interpolateString(ctx context.Context, templateStr string, item map[string]any) (string, error) {
+	b := budgetFromContext(ctx)
+	if err := b.evaluate(ctx); err != nil {
+		return templateStr, err
+	}
+
+	var step func() error
+	if b != nil {
+		step = func() error { return b.evaluate(ctx) }
 	}
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, nil); err != nil {
-		return templateStr, fmt.Errorf("error executing template: %w", err)
+	w := &limitedWriter{ctx: ctx, budget: b}
+	if err := expression.InterpolateTo(ctx, w, templateStr, t.params(ctx, item), step); err != nil {
+		return templateStr, err
 	}
-	return buf.String(), nil
+	return string(w.buf), nil
 }
 
-func (t *TemplateLib) evaluateCondition(_ context.Context, condition string, item map[string]any) (bool, error) {
-	params := map[string]interface{}{
-		"item": item,
+func (t *TemplateLib) evaluateCondition(ctx context.Context, condition string, item map[string]any) (bool, error) {
+	if err := budgetFromContext(ctx).evaluate(ctx); err != nil {
+		return false, err
 	}
 
-	if err := t.validateKeys(condition, params); err != nil {
+	params := t.params(ctx, item)
+
+	if err := t.validateKeys(ctx, condition, params); err != nil {
 		return false, err
 	}
 
-	exprLanguage := gval.Full()
-	expr, err := exprLanguage.Evaluate(condition, params)
+	result, err := expression.EvaluateBool(ctx, condition, params)
 	if err != nil {
 		return false, fmt.Errorf("error evaluating condition: %w", err)
 	}
-
-	result, ok := expr.(bool)
-	if !ok {
-		return false, fmt.Errorf("condition did not evaluate to a boolean: %v", expr)
-	}
 	return result, nil
 }
 
-func (t *TemplateLib) validateKeys(condition string, params map[string]interface{}) error {
-	exprLanguage := gval.Full()
-	_, err := exprLanguage.Evaluate(condition, params)
+// params returns expression parameters: the item and the request, client and
+// ctx namespaces of the request environment.
+func (t *TemplateLib) params(ctx context.Context, item map[string]any) map[string]any {
+	params := requestctx.FromContext(ctx).Params()
+	params["item"] = item
+	return params
+}
+
+func (t *TemplateLib) validateKeys(ctx context.Context, condition string, params map[string]any) error {
+	_, err := expression.Evaluate(ctx, condition, params)
 	if err != nil {
 		return errors.New("undefined keys in condition: " + err.Error())
 	}
 	return nil
 }
+
+// limitExceeded counts a limit violation and wraps it as a per-item error.
+func (t *TemplateLib) limitExceeded(err error) error {
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		t.metrics.errorsCount.WithLabelValues("limit_exceeded", limitErr.Limit).Inc()
+	}
+	return fmt.Errorf("item dropped: %w", err)
+}
+
+// withTimeout bounds the render; its expiry is told from the end of the
+// request by errRenderTimeout.
+func withTimeout(ctx context.Context, start time.Time, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadlineCause(ctx, start.Add(timeout), errRenderTimeout)
+}
+
+// renderStatus is the status label of a render.
+func renderStatus(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrLimitExceeded):
+		return "limit_exceeded"
+	case errors.Is(err, ErrRequiredField):
+		return "dropped"
+	default:
+		return "error"
+	}
+}
@@ -2,11 +2,24 @@ package parser
 
 import (
 	"context"
+	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"item_compositiom_service/pkg/expression"
+	"item_compositiom_service/pkg/i18n"
 	"item_compositiom_service/pkg/metrics"
+	"item_compositiom_service/pkg/output"
 	"item_compositiom_service/pkg/provider"
+	"item_compositiom_service/pkg/requestctx"
+	"item_compositiom_service/pkg/tracer"
+	"os"
 	"testing"
+	"time"
 )
 
 type mockMetricsRegistry struct {
@@ -25,8 +38,10 @@ func (m *mockMetricsRegistry) GetRegistry() prometheus.Registerer {
 
 func setupTestTemplateLib(t *testing.T) *TemplateLib {
 	registry := newMockMetricsRegistry()
-	storage := provider.NewProviderStorage()
-	templateLib, err := NewTemplateLib(registry, storage)
+	storage, err := provider.NewProviderStorage(zap.NewNop().Sugar(), registry)
+	assert.NoError(t, err)
+	messages := i18n.NewStorage(&i18n.Config{DefaultLocale: "en"})
+	templateLib, err := NewTemplateLib(&Config{}, registry, storage, messages, output.NewRegistry())
 	assert.NoError(t, err)
 	return templateLib
 }
@@ -431,7 +446,7 @@ spec:
 func TestValidateKeys(t *testing.T) {
 	cond := "item.unknownField > 10"
 	temp := setupTestTemplateLib(t)
-	err := temp.validateKeys(cond, map[string]interface{}{
+	err := temp.validateKeys(context.Background(), cond, map[string]interface{}{
 		"item": map[string]any{"knownField": 5},
 	})
 
@@ -526,3 +541,1137 @@ func TestProcessArrayValue_ObjectItem(t *testing.T) {
 	assert.True(t, ok, "Should be a slice")
 	assert.Len(t, arrVal, 1, "One item in array")
 }
+
+func TestAdjustTemplate_Localization(t *testing.T) {
+	yamlData := `
+---
+kind: Messages
+metadata:
+  locale: ru
+spec:
+  messages:
+    reactions.count:
+      one: "{count} реакция"
+      few: "{count} реакции"
+      many: "{count} реакций"
+      other: "{count} реакции"
+---
+kind: Messages
+metadata:
+  locale: en
+spec:
+  messages:
+    reactions.title: "Reactions"
+    reactions.count:
+      one: "{count} reaction"
+      other: "{count} reactions"
+---
+kind: View
+metadata:
+  name: view
+spec:
+  template:
+    templates: ["tmpl"]
+---
+kind: Template
+metadata:
+  name: tmpl
+spec:
+  title:
+    type: "string"
+    value: "{{t \"reactions.title\"}}"
+  counter:
+    type: "string"
+    value: "{{t \"reactions.count\" \"count\" item.count}}"
+`
+	temp := setupTestTemplateLib(t)
+	tpls, err := temp.ParseTemplate([]byte(yamlData))
+	assert.NoError(t, err)
+	assert.Len(t, tpls, 4, "Messages documents are instructions of the template")
+
+	tests := []struct {
+		locale   string
+		count    int
+		expected string
+	}{
+		{"ru-RU", 1, `{"title": "Reactions", "counter": "1 реакция"}`},
+		{"ru", 3, `{"title": "Reactions", "counter": "3 реакции"}`},
+		{"ru", 5, `{"title": "Reactions", "counter": "5 реакций"}`},
+		{"en", 5, `{"title": "Reactions", "counter": "5 reactions"}`},
+		{"", 1, `{"title": "Reactions", "counter": "1 reaction"}`},
+	}
+
+	for _, tc := range tests {
+		ctx := i18n.WithLocale(context.Background(), tc.locale)
+		resultJSON, err := temp.AdjustTemplate(ctx, map[string]any{"count": tc.count}, tpls)
+		assert.NoError(t, err)
+		assert.JSONEq(t, tc.expected, string(resultJSON), "locale %q, count %d", tc.locale, tc.count)
+	}
+}
+
+func TestAdjustTemplate_LocalizationScopedToTemplate(t *testing.T) {
+	template := func(messages string) string {
+		return `
+kind: Messages
+metadata:
+  locale: en
+spec:
+  messages:
+` + messages + `
+---
+kind: View
+metadata:
+  name: view
+spec:
+  template:
+    templates: ["tmpl"]
+---
+kind: Template
+metadata:
+  name: tmpl
+spec:
+  title:
+    type: "string"
+    value: "{{t \"title\"}}"
+  subtitle:
+    type: "string"
+    value: "{{t \"subtitle\"}}"
+`
+	}
+
+	temp := setupTestTemplateLib(t)
+	ctx := i18n.WithLocale(context.Background(), "en")
+
+	first, err := temp.ParseTemplate([]byte(template(`    title: "First"
+    subtitle: "Only first"`)))
+	assert.NoError(t, err)
+	second, err := temp.ParseTemplate([]byte(template(`    title: "Second"`)))
+	assert.NoError(t, err)
+
+	res, err := temp.AdjustTemplate(ctx, map[string]any{}, second)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"title": "Second", "subtitle": "subtitle"}`, string(res), "Keys of other templates are not visible")
+
+	res, err = temp.AdjustTemplate(ctx, map[string]any{}, first)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"title": "First", "subtitle": "Only first"}`, string(res))
+
+	reloaded, err := temp.ParseTemplate([]byte(template(`    title: "First"`)))
+	assert.NoError(t, err)
+
+	res, err = temp.AdjustTemplate(ctx, map[string]any{}, reloaded)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"title": "First", "subtitle": "subtitle"}`, string(res), "Removed keys are gone after reload")
+}
+
+func TestAdjustTemplate_TypedValues(t *testing.T) {
+	yamlData := `
+---
+kind: View
+metadata:
+  name: view
+spec:
+  template:
+    templates: ["tmpl"]
+---
+kind: Template
+metadata:
+  name: tmpl
+spec:
+  created:
+    type: "date"
+    path: "item.created"
+    input_format: "2006-01-02 15:04"
+    output_format: "02.01.2006 15:04"
+    timezone: "Europe/Moscow"
+  status:
+    type: "enum"
+    path: "item.status"
+    values: ["draft", "published"]
+    mapping:
+      1: "published"
+  price:
+    type: "money"
+    path: "item.price"
+    currency_path: "item.currency"
+    minor_units: true
+  link:
+    type: "url"
+    value: "https://example.com/items/{{item.id}}?ref=feed"
+    query:
+      utm_source: "app"
+      lang: "{{item.lang}}"
+  cover:
+    type: "image"
+    path: "item.cover"
+    sizes:
+      small:
+        w: 100
+        h: 100
+      large:
+        w: 800
+`
+	temp := setupTestTemplateLib(t)
+	tpls, err := temp.ParseTemplate([]byte(yamlData))
+	assert.NoError(t, err)
+
+	t.Run("valid", func(t *testing.T) {
+		item := map[string]any{
+			"id":       42,
+			"created":  "2024-03-01 21:30",
+			"status":   1,
+			"price":    12990,
+			"currency": "rub",
+			"lang":     "",
+			"cover":    "https://cdn.example.com/a.jpg",
+		}
+
+		report := NewReport()
+		resultJSON, err := temp.AdjustTemplate(WithReport(context.Background(), report), item, tpls)
+		assert.NoError(t, err)
+		assert.Empty(t, report.Errors())
+		assert.JSONEq(t, `{
+			"created": "02.03.2024 00:30",
+			"status": "published",
+			"price": {"amount": 129.9, "minor": 12990, "currency": "RUB"},
+			"link": "https://example.com/items/42?ref=feed&utm_source=app",
+			"cover": {
+				"url": "https://cdn.example.com/a.jpg",
+				"sizes": {
+					"small": "https://cdn.example.com/a.jpg?h=100&w=100",
+					"large": "https://cdn.example.com/a.jpg?w=800"
+				}
+			}
+		}`, string(resultJSON))
+	})
+
+	t.Run("invalid values become null", func(t *testing.T) {
+		item := map[string]any{
+			"id":       42,
+			"created":  "yesterday",
+			"status":   "deleted",
+			"price":    "free",
+			"currency": "RUB",
+			"lang":     "",
+			"cover":    "ftp://cdn.example.com/a.jpg",
+		}
+
+		report := NewReport()
+		resultJSON, err := temp.AdjustTemplate(WithReport(context.Background(), report), item, tpls)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{
+			"created": null,
+			"status": null,
+			"price": null,
+			"link": "https://example.com/items/42?ref=feed&utm_source=app",
+			"cover": null
+		}`, string(resultJSON))
+
+		var fields []string
+		for _, fieldErr := range report.Errors() {
+			fields = append(fields, fieldErr.Field)
+		}
+		assert.ElementsMatch(t, []string{"created", "status", "price", "cover"}, fields)
+	})
+}
+
+func TestConvertURL_Schemes(t *testing.T) {
+	temp := setupTestTemplateLib(t)
+
+	for raw, valid := range map[string]bool{
+		"https://example.com/a":            true,
+		"HTTP://example.com/a":             true,
+		"javascript:alert(1)":              false,
+		"data:text/html,<script></script>": false,
+		"ftp://example.com/a":              false,
+		"mailto:user@example.com":          false,
+		"//example.com/a":                  false,
+		"/items/1":                         false,
+	} {
+		_, err := temp.convertURL(context.Background(), raw, map[string]any{}, nil)
+		assert.Equal(t, valid, err == nil, raw)
+	}
+}
+
+func TestConvertMoney(t *testing.T) {
+	temp := setupTestTemplateLib(t)
+
+	tests := []struct {
+		name     string
+		amount   any
+		val      map[string]any
+		expected any
+		wantErr  bool
+	}{
+		{"major units", 10.555, map[string]any{"currency": "USD"}, map[string]any{"amount": 10.56, "minor": int64(1056), "currency": "USD"}, false},
+		{"zero exponent", 1500, map[string]any{"currency": "JPY"}, map[string]any{"amount": 1500.0, "minor": int64(1500), "currency": "JPY"}, false},
+		{"three digit exponent", 1234, map[string]any{"currency": "KWD", "minor_units": true}, map[string]any{"amount": 1.234, "minor": int64(1234), "currency": "KWD"}, false},
+		{"fractional minor units", 10.5, map[string]any{"currency": "RUB", "minor_units": true}, nil, true},
+		{"unknown currency", 10, map[string]any{"currency": "XXX"}, nil, true},
+		{"missing currency", 10, map[string]any{}, nil, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := temp.convertMoney(context.Background(), tc.amount, tc.val, nil)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+}
+
+func TestAdjustTemplate_DefaultFallbackRequired(t *testing.T) {
+	yamlData := `
+---
+kind: View
+metadata:
+  name: view
+spec:
+  template:
+    templates: ["tmpl"]
+---
+kind: Template
+metadata:
+  name: tmpl
+spec:
+  id:
+    type: "string"
+    path: "item.id"
+    required: true
+  title:
+    type: "string"
+    path: "item.title"
+    fallback: "item.name"
+    default: "Untitled"
+  reactions:
+    type: "number"
+    path: "item.reactions.total"
+    default: 0
+  published:
+    type: "date"
+    path: "item.published"
+    fallback: "item.created"
+    output_format: "date"
+`
+	temp := setupTestTemplateLib(t)
+	tpls, err := temp.ParseTemplate([]byte(yamlData))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		item     map[string]any
+		expected string
+	}{
+		{
+			name:     "primary paths",
+			item:     map[string]any{"id": "1", "title": "Title", "reactions": map[string]any{"total": 5}, "published": "2024-03-01"},
+			expected: `{"id": "1", "title": "Title", "reactions": 5, "published": "2024-03-01"}`,
+		},
+		{
+			name:     "fallback paths",
+			item:     map[string]any{"id": "1", "name": "Name", "created": "2024-02-01"},
+			expected: `{"id": "1", "title": "Name", "reactions": 0, "published": "2024-02-01"}`,
+		},
+		{
+			name:     "null primary uses fallback",
+			item:     map[string]any{"id": "1", "title": nil, "name": "Name", "created": "2024-02-01"},
+			expected: `{"id": "1", "title": "Name", "reactions": 0, "published": "2024-02-01"}`,
+		},
+		{
+			name:     "defaults",
+			item:     map[string]any{"id": "1"},
+			expected: `{"id": "1", "title": "Untitled", "reactions": 0, "published": null}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resultJSON, err := temp.AdjustTemplate(context.Background(), tc.item, tpls)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.expected, string(resultJSON))
+		})
+	}
+
+	t.Run("missing required drops item", func(t *testing.T) {
+		report := NewReport()
+		resultJSON, err := temp.AdjustTemplate(WithReport(context.Background(), report), map[string]any{"title": "Title"}, tpls)
+		assert.Nil(t, resultJSON)
+		assert.ErrorIs(t, err, ErrRequiredField)
+
+		var fieldErr *FieldError
+		assert.ErrorAs(t, err, &fieldErr)
+		assert.Equal(t, "id", fieldErr.Field)
+		assert.NotEmpty(t, report.Errors())
+	})
+
+	t.Run("null required drops item", func(t *testing.T) {
+		_, err := temp.AdjustTemplate(context.Background(), map[string]any{"id": nil}, tpls)
+		assert.ErrorIs(t, err, ErrRequiredField)
+	})
+
+	t.Run("report is reset for every item", func(t *testing.T) {
+		ctx := WithReport(context.Background(), NewReport())
+
+		_, err := temp.AdjustTemplate(ctx, map[string]any{}, tpls)
+		assert.ErrorIs(t, err, ErrRequiredField)
+
+		resultJSON, err := temp.AdjustTemplate(ctx, map[string]any{"id": "2"}, tpls)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"id": "2", "title": "Untitled", "reactions": 0, "published": null}`, string(resultJSON))
+	})
+}
+
+func TestAdjustTemplate_RequiredKinds(t *testing.T) {
+	field := map[string]string{
+		"failed interpolation": `
+    type: "string"
+    value: "{{broken"`,
+		"string without source": `
+    type: "string"`,
+		"number without path": `
+    type: "number"`,
+		"bool": `
+    type: "bool"
+    value: "yes"`,
+		"array": `
+    type: "array"`,
+		"date": `
+    type: "date"
+    value: "not a date"`,
+	}
+
+	for name, spec := range field {
+		t.Run(name, func(t *testing.T) {
+			temp := setupTestTemplateLib(t)
+			tpls, err := temp.ParseTemplate([]byte(`
+kind: View
+metadata:
+  name: view
+spec:
+  template:
+    templates: ["tmpl"]
+---
+kind: Template
+metadata:
+  name: tmpl
+spec:
+  field:` + spec + `
+    required: true
+`))
+			assert.NoError(t, err)
+
+			report := NewReport()
+			_, err = temp.AdjustTemplate(WithReport(context.Background(), report), map[string]any{}, tpls)
+			assert.ErrorIs(t, err, ErrRequiredField)
+
+			var fieldErr *FieldError
+			assert.ErrorAs(t, err, &fieldErr)
+			assert.Equal(t, "field", fieldErr.Field)
+			assert.Len(t, report.Errors(), 1, "the field is reported once")
+		})
+	}
+
+	t.Run("invalid value uses default", func(t *testing.T) {
+		temp := setupTestTemplateLib(t)
+		tpls, err := temp.ParseTemplate([]byte(`
+kind: View
+metadata:
+  name: view
+spec:
+  template:
+    templates: ["tmpl"]
+---
+kind: Template
+metadata:
+  name: tmpl
+spec:
+  published:
+    type: "date"
+    path: "item.published"
+    output_format: "date"
+    default: "unknown"
+    required: true
+`))
+		assert.NoError(t, err)
+
+		report := NewReport()
+		resultJSON, err := temp.AdjustTemplate(WithReport(context.Background(), report), map[string]any{"published": "not a date"}, tpls)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"published": "unknown"}`, string(resultJSON))
+		assert.Len(t, report.Errors(), 1)
+	})
+
+	t.Run("set values are kept", func(t *testing.T) {
+		temp := setupTestTemplateLib(t)
+		tpls, err := temp.ParseTemplate([]byte(`
+kind: View
+metadata:
+  name: view
+spec:
+  template:
+    templates: ["tmpl"]
+---
+kind: Template
+metadata:
+  name: tmpl
+spec:
+  title:
+    type: "string"
+    value: "{{item.title}}"
+    required: true
+  visible:
+    type: "bool"
+    value: false
+    required: true
+  tags:
+    type: "array"
+    value: []
+    required: true
+`))
+		assert.NoError(t, err)
+
+		resultJSON, err := temp.AdjustTemplate(context.Background(), map[string]any{"title": "Title"}, tpls)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"title": "Title", "visible": false, "tags": []}`, string(resultJSON))
+	})
+}
+
+func TestRender_Encodings(t *testing.T) {
+	yamlData := `
+---
+kind: Proto
+metadata:
+  name: card.proto
+spec:
+  proto: |
+    syntax = "proto3";
+    package feed;
+    message Card {
+      string title = 1;
+      int32 reactions = 2;
+    }
+---
+kind: View
+metadata:
+  name: card
+spec:
+  if: item.type == "card"
+  output:
+    message: feed.Card
+  template:
+    templates: ["tmpl"]
+---
+kind: View
+metadata:
+  name: plain
+spec:
+  if: item.type == "plain"
+  template:
+    templates: ["tmpl"]
+---
+kind: Template
+metadata:
+  name: tmpl
+spec:
+  title:
+    type: "string"
+    path: "item.title"
+  reactions:
+    type: "number"
+    path: "item.reactions"
+`
+	temp := setupTestTemplateLib(t)
+	tpls, err := temp.ParseTemplate([]byte(yamlData))
+	assert.NoError(t, err)
+	assert.Len(t, tpls, 3, "Proto documents are not instructions")
+
+	card := map[string]any{"type": "card", "title": "Hello", "reactions": 5}
+
+	res, err := temp.Render(context.Background(), card, tpls, output.EncodingProtobuf)
+	assert.NoError(t, err)
+	assert.Equal(t, output.EncodingProtobuf, res.Encoding)
+	assert.Equal(t, "feed.Card", res.MessageType)
+	assert.Equal(t, []byte{0x0a, 0x05, 'H', 'e', 'l', 'l', 'o', 0x10, 0x05}, res.Data)
+
+	res, err = temp.Render(context.Background(), card, tpls, output.EncodingCompactJSON)
+	assert.NoError(t, err)
+	assert.Equal(t, output.EncodingCompactJSON, res.Encoding)
+	assert.Equal(t, `{"reactions":5,"title":"Hello"}`, string(res.Data))
+
+	plain := map[string]any{"type": "plain", "title": "Hello", "reactions": 5}
+	res, err = temp.Render(context.Background(), plain, tpls, output.EncodingProtobuf)
+	assert.NoError(t, err)
+	assert.Equal(t, output.EncodingCompactJSON, res.Encoding, "falls back without an output message")
+	assert.Empty(t, res.MessageType)
+	assert.Equal(t, `{"reactions":5,"title":"Hello"}`, string(res.Data))
+
+	_, err = temp.ParseTemplate([]byte("kind: Proto\nmetadata:\n  name: empty.proto\n"))
+	assert.Error(t, err)
+}
+
+func TestAdjustTemplate_TemplateCondition(t *testing.T) {
+	yamlData := `
+---
+kind: View
+metadata:
+  name: view
+spec:
+  template:
+    templates: ["base", "promo"]
+---
+kind: Template
+metadata:
+  name: base
+spec:
+  title:
+    type: "string"
+    value: "Title"
+---
+kind: Template
+metadata:
+  name: promo
+spec:
+  if: item.promo == true
+  badge:
+    type: "string"
+    value: "Promo"
+`
+	temp := setupTestTemplateLib(t)
+	tpls, err := temp.ParseTemplate([]byte(yamlData))
+	assert.NoError(t, err)
+
+	resultJSON, err := temp.AdjustTemplate(context.Background(), map[string]any{"promo": true}, tpls)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"title": "Title", "badge": "Promo"}`, string(resultJSON))
+
+	resultJSON, err = temp.AdjustTemplate(context.Background(), map[string]any{"promo": false}, tpls)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"title": "Title"}`, string(resultJSON))
+
+	resultJSON, err = temp.AdjustTemplate(context.Background(), map[string]any{}, tpls)
+	assert.NoError(t, err, "A condition on an absent field skips the Template, not the item")
+	assert.JSONEq(t, `{"title": "Title"}`, string(resultJSON))
+}
+
+func TestAdjustTemplate_TemplateConditionOverride(t *testing.T) {
+	yamlData := `
+---
+kind: View
+metadata:
+  name: view
+spec:
+  template:
+    templates: ["base", "short"]
+---
+kind: Template
+metadata:
+  name: base
+spec:
+  title:
+    type: "string"
+    path: "item.title"
+---
+kind: Template
+metadata:
+  name: short
+spec:
+  if: len(item.title) > 5
+  title:
+    type: "string"
+    value: "{{slice item.title 0 5}}"
+`
+	temp := setupTestTemplateLib(t)
+	tpls, err := temp.ParseTemplate([]byte(yamlData))
+	assert.NoError(t, err)
+
+	resultJSON, err := temp.AdjustTemplate(context.Background(), map[string]any{"title": "Hello world"}, tpls)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"title": "Hello"}`, string(resultJSON), "A matching Template overrides the earlier ones")
+
+	resultJSON, err = temp.AdjustTemplate(context.Background(), map[string]any{"title": "Hi"}, tpls)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"title": "Hi"}`, string(resultJSON))
+}
+
+func TestAdjustTemplate_RequestEnv(t *testing.T) {
+	yamlData := `
+---
+kind: View
+metadata:
+  name: new_card
+spec:
+  if: client.platform == "ios" && versionCompare(client.app_version, "5.2") >= 0
+  template:
+    templates: ["new_card"]
+---
+kind: View
+metadata:
+  name: old_card
+spec:
+  if: versionCompare(client.app_version, "5.2") < 0
+  template:
+    templates: ["old_card"]
+---
+kind: Template
+metadata:
+  name: new_card
+spec:
+  title:
+    type: "string"
+    value: "{{request.screen}}: {{item.title}}"
+  badge:
+    type: "array"
+    value:
+      - if: client.user_segment == "premium"
+        text:
+          type: "string"
+          value: "premium"
+---
+kind: Template
+metadata:
+  name: old_card
+spec:
+  title:
+    type: "string"
+    path: "item.title"
+`
+	temp := setupTestTemplateLib(t)
+	tpls, err := temp.ParseTemplate([]byte(yamlData))
+	assert.NoError(t, err)
+
+	env := func(platform, version, segment string) context.Context {
+		return requestctx.WithEnv(context.Background(), &requestctx.Env{
+			Request: map[string]any{"screen": "feed"},
+			Client:  map[string]any{"platform": platform, "app_version": version, "user_segment": segment},
+		})
+	}
+	item := map[string]any{"title": "Hello"}
+
+	resultJSON, err := temp.AdjustTemplate(env("ios", "5.3.1", "premium"), item, tpls)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"title": "feed: Hello", "badge": [{"text": "premium"}]}`, string(resultJSON))
+
+	resultJSON, err = temp.AdjustTemplate(env("ios", "5.2.0", "free"), item, tpls)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"title": "feed: Hello", "badge": []}`, string(resultJSON))
+
+	resultJSON, err = temp.AdjustTemplate(env("android", "5.1", "free"), item, tpls)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"title": "Hello"}`, string(resultJSON))
+}
+
+func TestRender_Experiment(t *testing.T) {
+	yamlData := `
+---
+kind: View
+metadata:
+  name: card
+spec:
+  template:
+    templates: ["card"]
+  experiment:
+    name: card_layout
+    key: item.user_id
+    variants:
+      - name: control
+        weight: 50
+      - name: big_image
+        weight: 50
+        templates: ["card", "big_image"]
+---
+kind: Template
+metadata:
+  name: card
+spec:
+  title:
+    type: "string"
+    path: "item.title"
+---
+kind: Template
+metadata:
+  name: big_image
+spec:
+  image_size:
+    type: "string"
+    value: "big"
+`
+	temp := setupTestTemplateLib(t)
+	tpls, err := temp.ParseTemplate([]byte(yamlData))
+	assert.NoError(t, err)
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		item := map[string]any{"title": "Hello", "user_id": fmt.Sprintf("user-%d", i)}
+
+		res, err := temp.Render(context.Background(), item, tpls, output.EncodingJSON)
+		assert.NoError(t, err)
+		if !assert.Len(t, res.Experiments, 1) {
+			return
+		}
+
+		assignment := res.Experiments[0]
+		assert.Equal(t, "card_layout", assignment.Experiment)
+		counts[assignment.Variant]++
+
+		again, err := temp.Render(context.Background(), item, tpls, output.EncodingJSON)
+		assert.NoError(t, err)
+		assert.Equal(t, res.Experiments, again.Experiments)
+
+		switch assignment.Variant {
+		case "control":
+			assert.JSONEq(t, `{"title": "Hello"}`, string(res.Data))
+		case "big_image":
+			assert.JSONEq(t, `{"title": "Hello", "image_size": "big"}`, string(res.Data))
+		default:
+			t.Fatalf("unexpected variant %s", assignment.Variant)
+		}
+	}
+	assert.InDelta(t, 500, counts["control"], 75)
+	assert.InDelta(t, 500, counts["big_image"], 75)
+	assert.Equal(t, float64(2*counts["control"]), testutil.ToFloat64(temp.metrics.experimentAssignments.WithLabelValues("card_layout", "control")))
+
+	temp.metrics.limiter = metrics.NewLabelLimiter(&metrics.CardinalityConfig{Allowlist: map[string][]string{"variant": {"control"}}})
+	for i := 0; i < 100; i++ {
+		_, err := temp.Render(context.Background(), map[string]any{"title": "Hello", "user_id": fmt.Sprintf("user-%d", i)}, tpls, output.EncodingJSON)
+		assert.NoError(t, err)
+	}
+	assert.Positive(t, testutil.ToFloat64(temp.metrics.experimentAssignments.WithLabelValues("card_layout", metrics.OverflowValue)),
+		"Variant names are capped by the label limiter")
+
+	res, err := temp.Render(context.Background(), map[string]any{"title": "Hello"}, tpls, output.EncodingJSON)
+	assert.NoError(t, err)
+	assert.Empty(t, res.Experiments)
+	assert.JSONEq(t, `{"title": "Hello"}`, string(res.Data))
+
+	_, err = temp.ParseTemplate([]byte(`
+kind: View
+metadata:
+  name: card
+spec:
+  experiment:
+    name: broken
+    key: item.user_id
+`))
+	assert.Error(t, err)
+}
+
+func TestRender_Limits(t *testing.T) {
+	template := func(viewLimits, spec string) string {
+		return `
+---
+kind: View
+metadata:
+  name: card
+spec:
+  template:
+    templates: ["card"]
+` + viewLimits + `
+---
+kind: Template
+metadata:
+  name: card
+spec:
+` + spec
+	}
+
+	item := map[string]any{
+		"list": []any{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12},
+		"deep": map[string]any{"a": map[string]any{"b": map[string]any{"c": 1}}},
+	}
+
+	tests := []struct {
+		name      string
+		limits    Limits
+		template  string
+		wantLimit string
+	}{
+		{
+			name:      "output size of interpolation",
+			limits:    Limits{MaxOutputBytes: 100},
+			template:  template("", "  text:\n    type: string\n    value: \"{{range 1000}}x{{end}}\"\n"),
+			wantLimit: LimitOutputBytes,
+		},
+		{
+			name:      "range iterations are evaluations",
+			limits:    Limits{MaxEvaluations: 100},
+			template:  template("", "  text:\n    type: string\n    value: \"{{range 1000}}{{end}}\"\n"),
+			wantLimit: LimitEvaluations,
+		},
+		{
+			name:      "output size of encoded item",
+			limits:    Limits{MaxOutputBytes: 10},
+			template:  template("", "  list:\n    type: number\n    path: item.list\n"),
+			wantLimit: LimitOutputBytes,
+		},
+		{
+			name:      "depth",
+			limits:    Limits{MaxDepth: 3},
+			template:  template("", "  deep:\n    type: number\n    path: item.deep\n"),
+			wantLimit: LimitDepth,
+		},
+		{
+			name:      "array length",
+			limits:    Limits{MaxArrayLength: 10},
+			template:  template("", "  list:\n    type: number\n    path: item.list\n"),
+			wantLimit: LimitArrayLength,
+		},
+		{
+			name:      "evaluations",
+			limits:    Limits{MaxEvaluations: 2},
+			template:  template("", "  a:\n    type: number\n    path: item.list[0]\n  b:\n    type: number\n    path: item.list[1]\n  c:\n    type: number\n    path: item.list[2]\n"),
+			wantLimit: LimitEvaluations,
+		},
+		{
+			name:      "timeout",
+			limits:    Limits{Timeout: time.Nanosecond},
+			template:  template("", "  a:\n    type: number\n    path: item.list[0]\n"),
+			wantLimit: LimitTimeout,
+		},
+		{
+			name:      "view cannot raise global limit",
+			limits:    Limits{MaxArrayLength: 10},
+			template:  template("  limits:\n    max_array_length: 100\n", "  list:\n    type: number\n    path: item.list\n"),
+			wantLimit: LimitArrayLength,
+		},
+		{
+			name:      "view cannot raise global evaluations",
+			limits:    Limits{MaxEvaluations: 2},
+			template:  template("  limits:\n    max_evaluations: 100\n", "  a:\n    type: number\n    path: item.list[0]\n  b:\n    type: number\n    path: item.list[1]\n  c:\n    type: number\n    path: item.list[2]\n"),
+			wantLimit: LimitEvaluations,
+		},
+		{
+			name:      "view lowers global limit",
+			limits:    Limits{MaxArrayLength: 100},
+			template:  template("  limits:\n    max_array_length: 10\n", "  list:\n    type: number\n    path: item.list\n"),
+			wantLimit: LimitArrayLength,
+		},
+		{
+			name:      "view limit",
+			template:  template("  limits:\n    max_depth: 2\n", "  deep:\n    type: number\n    path: item.deep\n"),
+			wantLimit: LimitDepth,
+		},
+		{
+			name:      "within limits",
+			limits:    Limits{MaxOutputBytes: 1000, MaxDepth: 4, MaxArrayLength: 20, MaxEvaluations: 10, Timeout: time.Second},
+			template:  template("", "  deep:\n    type: number\n    path: item.deep\n  list:\n    type: number\n    path: item.list\n"),
+			wantLimit: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			temp := setupTestTemplateLib(t)
+			temp.limits = tt.limits
+
+			tpls, err := temp.ParseTemplate([]byte(tt.template))
+			assert.NoError(t, err)
+
+			_, err = temp.Render(context.Background(), item, tpls, output.EncodingJSON)
+			if tt.wantLimit == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var limitErr *LimitError
+			assert.ErrorIs(t, err, ErrLimitExceeded)
+			if assert.ErrorAs(t, err, &limitErr) {
+				assert.Equal(t, tt.wantLimit, limitErr.Limit)
+			}
+		})
+	}
+
+	temp := setupTestTemplateLib(t)
+	_, err := temp.ParseTemplate([]byte(template("  limits:\n    max_depth: -1\n", "")))
+	assert.Error(t, err)
+
+	t.Run("canceled request", func(t *testing.T) {
+		temp := setupTestTemplateLib(t)
+		temp.limits = Limits{Timeout: time.Second}
+
+		tpls, err := temp.ParseTemplate([]byte(template("", "  a:\n    type: number\n    path: item.list[0]\n")))
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = temp.Render(ctx, item, tpls, output.EncodingJSON)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NotErrorIs(t, err, ErrLimitExceeded, "A canceled request is not a render timeout")
+	})
+
+	t.Run("interpolation without output stops at timeout", func(t *testing.T) {
+		temp := setupTestTemplateLib(t)
+		temp.limits = Limits{Timeout: 50 * time.Millisecond}
+
+		tpls, err := temp.ParseTemplate([]byte(template("", "  text:\n    type: string\n    value: \"{{range 10000}}{{range 10000}}{{end}}{{end}}\"\n")))
+		assert.NoError(t, err)
+
+		start := time.Now()
+		_, err = temp.Render(context.Background(), item, tpls, output.EncodingJSON)
+		assert.Less(t, time.Since(start), time.Second)
+
+		var limitErr *LimitError
+		if assert.ErrorAs(t, err, &limitErr) {
+			assert.Equal(t, LimitTimeout, limitErr.Limit)
+		}
+	})
+
+	t.Run("range over a large int is rejected", func(t *testing.T) {
+		temp := setupTestTemplateLib(t)
+
+		tpls, err := temp.ParseTemplate([]byte(template("", "  text:\n    type: string\n    value: \"{{range 3000000000}}{{end}}\"\n    required: true\n")))
+		assert.NoError(t, err)
+
+		start := time.Now()
+		_, err = temp.Render(context.Background(), item, tpls, output.EncodingJSON)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.ErrorIs(t, err, expression.ErrRangeTooLarge)
+	})
+}
+
+func TestRender_Spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	yamlData := `
+---
+kind: View
+metadata:
+  name: card
+spec:
+  template:
+    templates: ["card"]
+---
+kind: Template
+metadata:
+  name: card
+spec:
+  title:
+    type: "string"
+    path: "item.title"
+`
+	temp := setupTestTemplateLib(t)
+	tpls, err := temp.ParseTemplate([]byte(yamlData))
+	assert.NoError(t, err)
+
+	ctx := tracer.WithAttributes(context.Background(), tracer.TemplateID("feed"), tracer.ItemKey("post:1"))
+	_, err = temp.Render(ctx, map[string]any{"title": "Hello"}, tpls, output.EncodingJSON)
+	assert.NoError(t, err)
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+		assert.Contains(t, span.Attributes(), tracer.TemplateID("feed"))
+		assert.Contains(t, span.Attributes(), tracer.ItemKey("post:1"))
+	}
+
+	render := spans["template.Render"]
+	if assert.NotNil(t, render) {
+		assert.Equal(t, render.SpanContext().SpanID(), spans["template.MatchViews"].Parent().SpanID())
+		assert.Equal(t, render.SpanContext().SpanID(), spans["template.Encode"].Parent().SpanID())
+	}
+	assert.Contains(t, spans["template.MatchViews"].Attributes(), tracer.ViewKey.StringSlice([]string{"card"}))
+}
+
+func TestRender_Metrics(t *testing.T) {
+	yamlData := `
+---
+kind: View
+metadata:
+  name: card
+spec:
+  template:
+    templates: ["card"]
+---
+kind: Template
+metadata:
+  name: card
+spec:
+  title:
+    type: "string"
+    path: "item.title"
+    required: true
+`
+	temp := setupTestTemplateLib(t)
+	tpls, err := temp.ParseTemplate([]byte(yamlData))
+	assert.NoError(t, err)
+
+	ctx := WithTemplateID(context.Background(), "feed")
+
+	_, err = temp.Render(ctx, map[string]any{"title": "Hello"}, tpls, output.EncodingJSON)
+	assert.NoError(t, err)
+	_, err = temp.Render(ctx, map[string]any{}, tpls, output.EncodingJSON)
+	assert.Error(t, err)
+	_, err = temp.Render(context.Background(), map[string]any{"title": "Hello"}, tpls, output.EncodingJSON)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(temp.metrics.adjustRequestCount.WithLabelValues("feed", "card", "ok")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(temp.metrics.adjustRequestCount.WithLabelValues("feed", "card", "dropped")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(temp.metrics.adjustRequestCount.WithLabelValues("unknown", "card", "ok")))
+}
+
+func TestValidateTemplate_NoSideEffects(t *testing.T) {
+	registry := newMockMetricsRegistry()
+	storage, err := provider.NewProviderStorage(zap.NewNop().Sugar(), registry)
+	assert.NoError(t, err)
+	outputs := output.NewRegistry()
+	temp, err := NewTemplateLib(&Config{}, registry, storage, i18n.NewStorage(&i18n.Config{DefaultLocale: "en"}), outputs)
+	assert.NoError(t, err)
+
+	_, err = temp.ParseTemplate([]byte(`
+kind: Proto
+metadata:
+  name: common.proto
+spec:
+  proto: |
+    syntax = "proto3";
+    package feed;
+    message Image { string url = 1; }
+`))
+	assert.NoError(t, err)
+
+	instructions, err := temp.ValidateTemplate([]byte(`
+kind: Proto
+metadata:
+  name: card.proto
+spec:
+  proto: |
+    syntax = "proto3";
+    package feed;
+    import "common.proto";
+    message Card { Image image = 1; }
+---
+kind: View
+metadata:
+  name: card
+spec:
+  template:
+    templates: ["card"]
+`))
+	assert.NoError(t, err, "Protos may import the registered ones")
+	assert.Len(t, instructions, 1)
+
+	_, err = outputs.FindMessage("feed.Card")
+	assert.Error(t, err, "Validated protos are not registered")
+	_, err = outputs.FindMessage("feed.Image")
+	assert.NoError(t, err)
+
+	t.Setenv("PLANET_REACTION_API_KEY", "key")
+	data, err := os.ReadFile("../../config/providers/reaction.yaml")
+	assert.NoError(t, err)
+	_, err = temp.ValidateTemplate(data)
+	assert.NoError(t, err)
+
+	_, err = storage.GetProvider("reaction")
+	assert.Error(t, err, "Validated providers are not registered")
+	assert.Empty(t, storage.HealthChecks())
+
+	_, err = temp.ValidateTemplate([]byte("kind: Proto\nmetadata:\n  name: bad.proto\nspec:\n  proto: \"message {\"\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+type reportContextKey struct{}
+
+// ErrRequiredField is wrapped by the AdjustTemplate error when a `required`
+// field could not be resolved and the item has to be dropped.
+var ErrRequiredField = errors.New("required field is missing")
+
+// FieldError describes a template field that could not be rendered.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s: %s", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Report collects non-fatal field errors of a single render. Attach it with
+// WithReport before AdjustTemplate to learn which fields were set to null; a
+// report reused for several items holds the errors of the last one only.
+type Report struct {
+	mu       sync.Mutex
+	errors   []*FieldError
+	required []*FieldError
+}
+
+func NewReport() *Report {
+	return &Report{}
+}
+
+func WithReport(ctx context.Context, r *Report) context.Context {
+	return context.WithValue(ctx, reportContextKey{}, r)
+}
+
+func reportFromContext(ctx context.Context) *Report {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(reportContextKey{}).(*Report)
+	return r
+}
+
+func (r *Report) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.errors, r.required = nil, nil
+}
+
+func (r *Report) add(err *FieldError) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.errors = append(r.errors, err)
+	if errors.Is(err.Err, ErrRequiredField) {
+		r.required = append(r.required, err)
+	}
+}
+
+// Errors returns the collected field errors in render order.
+func (r *Report) Errors() []*FieldError {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*FieldError(nil), r.errors...)
+}
+
+// missingRequired joins the errors of required fields that were not resolved.
+func (r *Report) missingRequired() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	errs := make([]error, len(r.required))
+	for i, err := range r.required {
+		errs[i] = err
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,296 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"item_compositiom_service/pkg/expression"
+	"item_compositiom_service/pkg/logger"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// currencyMinorUnits is the ISO 4217 exponent of supported currencies.
+var currencyMinorUnits = map[string]int{
+	"RUB": 2,
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"CNY": 2,
+	"KZT": 2,
+	"BYN": 2,
+	"UAH": 2,
+	"AMD": 2,
+	"GEL": 2,
+	"TRY": 2,
+	"AED": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"BHD": 3,
+	"KWD": 3,
+}
+
+type valueConverter func(ctx context.Context, raw any, val map[string]any, item map[string]any) (any, error)
+
+// processTypedValue resolves the source of a typed field (`path` or `value`)
+// and converts it. An invalid value is rendered as the field's `default`, or
+// as null, and reported once: a required field left null is reported by
+// requireValue.
+func (t *TemplateLib) processTypedValue(ctx context.Context, key, valueType string, val map[string]any, result map[string]any, item map[string]any, convert valueConverter) {
+	raw, err := t.resolveSource(ctx, val, item)
+	if err == nil {
+		raw, err = convert(ctx, raw, val, item)
+	}
+
+	if err != nil {
+		raw = val["default"]
+		if raw != nil || !isRequired(val) {
+			t.reportFieldError(ctx, key, valueType, err)
+		}
+	}
+
+	t.setField(ctx, key, val, result, raw, err)
+}
+
+func (t *TemplateLib) resolveSource(ctx context.Context, val map[string]any, item map[string]any) (any, error) {
+	if _, exists := val["path"]; exists {
+		return t.resolveField(ctx, val, item)
+	}
+
+	if value, exists := val["value"]; exists {
+		if tmpl, ok := value.(string); ok {
+			return t.interpolateString(ctx, tmpl, item)
+		}
+		return value, nil
+	}
+
+	return nil, fmt.Errorf("path or value is required")
+}
+
+func (t *TemplateLib) reportFieldError(ctx context.Context, key, valueType string, err error) {
+	t.metrics.errorsCount.WithLabelValues("value_error", valueType).Inc()
+	logger.FromContext(ctx).With("component", "template_lib").Warn("Invalid template value",
+		zap.String("field", key), zap.String("type", valueType), zap.Error(err))
+	reportFromContext(ctx).add(&FieldError{Field: key, Err: err})
+}
+
+// convertDate parses the value with `input_format` (common layouts when
+// omitted, Unix seconds for numbers) and formats it with `output_format` in
+// `timezone`.
+func convertDate(_ context.Context, raw any, val map[string]any, _ map[string]any) (any, error) {
+	if raw == nil {
+		return nil, fmt.Errorf("date value is empty")
+	}
+
+	inputFormat, _ := val["input_format"].(string)
+	parsed, err := expression.ToTime(raw, inputFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	if tz, ok := val["timezone"].(string); ok && tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone: %w", err)
+		}
+		parsed = parsed.In(loc)
+	}
+
+	outputFormat, _ := val["output_format"].(string)
+	if outputFormat == "" {
+		outputFormat = time.RFC3339
+	}
+
+	return expression.FormatTime(parsed, outputFormat), nil
+}
+
+// convertEnum checks the value against `values` and maps it through `mapping`.
+// A value present in `mapping` is allowed even if `values` is omitted.
+func convertEnum(_ context.Context, raw any, val map[string]any, _ map[string]any) (any, error) {
+	mapping := enumMapping(val["mapping"])
+	values, _ := val["values"].([]any)
+	if len(mapping) == 0 && len(values) == 0 {
+		return nil, fmt.Errorf("enum requires values or mapping")
+	}
+
+	key := expression.ToString(raw)
+	if mapped, ok := mapping[key]; ok {
+		return mapped, nil
+	}
+
+	for _, allowed := range values {
+		if expression.ToString(allowed) == key {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("value %q is not one of the enum values", key)
+}
+
+// enumMapping accepts both string and non-string YAML keys (`1: published`).
+func enumMapping(raw any) map[string]any {
+	switch m := raw.(type) {
+	case map[string]any:
+		return m
+	case map[any]any:
+		res := make(map[string]any, len(m))
+		for k, v := range m {
+			res[expression.ToString(k)] = v
+		}
+		return res
+	}
+	return nil
+}
+
+// convertMoney builds {amount, minor, currency}. The currency is taken from
+// `currency` or `currency_path`; `minor_units: true` means the source amount is
+// already in minor units (kopecks, cents).
+func (t *TemplateLib) convertMoney(ctx context.Context, raw any, val map[string]any, item map[string]any) (any, error) {
+	amount, ok := expression.ToFloat(raw)
+	if !ok || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return nil, fmt.Errorf("amount %v is not a number", raw)
+	}
+
+	currency, _ := val["currency"].(string)
+	if currencyPath, ok := val["currency_path"].(string); ok && currencyPath != "" {
+		resolved, err := t.resolvePath(ctx, currencyPath, item)
+		if err != nil {
+			return nil, fmt.Errorf("resolve currency: %w", err)
+		}
+		currency = expression.ToString(resolved)
+	}
+
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	exponent, ok := currencyMinorUnits[currency]
+	if !ok {
+		return nil, fmt.Errorf("unsupported currency %q", currency)
+	}
+
+	scale := math.Pow10(exponent)
+	var minor float64
+	if minorUnits, _ := val["minor_units"].(bool); minorUnits {
+		if amount != math.Trunc(amount) {
+			return nil, fmt.Errorf("amount %v in minor units is not an integer", amount)
+		}
+		minor = amount
+	} else {
+		minor = math.Round(amount * scale)
+	}
+
+	return map[string]any{
+		"amount":   minor / scale,
+		"minor":    int64(minor),
+		"currency": currency,
+	}, nil
+}
+
+// convertURL validates an absolute http(s) URL and sets `query` parameters; string
+// parameters are interpolated, empty ones are skipped.
+func (t *TemplateLib) convertURL(ctx context.Context, raw any, val map[string]any, item map[string]any) (any, error) {
+	u, err := parseAbsoluteURL(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if query, ok := val["query"].(map[string]any); ok {
+		params, err := t.buildQuery(ctx, query, item)
+		if err != nil {
+			return nil, err
+		}
+		u.RawQuery = mergeQuery(u, params).Encode()
+	}
+
+	return u.String(), nil
+}
+
+// convertImage validates an http(s) image URL and builds its size variants:
+// every entry of `sizes` is a set of query parameters added to the URL.
+func (t *TemplateLib) convertImage(ctx context.Context, raw any, val map[string]any, item map[string]any) (any, error) {
+	u, err := parseAbsoluteURL(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]any{
+		"url": u.String(),
+	}
+
+	sizes, ok := val["sizes"].(map[string]any)
+	if !ok {
+		return res, nil
+	}
+
+	variants := make(map[string]any, len(sizes))
+	for name, rawSize := range sizes {
+		size, ok := rawSize.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("size %s must be an object of query parameters", name)
+		}
+
+		params, err := t.buildQuery(ctx, size, item)
+		if err != nil {
+			return nil, fmt.Errorf("size %s: %w", name, err)
+		}
+
+		variant := *u
+		variant.RawQuery = mergeQuery(u, params).Encode()
+		variants[name] = variant.String()
+	}
+	res["sizes"] = variants
+
+	return res, nil
+}
+
+func (t *TemplateLib) buildQuery(ctx context.Context, params map[string]any, item map[string]any) (map[string]string, error) {
+	res := make(map[string]string, len(params))
+	for name, raw := range params {
+		value := expression.ToString(raw)
+		if tmpl, ok := raw.(string); ok {
+			interpolated, err := t.interpolateString(ctx, tmpl, item)
+			if err != nil {
+				return nil, fmt.Errorf("query parameter %s: %w", name, err)
+			}
+			value = interpolated
+		}
+
+		if value != "" {
+			res[name] = value
+		}
+	}
+	return res, nil
+}
+
+func mergeQuery(u *url.URL, params map[string]string) url.Values {
+	query := u.Query()
+	for name, value := range params {
+		query.Set(name, value)
+	}
+	return query
+}
+
+// parseAbsoluteURL parses an absolute http or https URL; other schemes, e.g.
+// javascript: or data:, are rejected.
+func parseAbsoluteURL(raw any) (*url.URL, error) {
+	s, ok := raw.(string)
+	if !ok || strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("url value must be a non-empty string")
+	}
+
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("url %q must be absolute", s)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("url must be http or https, got %s", u.Scheme)
+	}
+
+	return u, nil
+}
@@ -27,8 +27,11 @@ type MethodConfig struct {
 	Filter   FilterConfig                   `yaml:"filter"`
 	Request  map[string]string              `yaml:"request"`
 	Response map[string]string              `yaml:"response"`
+	Cache    *MethodCacheConfig             `yaml:"cache"`
+	Optional bool                           `yaml:"optional"`
 	desc     *desc.MethodDescriptor         `yaml:"-"`
 	inputMD  protoreflect.MessageDescriptor `yaml:"-"`
+	cache    *responseCache                 `yaml:"-"`
 }
 
 type FilterConfig struct {
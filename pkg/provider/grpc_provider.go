@@ -9,15 +9,23 @@ import (
 	"google.golang.org/protobuf/reflect/protodesc"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/dynamicpb"
+	"item_compositiom_service/pkg/deadline"
+	"item_compositiom_service/pkg/expression"
+	"item_compositiom_service/pkg/metrics"
+	"item_compositiom_service/pkg/tracer"
 	"net"
 	"sync"
 	"time"
 
-	"github.com/PaesslerAG/gval"
 	"github.com/jhump/protoreflect/desc/protoparse"
 	"github.com/jhump/protoreflect/dynamic"
 	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
 )
@@ -30,6 +38,7 @@ type GRPCProvider struct {
 	conn    *grpc.ClientConn
 	methods map[string]*MethodConfig
 	retry   *RetryConfig
+	metrics *metricsCollector
 
 	protoSet   bool
 	msgFactory *dynamic.MessageFactory
@@ -40,6 +49,7 @@ func NewGRPCProvider(spec *ProviderSpec) (*GRPCProvider, error) {
 	opts := []grpc.DialOption{
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithNoProxy(),
+		grpc.WithUnaryInterceptor(tracer.UnaryClientInterceptor()),
 		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
 			dialer := net.Dialer{Timeout: spec.Spec.Transport.Timeout}
 			return dialer.DialContext(ctx, "tcp", addr)
@@ -117,6 +127,15 @@ func (p *GRPCProvider) SetProto(proto []byte) error {
 	return nil
 }
 
+// enableCaches creates response caches for methods with a cache config.
+func (p *GRPCProvider) enableCaches(lgr *zap.SugaredLogger, registry metrics.MetricsRegistry) {
+	for _, method := range p.methods {
+		if method.Cache != nil {
+			method.cache = newResponseCache(lgr, registry, "provider_"+p.GetName()+"_"+method.Method, method.Cache, method.Timeout)
+		}
+	}
+}
+
 func (p *GRPCProvider) GetMethod(methodName string) (*MethodConfig, error) {
 	method, exists := p.methods[methodName]
 	if !exists {
@@ -125,23 +144,71 @@ func (p *GRPCProvider) GetMethod(methodName string) (*MethodConfig, error) {
 	return method, nil
 }
 
-func (p *GRPCProvider) ExecuteMethod(ctx context.Context, methodName string, data map[string]interface{}) (interface{}, error) {
+// ExecuteMethod calls a provider method, retrying retryable codes. The call is
+// traced as a `provider.ExecuteMethod` span; filter and budget skips and
+// retries are recorded as span events.
+func (p *GRPCProvider) ExecuteMethod(ctx context.Context, methodName string, data map[string]interface{}) (res interface{}, err error) {
+	startTime := time.Now()
+	ctx, span := tracer.Start(ctx, "provider.ExecuteMethod", tracer.Provider(p.GetName()), tracer.Method(methodName))
+	defer func() {
+		p.metrics.observeRequest(p.GetName(), methodName, err, time.Since(startTime))
+
+		if errors.Is(err, ErrrorNoMatch) || errors.Is(err, deadline.ErrBudgetExhausted) {
+			span.End()
+			return
+		}
+		tracer.End(span, err)
+	}()
+
 	method, err := p.GetMethod(methodName)
 	if err != nil {
 		return nil, err
 	}
 
 	if method.Filter.If != "" {
-		matches, err := p.evaluateFilter(method.Filter.If, data)
+		matches, err := p.evaluateFilter(ctx, method.Filter.If, data)
 		if err != nil {
 			return nil, fmt.Errorf("failed to evaluate filter: %w", err)
 		}
 		if !matches {
+			span.AddEvent("filter skip", trace.WithAttributes(attribute.String("filter", method.Filter.If)))
 			return nil, ErrrorNoMatch
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, method.Timeout)
+	if method.cache == nil {
+		return p.call(ctx, method, data)
+	}
+
+	key, err := method.cache.key(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	res, hit, err := method.cache.get(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return p.call(ctx, method, data)
+	})
+	span.SetAttributes(attribute.Bool("cache.hit", hit))
+	return res, err
+}
+
+// call invokes the method within the request budget, retrying retryable
+// codes. Once the budget is exhausted the method is not called: optional
+// methods are skipped with ErrBudgetExhausted, others fail with
+// DeadlineExceeded.
+func (p *GRPCProvider) call(ctx context.Context, method *MethodConfig, data map[string]interface{}) (interface{}, error) {
+	span := trace.SpanFromContext(ctx)
+
+	timeout, ok := deadline.Timeout(ctx, method.Timeout)
+	if !ok {
+		if method.Optional {
+			span.AddEvent("budget skip")
+			return nil, deadline.ErrBudgetExhausted
+		}
+		return nil, status.Errorf(codes.DeadlineExceeded, "%s: %s", deadline.ErrBudgetExhausted, method.Method)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	var result interface{}
@@ -149,7 +216,10 @@ func (p *GRPCProvider) ExecuteMethod(ctx context.Context, methodName string, dat
 	backoff := p.retry.InitialBackoff
 
 	for attempt := 1; attempt <= p.retry.MaxAttempts; attempt++ {
+		span.SetAttributes(tracer.AttemptKey.Int(attempt))
+
 		result, lastErr = p.executeGRPCCall(ctx, method, data)
+		p.metrics.observeAttempt(p.GetName(), method.Method, attempt, lastErr)
 		if lastErr == nil {
 			return result, nil
 		}
@@ -176,6 +246,12 @@ func (p *GRPCProvider) ExecuteMethod(ctx context.Context, methodName string, dat
 			backoff = p.retry.MaxBackoff
 		}
 
+		span.AddEvent("retry", trace.WithAttributes(
+			tracer.AttemptKey.Int(attempt),
+			attribute.String("error", tracer.TraceSafeString(lastErr.Error())),
+			attribute.String("backoff", backoff.String()),
+		))
+
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
@@ -212,23 +288,28 @@ func (p *GRPCProvider) executeGRPCCall(ctx context.Context, method *MethodConfig
 	return res, nil
 }
 
-func (p *GRPCProvider) evaluateFilter(condition string, data map[string]interface{}) (bool, error) {
-	expr, err := gval.Evaluate(condition, map[string]interface{}{
+func (p *GRPCProvider) evaluateFilter(ctx context.Context, condition string, data map[string]interface{}) (bool, error) {
+	result, err := expression.EvaluateBool(ctx, condition, map[string]interface{}{
 		"item": data,
-		"time": map[string]interface{}{
-			"Now": time.Now,
-		},
 	})
 	if err != nil {
 		return false, fmt.Errorf("failed to evaluate condition: %w", err)
 	}
 
-	result, ok := expr.(bool)
-	if !ok {
-		return false, fmt.Errorf("condition did not evaluate to boolean")
+	return result, nil
+}
+
+// Check reports the connection state of the provider. An idle connection is
+// asked to connect and is healthy until it fails.
+func (p *GRPCProvider) Check(_ context.Context) error {
+	switch state := p.conn.GetState(); state {
+	case connectivity.TransientFailure, connectivity.Shutdown:
+		return fmt.Errorf("provider %s connection is %s", p.GetName(), state)
+	case connectivity.Idle:
+		p.conn.Connect()
 	}
 
-	return result, nil
+	return nil
 }
 
 func (p *GRPCProvider) Close() error {
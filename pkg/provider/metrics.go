@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"errors"
+	"item_compositiom_service/pkg/deadline"
+	"item_compositiom_service/pkg/metrics"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	statusOK      = "ok"
+	statusSkipped = "skipped"
+	statusBudget  = "budget_exhausted"
+)
+
+type metricsCollector struct {
+	limiter *metrics.LabelLimiter
+
+	requestCount    prometheus.CounterVec
+	requestDuration prometheus.HistogramVec
+	attemptCount    prometheus.CounterVec
+}
+
+func newMetricsCollector(registry metrics.MetricsRegistry) (*metricsCollector, error) {
+	m := &metricsCollector{
+		limiter: metrics.Limiter(registry),
+	}
+
+	m.requestCount = *prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "provider_requests_total",
+		Help: "Total number of provider method executions",
+	}, []string{"provider", "method", "status"})
+
+	m.requestDuration = *prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "provider_request_duration_seconds",
+		Help: "Duration of provider method executions including retries",
+	}, []string{"provider", "method", "status"})
+
+	m.attemptCount = *prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "provider_attempts_total",
+		Help: "Total number of provider calls by retry attempt",
+	}, []string{"provider", "method", "attempt", "status"})
+
+	r := registry.GetRegistry()
+
+	err := errors.Join(
+		r.Register(m.requestCount),
+		r.Register(m.requestDuration),
+		r.Register(m.attemptCount),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *metricsCollector) observeRequest(provider, method string, err error, dur time.Duration) {
+	if m == nil {
+		return
+	}
+
+	provider, method = m.limiter.Value("provider", provider), m.limiter.Value("method", method)
+	st := callStatus(err)
+
+	m.requestCount.WithLabelValues(provider, method, st).Inc()
+	m.requestDuration.WithLabelValues(provider, method, st).Observe(dur.Seconds())
+}
+
+func (m *metricsCollector) observeAttempt(provider, method string, attempt int, err error) {
+	if m == nil {
+		return
+	}
+
+	provider, method = m.limiter.Value("provider", provider), m.limiter.Value("method", method)
+	m.attemptCount.WithLabelValues(provider, method, strconv.Itoa(attempt), callStatus(err)).Inc()
+}
+
+// callStatus is the status label of a call: ok, skipped for filtered out items,
+// budget_exhausted for skipped optional calls or the gRPC code of the error.
+func callStatus(err error) string {
+	switch {
+	case err == nil:
+		return statusOK
+	case errors.Is(err, ErrrorNoMatch):
+		return statusSkipped
+	case errors.Is(err, deadline.ErrBudgetExhausted):
+		return statusBudget
+	default:
+		return status.Code(err).String()
+	}
+}
@@ -3,8 +3,8 @@ package provider
 import (
 	"fmt"
 	"gopkg.in/yaml.v3"
+	"item_compositiom_service/pkg/expression"
 	"os"
-	"regexp"
 	"strings"
 )
 
@@ -129,6 +129,10 @@ func (p *GRPCProviderParser) validateMethods(methods []MethodConfig) error {
 			return fmt.Errorf("method[%d].filter: %w", i, err)
 		}
 
+		if err := p.validateCache(method.Cache); err != nil {
+			return fmt.Errorf("method[%d].cache: %w", i, err)
+		}
+
 		if err := p.validateRequestResponse(method.Request, "request", i); err != nil {
 			return err
 		}
@@ -146,9 +150,30 @@ func (p *GRPCProviderParser) validateFilter(filter *FilterConfig) error {
 		return nil
 	}
 
-	validExpr := regexp.MustCompile(`^[a-zA-Z0-9\s\.\(\)\+\-\*\/\>\<\=\!\&\|\:\"\'\,\[\]\{\}]+$`)
-	if !validExpr.MatchString(filter.If) {
-		return fmt.Errorf("invalid filter expression: %s", filter.If)
+	if err := expression.Validate(filter.If); err != nil {
+		return fmt.Errorf("invalid filter expression: %s: %w", filter.If, err)
+	}
+
+	return nil
+}
+
+func (p *GRPCProviderParser) validateCache(cache *MethodCacheConfig) error {
+	if cache == nil {
+		return nil
+	}
+
+	if cache.TTL <= 0 {
+		return fmt.Errorf("ttl must be positive")
+	}
+
+	if cache.NegativeTTL < 0 || cache.StaleWhileRevalidate < 0 || cache.Capacity < 0 {
+		return fmt.Errorf("negative_ttl, stale_while_revalidate and capacity cannot be negative")
+	}
+
+	if cache.Key != "" {
+		if err := expression.Validate(cache.Key); err != nil {
+			return fmt.Errorf("invalid key expression: %s: %w", cache.Key, err)
+		}
 	}
 
 	return nil
@@ -168,9 +193,8 @@ func (p *GRPCProviderParser) validateRequestResponse(mapping map[string]string,
 			return fmt.Errorf("method[%d].%s: path for field %s cannot be empty", methodIndex, kind, field)
 		}
 
-		validPath := regexp.MustCompile(`^[a-zA-Z0-9\.\[\]\"\']+$`)
-		if !validPath.MatchString(path) {
-			return fmt.Errorf("method[%d].%s: invalid path expression for field %s: %s", methodIndex, kind, field, path)
+		if err := expression.Validate(path); err != nil {
+			return fmt.Errorf("method[%d].%s: invalid path expression for field %s: %s: %w", methodIndex, kind, field, path, err)
 		}
 	}
 
@@ -24,6 +24,18 @@ type ProviderMetadata struct {
 	Labels map[string]string `yaml:"labels"`
 }
 
+// config returns a copy of the spec without the state the provider keeps in
+// its methods: descriptors and response caches.
+func (s *ProviderSpec) config() ProviderSpec {
+	res := *s
+	res.Spec.Methods = make([]MethodConfig, len(s.Spec.Methods))
+	for i, method := range s.Spec.Methods {
+		method.desc, method.inputMD, method.cache = nil, nil, nil
+		res.Spec.Methods[i] = method
+	}
+	return res
+}
+
 type Provider interface {
 	GetName() string
 
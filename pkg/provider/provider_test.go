@@ -1,13 +1,23 @@
 package provider
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"item_compositiom_service/pkg/deadline"
+	"item_compositiom_service/pkg/tracer"
 	"os"
 
-	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestGRPCProviderParser_Parse(t *testing.T) {
@@ -79,8 +89,8 @@ spec:
 	if !exists {
 		t.Error("Expected x-api-key header to exist")
 	}
-	if !strings.HasPrefix(apiKey, "${") || !strings.HasSuffix(apiKey, "}") {
-		t.Errorf("Expected x-api-key to be an environment variable reference, got %s", apiKey)
+	if apiKey != "test-api-key" {
+		t.Errorf("Expected x-api-key to be resolved from the environment, got %s", apiKey)
 	}
 
 	appName, exists := spec.Spec.Payload.Headers["x-app-name"]
@@ -114,14 +124,14 @@ spec:
 	if method.Filter.If != "item.createdAt > time.Now - 7 * time.Day" {
 		t.Errorf("Expected filter condition, got %s", method.Filter.If)
 	}
-	if method.Request.Domain != "item.domain" {
-		t.Errorf("Expected request domain item.domain, got %s", method.Request.Domain)
+	if method.Request["domain"] != "item.domain" {
+		t.Errorf("Expected request domain item.domain, got %s", method.Request["domain"])
 	}
-	if method.Request.DomainIDs != "item.id" {
-		t.Errorf("Expected request domain_ids item.id, got %s", method.Request.DomainIDs)
+	if method.Request["domain_ids"] != "item.id" {
+		t.Errorf("Expected request domain_ids item.id, got %s", method.Request["domain_ids"])
 	}
-	if method.Response.ItemID != "items.domain_id" {
-		t.Errorf("Expected response itemId items.domain_id, got %s", method.Response.ItemID)
+	if method.Response["itemId"] != "items.domain_id" {
+		t.Errorf("Expected response itemId items.domain_id, got %s", method.Response["itemId"])
 	}
 }
 
@@ -279,8 +289,7 @@ func TestGRPCProviderParser_FromFile(t *testing.T) {
 	assert.True(t, spec.Spec.Transport.Logging.Enabled)
 
 	assert.Equal(t, 2, len(spec.Spec.Payload.Headers))
-	assert.True(t, strings.HasPrefix(spec.Spec.Payload.Headers["x-api-key"], "${"))
-	assert.True(t, strings.HasSuffix(spec.Spec.Payload.Headers["x-api-key"], "}"))
+	assert.Equal(t, "test-api-key", spec.Spec.Payload.Headers["x-api-key"])
 	assert.Equal(t, "my-application", spec.Spec.Payload.Headers["x-app-name"])
 
 	assert.Equal(t, 1, len(spec.Spec.Methods))
@@ -291,7 +300,113 @@ func TestGRPCProviderParser_FromFile(t *testing.T) {
 	assert.Equal(t, "DomainBatch", string(method.Type))
 	assert.Equal(t, time.Second, method.Timeout)
 	assert.Equal(t, "item.createdAt > time.Now - 7 * time.Day", method.Filter.If)
-	assert.Equal(t, "item.domain", method.Request.Domain)
-	assert.Equal(t, "item.id", method.Request.DomainIDs)
-	assert.Equal(t, "items.domain_id", method.Response.ItemID)
+	assert.Equal(t, "item.domain", method.Request["domain"])
+	assert.Equal(t, "item.id", method.Request["domain_ids"])
+	assert.Equal(t, "items.domain_id", method.Response["itemId"])
+}
+
+func TestGRPCProvider_EvaluateFilter(t *testing.T) {
+	p := &GRPCProvider{}
+	ctx := context.Background()
+	filter := "item.createdAt > time.Now - 7 * time.Day"
+
+	matches, err := p.evaluateFilter(ctx, filter, map[string]interface{}{
+		"createdAt": float64(time.Now().Add(-time.Hour).Unix()),
+	})
+	assert.NoError(t, err)
+	assert.True(t, matches)
+
+	matches, err = p.evaluateFilter(ctx, filter, map[string]interface{}{
+		"createdAt": float64(time.Now().Add(-30 * 24 * time.Hour).Unix()),
+	})
+	assert.NoError(t, err)
+	assert.False(t, matches)
+
+	_, err = p.evaluateFilter(ctx, "item.createdAt", map[string]interface{}{"createdAt": 1})
+	assert.Error(t, err, "Non-boolean filter must fail")
+}
+
+func TestGRPCProvider_ExecuteMethodFilterSkipSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	p := &GRPCProvider{
+		spec: &ProviderSpec{Metadata: ProviderMetadata{Name: "reaction"}},
+		methods: map[string]*MethodConfig{
+			"GetReactionCounters": {Method: "GetReactionCounters", Filter: FilterConfig{If: "item.visible"}},
+		},
+	}
+
+	_, err := p.ExecuteMethod(context.Background(), "GetReactionCounters", map[string]interface{}{"visible": false})
+	assert.ErrorIs(t, err, ErrrorNoMatch)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		span := spans[0]
+		assert.Equal(t, "provider.ExecuteMethod", span.Name())
+		assert.Contains(t, span.Attributes(), tracer.Provider("reaction"))
+		assert.Contains(t, span.Attributes(), tracer.Method("GetReactionCounters"))
+		assert.Equal(t, codes.Unset, span.Status().Code)
+		if assert.Len(t, span.Events(), 1) {
+			assert.Equal(t, "filter skip", span.Events()[0].Name)
+		}
+	}
+}
+
+func TestGRPCProvider_ExecuteMethodBudgetSkip(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	p := &GRPCProvider{
+		spec: &ProviderSpec{Metadata: ProviderMetadata{Name: "reaction"}},
+		methods: map[string]*MethodConfig{
+			"GetReactionCounters": {Method: "GetReactionCounters", Timeout: time.Second, Optional: true},
+		},
+	}
+
+	// Less time is left than the safety margin.
+	ctx, cancel := context.WithTimeout(context.Background(), deadline.DefaultSafetyMargin/2)
+	defer cancel()
+
+	_, err := p.ExecuteMethod(ctx, "GetReactionCounters", map[string]interface{}{})
+	assert.ErrorIs(t, err, deadline.ErrBudgetExhausted)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, codes.Unset, spans[0].Status().Code)
+		if assert.Len(t, spans[0].Events(), 1) {
+			assert.Equal(t, "budget skip", spans[0].Events()[0].Name)
+		}
+	}
+}
+
+func TestGRPCProvider_ExecuteMethodBudgetExhausted(t *testing.T) {
+	p := &GRPCProvider{
+		spec: &ProviderSpec{Metadata: ProviderMetadata{Name: "reaction"}},
+		methods: map[string]*MethodConfig{
+			"GetReactionCounters": {Method: "GetReactionCounters", Timeout: time.Second},
+		},
+		retry: &RetryConfig{MaxAttempts: 1},
+	}
+
+	// Less time is left than the safety margin; the method is not called, as
+	// the provider has no connection.
+	ctx, cancel := context.WithTimeout(context.Background(), deadline.DefaultSafetyMargin/2)
+	defer cancel()
+
+	_, err := p.ExecuteMethod(ctx, "GetReactionCounters", map[string]interface{}{})
+	assert.Equal(t, grpccodes.DeadlineExceeded, status.Code(err))
+	assert.NotErrorIs(t, err, deadline.ErrBudgetExhausted, "Only optional methods are skipped")
+}
+
+func TestCallStatus(t *testing.T) {
+	assert.Equal(t, "ok", callStatus(nil))
+	assert.Equal(t, "skipped", callStatus(ErrrorNoMatch))
+	assert.Equal(t, "budget_exhausted", callStatus(deadline.ErrBudgetExhausted))
+	assert.Equal(t, "Unavailable", callStatus(fmt.Errorf("failed after 3 attempts, last error: %w", status.Error(grpccodes.Unavailable, "down"))))
+	assert.Equal(t, "Unknown", callStatus(errors.New("boom")))
 }
@@ -0,0 +1,218 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"item_compositiom_service/pkg/cache"
+	"item_compositiom_service/pkg/expression"
+	"item_compositiom_service/pkg/metrics"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultResponseCacheCapacity = 10000
+
+// MethodCacheConfig enables response caching for a method:
+//
+//	cache:
+//	  ttl: 30s
+//	  key: item.domain + ":" + item.id
+//	  negative_ttl: 5s
+//	  stale_while_revalidate: 1m
+//
+// Responses are fresh for ttl. For stale_while_revalidate after that the stale
+// response is returned while one background call refreshes it. NotFound errors
+// and empty responses are cached for negative_ttl; other errors are not cached.
+// Without a key the whole item is the cache key.
+type MethodCacheConfig struct {
+	TTL                  time.Duration `yaml:"ttl"`
+	Key                  string        `yaml:"key"`
+	NegativeTTL          time.Duration `yaml:"negative_ttl"`
+	StaleWhileRevalidate time.Duration `yaml:"stale_while_revalidate"`
+	Capacity             int           `yaml:"capacity"`
+}
+
+type cachedResponse struct {
+	value     interface{}
+	err       error
+	fetchedAt time.Time
+	ttl       time.Duration
+	negative  bool
+}
+
+func (r *cachedResponse) fresh(now time.Time) bool {
+	return now.Sub(r.fetchedAt) < r.ttl
+}
+
+// responseCache caches the responses of one provider method in an LRU cache.
+// Misses and refreshes are loaded through the cache, so concurrent loads of
+// the same key share one call. A shared call is not canceled with the request
+// that started it; it is bounded by the method timeout, and every request
+// waits for it only until its own deadline.
+type responseCache struct {
+	cfg   *MethodCacheConfig
+	cache *cache.Cache[string, *cachedResponse]
+	lgr   *zap.SugaredLogger
+	now   func() time.Time
+}
+
+type loaderKey struct{}
+
+// loader calls the provider method for the item of a request.
+type loader func(context.Context) (interface{}, error)
+
+// uncachedResponse carries a loaded response that is not stored, such as an
+// error other than NotFound, to every caller sharing the load.
+type uncachedResponse struct {
+	entry *cachedResponse
+}
+
+func (e *uncachedResponse) Error() string {
+	if e.entry.err != nil {
+		return e.entry.err.Error()
+	}
+	return "response is not cached"
+}
+
+func newResponseCache(lgr *zap.SugaredLogger, registry metrics.MetricsRegistry, name string, cfg *MethodCacheConfig, timeout time.Duration) *responseCache {
+	capacity := cfg.Capacity
+	if capacity == 0 {
+		capacity = defaultResponseCacheCapacity
+	}
+
+	c := &responseCache{
+		cfg: cfg,
+		lgr: lgr.With("component", "provider_cache", "cache_name", name),
+		now: time.Now,
+	}
+
+	noUpdate := func(context.Context, cache.SetGetter[string, *cachedResponse]) error { return nil }
+	opts := []cache.Option{
+		cache.WithName(name),
+		cache.WithLRU(),
+		cache.WithCapacity(capacity),
+		cache.WithTTL(cfg.TTL + cfg.StaleWhileRevalidate),
+	}
+	if timeout > 0 {
+		opts = append(opts, cache.WithLoadTimeout(timeout))
+	}
+	c.cache = cache.New(lgr, registry, noUpdate, c.fetch, opts...)
+
+	return c
+}
+
+// key evaluates the cache key expression against the item.
+func (c *responseCache) key(ctx context.Context, data map[string]interface{}) (string, error) {
+	if c.cfg.Key == "" {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return "", err
+		}
+		return string(raw), nil
+	}
+
+	value, err := expression.Evaluate(ctx, c.cfg.Key, map[string]interface{}{"item": data})
+	if err != nil {
+		return "", fmt.Errorf("failed to evaluate cache key: %w", err)
+	}
+	return expression.ToString(value), nil
+}
+
+// get returns the cached response for key or loads it. The second result
+// reports whether the response came from the cache.
+func (c *responseCache) get(ctx context.Context, key string, load func(context.Context) (interface{}, error)) (interface{}, bool, error) {
+	if entry, ok := c.cache.Get(key); ok {
+		if entry.fresh(c.now()) {
+			return entry.value, true, entry.err
+		}
+		// Stale negative responses are not served.
+		if !entry.negative {
+			c.refresh(ctx, key, load)
+			return entry.value, true, entry.err
+		}
+	}
+
+	entry, err := c.reload(ctx, key, load)
+	if err != nil {
+		return nil, false, err
+	}
+	return entry.value, false, entry.err
+}
+
+// refresh reloads a stale entry in the background; the request that found it
+// is not delayed and is not allowed to cancel the refresh.
+func (c *responseCache) refresh(ctx context.Context, key string, load func(context.Context) (interface{}, error)) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		entry, err := c.reload(ctx, key, load)
+		if err == nil && entry.err != nil && entry.ttl == 0 {
+			c.lgr.Warnw("Failed to refresh stale response", "key", key, "error", entry.err)
+		}
+	}()
+}
+
+// reload loads key through the cache with load. Responses that are not stored
+// are returned too; the error is only set if ctx is done first.
+func (c *responseCache) reload(ctx context.Context, key string, load func(context.Context) (interface{}, error)) (*cachedResponse, error) {
+	entry, err := c.cache.Reload(context.WithValue(ctx, loaderKey{}, loader(load)), key)
+
+	var uncached *uncachedResponse
+	if errors.As(err, &uncached) {
+		return uncached.entry, nil
+	}
+	return entry, err
+}
+
+// fetch is the cache loader: it calls the provider with the loader of the
+// request and stores cacheable results.
+func (c *responseCache) fetch(ctx context.Context, sg cache.SetGetter[string, *cachedResponse], key string) error {
+	load, _ := ctx.Value(loaderKey{}).(loader)
+	if load == nil {
+		return fmt.Errorf("no loader for %s", key)
+	}
+
+	value, err := load(ctx)
+	entry := &cachedResponse{
+		value:     value,
+		err:       err,
+		fetchedAt: c.now(),
+	}
+
+	switch {
+	case err == nil && !isEmptyResponse(value):
+		entry.ttl = c.cfg.TTL
+	case isNegative(value, err):
+		entry.ttl = c.cfg.NegativeTTL
+		entry.negative = true
+	}
+
+	if entry.ttl <= 0 {
+		return &uncachedResponse{entry: entry}
+	}
+
+	sg.Set(key, entry, time.Now())
+	return nil
+}
+
+func isNegative(value interface{}, err error) bool {
+	if err != nil {
+		return status.Code(err) == codes.NotFound
+	}
+	return isEmptyResponse(value)
+}
+
+func isEmptyResponse(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,247 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"item_compositiom_service/pkg/metrics"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestResponseCache(cfg *MethodCacheConfig) (*responseCache, *time.Time) {
+	now := time.Now()
+	c := newResponseCache(zap.NewNop().Sugar(), &metrics.NoopMetrics{}, "test", cfg, time.Second)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestResponseCache_Key(t *testing.T) {
+	c, _ := newTestResponseCache(&MethodCacheConfig{TTL: time.Minute, Key: `item.domain + ":" + item.id`})
+	key, err := c.key(context.Background(), map[string]interface{}{"domain": "post", "id": "1"})
+	require.NoError(t, err)
+	assert.Equal(t, "post:1", key)
+
+	c, _ = newTestResponseCache(&MethodCacheConfig{TTL: time.Minute})
+	key, err = c.key(context.Background(), map[string]interface{}{"id": "1"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"1"}`, key)
+}
+
+func TestResponseCache_Get(t *testing.T) {
+	notFound := status.Error(codes.NotFound, "not found")
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+
+	tests := []struct {
+		name      string
+		responses []interface{}
+		errs      []error
+		wantCalls int
+		wantHit   bool
+		wantErr   error
+	}{
+		{
+			name:      "response is cached",
+			responses: []interface{}{map[string]interface{}{"total": 1.0}},
+			errs:      []error{nil},
+			wantCalls: 1,
+			wantHit:   true,
+		},
+		{
+			name:      "not found is cached for negative ttl",
+			responses: []interface{}{nil},
+			errs:      []error{notFound},
+			wantCalls: 1,
+			wantHit:   true,
+			wantErr:   notFound,
+		},
+		{
+			name:      "empty response is cached for negative ttl",
+			responses: []interface{}{map[string]interface{}{}},
+			errs:      []error{nil},
+			wantCalls: 1,
+			wantHit:   true,
+		},
+		{
+			name:      "other errors are not cached",
+			responses: []interface{}{nil, map[string]interface{}{"total": 1.0}},
+			errs:      []error{unavailable, nil},
+			wantCalls: 2,
+			wantHit:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestResponseCache(&MethodCacheConfig{TTL: time.Minute, NegativeTTL: time.Second})
+
+			calls := 0
+			load := func(context.Context) (interface{}, error) {
+				i := calls
+				calls++
+				return tt.responses[i], tt.errs[i]
+			}
+
+			first, hit, err := c.get(context.Background(), "key", load)
+			assert.False(t, hit)
+			assert.Equal(t, tt.errs[0], err)
+
+			second, hit, err := c.get(context.Background(), "key", load)
+			assert.Equal(t, tt.wantHit, hit)
+			assert.Equal(t, tt.wantCalls, calls)
+			if tt.wantHit {
+				assert.Equal(t, first, second)
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResponseCache_NegativeExpires(t *testing.T) {
+	c, now := newTestResponseCache(&MethodCacheConfig{TTL: time.Minute, NegativeTTL: time.Second, StaleWhileRevalidate: time.Minute})
+
+	calls := 0
+	load := func(context.Context) (interface{}, error) {
+		calls++
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+
+	_, _, _ = c.get(context.Background(), "key", load)
+	*now = now.Add(2 * time.Second)
+	_, hit, _ := c.get(context.Background(), "key", load)
+
+	assert.False(t, hit, "stale negative responses are reloaded")
+	assert.Equal(t, 2, calls)
+}
+
+func TestResponseCache_StaleWhileRevalidate(t *testing.T) {
+	c, now := newTestResponseCache(&MethodCacheConfig{TTL: time.Second, StaleWhileRevalidate: time.Hour})
+
+	var calls atomic.Int32
+	refreshed := make(chan struct{})
+	load := func(context.Context) (interface{}, error) {
+		if calls.Add(1) > 1 {
+			defer close(refreshed)
+		}
+		return map[string]interface{}{"version": float64(calls.Load())}, nil
+	}
+
+	_, _, err := c.get(context.Background(), "key", load)
+	require.NoError(t, err)
+
+	*now = now.Add(2 * time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	stale, hit, err := c.get(ctx, "key", load)
+	cancel()
+	require.NoError(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, map[string]interface{}{"version": 1.0}, stale)
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("stale response was not refreshed")
+	}
+
+	assert.Eventually(t, func() bool {
+		fresh, hit, _ := c.get(context.Background(), "key", load)
+		return hit && assert.ObjectsAreEqual(map[string]interface{}{"version": 2.0}, fresh)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestResponseCache_Singleflight(t *testing.T) {
+	c, _ := newTestResponseCache(&MethodCacheConfig{TTL: time.Minute})
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	load := func(context.Context) (interface{}, error) {
+		calls.Add(1)
+		<-release
+		return map[string]interface{}{"total": 1.0}, nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, _, err := c.get(context.Background(), "key", load)
+			if err == nil && res == nil {
+				err = errors.New("empty result")
+			}
+			errs <- err
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestResponseCache_SharedCallOutlivesCaller(t *testing.T) {
+	c, _ := newTestResponseCache(&MethodCacheConfig{TTL: time.Minute})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	load := func(ctx context.Context) (interface{}, error) {
+		close(started)
+
+		deadline, ok := ctx.Deadline()
+		if !ok || time.Until(deadline) > time.Second {
+			return nil, errors.New("shared call is not bounded by the method timeout")
+		}
+
+		select {
+		case <-release:
+			return map[string]interface{}{"total": 1.0}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, _, err := c.get(ctx, "key", load)
+		first <- err
+	}()
+	<-started
+
+	second := make(chan error, 1)
+	go func() {
+		res, _, err := c.get(context.Background(), "key", load)
+		if err == nil && res == nil {
+			err = errors.New("empty result")
+		}
+		second <- err
+	}()
+
+	cancel()
+	select {
+	case err := <-first:
+		assert.ErrorIs(t, err, context.Canceled, "The canceled caller stops waiting")
+	case <-time.After(time.Second):
+		t.Fatal("canceled caller kept waiting for the shared call")
+	}
+
+	close(release)
+	assert.NoError(t, <-second, "The shared call is not canceled with its first caller")
+
+	_, hit, err := c.get(context.Background(), "key", load)
+	assert.NoError(t, err)
+	assert.True(t, hit)
+}
@@ -1,19 +1,36 @@
 package provider
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"item_compositiom_service/pkg/metrics"
+	"reflect"
 	"sync"
+
+	"go.uber.org/zap"
 )
 
 type ProviderStorage struct {
 	mu        sync.RWMutex
 	providers map[string]Provider
+	metrics   *metricsCollector
+	registry  metrics.MetricsRegistry
+	lgr       *zap.SugaredLogger
 }
 
-func NewProviderStorage() *ProviderStorage {
+func NewProviderStorage(lgr *zap.SugaredLogger, registry metrics.MetricsRegistry) (*ProviderStorage, error) {
+	collector, err := newMetricsCollector(registry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics collector: %w", err)
+	}
+
 	return &ProviderStorage{
 		providers: make(map[string]Provider),
-	}
+		metrics:   collector,
+		registry:  registry,
+		lgr:       lgr,
+	}, nil
 }
 func (p *ProviderStorage) GetProvider(providerName string) (Provider, error) {
 	p.mu.RLock()
@@ -26,8 +43,104 @@ func (p *ProviderStorage) GetProvider(providerName string) (Provider, error) {
 	return provider, nil
 }
 
+// RegisterProvider adds a provider; gRPC providers report their calls to the
+// storage metrics and get their method response caches. A gRPC provider with
+// the spec of the registered one is closed and the registered one is kept with
+// its connection and caches; a replaced provider is closed.
 func (p *ProviderStorage) RegisterProvider(provider Provider) {
 	p.mu.Lock()
+	unused := p.register(provider)
+	p.mu.Unlock()
+
+	p.close(unused)
+}
+
+// register stores provider unless it is the registered one, and returns the
+// provider that is no longer used. p.mu must be held.
+func (p *ProviderStorage) register(provider Provider) Provider {
+	old, exists := p.providers[provider.GetName()]
+	if exists && sameProvider(old, provider) {
+		return provider
+	}
+
+	if grpcProvider, ok := provider.(*GRPCProvider); ok {
+		grpcProvider.metrics = p.metrics
+		grpcProvider.enableCaches(p.lgr, p.registry)
+	}
+
 	p.providers[provider.GetName()] = provider
+	return old
+}
+
+// MoveTo registers the providers of p to dst at once, as RegisterProvider
+// does, and leaves p empty.
+func (p *ProviderStorage) MoveTo(dst *ProviderStorage) {
+	p.mu.Lock()
+	providers := p.providers
+	p.providers = make(map[string]Provider)
 	p.mu.Unlock()
+
+	unused := make([]Provider, 0, len(providers))
+	dst.mu.Lock()
+	for _, provider := range providers {
+		unused = append(unused, dst.register(provider))
+	}
+	dst.mu.Unlock()
+
+	for _, provider := range unused {
+		dst.close(provider)
+	}
+}
+
+func (p *ProviderStorage) close(provider Provider) {
+	if provider == nil {
+		return
+	}
+
+	if err := provider.Close(); err != nil {
+		p.lgr.Desugar().Warn("Failed to close provider", zap.String("provider", provider.GetName()), zap.Error(err))
+	}
+}
+
+// sameProvider reports whether two gRPC providers have the same spec.
+func sameProvider(a, b Provider) bool {
+	pa, ok := a.(*GRPCProvider)
+	if !ok {
+		return false
+	}
+	pb, ok := b.(*GRPCProvider)
+	if !ok {
+		return false
+	}
+
+	return reflect.DeepEqual(pa.spec.config(), pb.spec.config())
+}
+
+// HealthChecks returns the health check of every registered provider that
+// has one, by provider name.
+func (p *ProviderStorage) HealthChecks() map[string]func(context.Context) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	checks := make(map[string]func(context.Context) error, len(p.providers))
+	for name, provider := range p.providers {
+		if checker, ok := provider.(interface{ Check(context.Context) error }); ok {
+			checks[name] = checker.Check
+		}
+	}
+
+	return checks
+}
+
+// Close closes every registered provider.
+func (p *ProviderStorage) Close() error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var errs []error
+	for _, provider := range p.providers {
+		errs = append(errs, provider.Close())
+	}
+
+	return errors.Join(errs...)
 }
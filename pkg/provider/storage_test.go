@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"item_compositiom_service/pkg/metrics"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/connectivity"
+)
+
+func TestProviderStorage_RegisterProvider(t *testing.T) {
+	storage, err := NewProviderStorage(zap.NewNop().Sugar(), &metrics.NoopMetrics{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = storage.Close() })
+
+	newProvider := func(timeout time.Duration) *GRPCProvider {
+		p, err := NewGRPCProvider(&ProviderSpec{
+			Kind:     "ProviderGRPC",
+			Metadata: ProviderMetadata{Name: "reaction"},
+			Spec: ProviderConfig{
+				Transport: TransportConfig{Address: "localhost:1", Timeout: time.Second},
+				Methods: []MethodConfig{{
+					Service: "ReactionService",
+					Method:  "GetReactionCounters",
+					Type:    TypeBatch,
+					Timeout: timeout,
+					Cache:   &MethodCacheConfig{TTL: time.Minute},
+				}},
+			},
+		})
+		require.NoError(t, err)
+		return p
+	}
+
+	first := newProvider(time.Second)
+	storage.RegisterProvider(first)
+	method, err := first.GetMethod("GetReactionCounters")
+	require.NoError(t, err)
+	cache := method.cache
+	require.NotNil(t, cache)
+
+	same := newProvider(time.Second)
+	storage.RegisterProvider(same)
+
+	registered, err := storage.GetProvider("reaction")
+	require.NoError(t, err)
+	assert.Same(t, first, registered, "a provider with an unchanged spec is kept")
+	assert.Same(t, cache, method.cache, "and so are its response caches")
+	assert.Equal(t, connectivity.Shutdown, same.conn.GetState(), "the new provider is closed")
+	assert.NotEqual(t, connectivity.Shutdown, first.conn.GetState())
+
+	changed := newProvider(2 * time.Second)
+	storage.RegisterProvider(changed)
+
+	registered, err = storage.GetProvider("reaction")
+	require.NoError(t, err)
+	assert.Same(t, changed, registered)
+	assert.Equal(t, connectivity.Shutdown, first.conn.GetState(), "the replaced provider is closed")
+}
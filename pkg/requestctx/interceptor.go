@@ -0,0 +1,113 @@
+package requestctx
+
+import (
+	"context"
+	"item_compositiom_service/pkg/i18n"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const headerPrefix = "x-"
+
+// sensitiveHeaders never reach templates.
+var sensitiveHeaders = []string{"authorization", "cookie", "token", "secret", "api-key", "password"}
+
+type requestWithMetadata interface {
+	GetMetadata() *structpb.Struct
+}
+
+type Interceptor struct{}
+
+func NewInterceptor() *Interceptor {
+	return &Interceptor{}
+}
+
+// GetServerInterceptor collects the request environment. It must run after the
+// i18n interceptor to see the resolved locale.
+func (i *Interceptor) GetServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		env := &Env{
+			Request: requestMetadata(req),
+			Client:  clientHeaders(ctx),
+			Ctx:     callContext(ctx, info),
+		}
+
+		return handler(WithEnv(ctx, env), req)
+	}
+}
+
+func requestMetadata(req interface{}) map[string]any {
+	if r, ok := req.(requestWithMetadata); ok && r.GetMetadata() != nil {
+		return r.GetMetadata().AsMap()
+	}
+	return map[string]any{}
+}
+
+// clientHeaders exposes `x-` headers and user-agent: `x-app-version` becomes
+// `client.app_version`. Repeated headers become arrays.
+func clientHeaders(ctx context.Context) map[string]any {
+	res := make(map[string]any)
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return res
+	}
+
+	for key, values := range md {
+		if len(values) == 0 || isSensitive(key) {
+			continue
+		}
+
+		name, ok := strings.CutPrefix(key, headerPrefix)
+		if !ok && key != "user-agent" {
+			continue
+		}
+		name = strings.ReplaceAll(name, "-", "_")
+
+		if len(values) == 1 {
+			res[name] = values[0]
+			continue
+		}
+
+		list := make([]any, len(values))
+		for i, v := range values {
+			list[i] = v
+		}
+		res[name] = list
+	}
+
+	return res
+}
+
+func callContext(ctx context.Context, info *grpc.UnaryServerInfo) map[string]any {
+	res := map[string]any{
+		"locale": i18n.LocaleFromContext(ctx),
+	}
+
+	if info != nil {
+		res["method"] = info.FullMethod
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		res["deadline"] = time.Until(deadline).Seconds()
+	}
+
+	return res
+}
+
+func isSensitive(key string) bool {
+	for _, s := range sensitiveHeaders {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
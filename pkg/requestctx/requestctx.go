@@ -0,0 +1,47 @@
+package requestctx
+
+import "context"
+
+type contextKey struct{}
+
+// Env is the request environment visible to template expressions as the
+// `request`, `client` and `ctx` namespaces.
+type Env struct {
+	// Request is GetItemsRequest.metadata.
+	Request map[string]any
+	// Client holds client headers: platform, app_version, user_segment, ...
+	Client map[string]any
+	// Ctx holds call properties: method, locale, deadline.
+	Ctx map[string]any
+}
+
+func WithEnv(ctx context.Context, env *Env) context.Context {
+	return context.WithValue(ctx, contextKey{}, env)
+}
+
+// FromContext returns the request environment or an empty one.
+func FromContext(ctx context.Context) *Env {
+	if ctx != nil {
+		if env, ok := ctx.Value(contextKey{}).(*Env); ok && env != nil {
+			return env
+		}
+	}
+	return &Env{}
+}
+
+// Params returns expression parameters with all three namespaces set, so
+// conditions on absent values fail the same way as on absent item fields.
+func (e *Env) Params() map[string]any {
+	return map[string]any{
+		"request": orEmpty(e.Request),
+		"client":  orEmpty(e.Client),
+		"ctx":     orEmpty(e.Ctx),
+	}
+}
+
+func orEmpty(m map[string]any) map[string]any {
+	if m == nil {
+		return map[string]any{}
+	}
+	return m
+}
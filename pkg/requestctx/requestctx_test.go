@@ -0,0 +1,66 @@
+package requestctx
+
+import (
+	"context"
+	"item_compositiom_service/pkg/i18n"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type testRequest struct {
+	metadata *structpb.Struct
+}
+
+func (r *testRequest) GetMetadata() *structpb.Struct {
+	return r.metadata
+}
+
+func TestInterceptor(t *testing.T) {
+	interceptor := NewInterceptor().GetServerInterceptor()
+
+	md, err := structpb.NewStruct(map[string]any{"principal_id": "u1", "screen": "feed"})
+	assert.NoError(t, err)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.MD{
+		"x-platform":     {"ios"},
+		"x-app-version":  {"5.2.0"},
+		"x-experiments":  {"a", "b"},
+		"x-api-key":      {"secret"},
+		"authorization":  {"Bearer token"},
+		"user-agent":     {"app/5.2.0"},
+		"content-type":   {"application/grpc"},
+		"x-user-segment": {"premium"},
+	})
+	ctx = i18n.WithLocale(ctx, "ru")
+
+	var env *Env
+	_, err = interceptor(ctx, &testRequest{metadata: md}, &grpc.UnaryServerInfo{FullMethod: "/svc/GetItems"},
+		func(ctx context.Context, _ interface{}) (interface{}, error) {
+			env = FromContext(ctx)
+			return nil, nil
+		})
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]any{"principal_id": "u1", "screen": "feed"}, env.Request)
+	assert.Equal(t, map[string]any{
+		"platform":     "ios",
+		"app_version":  "5.2.0",
+		"experiments":  []any{"a", "b"},
+		"user_agent":   "app/5.2.0",
+		"user_segment": "premium",
+	}, env.Client)
+	assert.Equal(t, map[string]any{"locale": "ru", "method": "/svc/GetItems"}, env.Ctx)
+}
+
+func TestFromContext_Empty(t *testing.T) {
+	params := FromContext(context.Background()).Params()
+	assert.Equal(t, map[string]any{
+		"request": map[string]any{},
+		"client":  map[string]any{},
+		"ctx":     map[string]any{},
+	}, params)
+}
@@ -0,0 +1,207 @@
+package schema
+
+import (
+	"fmt"
+	"item_compositiom_service/pkg/parser"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type ChangeKind string
+
+const (
+	ViewRemoved      ChangeKind = "view_removed"
+	ViewAdded        ChangeKind = "view_added"
+	FieldRemoved     ChangeKind = "field_removed"
+	FieldAdded       ChangeKind = "field_added"
+	TypeChanged      ChangeKind = "type_changed"
+	BecameOptional   ChangeKind = "became_optional"
+	BecameRequired   ChangeKind = "became_required"
+	BecameNullable   ChangeKind = "became_nullable"
+	BecameNonNull    ChangeKind = "became_non_null"
+	EnumValueRemoved ChangeKind = "enum_value_removed"
+	EnumValueAdded   ChangeKind = "enum_value_added"
+	FormatChanged    ChangeKind = "format_changed"
+)
+
+// breakingKinds are changes that can break a client parsing the old shape.
+var breakingKinds = map[ChangeKind]bool{
+	ViewRemoved:    true,
+	FieldRemoved:   true,
+	TypeChanged:    true,
+	BecameOptional: true,
+	BecameNullable: true,
+	EnumValueAdded: true,
+	FormatChanged:  true,
+}
+
+// Change is a difference between two output schemas. Path is the dotted field
+// path prefixed with the view name; array elements are marked with `[]`.
+type Change struct {
+	Path     string     `json:"path"`
+	Kind     ChangeKind `json:"kind"`
+	Breaking bool       `json:"breaking"`
+	Message  string     `json:"message"`
+}
+
+func (c Change) String() string {
+	prefix := ""
+	if c.Breaking {
+		prefix = "BREAKING "
+	}
+	return fmt.Sprintf("%s%s: %s", prefix, c.Path, c.Message)
+}
+
+// DiffTemplates compares the output schemas of all views of two template
+// versions.
+func DiffTemplates(oldInstructions, newInstructions []parser.Instruction) ([]Change, error) {
+	var changes []Change
+
+	oldViews := Views(oldInstructions)
+	newViews := Views(newInstructions)
+
+	for _, view := range oldViews {
+		if !slices.Contains(newViews, view) {
+			changes = append(changes, newChange(view, ViewRemoved, "view removed"))
+			continue
+		}
+
+		oldSchema, err := Generate(oldInstructions, view)
+		if err != nil {
+			return nil, fmt.Errorf("generate old schema: %w", err)
+		}
+
+		newSchema, err := Generate(newInstructions, view)
+		if err != nil {
+			return nil, fmt.Errorf("generate new schema: %w", err)
+		}
+
+		changes = append(changes, Diff(view, oldSchema, newSchema)...)
+	}
+
+	for _, view := range newViews {
+		if !slices.Contains(oldViews, view) {
+			changes = append(changes, newChange(view, ViewAdded, "view added"))
+		}
+	}
+
+	return changes, nil
+}
+
+// Diff compares two schemas of the same value.
+func Diff(path string, oldSchema, newSchema *Schema) []Change {
+	var changes []Change
+
+	oldTypes, newTypes := oldSchema.NonNull(), newSchema.NonNull()
+	if !sameTypes(oldTypes, newTypes) {
+		return append(changes, newChange(path, TypeChanged,
+			fmt.Sprintf("type changed from %s to %s", typeList(oldTypes), typeList(newTypes))))
+	}
+
+	if !oldSchema.Nullable() && newSchema.Nullable() {
+		changes = append(changes, newChange(path, BecameNullable, "may be null"))
+	} else if oldSchema.Nullable() && !newSchema.Nullable() {
+		changes = append(changes, newChange(path, BecameNonNull, "is never null"))
+	}
+
+	if oldSchema.Format != newSchema.Format {
+		changes = append(changes, newChange(path, FormatChanged,
+			fmt.Sprintf("format changed from %q to %q", oldSchema.Format, newSchema.Format)))
+	}
+
+	changes = append(changes, diffEnum(path, oldSchema.Enum, newSchema.Enum)...)
+
+	for _, name := range sortedKeys(oldSchema.Properties) {
+		fieldPath := path + "." + name
+		newProp, ok := newSchema.Properties[name]
+		if !ok {
+			changes = append(changes, newChange(fieldPath, FieldRemoved, "field removed"))
+			continue
+		}
+
+		if oldSchema.IsRequired(name) && !newSchema.IsRequired(name) {
+			changes = append(changes, newChange(fieldPath, BecameOptional, "field became optional"))
+		} else if !oldSchema.IsRequired(name) && newSchema.IsRequired(name) {
+			changes = append(changes, newChange(fieldPath, BecameRequired, "field became required"))
+		}
+
+		changes = append(changes, Diff(fieldPath, oldSchema.Properties[name], newProp)...)
+	}
+
+	for _, name := range sortedKeys(newSchema.Properties) {
+		if _, ok := oldSchema.Properties[name]; !ok {
+			changes = append(changes, newChange(path+"."+name, FieldAdded, "field added"))
+		}
+	}
+
+	if oldSchema.Items != nil && newSchema.Items != nil {
+		changes = append(changes, Diff(path+"[]", oldSchema.Items, newSchema.Items)...)
+	}
+
+	return changes
+}
+
+// Breaking filters breaking changes.
+func Breaking(changes []Change) []Change {
+	var res []Change
+	for _, c := range changes {
+		if c.Breaking {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// diffEnum reports enum value changes. Removing values narrows the output and is
+// safe; a new value can break exhaustive client-side handling.
+func diffEnum(path string, oldValues, newValues []any) []Change {
+	if len(oldValues) == 0 || len(newValues) == 0 {
+		return nil
+	}
+
+	var changes []Change
+	for _, v := range oldValues {
+		if !containsValue(newValues, v) {
+			changes = append(changes, newChange(path, EnumValueRemoved, fmt.Sprintf("enum value %v removed", v)))
+		}
+	}
+	for _, v := range newValues {
+		if !containsValue(oldValues, v) {
+			changes = append(changes, newChange(path, EnumValueAdded, fmt.Sprintf("enum value %v added", v)))
+		}
+	}
+	return changes
+}
+
+func newChange(path string, kind ChangeKind, message string) Change {
+	return Change{
+		Path:     path,
+		Kind:     kind,
+		Breaking: breakingKinds[kind],
+		Message:  message,
+	}
+}
+
+func sameTypes(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	sort.Strings(a)
+	sort.Strings(b)
+	return slices.Equal(a, b)
+}
+
+func typeList(types []string) string {
+	if len(types) == 0 {
+		return "any"
+	}
+	return strings.Join(types, "|")
+}
+
+func containsValue(values []any, v any) bool {
+	for _, value := range values {
+		if fmt.Sprint(value) == fmt.Sprint(v) {
+			return true
+		}
+	}
+	return false
+}
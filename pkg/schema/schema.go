@@ -0,0 +1,509 @@
+package schema
+
+import (
+	"fmt"
+	"item_compositiom_service/pkg/parser"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeNull    = "null"
+)
+
+// Schema is the subset of JSON Schema used to describe View output.
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        Types              `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Enum        []any              `json:"enum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+}
+
+// Types is a JSON Schema `type`: a single type or a list such as
+// ["string", "null"] for nullable values.
+type Types []string
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return []byte(`"` + t[0] + `"`), nil
+	}
+
+	quoted := make([]string, len(t))
+	for i, typ := range t {
+		quoted[i] = `"` + typ + `"`
+	}
+	return []byte("[" + strings.Join(quoted, ",") + "]"), nil
+}
+
+// Nullable reports whether the value may be null.
+func (s *Schema) Nullable() bool {
+	return slices.Contains(s.Type, TypeNull)
+}
+
+// NonNull returns the types without null.
+func (s *Schema) NonNull() []string {
+	res := make([]string, 0, len(s.Type))
+	for _, typ := range s.Type {
+		if typ != TypeNull {
+			res = append(res, typ)
+		}
+	}
+	return res
+}
+
+func (s *Schema) IsRequired(name string) bool {
+	return slices.Contains(s.Required, name)
+}
+
+// Generate builds the output schema of a View from the Template documents it
+// references. Fields of Templates with an `if` condition are optional; fields
+// that may render as null (unresolved paths, invalid typed values) are nullable.
+// A View with an experiment renders the union of its variants: a field missing
+// from some variant is optional.
+func Generate(instructions []parser.Instruction, view string) (*Schema, error) {
+	variants, err := viewTemplates(instructions, view)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *Schema
+	for _, templates := range variants {
+		variant := templatesSchema(instructions, templates)
+		if res != nil {
+			variant = union(res, variant)
+		}
+		res = variant
+	}
+
+	res.Schema = Draft
+	res.Title = view
+	return res, nil
+}
+
+func templatesSchema(instructions []parser.Instruction, templates map[string]struct{}) *Schema {
+	res := object()
+
+	for _, instr := range instructions {
+		if strings.TrimSpace(instr.Kind) != "Template" {
+			continue
+		}
+
+		name, _ := instr.Metadata["name"].(string)
+		if _, ok := templates[name]; !ok {
+			continue
+		}
+
+		mergeFields(res, instr.Spec, instr.If == "")
+	}
+
+	return res
+}
+
+// Views lists the names of View documents.
+func Views(instructions []parser.Instruction) []string {
+	var res []string
+	for _, instr := range instructions {
+		if strings.TrimSpace(instr.Kind) != "View" {
+			continue
+		}
+		if name, ok := instr.Metadata["name"].(string); ok {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+// viewTemplates returns the template sets a View may render with: one per
+// experiment variant, a variant without templates keeps the View's templates.
+func viewTemplates(instructions []parser.Instruction, view string) ([]map[string]struct{}, error) {
+	for _, instr := range instructions {
+		if strings.TrimSpace(instr.Kind) != "View" {
+			continue
+		}
+
+		if name, _ := instr.Metadata["name"].(string); name != view {
+			continue
+		}
+
+		spec, _ := instr.Spec["template"].(map[string]any)
+		templates := templateSet(spec["templates"])
+
+		experiment, _ := instr.Spec["experiment"].(map[string]any)
+		variants, _ := experiment["variants"].([]any)
+		if len(variants) == 0 {
+			return []map[string]struct{}{templates}, nil
+		}
+
+		res := make([]map[string]struct{}, 0, len(variants))
+		for _, rawVariant := range variants {
+			v, _ := rawVariant.(map[string]any)
+			if variantTemplates := templateSet(v["templates"]); len(variantTemplates) > 0 {
+				res = append(res, variantTemplates)
+			} else {
+				res = append(res, templates)
+			}
+		}
+		return res, nil
+	}
+
+	return nil, fmt.Errorf("view %s not found", view)
+}
+
+func templateSet(raw any) map[string]struct{} {
+	res := make(map[string]struct{})
+	list, _ := raw.([]any)
+	for _, tmpl := range list {
+		if tmplName, ok := tmpl.(string); ok {
+			res[tmplName] = struct{}{}
+		}
+	}
+	return res
+}
+
+// mergeFields adds the fields of a template spec to an object schema. Later
+// templates override earlier ones, nested objects are merged like at render time.
+func mergeFields(dst *Schema, spec map[string]any, required bool) {
+	for key, value := range spec {
+		if strings.TrimSpace(key) == "if" {
+			continue
+		}
+
+		field, present := fieldSchema(value)
+		if field == nil {
+			continue
+		}
+
+		if existing, ok := dst.Properties[key]; ok && existing.Type.has(TypeObject) && field.Type.has(TypeObject) && field.Properties != nil {
+			for name, prop := range field.Properties {
+				existing.Properties[name] = prop
+				if field.IsRequired(name) {
+					existing.addRequired(name)
+				}
+			}
+			continue
+		}
+
+		dst.Properties[key] = field
+		if required && present {
+			dst.addRequired(key)
+		}
+	}
+}
+
+// fieldSchema returns the schema of a template field and whether the key is
+// always present in the output; nil means the field is never rendered.
+func fieldSchema(value any) (*Schema, bool) {
+	val, ok := value.(map[string]any)
+	if !ok {
+		return literalSchema(value), true
+	}
+
+	typeStr, hasType := val["type"].(string)
+	if !hasType {
+		if _, ok := val["type"]; ok {
+			return literalSchema(val), true
+		}
+		return nestedObject(val), true
+	}
+
+	switch typeStr {
+	case "string":
+		if _, ok := val["path"]; ok {
+			return nullableUnless(val, scalar(TypeString)), true
+		}
+		if _, ok := val["value"].(string); ok {
+			return scalar(TypeString), true
+		}
+		return nil, false
+	case "number":
+		if _, ok := val["path"]; !ok {
+			return nil, false
+		}
+		return nullableUnless(val, scalar(TypeNumber)), true
+	case "bool":
+		if _, ok := val["value"].(bool); !ok {
+			return nil, false
+		}
+		return scalar(TypeBoolean), true
+	case "array":
+		elements, ok := val["value"].([]any)
+		if !ok {
+			return nil, false
+		}
+		return arraySchema(elements), true
+	case "object":
+		return nestedObject(val), true
+	case "date":
+		s := scalar(TypeString)
+		switch format, _ := val["output_format"].(string); format {
+		case "", "RFC3339":
+			s.Format = "date-time"
+		case "date":
+			s.Format = "date"
+		}
+		return nullableUnless(val, s), true
+	case "enum":
+		return nullableUnless(val, enumSchema(val)), true
+	case "money":
+		s := object()
+		s.Properties["amount"] = scalar(TypeNumber)
+		s.Properties["minor"] = scalar(TypeInteger)
+		s.Properties["currency"] = scalar(TypeString)
+		s.Required = []string{"amount", "currency", "minor"}
+		return nullableUnless(val, s), true
+	case "url":
+		s := scalar(TypeString)
+		s.Format = "uri"
+		return nullableUnless(val, s), true
+	case "image":
+		return nullableUnless(val, imageSchema(val)), true
+	default:
+		return literalSchema(val), true
+	}
+}
+
+func nestedObject(val map[string]any) *Schema {
+	children, ok := val["value"].(map[string]any)
+	if !ok {
+		return literalSchema(val)
+	}
+
+	s := object()
+	mergeFields(s, children, true)
+	return s
+}
+
+// arraySchema describes array elements as one object: a field is required if
+// every element template has it.
+func arraySchema(elements []any) *Schema {
+	items := object()
+	counts := make(map[string]int)
+	total := 0
+
+	for _, element := range elements {
+		elem, ok := element.(map[string]any)
+		if !ok {
+			continue
+		}
+		total++
+
+		elemSchema := object()
+		mergeFields(elemSchema, elem, true)
+		for name, prop := range elemSchema.Properties {
+			if existing, ok := items.Properties[name]; ok {
+				prop = union(existing, prop)
+			}
+			items.Properties[name] = prop
+			if elemSchema.IsRequired(name) {
+				counts[name]++
+			}
+		}
+	}
+
+	for name, count := range counts {
+		if count == total {
+			items.addRequired(name)
+		}
+	}
+
+	return &Schema{Type: Types{TypeArray}, Items: items}
+}
+
+func enumSchema(val map[string]any) *Schema {
+	var values []any
+	seen := make(map[string]struct{})
+	add := func(v any) {
+		key := fmt.Sprintf("%T:%v", v, v)
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+		values = append(values, v)
+	}
+
+	if list, ok := val["values"].([]any); ok {
+		for _, v := range list {
+			add(v)
+		}
+	}
+
+	switch mapping := val["mapping"].(type) {
+	case map[string]any:
+		for _, k := range sortedKeys(mapping) {
+			add(mapping[k])
+		}
+	case map[any]any:
+		keys := make([]string, 0, len(mapping))
+		byKey := make(map[string]any, len(mapping))
+		for k, v := range mapping {
+			keys = append(keys, fmt.Sprint(k))
+			byKey[fmt.Sprint(k)] = v
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			add(byKey[k])
+		}
+	}
+
+	var types Types
+	for _, v := range values {
+		typ := literalSchema(v).Type[0]
+		if !types.has(typ) {
+			types = append(types, typ)
+		}
+	}
+	if len(types) == 0 {
+		types = Types{TypeString}
+	}
+
+	return &Schema{Type: types, Enum: values}
+}
+
+func imageSchema(val map[string]any) *Schema {
+	url := scalar(TypeString)
+	url.Format = "uri"
+
+	s := object()
+	s.Properties["url"] = url
+	s.Required = []string{"url"}
+
+	if sizes, ok := val["sizes"].(map[string]any); ok {
+		sizesSchema := object()
+		for _, name := range sortedKeys(sizes) {
+			size := scalar(TypeString)
+			size.Format = "uri"
+			sizesSchema.Properties[name] = size
+			sizesSchema.addRequired(name)
+		}
+		s.Properties["sizes"] = sizesSchema
+		s.addRequired("sizes")
+	}
+
+	return s
+}
+
+func literalSchema(value any) *Schema {
+	switch v := value.(type) {
+	case nil:
+		return scalar(TypeNull)
+	case string:
+		return scalar(TypeString)
+	case bool:
+		return scalar(TypeBoolean)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return scalar(TypeInteger)
+	case float32, float64:
+		return scalar(TypeNumber)
+	case []any:
+		s := &Schema{Type: Types{TypeArray}}
+		for _, elem := range v {
+			if s.Items == nil {
+				s.Items = literalSchema(elem)
+			} else {
+				s.Items = union(s.Items, literalSchema(elem))
+			}
+		}
+		return s
+	case map[string]any:
+		s := object()
+		for key, elem := range v {
+			s.Properties[key] = literalSchema(elem)
+			s.addRequired(key)
+		}
+		return s
+	default:
+		return &Schema{}
+	}
+}
+
+// nullableUnless marks a field nullable unless it is required or has a default.
+func nullableUnless(val map[string]any, s *Schema) *Schema {
+	if required, _ := val["required"].(bool); required {
+		return s
+	}
+	if _, ok := val["default"]; ok {
+		return s
+	}
+
+	s.Type = append(s.Type, TypeNull)
+	return s
+}
+
+// union combines two schemas of the same field: object properties are merged,
+// different types become a type list.
+func union(a, b *Schema) *Schema {
+	res := *a
+	res.Type = append(Types(nil), a.Type...)
+	for _, typ := range b.Type {
+		if !res.Type.has(typ) {
+			res.Type = append(res.Type, typ)
+		}
+	}
+
+	if a.Properties != nil || b.Properties != nil {
+		res.Properties = make(map[string]*Schema)
+		for name, prop := range a.Properties {
+			res.Properties[name] = prop
+		}
+		for name, prop := range b.Properties {
+			if existing, ok := res.Properties[name]; ok {
+				prop = union(existing, prop)
+			}
+			res.Properties[name] = prop
+		}
+
+		res.Required = nil
+		for _, name := range a.Required {
+			if b.IsRequired(name) {
+				res.Required = append(res.Required, name)
+			}
+		}
+	}
+
+	return &res
+}
+
+func scalar(typ string) *Schema {
+	return &Schema{Type: Types{typ}}
+}
+
+func object() *Schema {
+	return &Schema{
+		Type:       Types{TypeObject},
+		Properties: make(map[string]*Schema),
+	}
+}
+
+func (s *Schema) addRequired(name string) {
+	if !s.IsRequired(name) {
+		s.Required = append(s.Required, name)
+		sort.Strings(s.Required)
+	}
+}
+
+func (t Types) has(typ string) bool {
+	return slices.Contains(t, typ)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
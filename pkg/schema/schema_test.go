@@ -0,0 +1,408 @@
+package schema
+
+import (
+	"encoding/json"
+	"item_compositiom_service/pkg/i18n"
+	"item_compositiom_service/pkg/metrics"
+	"item_compositiom_service/pkg/output"
+	"item_compositiom_service/pkg/parser"
+	"item_compositiom_service/pkg/provider"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testTemplate = `
+---
+kind: View
+metadata:
+  name: feed_card
+spec:
+  template:
+    templates: ["base", "promo"]
+---
+kind: Template
+metadata:
+  name: base
+spec:
+  id:
+    type: "string"
+    path: "item.id"
+    required: true
+  title:
+    type: "string"
+    value: "{{item.title}}"
+  reactions:
+    type: "number"
+    path: "item.reactions"
+    default: 0
+  author:
+    type: "object"
+    value:
+      name:
+        type: "string"
+        path: "item.author.name"
+  status:
+    type: "enum"
+    path: "item.status"
+    mapping:
+      1: "draft"
+      2: "published"
+  created:
+    type: "date"
+    path: "item.created"
+  tags:
+    type: "array"
+    value:
+      - name:
+          type: "string"
+          value: "news"
+        url:
+          type: "url"
+          value: "https://example.com/news"
+      - if: item.hot
+        name:
+          type: "string"
+          value: "hot"
+---
+kind: Template
+metadata:
+  name: promo
+spec:
+  if: item.promo
+  price:
+    type: "money"
+    path: "item.price"
+    currency: "RUB"
+`
+
+func parseTemplate(t *testing.T, data string) []parser.Instruction {
+	t.Helper()
+
+	storage, err := provider.NewProviderStorage(zap.NewNop().Sugar(), &metrics.NoopMetrics{})
+	require.NoError(t, err)
+
+	templateLib, err := parser.NewTemplateLib(
+		&parser.Config{},
+		&metrics.NoopMetrics{},
+		storage,
+		i18n.NewStorage(&i18n.Config{}),
+		output.NewRegistry(),
+	)
+	require.NoError(t, err)
+
+	instructions, err := templateLib.ParseTemplate([]byte(data))
+	require.NoError(t, err)
+	return instructions
+}
+
+func TestGenerate(t *testing.T) {
+	instructions := parseTemplate(t, testTemplate)
+
+	s, err := Generate(instructions, "feed_card")
+	require.NoError(t, err)
+
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title": "feed_card",
+		"type": "object",
+		"properties": {
+			"id": {"type": "string"},
+			"title": {"type": "string"},
+			"reactions": {"type": "number"},
+			"author": {
+				"type": "object",
+				"properties": {"name": {"type": ["string", "null"]}},
+				"required": ["name"]
+			},
+			"status": {"type": ["string", "null"], "enum": ["draft", "published"]},
+			"created": {"type": ["string", "null"], "format": "date-time"},
+			"tags": {
+				"type": "array",
+				"items": {
+					"type": "object",
+					"properties": {
+						"name": {"type": "string"},
+						"url": {"type": ["string", "null"], "format": "uri"}
+					},
+					"required": ["name"]
+				}
+			},
+			"price": {
+				"type": ["object", "null"],
+				"properties": {
+					"amount": {"type": "number"},
+					"minor": {"type": "integer"},
+					"currency": {"type": "string"}
+				},
+				"required": ["amount", "currency", "minor"]
+			}
+		},
+		"required": ["author", "created", "id", "reactions", "status", "tags", "title"]
+	}`, string(data))
+
+	_, err = Generate(instructions, "unknown")
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{"feed_card"}, Views(instructions))
+}
+
+func TestStubs(t *testing.T) {
+	s, err := Generate(parseTemplate(t, testTemplate), "feed_card")
+	require.NoError(t, err)
+
+	ts := TypeScript("feed_card", s)
+	assert.Contains(t, ts, "export interface FeedCard {\n")
+	assert.Contains(t, ts, "  id: string;\n")
+	assert.Contains(t, ts, "  price?: FeedCardPrice | null;\n")
+	assert.Contains(t, ts, `  status: "draft" | "published" | null;`)
+	assert.Contains(t, ts, "  tags: FeedCardTagsItem[];\n")
+	assert.Contains(t, ts, "export interface FeedCardAuthor {\n  name: string | null;\n}\n")
+
+	kt := Kotlin("feed_card", s)
+	assert.Contains(t, kt, "data class FeedCard(\n")
+	assert.Contains(t, kt, `    @SerialName("id") val id: String,`)
+	assert.Contains(t, kt, `    @SerialName("price") val price: FeedCardPrice? = null,`)
+	assert.Contains(t, kt, `    @SerialName("reactions") val reactions: Double,`)
+	assert.Contains(t, kt, `    @SerialName("tags") val tags: List<FeedCardTagsItem>,`)
+
+	_, err = Render(s, "xml", "feed_card")
+	assert.Error(t, err)
+}
+
+func TestDiffTemplates(t *testing.T) {
+	const oldTemplate = `
+---
+kind: View
+metadata:
+  name: card
+spec:
+  template:
+    templates: ["card"]
+---
+kind: View
+metadata:
+  name: legacy
+spec:
+  template:
+    templates: ["card"]
+---
+kind: Template
+metadata:
+  name: card
+spec:
+  title:
+    type: "string"
+    value: "{{item.title}}"
+  subtitle:
+    type: "string"
+    value: "{{item.subtitle}}"
+  count:
+    type: "number"
+    path: "item.count"
+    default: 0
+  kind:
+    type: "enum"
+    path: "item.kind"
+    values: ["post", "video"]
+`
+	const newTemplate = `
+---
+kind: View
+metadata:
+  name: card
+spec:
+  template:
+    templates: ["card", "extra"]
+---
+kind: View
+metadata:
+  name: story
+spec:
+  template:
+    templates: ["card"]
+---
+kind: Template
+metadata:
+  name: card
+spec:
+  title:
+    type: "number"
+    path: "item.title"
+    required: true
+  count:
+    type: "number"
+    path: "item.count"
+  kind:
+    type: "enum"
+    path: "item.kind"
+    values: ["post"]
+---
+kind: Template
+metadata:
+  name: extra
+spec:
+  if: item.extra
+  subtitle:
+    type: "string"
+    value: "{{item.subtitle}}"
+  badge:
+    type: "string"
+    value: "new"
+`
+	changes, err := DiffTemplates(parseTemplate(t, oldTemplate), parseTemplate(t, newTemplate))
+	require.NoError(t, err)
+
+	got := make([]string, len(changes))
+	for i, c := range changes {
+		got[i] = c.String()
+	}
+
+	assert.Equal(t, []string{
+		"card.badge: field added",
+		"BREAKING card.count: may be null",
+		"card.kind: enum value video removed",
+		"BREAKING card.subtitle: field became optional",
+		"BREAKING card.title: type changed from string to number",
+		"BREAKING legacy: view removed",
+		"story: view added",
+	}, sortedStrings(got))
+
+	assert.Len(t, Breaking(changes), 4)
+
+	changes, err = DiffTemplates(parseTemplate(t, oldTemplate), parseTemplate(t, oldTemplate))
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+const experimentTemplate = `
+---
+kind: View
+metadata:
+  name: card
+spec:
+  template:
+    templates: ["card"]
+  experiment:
+    name: card_layout
+    key: item.id
+    variants:
+      - name: control
+        weight: 50
+      - name: big_image
+        weight: 50
+        templates: ["card", "big_image"]
+---
+kind: Template
+metadata:
+  name: card
+spec:
+  title:
+    type: "string"
+    value: "{{item.title}}"
+---
+kind: Template
+metadata:
+  name: big_image
+spec:
+  image:
+    type: "url"
+    path: "item.image"
+    required: true
+`
+
+func TestGenerate_ExperimentVariants(t *testing.T) {
+	s, err := Generate(parseTemplate(t, experimentTemplate), "card")
+	require.NoError(t, err)
+
+	assert.Equal(t, "card", s.Title)
+	assert.Contains(t, s.Properties, "title")
+	assert.Contains(t, s.Properties, "image", "Fields of variant templates are in the schema")
+	assert.Equal(t, []string{"title"}, s.Required, "Fields missing from some variant are optional")
+}
+
+func TestDiffTemplates_ExperimentVariants(t *testing.T) {
+	const oldTemplate = `
+---
+kind: View
+metadata:
+  name: card
+spec:
+  template:
+    templates: ["card"]
+---
+kind: Template
+metadata:
+  name: card
+spec:
+  title:
+    type: "string"
+    value: "{{item.title}}"
+`
+	changes, err := DiffTemplates(parseTemplate(t, oldTemplate), parseTemplate(t, experimentTemplate))
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "card.image: field added", changes[0].String())
+
+	const variantDropsTitle = `
+---
+kind: View
+metadata:
+  name: card
+spec:
+  template:
+    templates: ["card"]
+  experiment:
+    name: card_layout
+    key: item.id
+    variants:
+      - name: control
+        weight: 50
+      - name: image_only
+        weight: 50
+        templates: ["big_image"]
+---
+kind: Template
+metadata:
+  name: card
+spec:
+  title:
+    type: "string"
+    value: "{{item.title}}"
+---
+kind: Template
+metadata:
+  name: big_image
+spec:
+  image:
+    type: "url"
+    path: "item.image"
+    required: true
+`
+	changes, err = DiffTemplates(parseTemplate(t, oldTemplate), parseTemplate(t, variantDropsTitle))
+	require.NoError(t, err)
+
+	got := make([]string, len(changes))
+	for i, c := range changes {
+		got[i] = c.String()
+	}
+	assert.Equal(t, []string{
+		"card.image: field added",
+		"BREAKING card.title: field became optional",
+	}, sortedStrings(got))
+}
+
+func sortedStrings(s []string) []string {
+	res := append([]string(nil), s...)
+	sort.Slice(res, func(i, j int) bool {
+		return strings.TrimPrefix(res[i], "BREAKING ") < strings.TrimPrefix(res[j], "BREAKING ")
+	})
+	return res
+}
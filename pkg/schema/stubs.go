@@ -0,0 +1,228 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// namedObject is a nested object that gets its own type declaration.
+type namedObject struct {
+	name   string
+	schema *Schema
+}
+
+// TypeScript renders the schema as TypeScript interfaces. Nested objects get
+// their own interfaces named after the parent type and the field.
+func TypeScript(name string, s *Schema) string {
+	var b strings.Builder
+
+	queue := []namedObject{{typeName(name), s}}
+	for len(queue) > 0 {
+		obj := queue[0]
+		queue = queue[1:]
+
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+
+		fmt.Fprintf(&b, "export interface %s {\n", obj.name)
+		for _, field := range sortedKeys(obj.schema.Properties) {
+			optional := ""
+			if !obj.schema.IsRequired(field) {
+				optional = "?"
+			}
+
+			typ := tsType(obj.name+typeName(field), obj.schema.Properties[field], &queue)
+			fmt.Fprintf(&b, "  %s%s: %s;\n", tsKey(field), optional, typ)
+		}
+		b.WriteString("}\n")
+	}
+
+	return b.String()
+}
+
+func tsType(name string, s *Schema, queue *[]namedObject) string {
+	var parts []string
+	for _, typ := range s.NonNull() {
+		switch typ {
+		case TypeString:
+			parts = append(parts, tsLiteral(s, TypeString, "string"))
+		case TypeNumber, TypeInteger:
+			parts = append(parts, tsLiteral(s, typ, "number"))
+		case TypeBoolean:
+			parts = append(parts, "boolean")
+		case TypeArray:
+			elem := "unknown"
+			if s.Items != nil {
+				elem = tsType(name+"Item", s.Items, queue)
+			}
+			if strings.Contains(elem, " ") {
+				elem = "(" + elem + ")"
+			}
+			parts = append(parts, elem+"[]")
+		case TypeObject:
+			if len(s.Properties) == 0 {
+				parts = append(parts, "Record<string, unknown>")
+				continue
+			}
+			*queue = append(*queue, namedObject{name, s})
+			parts = append(parts, name)
+		}
+	}
+
+	if len(parts) == 0 {
+		parts = append(parts, "unknown")
+	}
+	if s.Nullable() {
+		parts = append(parts, "null")
+	}
+	return strings.Join(parts, " | ")
+}
+
+// tsLiteral renders enum values of the given JSON type as a literal union.
+func tsLiteral(s *Schema, typ, fallback string) string {
+	var literals []string
+	for _, v := range s.Enum {
+		if literalSchema(v).Type.has(typ) || (typ == TypeNumber && literalSchema(v).Type.has(TypeInteger)) {
+			if str, ok := v.(string); ok {
+				literals = append(literals, fmt.Sprintf("%q", str))
+			} else {
+				literals = append(literals, fmt.Sprint(v))
+			}
+		}
+	}
+
+	if len(literals) == 0 {
+		return fallback
+	}
+	return strings.Join(literals, " | ")
+}
+
+func tsKey(field string) string {
+	for i, r := range field {
+		if !(unicode.IsLetter(r) || r == '_' || r == '$' || (i > 0 && unicode.IsDigit(r))) {
+			return fmt.Sprintf("%q", field)
+		}
+	}
+	return field
+}
+
+// Kotlin renders the schema as Kotlin data classes for kotlinx.serialization.
+// Nested objects get their own classes named after the parent and the field.
+func Kotlin(name string, s *Schema) string {
+	var b strings.Builder
+
+	queue := []namedObject{{typeName(name), s}}
+	for len(queue) > 0 {
+		obj := queue[0]
+		queue = queue[1:]
+
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+
+		fmt.Fprintf(&b, "@Serializable\ndata class %s(\n", obj.name)
+		for _, field := range sortedKeys(obj.schema.Properties) {
+			prop := obj.schema.Properties[field]
+			typ := kotlinType(obj.name+typeName(field), prop, &queue)
+
+			nullable := prop.Nullable() || !obj.schema.IsRequired(field)
+			if nullable && !strings.HasSuffix(typ, "?") {
+				typ += "?"
+			}
+
+			fmt.Fprintf(&b, "    @SerialName(%q) val %s: %s", field, fieldName(field), typ)
+			if nullable {
+				b.WriteString(" = null")
+			}
+			b.WriteString(",\n")
+		}
+		b.WriteString(")\n")
+	}
+
+	return b.String()
+}
+
+func kotlinType(name string, s *Schema, queue *[]namedObject) string {
+	types := s.NonNull()
+	if len(types) != 1 {
+		return "JsonElement"
+	}
+
+	switch types[0] {
+	case TypeString:
+		return "String"
+	case TypeNumber:
+		return "Double"
+	case TypeInteger:
+		return "Long"
+	case TypeBoolean:
+		return "Boolean"
+	case TypeArray:
+		if s.Items == nil {
+			return "List<JsonElement>"
+		}
+		elem := kotlinType(name+"Item", s.Items, queue)
+		if s.Items.Nullable() {
+			elem += "?"
+		}
+		return "List<" + elem + ">"
+	case TypeObject:
+		if len(s.Properties) == 0 {
+			return "JsonObject"
+		}
+		*queue = append(*queue, namedObject{name, s})
+		return name
+	default:
+		return "JsonElement"
+	}
+}
+
+// typeName converts a field or view name to PascalCase.
+func typeName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// fieldName converts a field name to camelCase.
+func fieldName(name string) string {
+	res := []rune(typeName(name))
+	if len(res) > 0 {
+		res[0] = unicode.ToLower(res[0])
+	}
+	return string(res)
+}
+
+const (
+	FormatJSONSchema = "json_schema"
+	FormatTypeScript = "typescript"
+	FormatKotlin     = "kotlin"
+)
+
+// Render serializes the schema as JSON Schema or as type stubs named name.
+func Render(s *Schema, format, name string) ([]byte, error) {
+	switch format {
+	case "", FormatJSONSchema:
+		return json.MarshalIndent(s, "", "  ")
+	case FormatTypeScript:
+		return []byte(TypeScript(name, s)), nil
+	case FormatKotlin:
+		return []byte(Kotlin(name, s)), nil
+	default:
+		return nil, fmt.Errorf("unknown schema format %q", format)
+	}
+}
@@ -0,0 +1,60 @@
+package tracer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	prevTp, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTp)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	return recorder
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	ctx := WithAttributes(context.Background(), TemplateID("feed"), ItemKey("post:1"))
+	ctx, parent := Start(ctx, "parent")
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-request-id", "42")
+
+	var outgoing metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		outgoing, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+
+	err := UnaryClientInterceptor()(ctx, "/reaction.Reactions/Get", nil, nil, nil, invoker)
+	assert.NoError(t, err)
+	parent.End()
+
+	assert.Equal(t, []string{"42"}, outgoing.Get("x-request-id"))
+	if assert.Len(t, outgoing.Get("traceparent"), 1) {
+		assert.Contains(t, outgoing.Get("traceparent")[0], parent.SpanContext().TraceID().String())
+	}
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		client := spans[0]
+		assert.Equal(t, "/reaction.Reactions/Get", client.Name())
+		assert.Equal(t, parent.SpanContext().SpanID(), client.Parent().SpanID())
+		assert.Contains(t, client.Attributes(), TemplateID("feed"))
+		assert.Contains(t, client.Attributes(), ItemKey("post:1"))
+	}
+}
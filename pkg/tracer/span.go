@@ -0,0 +1,76 @@
+package tracer
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "item-composition-service"
+
+const (
+	TemplateIDKey = attribute.Key("template.id")
+	ViewKey       = attribute.Key("template.views")
+	ItemKeyKey    = attribute.Key("item.key")
+	ProviderKey   = attribute.Key("provider.name")
+	MethodKey     = attribute.Key("provider.method")
+	AttemptKey    = attribute.Key("provider.attempt")
+	ClientIDKey   = attribute.Key("client.id")
+)
+
+func TemplateID(id string) attribute.KeyValue {
+	return TemplateIDKey.String(TraceSafeString(id))
+}
+
+func ItemKey(key string) attribute.KeyValue {
+	return ItemKeyKey.String(TraceSafeString(key))
+}
+
+func Provider(name string) attribute.KeyValue {
+	return ProviderKey.String(name)
+}
+
+func Method(name string) attribute.KeyValue {
+	return MethodKey.String(name)
+}
+
+func ClientID(id string) attribute.KeyValue {
+	return ClientIDKey.String(TraceSafeString(id))
+}
+
+type attributesKey struct{}
+
+// WithAttributes adds attributes to every span started with Start from ctx, so
+// the template id and item key set by the service reach provider spans.
+func WithAttributes(ctx context.Context, attrs ...attribute.KeyValue) context.Context {
+	existing := attributesFromContext(ctx)
+	merged := make([]attribute.KeyValue, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attributesKey{}, merged)
+}
+
+func attributesFromContext(ctx context.Context) []attribute.KeyValue {
+	attrs, _ := ctx.Value(attributesKey{}).([]attribute.KeyValue)
+	return attrs
+}
+
+// Start starts an internal child span with the context attributes and attrs.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctxAttrs := attributesFromContext(ctx)
+	all := make([]attribute.KeyValue, 0, len(ctxAttrs)+len(attrs))
+	all = append(append(all, ctxAttrs...), attrs...)
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(all...))
+}
+
+// End records err on the span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, TraceSafeString(err.Error()))
+	}
+	span.End()
+}